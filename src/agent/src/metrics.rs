@@ -11,6 +11,7 @@ use anyhow::{anyhow, Result};
 use nix::sys::statfs;
 use slog::warn;
 use std::sync::Mutex;
+use std::time::{SystemTime, UNIX_EPOCH};
 use tracing::instrument;
 
 const NAMESPACE_KATA_AGENT: &str = "kata_agent";
@@ -80,8 +81,17 @@ lazy_static! {
 
     static ref GUEST_FILESYSTEM_INODES: GaugeVec =
     GaugeVec::new(Opts::new(format!("{}_{}",NAMESPACE_KATA_GUEST,"filesystem_inodes"), "Guest filesystem inode usage."), &["mount","device","item"]).unwrap();
+
+    static ref GUEST_PRESSURE: GaugeVec =
+    GaugeVec::new(Opts::new(format!("{}_{}",NAMESPACE_KATA_GUEST,"pressure"), "Guest pressure stall information."), &["resource","kind","item"]).unwrap();
+
+    static ref GUEST_CLOCK_REALTIME: Gauge =
+    Gauge::new(format!("{}_{}",NAMESPACE_KATA_GUEST,"clock_realtime_seconds"), "Guest wall clock time, in seconds since the Epoch, when the metrics were gathered.").unwrap();
 }
 
+// Resources reporting pressure stall information in /proc/pressure.
+const PRESSURE_RESOURCES: &[&str] = &["cpu", "memory", "io"];
+
 #[instrument]
 pub fn get_metrics(_: &protocols::agent::GetMetricsRequest) -> Result<String> {
     let mut registered = REGISTERED
@@ -101,6 +111,10 @@ pub fn get_metrics(_: &protocols::agent::GetMetricsRequest) -> Result<String> {
     // update guest os metrics
     update_guest_metrics();
 
+    // sampled last, as close as possible to the reply, so that the runtime
+    // can compare it with the host clock
+    update_guest_clock();
+
     // gather all metrics and return as a String
     let metric_families = REGISTRY.gather();
 
@@ -134,6 +148,8 @@ fn register_metrics() -> Result<()> {
     REGISTRY.register(Box::new(GUEST_MEMINFO.clone()))?;
     REGISTRY.register(Box::new(GUEST_FILESYSTEM_BYTES.clone()))?;
     REGISTRY.register(Box::new(GUEST_FILESYSTEM_INODES.clone()))?;
+    REGISTRY.register(Box::new(GUEST_PRESSURE.clone()))?;
+    REGISTRY.register(Box::new(GUEST_CLOCK_REALTIME.clone()))?;
 
     Ok(())
 }
@@ -194,6 +210,16 @@ fn update_agent_metrics() -> Result<()> {
     Ok(())
 }
 
+#[instrument]
+fn update_guest_clock() {
+    match SystemTime::now().duration_since(UNIX_EPOCH) {
+        Err(err) => {
+            info!(sl(), "guest clock is before the Epoch: {:?}", err);
+        }
+        Ok(now) => GUEST_CLOCK_REALTIME.set(now.as_secs_f64()),
+    }
+}
+
 #[instrument]
 fn update_guest_metrics() {
     // try get load and task info
@@ -278,6 +304,55 @@ fn update_guest_metrics() {
 
     // get filesystem space usage via statfs
     update_guest_filesystem_metrics();
+
+    // get pressure stall information, the kernel must be built with CONFIG_PSI
+    update_guest_pressure_metrics();
+}
+
+#[instrument]
+fn update_guest_pressure_metrics() {
+    for &resource in PRESSURE_RESOURCES {
+        let path = format!("/proc/pressure/{}", resource);
+        match std::fs::read_to_string(&path) {
+            Err(err) => {
+                info!(sl(), "failed to read {}: {:?}", path, err);
+            }
+            Ok(content) => {
+                for (kind, item, value) in parse_pressure(&content) {
+                    GUEST_PRESSURE
+                        .with_label_values(&[resource, kind.as_str(), item.as_str()])
+                        .set(value);
+                }
+            }
+        }
+    }
+}
+
+// parse_pressure parses the content of a /proc/pressure file, e.g.
+//   some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//   full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+// into (kind, item, value) tuples. The averages are percentages of time
+// stalled, the total is the stall time in microseconds.
+fn parse_pressure(content: &str) -> Vec<(String, String, f64)> {
+    let mut values = Vec::new();
+
+    for line in content.lines() {
+        let mut fields = line.split_whitespace();
+        let kind = match fields.next() {
+            Some(kind) => kind,
+            None => continue,
+        };
+
+        for field in fields {
+            if let Some((item, value)) = field.split_once('=') {
+                if let Ok(value) = value.parse::<f64>() {
+                    values.push((kind.to_string(), item.to_string(), value));
+                }
+            }
+        }
+    }
+
+    values
 }
 
 #[instrument]
@@ -648,3 +723,23 @@ fn set_gauge_vec_proc_stat(gv: &prometheus::GaugeVec, stat: &procfs::process::St
     gv.with_label_values(&["cutime"]).set(stat.cutime as f64);
     gv.with_label_values(&["cstime"]).set(stat.cstime as f64);
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_pressure() {
+        let content = "some avg10=1.50 avg60=0.25 avg300=0.00 total=12345\nfull avg10=0.75 avg60=0.10 avg300=0.00 total=678\n";
+        let values = parse_pressure(content);
+
+        assert_eq!(values.len(), 8);
+        assert!(values.contains(&("some".to_string(), "avg10".to_string(), 1.5)));
+        assert!(values.contains(&("some".to_string(), "total".to_string(), 12345.0)));
+        assert!(values.contains(&("full".to_string(), "avg60".to_string(), 0.1)));
+
+        // Unexpected fields are skipped
+        let values = parse_pressure("some avg10=x avg60 total=1\n\n");
+        assert_eq!(values, vec![("some".to_string(), "total".to_string(), 1.0)]);
+    }
+}
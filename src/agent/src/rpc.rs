@@ -726,6 +726,39 @@ impl AgentService {
         Ok(resp)
     }
 
+    async fn do_list_processes(
+        &self,
+        container_id: &str,
+    ) -> Result<protocols::agent::GetDiagnosticDataResponse> {
+        let (pids, exec_ids) = {
+            let mut sandbox = self.sandbox.lock().await;
+            let ctr = sandbox
+                .get_container(container_id)
+                .ok_or_else(|| anyhow!("Invalid container id: {}", container_id))?;
+
+            let exec_ids: std::collections::HashMap<pid_t, String> = ctr
+                .processes
+                .values()
+                .map(|p| (p.pid, p.exec_id.clone()))
+                .collect();
+
+            (ctr.cgroup_manager.as_ref().get_pids()?, exec_ids)
+        };
+
+        let mut processes = Vec::new();
+        for pid in pids {
+            // The process may have exited since the cgroup was read.
+            match guest_process(pid, exec_ids.get(&pid)) {
+                Ok(p) => processes.push(p),
+                Err(e) => debug!(sl(), "failed to read process {}: {:?}", pid, e),
+            }
+        }
+
+        let mut resp = protocols::agent::GetDiagnosticDataResponse::new();
+        resp.data = serde_json::to_string(&processes)?;
+        Ok(resp)
+    }
+
     async fn do_write_stream(
         &self,
         req: protocols::agent::WriteStreamRequest,
@@ -1490,12 +1523,14 @@ impl agent_ttrpc::AgentService for AgentService {
         {
             let mut s = self.sandbox.lock().await;
             if !req.guest_hook_path.is_empty() {
-                let _ = s.add_hooks(&req.guest_hook_path).map_err(|e| {
-                    error!(
-                        sl(),
-                        "add guest hook {} failed: {:?}", req.guest_hook_path, e
-                    );
-                });
+                s.add_hooks(&req.guest_hook_path)
+                    .inspect_err(|e| {
+                        error!(
+                            sl(),
+                            "add guest hook {} failed: {:?}", req.guest_hook_path, e
+                        );
+                    })
+                    .map_ttrpc_err(same)?;
             }
         }
 
@@ -1809,6 +1844,10 @@ impl agent_ttrpc::AgentService for AgentService {
                 .do_read_termination_log(&req.container_id)
                 .await
                 .map_ttrpc_err(same),
+            "process_list" => self
+                .do_list_processes(&req.container_id)
+                .await
+                .map_ttrpc_err(same),
             other => Err(ttrpc_error(
                 ttrpc::Code::INVALID_ARGUMENT,
                 format!("unsupported diagnostic log_type: {other}"),
@@ -1968,6 +2007,52 @@ fn get_volume_inode_stats(path: &str) -> Result<VolumeUsage> {
     Ok(usage)
 }
 
+// A process of a container, as listed by the process_list diagnostic data.
+#[derive(Debug, Default, PartialEq, serde::Serialize)]
+struct GuestProcess {
+    #[serde(skip_serializing_if = "String::is_empty")]
+    exec_id: String,
+    state: String,
+    cmd: Vec<String>,
+    cpu_time_ns: u64,
+    rss_bytes: u64,
+    start_time_ns: u64,
+    threads: i64,
+    pid: pid_t,
+    ppid: pid_t,
+    uid: u32,
+}
+
+fn guest_process(pid: pid_t, exec_id: Option<&String>) -> Result<GuestProcess> {
+    let p = procfs::process::Process::new(pid)?;
+    let tps = procfs::ticks_per_second()? as u64;
+    let page_size = procfs::page_size()? as u64;
+
+    // Kernel threads and zombies have no command line.
+    let mut cmd = p.cmdline().unwrap_or_default();
+    if cmd.is_empty() {
+        cmd.push(p.stat.comm.clone());
+    }
+
+    Ok(GuestProcess {
+        exec_id: exec_id.cloned().unwrap_or_default(),
+        state: p.stat.state.to_string(),
+        cmd,
+        cpu_time_ns: ticks_to_ns(p.stat.utime + p.stat.stime, tps),
+        rss_bytes: p.stat.rss as u64 * page_size,
+        start_time_ns: ticks_to_ns(p.stat.starttime, tps),
+        threads: p.stat.num_threads,
+        pid,
+        ppid: p.stat.ppid,
+        uid: p.owner,
+    })
+}
+
+fn ticks_to_ns(ticks: u64, ticks_per_second: u64) -> u64 {
+    const NS_PER_SEC: u64 = 1_000_000_000;
+    ticks / ticks_per_second * NS_PER_SEC + ticks % ticks_per_second * NS_PER_SEC / ticks_per_second
+}
+
 pub fn have_seccomp() -> bool {
     if cfg!(feature = "seccomp") {
         return true;
@@ -3238,6 +3323,38 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_ticks_to_ns() {
+        assert_eq!(ticks_to_ns(0, 100), 0);
+        assert_eq!(ticks_to_ns(150, 100), 1_500_000_000);
+        // No overflow after years of uptime
+        assert_eq!(
+            ticks_to_ns(100 * 86400 * 3650, 100),
+            86400 * 3650 * 1_000_000_000
+        );
+    }
+
+    #[test]
+    fn test_guest_process() {
+        let pid = std::process::id() as pid_t;
+        let exec_id = "exec".to_string();
+
+        let p = guest_process(pid, Some(&exec_id)).unwrap();
+        assert_eq!(p.pid, pid);
+        assert_eq!(p.exec_id, exec_id);
+        assert_eq!(p.uid, unistd::getuid().as_raw());
+        assert!(!p.cmd.is_empty());
+        assert!(p.threads > 0);
+
+        let json = serde_json::to_string(&p).unwrap();
+        assert!(json.contains("\"exec_id\":\"exec\""));
+        assert!(json.contains(&format!("\"pid\":{}", pid)));
+
+        // The exec ID is omitted for the processes forked by the workload
+        let p = guest_process(pid, None).unwrap();
+        assert!(!serde_json::to_string(&p).unwrap().contains("exec_id"));
+    }
+
     #[tokio::test]
     async fn test_is_signal_handled() {
         #[derive(Debug)]
@@ -9,7 +9,7 @@ use std::fmt::{Debug, Formatter};
 use std::fs;
 use std::os::fd::{AsRawFd, BorrowedFd, FromRawFd, IntoRawFd};
 use std::os::unix::fs::PermissionsExt;
-use std::path::{Path, PathBuf};
+use std::path::{Component, Path, PathBuf};
 use std::str::FromStr;
 use std::sync::atomic::{AtomicU32, Ordering};
 use std::sync::{Arc, RwLock};
@@ -31,6 +31,8 @@ use rustjail::cgroups::{self as rustjail_cgroups, DevicesCgroupInfo};
 use rustjail::container::BaseContainer;
 use rustjail::container::LinuxContainer;
 use rustjail::process::Process;
+use serde::Deserialize;
+use sha2::{Digest, Sha256, Sha512};
 use slog::Logger;
 use thiserror::Error;
 use tokio::sync::mpsc::{channel, Receiver, Sender};
@@ -46,6 +48,7 @@ use crate::network::Network;
 use crate::pci;
 use crate::storage::StorageDeviceGeneric;
 use crate::uevent::{Uevent, UeventMatcher};
+use crate::util;
 use crate::watcher::BindWatcher;
 
 /// Errors that can occur when looking up processes in the sandbox.
@@ -61,6 +64,25 @@ pub enum SandboxError {
 
 type UeventWatcher = (Box<dyn UeventMatcher>, oneshot::Sender<Uevent>);
 
+// Manifest of the hooks shipped by the runtime to the guest hook directory.
+const GUEST_HOOKS_MANIFEST: &str = "hooks.json";
+
+/// A hook shipped by the runtime, with the digest of its binary.
+#[derive(Debug, Deserialize)]
+struct GuestHook {
+    #[serde(flatten)]
+    hook: Hook,
+    digest: String,
+}
+
+#[derive(Debug, Default, Deserialize)]
+#[serde(default)]
+struct GuestHooksManifest {
+    prestart: Vec<GuestHook>,
+    poststart: Vec<GuestHook>,
+    poststop: Vec<GuestHook>,
+}
+
 #[derive(Clone)]
 pub struct StorageState {
     count: Arc<AtomicU32>,
@@ -397,11 +419,51 @@ impl Sandbox {
         if let Ok(hook) = self.find_hooks(dir, "poststop") {
             hooks.set_poststop(Some(hook));
         }
+
+        // Unlike the scanned hooks, the hooks shipped by the runtime must
+        // all be valid.
+        let manifest = Path::new(dir).join(GUEST_HOOKS_MANIFEST);
+        if manifest.exists() {
+            let shipped = self.load_hooks_manifest(dir, &manifest)?;
+
+            let prestart = util::merge(hooks.prestart_mut(), shipped.prestart());
+            hooks.set_prestart(prestart);
+            let poststart = util::merge(hooks.poststart_mut(), shipped.poststart());
+            hooks.set_poststart(poststart);
+            let poststop = util::merge(hooks.poststop_mut(), shipped.poststop());
+            hooks.set_poststop(poststop);
+        }
+
         self.hooks = Some(hooks);
 
         Ok(())
     }
 
+    #[instrument]
+    fn load_hooks_manifest(&self, dir: &str, manifest: &Path) -> Result<Hooks> {
+        let content = fs::read(manifest).with_context(|| format!("read {:?}", manifest))?;
+        let guest_hooks: GuestHooksManifest =
+            serde_json::from_slice(&content).with_context(|| format!("parse {:?}", manifest))?;
+
+        let verify = |guest_hooks: Vec<GuestHook>| -> Result<Vec<Hook>> {
+            guest_hooks
+                .into_iter()
+                .map(|guest_hook| -> Result<Hook> {
+                    verify_guest_hook(dir, &guest_hook)?;
+                    info!(self.logger, "found shipped hook {:?}", guest_hook.hook);
+                    Ok(guest_hook.hook)
+                })
+                .collect()
+        };
+
+        let mut hooks = Hooks::default();
+        hooks.set_prestart(Some(verify(guest_hooks.prestart)?));
+        hooks.set_poststart(Some(verify(guest_hooks.poststart)?));
+        hooks.set_poststop(Some(verify(guest_hooks.poststop)?));
+
+        Ok(hooks)
+    }
+
     #[instrument]
     fn find_hooks(&self, hook_path: &str, hook_type: &str) -> Result<Vec<Hook>> {
         let mut hooks = Vec::new();
@@ -708,6 +770,49 @@ fn onlined_cpus() -> Result<i32> {
     Ok(online_cpu_set.len() as i32)
 }
 
+// Verifies that the binary of a hook shipped by the runtime is an executable
+// file of the hook directory, matching the digest of the manifest.
+fn verify_guest_hook(dir: &str, guest_hook: &GuestHook) -> Result<()> {
+    let path = guest_hook.hook.path();
+    if !path.starts_with(dir) || path.components().any(|c| c == Component::ParentDir) {
+        return Err(anyhow!("hook {:?} is not in {}", path, dir));
+    }
+
+    let metadata = fs::symlink_metadata(path).with_context(|| format!("stat hook {:?}", path))?;
+    if !metadata.file_type().is_file() || metadata.permissions().mode() & 0o111 == 0 {
+        return Err(anyhow!("hook {:?} is not an executable file", path));
+    }
+
+    let (algorithm, expected) = guest_hook
+        .digest
+        .split_once(':')
+        .ok_or_else(|| anyhow!("invalid digest {:?} of hook {:?}", guest_hook.digest, path))?;
+
+    let content = fs::read(path).with_context(|| format!("read hook {:?}", path))?;
+    let digest = match algorithm {
+        "sha256" => format!("{:x}", Sha256::digest(&content)),
+        "sha512" => format!("{:x}", Sha512::digest(&content)),
+        others => {
+            return Err(anyhow!(
+                "unsupported digest algorithm {} of hook {:?}",
+                others,
+                path
+            ))
+        }
+    };
+
+    if digest != expected.to_lowercase() {
+        return Err(anyhow!(
+            "hook {:?} digest mismatch: expected {}, got {}",
+            path,
+            expected,
+            digest
+        ));
+    }
+
+    Ok(())
+}
+
 #[cfg(test)]
 #[allow(dead_code)]
 #[allow(unused_imports)]
@@ -1036,6 +1141,55 @@ mod tests {
             .is_empty());
     }
 
+    #[tokio::test]
+    #[serial]
+    async fn add_shipped_guest_hooks() {
+        let logger = slog::Logger::root(slog::Discard, o!());
+        let mut s = Sandbox::new(&logger).unwrap();
+        let tmpdir = Builder::new().tempdir().unwrap();
+        let tmpdir_path = tmpdir.path().to_str().unwrap();
+
+        let content = b"#!/bin/sh\n";
+        let hook_path = tmpdir.path().join("bin").join("00-hook");
+        fs::create_dir_all(tmpdir.path().join("bin")).unwrap();
+        fs::write(&hook_path, content).unwrap();
+        fs::set_permissions(&hook_path, fs::Permissions::from_mode(0o755)).unwrap();
+
+        let write_manifest = |digest: String| {
+            let manifest = format!(
+                r#"{{"poststart": [{{"path": "{}", "args": ["hook"], "digest": "{}"}}]}}"#,
+                hook_path.display(),
+                digest
+            );
+            fs::write(tmpdir.path().join(GUEST_HOOKS_MANIFEST), manifest).unwrap();
+        };
+
+        write_manifest(format!("sha256:{:x}", Sha256::digest(content)));
+        assert!(s.add_hooks(tmpdir_path).is_ok());
+        let hooks = s.hooks.as_ref().unwrap();
+        assert!(hooks.prestart().clone().unwrap().is_empty());
+        let poststart = hooks.poststart().clone().unwrap();
+        assert_eq!(poststart.len(), 1);
+        assert_eq!(poststart[0].path(), &hook_path);
+        assert_eq!(
+            poststart[0].args().clone().unwrap(),
+            vec!["hook".to_string()]
+        );
+
+        // The binary does not match its digest
+        write_manifest(format!("sha256:{:x}", Sha256::digest(b"other")));
+        assert!(s.add_hooks(tmpdir_path).is_err());
+
+        write_manifest("md5:1234".to_string());
+        assert!(s.add_hooks(tmpdir_path).is_err());
+
+        // The binary is not executable
+        write_manifest(format!("sha512:{:x}", Sha512::digest(content)));
+        assert!(s.add_hooks(tmpdir_path).is_ok());
+        fs::set_permissions(&hook_path, fs::Permissions::from_mode(0o644)).unwrap();
+        assert!(s.add_hooks(tmpdir_path).is_err());
+    }
+
     #[tokio::test]
     #[serial]
     async fn test_sandbox_set_destroy() {
@@ -0,0 +1,418 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+)
+
+// variables rather than consts to allow tests to modify them
+var (
+	vhostNetDevice        = "/dev/vhost-net"
+	vhostVsockDevice      = "/dev/vhost-vsock"
+	procMemInfo           = "/proc/meminfo"
+	cgroupControllersFile = "/sys/fs/cgroup/cgroup.controllers"
+)
+
+// cgroup v2 controllers the runtime relies on to constrain a sandbox.
+var requiredCgroupControllers = []string{"cpu", "cpuset", "io", "memory", "pids"}
+
+// runHostChecks performs the checks which depend on the runtime
+// configuration rather than on the CPU architecture, logs and records their
+// results, and returns the number of failed checks with error severity.
+func runHostChecks(runtimeConfig oci.RuntimeConfig) (count uint32) {
+	for _, result := range hostChecks(runtimeConfig) {
+		logCheckResult(result)
+		recordCheck(result)
+
+		if result.Status == checkStatusFail && result.Severity == checkSeverityError {
+			count++
+		}
+	}
+
+	return count
+}
+
+func hostChecks(runtimeConfig oci.RuntimeConfig) []checkResult {
+	// None of the host resources are used when the VM runs elsewhere.
+	if runtimeConfig.HypervisorType == vc.RemoteHypervisor {
+		return nil
+	}
+
+	hConfig := runtimeConfig.HypervisorConfig
+
+	results := []checkResult{
+		checkVhostNet(hConfig),
+		checkVhostVsock(runtimeConfig.HypervisorType),
+		checkHugePages(hConfig),
+		checkCgroupControllers(),
+		checkVirtioFSDaemon(hConfig),
+	}
+
+	results = append(results, checkAssetPaths(hConfig)...)
+
+	return append(results, checkAssetDigests(hConfig)...)
+}
+
+func checkCharDevice(path string) (observed string, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err.Error(), false
+	}
+
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Sprintf("%s is not a character device", path), false
+	}
+
+	return "present", true
+}
+
+func checkVhostNet(hConfig vc.HypervisorConfig) checkResult {
+	result := checkResult{
+		ID:          "device.vhost-net",
+		Category:    checkCategoryDevice,
+		Severity:    checkSeverityWarning,
+		Description: "vhost-net device for accelerated guest networking",
+		Expected:    "present",
+		Remediation: "Load the vhost_net kernel module with 'modprobe vhost_net', or set 'disable_vhost_net = true'",
+	}
+
+	if hConfig.DisableVhostNet {
+		result.Status = checkStatusSkip
+		result.Observed = "vhost-net disabled by configuration"
+		return result
+	}
+
+	observed, ok := checkCharDevice(vhostNetDevice)
+	result.Observed = observed
+	if ok {
+		result.Status = checkStatusPass
+	} else {
+		result.Status = checkStatusFail
+	}
+
+	return result
+}
+
+func checkVhostVsock(hypervisorType vc.HypervisorType) checkResult {
+	result := checkResult{
+		ID:          "device.vhost-vsock",
+		Category:    checkCategoryDevice,
+		Severity:    checkSeverityWarning,
+		Description: "vhost-vsock device used to talk to the agent",
+		Expected:    "present",
+		Remediation: "Load the vhost_vsock kernel module with 'modprobe vhost_vsock'",
+	}
+
+	// Hypervisors using hybrid vsock do not need the host device.
+	switch hypervisorType {
+	case vc.QemuHypervisor, vc.StratovirtHypervisor:
+		result.Severity = checkSeverityError
+	}
+
+	observed, ok := checkCharDevice(vhostVsockDevice)
+	result.Observed = observed
+	if ok {
+		result.Status = checkStatusPass
+	} else {
+		result.Status = checkStatusFail
+	}
+
+	return result
+}
+
+// getMemInfoValue returns the value of a field of the meminfo file, without
+// its unit.
+func getMemInfoValue(memInfoFile, field string) (string, error) {
+	f, err := os.Open(memInfoFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == field+":" {
+			return fields[1], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("field %q not found in %s", field, memInfoFile)
+}
+
+func checkHugePages(hConfig vc.HypervisorConfig) checkResult {
+	result := checkResult{
+		ID:          "memory.hugepages",
+		Category:    checkCategoryMemory,
+		Severity:    checkSeverityError,
+		Description: "huge pages available to back guest memory",
+		Expected:    "HugePages_Free > 0",
+		Remediation: "Reserve huge pages, e.g. 'sysctl vm.nr_hugepages=<count>', or set 'enable_hugepages = false'",
+	}
+
+	if !hConfig.HugePages {
+		result.Status = checkStatusSkip
+		result.Observed = "huge pages disabled by configuration"
+		return result
+	}
+
+	free, err := getMemInfoValue(procMemInfo, "HugePages_Free")
+	if err != nil {
+		result.Status = checkStatusFail
+		result.Observed = err.Error()
+		return result
+	}
+
+	result.Observed = "HugePages_Free = " + free
+	if free == "0" {
+		result.Status = checkStatusFail
+	} else {
+		result.Status = checkStatusPass
+	}
+
+	return result
+}
+
+func checkCgroupControllers() checkResult {
+	result := checkResult{
+		ID:          "cgroup.v2-controllers",
+		Category:    checkCategoryCgroup,
+		Severity:    checkSeverityWarning,
+		Description: "cgroup v2 controllers used to constrain sandboxes",
+		Expected:    strings.Join(requiredCgroupControllers, " "),
+		Remediation: "Enable the missing controllers in the root cgroup and make sure they are not bound to a cgroup v1 hierarchy",
+	}
+
+	contents, err := os.ReadFile(cgroupControllersFile)
+	if os.IsNotExist(err) {
+		result.Status = checkStatusSkip
+		result.Observed = "cgroup v2 not mounted"
+		return result
+	}
+
+	if err != nil {
+		result.Status = checkStatusFail
+		result.Observed = err.Error()
+		return result
+	}
+
+	available := strings.Fields(string(contents))
+	result.Observed = strings.Join(available, " ")
+
+	var missing []string
+	for _, c := range requiredCgroupControllers {
+		found := false
+		for _, a := range available {
+			if a == c {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, c)
+		}
+	}
+
+	if len(missing) > 0 {
+		result.Status = checkStatusFail
+		result.Remediation = fmt.Sprintf("Enable the %s controller(s): %s", strings.Join(missing, ", "), result.Remediation)
+	} else {
+		result.Status = checkStatusPass
+	}
+
+	return result
+}
+
+func checkExecutable(path string) (observed string, ok bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err.Error(), false
+	}
+
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Sprintf("%s is not an executable file", path), false
+	}
+
+	return path, true
+}
+
+func checkVirtioFSDaemon(hConfig vc.HypervisorConfig) checkResult {
+	result := checkResult{
+		ID:          "shared-fs.virtiofsd",
+		Category:    checkCategorySharedFS,
+		Severity:    checkSeverityError,
+		Description: "virtio-fs daemon used to share container files with the guest",
+		Expected:    "executable file",
+		Remediation: "Install virtiofsd and point 'virtio_fs_daemon' at it",
+	}
+
+	if hConfig.SharedFS != config.VirtioFS && hConfig.SharedFS != config.VirtioFSNydus {
+		result.Status = checkStatusSkip
+		result.Observed = fmt.Sprintf("shared_fs is %q", hConfig.SharedFS)
+		return result
+	}
+
+	if hConfig.VirtioFSDaemon == "" {
+		result.Status = checkStatusFail
+		result.Observed = "virtio_fs_daemon is not set"
+		return result
+	}
+
+	observed, ok := checkExecutable(hConfig.VirtioFSDaemon)
+	result.Observed = observed
+	if ok {
+		result.Status = checkStatusPass
+	} else {
+		result.Status = checkStatusFail
+	}
+
+	return result
+}
+
+// checkAssetPaths checks that every asset set in the configuration still
+// exists.
+func checkAssetPaths(hConfig vc.HypervisorConfig) []checkResult {
+	assets := []struct {
+		name string
+		path string
+	}{
+		{"hypervisor", hConfig.HypervisorPath},
+		{"jailer", hConfig.JailerPath},
+		{"kernel", hConfig.KernelPath},
+		{"image", hConfig.ImagePath},
+		{"initrd", hConfig.InitrdPath},
+		{"firmware", hConfig.FirmwarePath},
+		{"firmware-volume", hConfig.FirmwareVolumePath},
+	}
+
+	var results []checkResult
+
+	for _, a := range assets {
+		if a.path == "" {
+			continue
+		}
+
+		result := checkResult{
+			ID:          "asset." + a.name,
+			Category:    checkCategoryAsset,
+			Severity:    checkSeverityError,
+			Description: fmt.Sprintf("configured %s path", a.name),
+			Expected:    a.path,
+			Remediation: fmt.Sprintf("Install the %s or fix its path in the configuration file", a.name),
+		}
+
+		info, err := os.Stat(a.path)
+		switch {
+		case err != nil:
+			result.Status = checkStatusFail
+			result.Observed = err.Error()
+		case info.IsDir():
+			result.Status = checkStatusFail
+			result.Observed = fmt.Sprintf("%s is a directory", a.path)
+		default:
+			result.Status = checkStatusPass
+			result.Observed = a.path
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// checkAssetDigests checks every asset with a configured digest, or listed in
+// the asset manifest, the same way it is verified when a sandbox is created.
+func checkAssetDigests(hConfig vc.HypervisorConfig) []checkResult {
+	if len(hConfig.AssetDigests) == 0 && hConfig.AssetManifest == "" {
+		return nil
+	}
+
+	var results []checkResult
+
+	expected, err := hConfig.ExpectedAssetDigests()
+	if err != nil {
+		return append(results, checkResult{
+			ID:          "asset.manifest",
+			Category:    checkCategoryAsset,
+			Severity:    checkSeverityError,
+			Status:      checkStatusFail,
+			Description: "signed asset manifest",
+			Observed:    err.Error(),
+			Expected:    hConfig.AssetManifest,
+			Remediation: "Fix 'asset_manifest' and 'asset_manifest_public_key', and list every asset in the manifest",
+		})
+	}
+
+	assetTypes := make([]string, 0, len(expected))
+	for t := range expected {
+		assetTypes = append(assetTypes, string(t))
+	}
+	sort.Strings(assetTypes)
+
+	for _, t := range assetTypes {
+		digest := expected[types.AssetType(t)]
+		name := strings.ReplaceAll(t, "_", "-")
+
+		result := checkResult{
+			ID:          "asset." + name + ".digest",
+			Category:    checkCategoryAsset,
+			Severity:    checkSeverityError,
+			Description: fmt.Sprintf("%s digest", name),
+			Expected:    digest,
+			Remediation: fmt.Sprintf("Reinstall the %s or update its digest in the configuration file or asset manifest", name),
+		}
+
+		if err := vc.VerifyAssetDigest(configuredAssetPath(hConfig, types.AssetType(t)), digest); err != nil {
+			result.Status = checkStatusFail
+			result.Observed = err.Error()
+		} else {
+			result.Status = checkStatusPass
+			result.Observed = digest
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// configuredAssetPath returns the path of an asset set in the configuration
+// file. Unlike a sandbox, the check command has no annotations overriding it.
+func configuredAssetPath(hConfig vc.HypervisorConfig, t types.AssetType) string {
+	switch t {
+	case types.HypervisorAsset:
+		return hConfig.HypervisorPath
+	case types.JailerAsset:
+		return hConfig.JailerPath
+	case types.KernelAsset:
+		return hConfig.KernelPath
+	case types.ImageAsset:
+		return hConfig.ImagePath
+	case types.InitrdAsset:
+		return hConfig.InitrdPath
+	case types.FirmwareAsset:
+		return hConfig.FirmwarePath
+	case types.FirmwareVolumeAsset:
+		return hConfig.FirmwareVolumePath
+	}
+
+	return ""
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHugePages(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	savedProcMemInfo := procMemInfo
+	defer func() {
+		procMemInfo = savedProcMemInfo
+	}()
+
+	procMemInfo = filepath.Join(dir, "meminfo")
+
+	result := checkHugePages(vc.HypervisorConfig{})
+	assert.Equal(checkStatusSkip, result.Status)
+
+	hConfig := vc.HypervisorConfig{HugePages: true}
+
+	// missing file
+	result = checkHugePages(hConfig)
+	assert.Equal(checkStatusFail, result.Status)
+
+	err := createFile(procMemInfo, "MemTotal: 1000 kB\nHugePages_Total: 0\nHugePages_Free: 0\n")
+	assert.NoError(err)
+
+	result = checkHugePages(hConfig)
+	assert.Equal(checkStatusFail, result.Status)
+	assert.Equal("HugePages_Free = 0", result.Observed)
+	assert.NotEmpty(result.Remediation)
+
+	err = createFile(procMemInfo, "MemTotal: 1000 kB\nHugePages_Total: 16\nHugePages_Free: 8\n")
+	assert.NoError(err)
+
+	result = checkHugePages(hConfig)
+	assert.Equal(checkStatusPass, result.Status)
+	assert.Equal("HugePages_Free = 8", result.Observed)
+}
+
+func TestCheckCgroupControllers(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	savedCgroupControllersFile := cgroupControllersFile
+	defer func() {
+		cgroupControllersFile = savedCgroupControllersFile
+	}()
+
+	cgroupControllersFile = filepath.Join(dir, "cgroup.controllers")
+
+	result := checkCgroupControllers()
+	assert.Equal(checkStatusSkip, result.Status)
+
+	err := createFile(cgroupControllersFile, "cpuset cpu memory\n")
+	assert.NoError(err)
+
+	result = checkCgroupControllers()
+	assert.Equal(checkStatusFail, result.Status)
+	assert.Contains(result.Remediation, "io, pids")
+
+	err = createFile(cgroupControllersFile, "cpuset cpu io memory hugetlb pids rdma misc\n")
+	assert.NoError(err)
+
+	result = checkCgroupControllers()
+	assert.Equal(checkStatusPass, result.Status)
+}
+
+func TestCheckVhostDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	savedVhostNetDevice := vhostNetDevice
+	savedVhostVsockDevice := vhostVsockDevice
+	defer func() {
+		vhostNetDevice = savedVhostNetDevice
+		vhostVsockDevice = savedVhostVsockDevice
+	}()
+
+	// a regular file is not a device
+	vhostNetDevice = filepath.Join(dir, "vhost-net")
+	vhostVsockDevice = filepath.Join(dir, "vhost-vsock")
+	assert.NoError(createFile(vhostNetDevice, ""))
+
+	result := checkVhostNet(vc.HypervisorConfig{})
+	assert.Equal(checkStatusFail, result.Status)
+	assert.Equal(checkSeverityWarning, result.Severity)
+
+	result = checkVhostNet(vc.HypervisorConfig{DisableVhostNet: true})
+	assert.Equal(checkStatusSkip, result.Status)
+
+	result = checkVhostVsock(vc.QemuHypervisor)
+	assert.Equal(checkStatusFail, result.Status)
+	assert.Equal(checkSeverityError, result.Severity)
+
+	result = checkVhostVsock(vc.FirecrackerHypervisor)
+	assert.Equal(checkStatusFail, result.Status)
+	assert.Equal(checkSeverityWarning, result.Severity)
+
+	vhostNetDevice = os.DevNull
+	result = checkVhostNet(vc.HypervisorConfig{})
+	assert.Equal(checkStatusPass, result.Status)
+}
+
+func TestCheckVirtioFSDaemon(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	result := checkVirtioFSDaemon(vc.HypervisorConfig{SharedFS: config.Virtio9P})
+	assert.Equal(checkStatusSkip, result.Status)
+
+	hConfig := vc.HypervisorConfig{SharedFS: config.VirtioFS}
+	result = checkVirtioFSDaemon(hConfig)
+	assert.Equal(checkStatusFail, result.Status)
+
+	daemon := filepath.Join(dir, "virtiofsd")
+	assert.NoError(os.WriteFile(daemon, []byte(""), 0644))
+
+	hConfig.VirtioFSDaemon = daemon
+	result = checkVirtioFSDaemon(hConfig)
+	assert.Equal(checkStatusFail, result.Status)
+
+	assert.NoError(os.Chmod(daemon, 0755))
+	result = checkVirtioFSDaemon(hConfig)
+	assert.Equal(checkStatusPass, result.Status)
+}
+
+func TestCheckAssetPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "kernel")
+	assert.NoError(createFile(kernel, "kernel"))
+
+	results := checkAssetPaths(vc.HypervisorConfig{
+		KernelPath: kernel,
+		ImagePath:  filepath.Join(dir, "image"),
+		InitrdPath: dir,
+	})
+
+	assert.Len(results, 3)
+
+	status := make(map[string]checkStatus)
+	for _, r := range results {
+		status[r.ID] = r.Status
+	}
+
+	assert.Equal(checkStatusPass, status["asset.kernel"])
+	assert.Equal(checkStatusFail, status["asset.image"])
+	assert.Equal(checkStatusFail, status["asset.initrd"])
+}
+
+func TestCheckAssetDigests(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "kernel")
+	assert.NoError(createFile(kernel, "kernel"))
+
+	digest, err := types.ComputeFileDigest(kernel, types.SHA256)
+	assert.NoError(err)
+
+	assert.Empty(checkAssetDigests(vc.HypervisorConfig{KernelPath: kernel}))
+
+	hConfig := vc.HypervisorConfig{
+		KernelPath: kernel,
+		AssetDigests: map[types.AssetType]string{
+			types.KernelAsset: digest,
+		},
+	}
+
+	results := checkAssetDigests(hConfig)
+	assert.Len(results, 1)
+	assert.Equal("asset.kernel.digest", results[0].ID)
+	assert.Equal(checkStatusPass, results[0].Status)
+
+	assert.NoError(createFile(kernel, "modified kernel"))
+
+	results = checkAssetDigests(hConfig)
+	assert.Len(results, 1)
+	assert.Equal(checkStatusFail, results[0].Status)
+
+	hConfig.AssetManifest = filepath.Join(dir, "missing.manifest")
+	results = checkAssetDigests(hConfig)
+	assert.Len(results, 1)
+	assert.Equal("asset.manifest", results[0].ID)
+	assert.Equal(checkStatusFail, results[0].Status)
+}
+
+func TestHostChecksRemoteHypervisor(t *testing.T) {
+	assert := assert.New(t)
+
+	results := hostChecks(oci.RuntimeConfig{HypervisorType: vc.RemoteHypervisor})
+	assert.Empty(results)
+}
+
+func TestCheckReport(t *testing.T) {
+	assert := assert.New(t)
+
+	report := newCheckReport()
+	report.Hypervisor = string(vc.QemuHypervisor)
+
+	report.Checks = append(report.Checks, checkResult{
+		ID:       "device.vhost-net",
+		Category: checkCategoryDevice,
+		Severity: checkSeverityWarning,
+		Status:   checkStatusFail,
+	})
+	assert.False(report.failed())
+
+	report.addFailure("host.vm-capable", checkCategoryCPU, "host is capable of running VMs", nil)
+	assert.False(report.failed())
+
+	report.addFailure("host.vm-capable", checkCategoryCPU, "host is capable of running VMs", errors.New("no cpuinfo"))
+	assert.True(report.failed())
+
+	// only the first failure is recorded
+	report.addFailure("host.other", checkCategoryCPU, "other", errors.New("other"))
+	assert.Len(report.Checks, 2)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(report.write(buf))
+
+	var decoded map[string]interface{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+
+	assert.Equal(checkReportSchemaVersion, decoded["schema_version"])
+	assert.Equal(false, decoded["passed"])
+
+	checks, ok := decoded["checks"].([]interface{})
+	assert.True(ok)
+	assert.Len(checks, 2)
+
+	check, ok := checks[1].(map[string]interface{})
+	assert.True(ok)
+	for _, key := range []string{"id", "category", "severity", "status", "description", "observed"} {
+		assert.Contains(check, key)
+	}
+}
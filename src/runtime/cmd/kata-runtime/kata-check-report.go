@@ -0,0 +1,180 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	checkOutputText = "text"
+	checkOutputJSON = "json"
+
+	// checkReportSchemaVersion must be bumped whenever a field of
+	// checkReport or checkResult is renamed, removed or changes meaning.
+	checkReportSchemaVersion = "1"
+)
+
+type checkSeverity string
+
+const (
+	// A failed check with this severity means the host cannot run
+	// Kata Containers.
+	checkSeverityError checkSeverity = "error"
+
+	// A failed check with this severity means some features will not
+	// be available or will perform poorly.
+	checkSeverityWarning checkSeverity = "warning"
+)
+
+type checkStatus string
+
+const (
+	checkStatusPass checkStatus = "pass"
+	checkStatusFail checkStatus = "fail"
+	checkStatusSkip checkStatus = "skip"
+)
+
+// check categories
+const (
+	checkCategoryCPU          = "cpu"
+	checkCategoryKernelModule = "kernel-module"
+	checkCategoryKVM          = "kvm"
+	checkCategoryDevice       = "device"
+	checkCategoryMemory       = "memory"
+	checkCategoryCgroup       = "cgroup"
+	checkCategorySharedFS     = "shared-fs"
+	checkCategoryAsset        = "asset"
+)
+
+// checkResult is the outcome of a single check. The JSON field names form
+// a stable interface consumed by external tooling.
+type checkResult struct {
+	ID          string        `json:"id"`
+	Category    string        `json:"category"`
+	Severity    checkSeverity `json:"severity"`
+	Status      checkStatus   `json:"status"`
+	Description string        `json:"description"`
+	Observed    string        `json:"observed,omitempty"`
+	Expected    string        `json:"expected,omitempty"`
+	Remediation string        `json:"remediation,omitempty"`
+}
+
+// checkReport is the document written by "check --output json".
+type checkReport struct {
+	SchemaVersion string        `json:"schema_version"`
+	Runtime       string        `json:"runtime"`
+	Version       string        `json:"version"`
+	Hypervisor    string        `json:"hypervisor"`
+	Passed        bool          `json:"passed"`
+	Checks        []checkResult `json:"checks"`
+}
+
+// checkReporter collects the results of all checks when a structured
+// report was requested. It is nil otherwise.
+var checkReporter *checkReport
+
+func newCheckReport() *checkReport {
+	return &checkReport{
+		SchemaVersion: checkReportSchemaVersion,
+		Runtime:       katautils.NAME,
+		Version:       katautils.VERSION,
+		Checks:        []checkResult{},
+	}
+}
+
+// recordCheck adds a result to the report, if one is being collected.
+func recordCheck(result checkResult) {
+	if checkReporter == nil {
+		return
+	}
+
+	checkReporter.Checks = append(checkReporter.Checks, result)
+}
+
+// failed returns true if any check with error severity failed.
+func (r *checkReport) failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == checkStatusFail && c.Severity == checkSeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addFailure records err as a failed check unless an error has already been
+// recorded, so that failures detected outside the individual checks (for
+// example an unreadable cpuinfo file) are not lost.
+func (r *checkReport) addFailure(id, category, description string, err error) {
+	if err == nil || r.failed() {
+		return
+	}
+
+	r.Checks = append(r.Checks, checkResult{
+		ID:          id,
+		Category:    category,
+		Severity:    checkSeverityError,
+		Status:      checkStatusFail,
+		Description: description,
+		Observed:    err.Error(),
+	})
+}
+
+func (r *checkReport) write(w io.Writer) error {
+	if w == nil {
+		return errors.New("Invalid output file specified")
+	}
+
+	r.Passed = !r.failed()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// logCheckResult logs a check result the same way the CPU and kernel
+// module checks are logged.
+func logCheckResult(result checkResult) {
+	fields := logrus.Fields{
+		"type":        result.Category,
+		"name":        result.ID,
+		"description": result.Description,
+	}
+
+	if result.Observed != "" {
+		fields["value"] = result.Observed
+	}
+
+	if result.Expected != "" {
+		fields["expected"] = result.Expected
+	}
+
+	entry := kataLog.WithFields(fields)
+
+	switch result.Status {
+	case checkStatusPass:
+		entry.Info("check passed")
+	case checkStatusSkip:
+		entry.Debug("check skipped")
+	default:
+		if result.Remediation != "" {
+			entry = entry.WithField("remediation", result.Remediation)
+		}
+
+		if result.Severity == checkSeverityError {
+			entry.Error("check failed")
+		} else {
+			entry.Warn("check failed")
+		}
+	}
+}
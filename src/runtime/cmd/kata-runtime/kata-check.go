@@ -172,15 +172,28 @@ func checkCPU(tag, cpuinfo string, attribs map[string]string) (count uint32) {
 			"description": desc,
 		}
 
+		result := checkResult{
+			ID:          fmt.Sprintf("cpu.%s.%s", tag, attrib),
+			Category:    checkCategoryCPU,
+			Severity:    checkSeverityError,
+			Status:      checkStatusPass,
+			Description: desc,
+			Expected:    attrib,
+		}
+
 		found := findAnchoredString(cpuinfo, attrib)
 		if !found {
 			kataLog.WithFields(fields).Errorf("CPU property not found")
+			result.Status = checkStatusFail
+			result.Remediation = "Use a host CPU providing this feature; virtualization extensions may need to be enabled in the firmware, or nested virtualization in the parent hypervisor"
+			recordCheck(result)
 			count++
 			continue
 
 		}
 
 		kataLog.WithFields(fields).Infof("CPU property found")
+		recordCheck(result)
 	}
 
 	return count
@@ -228,8 +241,25 @@ func checkKernelModules(modules map[string]kernelModule, handler kernelParamHand
 			"description": details.desc,
 		}
 
+		result := checkResult{
+			ID:          "kernel-module." + module,
+			Category:    checkCategoryKernelModule,
+			Severity:    checkSeverityWarning,
+			Status:      checkStatusPass,
+			Description: details.desc,
+			Expected:    "loaded",
+		}
+
+		if details.required {
+			result.Severity = checkSeverityError
+		}
+
 		if !haveKernelModule(module) {
 			kataLog.WithFields(fields).Errorf("kernel property %s not found", module)
+			result.Status = checkStatusFail
+			result.Observed = "not loaded"
+			result.Remediation = fmt.Sprintf("Load the module with 'modprobe %s' or install the kernel package providing it", module)
+			recordCheck(result)
 			if details.required {
 				count++
 			}
@@ -237,6 +267,8 @@ func checkKernelModules(modules map[string]kernelModule, handler kernelParamHand
 		}
 
 		kataLog.WithFields(fields).Infof("kernel property found")
+		result.Observed = "loaded"
+		recordCheck(result)
 
 		for param, expected := range details.parameters {
 			path := filepath.Join(sysModuleDir, module, moduleParamDir, param)
@@ -250,20 +282,38 @@ func checkKernelModules(modules map[string]kernelModule, handler kernelParamHand
 			fields["parameter"] = param
 			fields["value"] = value
 
+			paramResult := checkResult{
+				ID:          fmt.Sprintf("kernel-module.%s.%s", module, param),
+				Category:    checkCategoryKernelModule,
+				Severity:    result.Severity,
+				Status:      checkStatusPass,
+				Description: fmt.Sprintf("%s parameter %s", details.desc, param),
+				Observed:    value,
+				Expected:    expected,
+			}
+
 			if value != expected {
 				fields["expected"] = expected
 
 				msg := "kernel module parameter has unexpected value"
 
+				paramResult.Status = checkStatusFail
+				paramResult.Remediation = fmt.Sprintf("Reload the module with 'modprobe -r %s && modprobe %s %s=%s'", module, module, param, expected)
+
 				if handler != nil {
 					ignoreError := handler(onVMM, fields, msg)
 					if ignoreError {
+						paramResult.Severity = checkSeverityWarning
+						recordCheck(paramResult)
 						continue
 					}
 				}
 
 				kataLog.WithFields(fields).Error(msg)
+				recordCheck(paramResult)
 				count++
+			} else {
+				recordCheck(paramResult)
 			}
 
 			kataLog.WithFields(fields).Info(kernelPropertyCorrect)
@@ -332,9 +382,14 @@ var kataCheckCLICommand = cli.Command{
 			Name:  "only-list-releases",
 			Usage: "Only list newer available releases (non-root only)",
 		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Value: checkOutputText,
+			Usage: "output format: \"text\" or \"json\" (json implies --no-network-checks)",
+		},
 		cli.BoolFlag{
 			Name:  "strict, s",
-			Usage: "perform strict checking",
+			Usage: "perform strict checking (host configuration failures are fatal)",
 		},
 		cli.BoolFlag{
 			Name:  "verbose, v",
@@ -361,6 +416,10 @@ EXAMPLES:
 
   $ sudo %s check
 
+- Write a machine-readable report of all checks:
+
+  $ sudo %s check --output json
+
 - Just check if a newer version is available:
 
   $ %s check --check-version-only
@@ -381,6 +440,7 @@ EXAMPLES:
 		katautils.NAME,
 		katautils.NAME,
 		katautils.NAME,
+		katautils.NAME,
 	),
 
 	Action: func(context *cli.Context) error {
@@ -389,7 +449,20 @@ EXAMPLES:
 			kataLog.Logger.SetLevel(logrus.InfoLevel)
 		}
 
-		if !context.Bool("no-network-checks") && os.Getenv(noNetworkEnvVar) == "" {
+		var jsonOutput bool
+		switch output := context.String("output"); output {
+		case "", checkOutputText:
+		case checkOutputJSON:
+			jsonOutput = true
+		default:
+			return fmt.Errorf("check: invalid output format %q", output)
+		}
+
+		if jsonOutput && (context.Bool("check-version-only") || context.Bool("only-list-releases")) {
+			return errors.New("check: JSON output is only supported for host checks")
+		}
+
+		if !jsonOutput && !context.Bool("no-network-checks") && os.Getenv(noNetworkEnvVar) == "" {
 			cmd := RelCmdCheck
 
 			if context.Bool("only-list-releases") {
@@ -415,6 +488,10 @@ EXAMPLES:
 			return errors.New("check: cannot determine runtime config")
 		}
 
+		if jsonOutput {
+			return handleCheckReport(defaultOutputFile, runtimeConfig)
+		}
+
 		err := setCPUtype(runtimeConfig.HypervisorType)
 		if err != nil {
 			return err
@@ -431,6 +508,11 @@ EXAMPLES:
 		if err != nil {
 			return err
 		}
+
+		if runHostChecks(runtimeConfig) > 0 && context.Bool("strict") {
+			return fmt.Errorf("ERROR: %s", failMessage)
+		}
+
 		fmt.Println(successMessageCapable)
 
 		if os.Geteuid() == 0 {
@@ -446,6 +528,66 @@ EXAMPLES:
 	},
 }
 
+// handleCheckReport runs all checks, whatever their outcome, and writes the
+// results as JSON. An error is returned if any check with error severity
+// failed so that the exit code can be used to gate on the report.
+func handleCheckReport(file *os.File, runtimeConfig oci.RuntimeConfig) error {
+	checkReporter = newCheckReport()
+	defer func() {
+		checkReporter = nil
+	}()
+
+	report := checkReporter
+	report.Hypervisor = string(runtimeConfig.HypervisorType)
+
+	if err := setCPUtype(runtimeConfig.HypervisorType); err != nil {
+		report.addFailure("cpu.type", checkCategoryCPU, "supported CPU type", err)
+	} else {
+		details := vmContainerCapableDetails{
+			cpuInfoFile:           procCPUInfo,
+			requiredCPUFlags:      archRequiredCPUFlags,
+			requiredCPUAttribs:    archRequiredCPUAttribs,
+			requiredKernelModules: archRequiredKernelModules,
+		}
+
+		err = hostIsVMContainerCapable(details)
+		report.addFailure("host.vm-capable", checkCategoryCPU, "host is capable of running VMs", err)
+	}
+
+	runHostChecks(runtimeConfig)
+
+	createVM := checkResult{
+		ID:          "kvm.create-vm",
+		Category:    checkCategoryKVM,
+		Severity:    checkSeverityError,
+		Description: "a minimal VM can be created",
+		Expected:    "success",
+	}
+
+	if os.Geteuid() != 0 {
+		createVM.Status = checkStatusSkip
+		createVM.Observed = "not running as root"
+	} else if err := archHostCanCreateVMContainer(runtimeConfig.HypervisorType); err != nil {
+		createVM.Status = checkStatusFail
+		createVM.Observed = err.Error()
+		createVM.Remediation = "Make sure " + kvmDevice + " is accessible and no other hypervisor holds the virtualization extensions"
+	} else {
+		createVM.Status = checkStatusPass
+		createVM.Observed = "success"
+	}
+	recordCheck(createVM)
+
+	if err := report.write(file); err != nil {
+		return err
+	}
+
+	if report.failed() {
+		return fmt.Errorf("ERROR: %s", failMessage)
+	}
+
+	return nil
+}
+
 func genericArchKernelParamHandler(onVMM bool, fields logrus.Fields, msg string) bool {
 	param, ok := fields["parameter"].(string)
 	if !ok {
@@ -527,13 +669,27 @@ func genericCheckKVMExtensions(extensions map[string]kvmExtension) (map[string]i
 
 		// Generally return value(ret) 0 means no and 1 means yes,
 		// but some extensions may report additional information in the integer return value.
+		result := checkResult{
+			ID:          "kvm.extension." + name,
+			Category:    checkCategoryKVM,
+			Severity:    checkSeverityError,
+			Status:      checkStatusPass,
+			Description: extension.desc,
+			Observed:    fmt.Sprintf("%d", ret),
+		}
+
 		if errno != 0 {
 			kataLog.WithFields(fields).Error("is not supported")
+			result.Status = checkStatusFail
+			result.Observed = errno.Error()
+			result.Remediation = "Use a host kernel whose KVM implementation supports this extension"
+			recordCheck(result)
 			return results, errno
 		}
 
 		results[name] = int(ret)
 		kataLog.WithFields(fields).Info("kvm extension is supported")
+		recordCheck(result)
 	}
 
 	return results, nil
@@ -0,0 +1,61 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	containerdshim "github.com/kata-containers/kata-containers/src/runtime/pkg/containerd-shim-v2"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/utils/shimclient"
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/urfave/cli"
+)
+
+var kataConsoleLogCLICommand = cli.Command{
+	Name:      "console-log",
+	Usage:     "show the guest console log of a sandbox",
+	UsageText: "console-log [--json] <sandbox id>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "show the console log entries as JSON lines",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		sandboxID := context.Args().Get(0)
+
+		if err := katautils.VerifyContainerID(sandboxID); err != nil {
+			return err
+		}
+
+		data, err := shimclient.DoGet(sandboxID, defaultTimeout, containerdshim.ConsoleLogURL)
+		if err != nil {
+			return fmt.Errorf("Error observed when making console-log request: %s", err)
+		}
+
+		// The shim replies with the error message when the console log
+		// cannot be read.
+		entries, err := vc.ParseConsoleLog(data)
+		if err != nil {
+			return fmt.Errorf("Error observed when making console-log request: %s", strings.TrimSpace(string(data)))
+		}
+
+		if context.Bool("json") {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s %s\n", entry.Time.Format(time.RFC3339Nano), entry.Line)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,382 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+// Fields that change from one invocation to the next and are never
+// compared.
+var envVolatileFields = []string{
+	"Host.Memory.Free",
+	"Host.Memory.Available",
+}
+
+// Fields compared in baseline mode unless "--all" is given: the assets that
+// end up in the guest, the hypervisor and runtime versions, the guest kernel
+// parameters and the security related settings.
+var envBaselineFields = []string{
+	"Kernel.",
+	"Image.",
+	"Initrd.",
+	"Hypervisor.Path",
+	"Hypervisor.Digest",
+	"Hypervisor.ExpectedDigest",
+	"Hypervisor.Version",
+	"Hypervisor.SecurityInfo.",
+	"Runtime.Version.",
+	"Runtime.DisableGuestSeccomp",
+	"Runtime.GuestSeLinuxLabel",
+}
+
+// envDifference describes a field whose value is not the same in two
+// environment documents. A field missing from one of the documents has
+// a nil value on that side.
+type envDifference struct {
+	Field string  `json:"field"`
+	A     *string `json:"a"`
+	B     *string `json:"b"`
+}
+
+func (d envDifference) String() string {
+	value := func(v *string) string {
+		if v == nil {
+			return "<missing>"
+		}
+		return strconv.Quote(*v)
+	}
+
+	return fmt.Sprintf("%s: %s != %s", d.Field, value(d.A), value(d.B))
+}
+
+// loadEnvDocument reads a document written by "env" or "env --json".
+func loadEnvDocument(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeEnvDocument(data)
+}
+
+func decodeEnvDocument(data []byte) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON environment document: %v", err)
+		}
+		return doc, nil
+	}
+
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, fmt.Errorf("invalid TOML environment document: %v", err)
+	}
+
+	return doc, nil
+}
+
+// envToDocument converts an EnvInfo into the generic form used to compare
+// documents.
+func envToDocument(env EnvInfo) (map[string]interface{}, error) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeEnvDocument(data)
+}
+
+// envValueString renders a leaf value so that the same setting read from a
+// JSON and a TOML document compares equal.
+func envValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, envValueString(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []map[string]interface{}:
+		// arrays of tables, as decoded from TOML
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return envValueString(items)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// flattenEnvDocument maps the dotted name of every leaf field to its value.
+func flattenEnvDocument(prefix string, doc map[string]interface{}, result map[string]string) {
+	for key, value := range doc {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+
+		if table, ok := value.(map[string]interface{}); ok {
+			flattenEnvDocument(name, table, result)
+			continue
+		}
+
+		// TOML has no null value, so unset fields are simply absent.
+		if value == nil {
+			continue
+		}
+
+		result[name] = envValueString(value)
+	}
+}
+
+func envFieldMatches(field string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if field == p || (strings.HasSuffix(p, ".") && strings.HasPrefix(field, p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffEnvDocuments compares two environment documents field by field. If
+// 'only' is not empty, only the fields it matches are compared. Fields
+// matched by 'ignore' are never compared. A pattern ending with a dot
+// matches every field below it.
+func diffEnvDocuments(a, b map[string]interface{}, only, ignore []string) []envDifference {
+	fieldsA := make(map[string]string)
+	fieldsB := make(map[string]string)
+
+	flattenEnvDocument("", a, fieldsA)
+	flattenEnvDocument("", b, fieldsB)
+
+	names := make(map[string]struct{})
+	for name := range fieldsA {
+		names[name] = struct{}{}
+	}
+	for name := range fieldsB {
+		names[name] = struct{}{}
+	}
+
+	var diffs []envDifference
+
+	for name := range names {
+		if envFieldMatches(name, ignore) {
+			continue
+		}
+
+		if len(only) > 0 && !envFieldMatches(name, only) {
+			continue
+		}
+
+		valueA, okA := fieldsA[name]
+		valueB, okB := fieldsB[name]
+
+		if okA && okB && valueA == valueB {
+			continue
+		}
+
+		d := envDifference{Field: name}
+		if okA {
+			d.A = &valueA
+		}
+		if okB {
+			d.B = &valueB
+		}
+
+		diffs = append(diffs, d)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Field < diffs[j].Field
+	})
+
+	return diffs
+}
+
+// baselineDigestFields returns the asset digest fields set in a baseline
+// document, which are the only asset digests worth computing to compare
+// the host with that baseline.
+func baselineDigestFields(baseline map[string]interface{}) []string {
+	fields := make(map[string]string)
+	flattenEnvDocument("", baseline, fields)
+
+	digests := []string{}
+	for _, field := range []string{envHypervisorDigestField, envImageDigestField, envKernelDigestField, envInitrdDigestField} {
+		if fields[field] != "" {
+			digests = append(digests, field)
+		}
+	}
+
+	return digests
+}
+
+func writeEnvDifferences(w io.Writer, diffs []envDifference, asJSON bool) error {
+	if asJSON {
+		if diffs == nil {
+			diffs = []envDifference{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diffs)
+	}
+
+	for _, d := range diffs {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handleEnvDiff(file *os.File, c *cli.Context) error {
+	if file == nil {
+		return errors.New("Invalid output file specified")
+	}
+
+	ignore := append([]string{}, envVolatileFields...)
+	ignore = append(ignore, c.StringSlice("ignore")...)
+	baseline := c.String("baseline")
+
+	var a, b map[string]interface{}
+	var only []string
+	var err error
+
+	if baseline != "" {
+		if c.NArg() != 0 {
+			return errors.New("env diff: --baseline does not take any document argument")
+		}
+
+		configFile, ok := c.App.Metadata["configFile"].(string)
+		if !ok {
+			return errors.New("cannot determine config file")
+		}
+
+		runtimeConfig, ok := c.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("cannot determine runtime config")
+		}
+
+		if a, err = loadEnvDocument(baseline); err != nil {
+			return err
+		}
+
+		env, err := getEnvInfo(configFile, runtimeConfig)
+		if err != nil {
+			return err
+		}
+
+		setAssetDigests(&env, baselineDigestFields(a))
+
+		if b, err = envToDocument(env); err != nil {
+			return err
+		}
+
+		if !c.Bool("all") {
+			only = envBaselineFields
+		}
+	} else {
+		if c.NArg() != 2 {
+			return errors.New("env diff: expected two environment documents")
+		}
+
+		if a, err = loadEnvDocument(c.Args().Get(0)); err != nil {
+			return err
+		}
+
+		if b, err = loadEnvDocument(c.Args().Get(1)); err != nil {
+			return err
+		}
+	}
+
+	diffs := diffEnvDocuments(a, b, only, ignore)
+
+	if err := writeEnvDifferences(file, diffs, c.Bool("json")); err != nil {
+		return err
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	if baseline != "" {
+		return fmt.Errorf("host configuration drifted from baseline %s (%d differences)", baseline, len(diffs))
+	}
+
+	return fmt.Errorf("environment documents differ (%d differences)", len(diffs))
+}
+
+var kataEnvDiffCLICommand = cli.Command{
+	Name:      "diff",
+	Usage:     "compare two saved env documents, or this host with a baseline",
+	ArgsUsage: "[<a> <b>]",
+	Description: fmt.Sprintf(`Compares the settings reported by "env" field by field. Documents may be
+in TOML or JSON format. The command exits with an error when any compared
+field differs.
+
+EXAMPLES:
+
+- Compare the environment of two nodes:
+
+  $ %s env diff node-a.toml node-b.toml
+
+- Save a baseline and later check this host against it:
+
+  $ %s env --json --digests > baseline.json
+  $ %s env diff --baseline baseline.json
+`, katautils.NAME, katautils.NAME, katautils.NAME),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "baseline",
+			Usage: "compare the current host against this saved document",
+		},
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "in baseline mode, compare all fields rather than only assets, versions, kernel parameters and security settings",
+		},
+		cli.StringSliceFlag{
+			Name:  "ignore",
+			Usage: "ignore a field, or all fields below a prefix ending with a dot (may be repeated)",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return handleEnvDiff(defaultOutputFile, context)
+	},
+}
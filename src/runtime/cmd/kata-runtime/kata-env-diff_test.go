@@ -0,0 +1,221 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func testEnvDiffEnvInfo() EnvInfo {
+	return EnvInfo{
+		Meta: MetaInfo{Version: formatVersion},
+		Kernel: KernelInfo{
+			Path:       "/usr/share/kata-containers/vmlinux",
+			Digest:     "sha512:aaaa",
+			Parameters: "console=hvc0",
+		},
+		Image: ImageInfo{
+			Path:   "/usr/share/kata-containers/kata.img",
+			Digest: "sha512:bbbb",
+		},
+		Hypervisor: HypervisorInfo{
+			Path:        "/usr/bin/qemu-system-x86_64",
+			Version:     "QEMU emulator version 9.1.0",
+			MemorySlots: 10,
+			SecurityInfo: SecurityInfo{
+				EnableAnnotations: []string{"enable_iommu"},
+			},
+		},
+		Host: HostInfo{
+			Kernel: "6.1.0",
+			Memory: MemoryInfo{Total: 1000, Free: 500, Available: 600},
+		},
+	}
+}
+
+func writeEnvDiffDocument(t *testing.T, path string, env EnvInfo, asJSON bool) {
+	var buf bytes.Buffer
+
+	if asJSON {
+		assert.NoError(t, json.NewEncoder(&buf).Encode(env))
+	} else {
+		assert.NoError(t, toml.NewEncoder(&buf).Encode(env))
+	}
+
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), testFileMode))
+}
+
+func TestEnvDiffDocuments(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	envA := testEnvDiffEnvInfo()
+	envB := testEnvDiffEnvInfo()
+
+	pathA := filepath.Join(dir, "a.toml")
+	pathB := filepath.Join(dir, "b.json")
+
+	// Same settings in both formats, only volatile fields differ.
+	envB.Host.Memory.Free = 100
+	writeEnvDiffDocument(t, pathA, envA, false)
+	writeEnvDiffDocument(t, pathB, envB, true)
+
+	a, err := loadEnvDocument(pathA)
+	assert.NoError(err)
+	b, err := loadEnvDocument(pathB)
+	assert.NoError(err)
+
+	diffs := diffEnvDocuments(a, b, nil, envVolatileFields)
+	assert.Empty(diffs)
+
+	envB.Hypervisor.Version = "QEMU emulator version 9.2.0"
+	envB.Hypervisor.MemorySlots = 12
+	envB.Host.Kernel = "6.6.0"
+	writeEnvDiffDocument(t, pathB, envB, true)
+
+	b, err = loadEnvDocument(pathB)
+	assert.NoError(err)
+
+	diffs = diffEnvDocuments(a, b, nil, envVolatileFields)
+	assert.Len(diffs, 3)
+	assert.Equal("Host.Kernel", diffs[0].Field)
+	assert.Equal("Hypervisor.MemorySlots", diffs[1].Field)
+	assert.Equal("10", *diffs[1].A)
+	assert.Equal("12", *diffs[1].B)
+	assert.Equal("Hypervisor.Version", diffs[2].Field)
+
+	// restricted to the baseline fields
+	diffs = diffEnvDocuments(a, b, envBaselineFields, envVolatileFields)
+	assert.Len(diffs, 1)
+	assert.Equal("Hypervisor.Version", diffs[0].Field)
+
+	// explicitly ignored
+	diffs = diffEnvDocuments(a, b, nil, append([]string{"Host.", "Hypervisor.Version"}, envVolatileFields...))
+	assert.Len(diffs, 1)
+	assert.Equal("Hypervisor.MemorySlots", diffs[0].Field)
+
+	// field only present in one document
+	delete(b["Kernel"].(map[string]interface{}), "Digest")
+	diffs = diffEnvDocuments(a, b, []string{"Kernel."}, nil)
+	assert.Len(diffs, 1)
+	assert.Equal("Kernel.Digest", diffs[0].Field)
+	assert.NotNil(diffs[0].A)
+	assert.Nil(diffs[0].B)
+	assert.Equal(`Kernel.Digest: "sha512:aaaa" != <missing>`, diffs[0].String())
+}
+
+func TestEnvDiffInvalidDocument(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := decodeEnvDocument([]byte("{ not json"))
+	assert.Error(err)
+
+	_, err = decodeEnvDocument([]byte("[not toml"))
+	assert.Error(err)
+
+	_, err = loadEnvDocument(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(err)
+}
+
+func TestEnvDiffCLIFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	pathA := filepath.Join(dir, "a.toml")
+	pathB := filepath.Join(dir, "b.toml")
+
+	env := testEnvDiffEnvInfo()
+	writeEnvDiffDocument(t, pathA, env, false)
+	writeEnvDiffDocument(t, pathB, env, false)
+
+	out, err := os.CreateTemp(dir, "out")
+	assert.NoError(err)
+	defer out.Close()
+
+	set := flag.NewFlagSet("", 0)
+	assert.NoError(set.Parse([]string{pathA, pathB}))
+	ctx := createCLIContext(set)
+
+	assert.NoError(handleEnvDiff(out, ctx))
+
+	env.Image.Digest = "sha512:cccc"
+	writeEnvDiffDocument(t, pathB, env, false)
+
+	assert.Error(handleEnvDiff(out, ctx))
+
+	data, err := os.ReadFile(out.Name())
+	assert.NoError(err)
+	assert.Contains(string(data), "Image.Digest")
+
+	// wrong number of arguments
+	set = flag.NewFlagSet("", 0)
+	assert.NoError(set.Parse([]string{pathA}))
+	assert.Error(handleEnvDiff(out, createCLIContext(set)))
+
+	assert.Error(handleEnvDiff(nil, cli.NewContext(cli.NewApp(), set, nil)))
+}
+
+func TestEnvDiffBaselineDigestFields(t *testing.T) {
+	assert := assert.New(t)
+
+	env := testEnvDiffEnvInfo()
+	doc, err := envToDocument(env)
+	assert.NoError(err)
+
+	assert.Equal([]string{envImageDigestField, envKernelDigestField}, baselineDigestFields(doc))
+
+	// a baseline saved without the digests requires none
+	env.Kernel.Digest = ""
+	env.Image.Digest = ""
+	doc, err = envToDocument(env)
+	assert.NoError(err)
+
+	fields := baselineDigestFields(doc)
+	assert.NotNil(fields)
+	assert.Empty(fields)
+}
+
+func TestEnvSetAssetDigests(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinux")
+	image := filepath.Join(dir, "kata.img")
+	assert.NoError(os.WriteFile(kernel, []byte("kernel"), testFileMode))
+	assert.NoError(os.WriteFile(image, []byte("image"), testFileMode))
+
+	env := EnvInfo{
+		Kernel: KernelInfo{Path: kernel},
+		Image:  ImageInfo{Path: image},
+	}
+
+	setAssetDigests(&env, []string{})
+	assert.Empty(env.Kernel.Digest)
+	assert.Empty(env.Image.Digest)
+
+	setAssetDigests(&env, []string{envKernelDigestField})
+	assert.Equal(getAssetDigest(kernel, ""), env.Kernel.Digest)
+	assert.Contains(env.Kernel.Digest, "sha512:")
+	assert.Empty(env.Image.Digest)
+
+	setAssetDigests(&env, nil)
+	assert.Equal(getAssetDigest(image, ""), env.Image.Digest)
+
+	// assets which are not configured have no digest
+	assert.Empty(env.Initrd.Digest)
+	assert.Empty(env.Hypervisor.Digest)
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"os"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -23,6 +24,7 @@ import (
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/utils"
 	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
 	exp "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/experimental"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	vcUtils "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
 )
 
@@ -30,7 +32,7 @@ import (
 //
 // XXX: Increment for every change to the output format
 // (meaning any change to the EnvInfo type).
-const formatVersion = "1.0.27"
+const formatVersion = "1.0.29"
 
 // MetaInfo stores information on the format of the output itself
 type MetaInfo struct {
@@ -40,18 +42,24 @@ type MetaInfo struct {
 
 // KernelInfo stores kernel details
 type KernelInfo struct {
-	Path       string
-	Parameters string
+	Path           string
+	Digest         string
+	ExpectedDigest string
+	Parameters     string
 }
 
 // InitrdInfo stores initrd image details
 type InitrdInfo struct {
-	Path string
+	Path           string
+	Digest         string
+	ExpectedDigest string
 }
 
 // ImageInfo stores root filesystem image details
 type ImageInfo struct {
-	Path string
+	Path           string
+	Digest         string
+	ExpectedDigest string
 }
 
 // CPUInfo stores host CPU details
@@ -107,6 +115,7 @@ type SecurityInfo struct {
 	GuestHookPath     string
 	EnableAnnotations []string
 	ConfidentialGuest bool
+	AssetManifest     string
 }
 
 // HypervisorInfo stores hypervisor details
@@ -114,6 +123,8 @@ type HypervisorInfo struct {
 	MachineType       string
 	Version           string
 	Path              string
+	Digest            string
+	ExpectedDigest    string
 	BlockDeviceDriver string
 	EntropySource     string
 	SharedFS          string
@@ -281,6 +292,74 @@ func getMemoryInfo() MemoryInfo {
 	}
 }
 
+// getAssetDigest returns the digest of the specified asset, in the form
+// "<algorithm>:<hex>", or an empty string if it cannot be read. The
+// algorithm of the expected digest is used if one is configured, SHA-512
+// otherwise.
+func getAssetDigest(path, expected string) string {
+	if path == "" {
+		return ""
+	}
+
+	algorithm, _, err := types.ParseAssetDigest(expected)
+	if err != nil {
+		algorithm = types.SHA512
+	}
+
+	digest, err := vc.AssetDigest(path, algorithm)
+	if err != nil {
+		return ""
+	}
+
+	return digest
+}
+
+// getExpectedAssetDigests returns the digests the assets are verified
+// against when a sandbox is created.
+func getExpectedAssetDigests(config vc.HypervisorConfig) map[types.AssetType]string {
+	digests, err := config.ExpectedAssetDigests()
+	if err != nil {
+		kataLog.WithError(err).Warn("cannot determine expected asset digests")
+		return nil
+	}
+
+	return digests
+}
+
+// Fields of the env document holding the digest of an asset.
+const (
+	envHypervisorDigestField = "Hypervisor.Digest"
+	envImageDigestField      = "Image.Digest"
+	envKernelDigestField     = "Kernel.Digest"
+	envInitrdDigestField     = "Initrd.Digest"
+)
+
+// setAssetDigests computes the digests of the assets whose digest field is
+// listed in fields, or of all the assets if fields is nil. Hashing the
+// assets is expensive, so it is only done when the digests are asked for
+// or compared with a baseline.
+func setAssetDigests(env *EnvInfo, fields []string) {
+	assets := []struct {
+		field    string
+		path     string
+		expected string
+		digest   *string
+	}{
+		{envHypervisorDigestField, env.Hypervisor.Path, env.Hypervisor.ExpectedDigest, &env.Hypervisor.Digest},
+		{envImageDigestField, env.Image.Path, env.Image.ExpectedDigest, &env.Image.Digest},
+		{envKernelDigestField, env.Kernel.Path, env.Kernel.ExpectedDigest, &env.Kernel.Digest},
+		{envInitrdDigestField, env.Initrd.Path, env.Initrd.ExpectedDigest, &env.Initrd.Digest},
+	}
+
+	for _, asset := range assets {
+		if fields != nil && !slices.Contains(fields, asset.field) {
+			continue
+		}
+
+		*asset.digest = getAssetDigest(asset.path, asset.expected)
+	}
+}
+
 func getCommandVersion(cmd string) (string, error) {
 	return utils.RunCommand([]string{cmd, "--version"})
 }
@@ -302,6 +381,7 @@ func getSecurityInfo(config vc.HypervisorConfig) SecurityInfo {
 		GuestHookPath:     config.GuestHookPath,
 		EnableAnnotations: config.EnableAnnotations,
 		ConfidentialGuest: config.ConfidentialGuest,
+		AssetManifest:     config.AssetManifest,
 	}
 }
 
@@ -328,11 +408,14 @@ func getHypervisorInfo(config oci.RuntimeConfig) (HypervisorInfo, error) {
 
 	securityInfo := getSecurityInfo(config.HypervisorConfig)
 
+	expectedDigest := getExpectedAssetDigests(config.HypervisorConfig)[types.HypervisorAsset]
+
 	return HypervisorInfo{
 		Debug:             config.HypervisorConfig.Debug,
 		MachineType:       config.HypervisorConfig.HypervisorMachineType,
 		Version:           version,
 		Path:              hypervisorPath,
+		ExpectedDigest:    expectedDigest,
 		BlockDeviceDriver: config.HypervisorConfig.BlockDeviceDriver,
 		Msize9p:           config.HypervisorConfig.Msize9p,
 		MemorySlots:       config.HypervisorConfig.MemSlots,
@@ -373,17 +456,22 @@ func getEnvInfo(configFile string, config oci.RuntimeConfig) (env EnvInfo, err e
 		return EnvInfo{}, err
 	}
 
+	expectedDigests := getExpectedAssetDigests(config.HypervisorConfig)
+
 	image := ImageInfo{
-		Path: config.HypervisorConfig.ImagePath,
+		Path:           config.HypervisorConfig.ImagePath,
+		ExpectedDigest: expectedDigests[types.ImageAsset],
 	}
 
 	kernel := KernelInfo{
-		Path:       config.HypervisorConfig.KernelPath,
-		Parameters: strings.Join(vc.SerializeParams(config.HypervisorConfig.KernelParams, "="), " "),
+		Path:           config.HypervisorConfig.KernelPath,
+		ExpectedDigest: expectedDigests[types.KernelAsset],
+		Parameters:     strings.Join(vc.SerializeParams(config.HypervisorConfig.KernelParams, "="), " "),
 	}
 
 	initrd := InitrdInfo{
-		Path: config.HypervisorConfig.InitrdPath,
+		Path:           config.HypervisorConfig.InitrdPath,
+		ExpectedDigest: expectedDigests[types.InitrdAsset],
 	}
 
 	env = EnvInfo{
@@ -420,6 +508,10 @@ func handleSettings(file *os.File, c *cli.Context) error {
 		return err
 	}
 
+	if c.Bool("digests") {
+		setAssetDigests(&env, nil)
+	}
+
 	if c.Bool("json") {
 		return writeJSONSettings(env, file)
 	}
@@ -456,11 +548,18 @@ var kataEnvCLICommand = cli.Command{
 	Name:    "env",
 	Aliases: []string{"kata-env"},
 	Usage:   "display settings. Default to TOML",
+	Subcommands: []cli.Command{
+		kataEnvDiffCLICommand,
+	},
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "json",
 			Usage: "Format output as JSON",
 		},
+		cli.BoolFlag{
+			Name:  "digests",
+			Usage: "Include the digests of the hypervisor and guest assets, which requires reading them entirely",
+		},
 	},
 	Action: func(context *cli.Context) error {
 		return handleSettings(defaultOutputFile, context)
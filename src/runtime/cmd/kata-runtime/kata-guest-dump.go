@@ -0,0 +1,39 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+
+	containerdshim "github.com/kata-containers/kata-containers/src/runtime/pkg/containerd-shim-v2"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/utils/shimclient"
+	"github.com/urfave/cli"
+)
+
+var kataGuestDumpCLICommand = cli.Command{
+	Name:      "guest-dump",
+	Usage:     "dump the guest memory of a sandbox under the configured guest memory dump path",
+	UsageText: "guest-dump <sandbox id>",
+	Action: func(context *cli.Context) error {
+		sandboxID := context.Args().Get(0)
+
+		if err := katautils.VerifyContainerID(sandboxID); err != nil {
+			return err
+		}
+
+		// No timeout, dumping the guest memory takes as long as
+		// writing it to disk.
+		dir, err := shimclient.DoPutWithResponse(sandboxID, 0, containerdshim.VMDumpURL, "application/json", nil)
+		if err != nil {
+			return fmt.Errorf("Error observed when making guest-dump request: %s", err)
+		}
+
+		fmt.Printf("%s\n", dir)
+
+		return nil
+	},
+}
@@ -123,6 +123,8 @@ var runtimeCommands = []cli.Command{
 	kataEnvCLICommand,
 	kataExecCLICommand,
 	kataMetricsCLICommand,
+	kataGuestDumpCLICommand,
+	kataConsoleLogCLICommand,
 	factoryCLICommand,
 	kataVolumeCommand,
 	kataIPTablesCommand,
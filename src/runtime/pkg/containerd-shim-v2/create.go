@@ -228,7 +228,7 @@ func create(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest) (*con
 		// during device attachment.
 		removeCDIAnnotations(ociSpec.Annotations)
 
-		_, err = katautils.CreateContainer(ctx, s.sandbox, *ociSpec, rootFs, r.ID, bundlePath, disableOutput, runtimeConfig.DisableGuestEmptyDir)
+		_, err = katautils.CreateContainer(ctx, s.sandbox, *ociSpec, rootFs, r.ID, bundlePath, disableOutput, runtimeConfig.DisableGuestEmptyDir, runtimeConfig.HookPolicy)
 		if err != nil {
 			return nil, err
 		}
@@ -294,7 +294,8 @@ func loadRuntimeConfig(s *service, r *taskAPI.CreateTaskRequest, anno map[string
 		configPath = os.Getenv("KATA_CONF_FILE")
 	}
 
-	_, runtimeConfig, err := katautils.LoadConfiguration(configPath, false)
+	profile := oci.GetSandboxConfigProfile(anno)
+	_, runtimeConfig, err := katautils.LoadConfigurationWithProfile(configPath, profile, false)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,149 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"time"
+
+	"github.com/containerd/typeurl/v2"
+
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+)
+
+// Topics of the Kata Containers specific events published to containerd.
+const (
+	SandboxRightsizedEventTopic     = "/kata/sandbox/rightsized"
+	SandboxOOMEventTopic            = "/kata/sandbox/oom"
+	SandboxMemoryPressureEventTopic = "/kata/sandbox/memory-pressure"
+
+	// The lifecycle events of the VM are published on the topic of their
+	// type, e.g. /kata/hypervisor/guest-panic.
+	HypervisorEventTopicPrefix = "/kata/hypervisor/"
+)
+
+// kataEventsPackage is the type URL prefix of the Kata Containers specific
+// events. These events are not protobuf messages and are encoded as JSON.
+const kataEventsPackage = "io.katacontainers.events"
+
+func init() {
+	typeurl.Register(&SandboxRightsized{}, kataEventsPackage, "SandboxRightsized")
+	typeurl.Register(&HypervisorEvent{}, kataEventsPackage, "HypervisorEvent")
+	typeurl.Register(&SandboxOOM{}, kataEventsPackage, "SandboxOOM")
+	typeurl.Register(&SandboxMemoryPressure{}, kataEventsPackage, "SandboxMemoryPressure")
+}
+
+// SandboxRightsized is published when the rightsizing controller resizes the
+// sandbox VM. Sizes are in vCPUs for the cpu resource and in MiB for the
+// memory resource.
+type SandboxRightsized struct {
+	Timestamp time.Time `json:"timestamp"`
+	SandboxID string    `json:"sandbox_id"`
+	Resource  string    `json:"resource"`
+	Reason    string    `json:"reason"`
+	From      uint32    `json:"from"`
+	To        uint32    `json:"to"`
+}
+
+// watchRightsizingEvents forwards the resizes performed by the sandbox
+// rightsizing controller to containerd.
+func watchRightsizingEvents(s *service) {
+	if s.sandbox == nil {
+		return
+	}
+
+	events := s.sandbox.RightsizingEvents()
+	if events == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case d := <-events:
+			s.send(&SandboxRightsized{
+				Timestamp: d.Timestamp,
+				SandboxID: s.sandbox.ID(),
+				Resource:  d.Resource,
+				Reason:    d.Reason,
+				From:      d.From,
+				To:        d.To,
+			})
+		}
+	}
+}
+
+// HypervisorEvent is published when the hypervisor reports a lifecycle event
+// of the sandbox VM, like a guest panic, a reset or a shutdown, so that the
+// reason why a VM died can be told.
+type HypervisorEvent struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+	SandboxID string            `json:"sandbox_id"`
+	Type      string            `json:"type"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+// Topic returns the topic the event is published on.
+func (e *HypervisorEvent) Topic() string {
+	return HypervisorEventTopicPrefix + e.Type
+}
+
+// watchHypervisorEvents forwards the lifecycle events of the VM reported by
+// the hypervisor to containerd.
+func watchHypervisorEvents(s *service) {
+	if s.sandbox == nil {
+		return
+	}
+
+	events := s.sandbox.HypervisorEvents()
+	if events == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case e := <-events:
+			s.send(newHypervisorEvent(s.sandbox.ID(), e))
+		}
+	}
+}
+
+func newHypervisorEvent(sandboxID string, e vc.HypervisorEvent) *HypervisorEvent {
+	return &HypervisorEvent{
+		Timestamp: e.Timestamp,
+		SandboxID: sandboxID,
+		Type:      string(e.Type),
+		Reason:    e.Reason,
+		Details:   e.Details,
+	}
+}
+
+// SandboxOOM is published when the OOM killer killed processes of the
+// sandbox. The source tells whether the host killed processes of the sandbox
+// cgroup, the process being the VMM when it is gone, or the guest kernel
+// killed processes of the guest.
+type SandboxOOM struct {
+	Timestamp time.Time `json:"timestamp"`
+	SandboxID string    `json:"sandbox_id"`
+	Source    string    `json:"source"`
+	Process   string    `json:"process"`
+	Count     uint64    `json:"count"`
+}
+
+// SandboxMemoryPressure is published when the sandbox enters or leaves memory
+// pressure. On the host, the value is the number of times the memory of the
+// sandbox cgroup was throttled since the last check. In the guest, it is the
+// percentage of time some tasks were stalled on memory.
+type SandboxMemoryPressure struct {
+	Timestamp     time.Time `json:"timestamp"`
+	SandboxID     string    `json:"sandbox_id"`
+	Source        string    `json:"source"`
+	Value         float64   `json:"value"`
+	UnderPressure bool      `json:"under_pressure"`
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"testing"
+	"time"
+
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHypervisorEventTopic(t *testing.T) {
+	assert := assert.New(t)
+
+	timestamp := time.Now()
+	e := newHypervisorEvent(testSandboxID, vc.HypervisorEvent{
+		Timestamp: timestamp,
+		Type:      vc.HypervisorEventGuestPanic,
+		Reason:    "guest",
+		Details:   map[string]string{"action": "pause"},
+	})
+
+	assert.Equal(testSandboxID, e.SandboxID)
+	assert.Equal(timestamp, e.Timestamp)
+	assert.Equal("guest", e.Reason)
+	assert.Equal("pause", e.Details["action"])
+	assert.Equal("/kata/hypervisor/guest-panic", getTopic(e))
+
+	e.Type = string(vc.HypervisorEventBlockIOError)
+	assert.Equal("/kata/hypervisor/block-io-error", getTopic(e))
+}
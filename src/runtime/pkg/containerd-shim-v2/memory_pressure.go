@@ -0,0 +1,255 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+)
+
+const (
+	// The guest is under memory pressure when some of its tasks were
+	// stalled on memory for more than this percentage of the last 10
+	// seconds.
+	guestMemoryPressureThreshold = 10.0
+
+	// Sources of the memory events.
+	memorySourceHost  = "host"
+	memorySourceGuest = "guest"
+
+	// Processes killed by the OOM killer.
+	oomProcessVMM     = "vmm"
+	oomProcessSandbox = "sandbox"
+	oomProcessGuest   = "guest"
+
+	// Agent metrics the guest memory events are read from.
+	guestVMStatMetric   = "kata_guest_vm_stat"
+	guestPressureMetric = "kata_guest_pressure"
+)
+
+// memorySample holds the memory counters of the sandbox at a point in time.
+type memorySample struct {
+	host vc.SandboxMemoryEvents
+	// The percentage of the last 10 seconds some guest tasks were
+	// stalled on memory.
+	guestPressure float64
+	guestOOMKills uint64
+	hostOK        bool
+	guestOK       bool
+	// Whether the VMM process is still running.
+	vmmAlive bool
+}
+
+// memoryPressureMonitor turns the changes of the memory counters of the
+// sandbox into events.
+type memoryPressureMonitor struct {
+	last      memorySample
+	sandboxID string
+	// Whether the sandbox was under memory pressure at the last check,
+	// by source.
+	underPressure map[string]bool
+	primed        bool
+}
+
+func newMemoryPressureMonitor(sandboxID string) *memoryPressureMonitor {
+	return &memoryPressureMonitor{
+		sandboxID:     sandboxID,
+		underPressure: make(map[string]bool),
+	}
+}
+
+// watchMemoryPressure reports the OOM kills and the memory pressure of the
+// sandbox, as seen by the host on the sandbox cgroup and by the guest kernel,
+// to containerd and to Prometheus, when enabled in the configuration.
+func watchMemoryPressure(s *service) {
+	if s.sandbox == nil || s.config == nil || s.config.MemoryPressureInterval == 0 {
+		return
+	}
+
+	monitor := newMemoryPressureMonitor(s.sandbox.ID())
+	ticker := time.NewTicker(time.Duration(s.config.MemoryPressureInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			sample := collectMemorySample(s.ctx, s.sandbox)
+			for _, e := range monitor.check(sample, time.Now()) {
+				s.send(e)
+			}
+		}
+	}
+}
+
+// collectMemorySample reads the memory events of the sandbox cgroup on the
+// host and the memory metrics of the guest reported by the agent.
+func collectMemorySample(ctx context.Context, sandbox vc.VCSandbox) memorySample {
+	sample := memorySample{vmmAlive: true}
+
+	if stats, err := sandbox.Stats(ctx); err != nil {
+		shimLog.WithError(err).Debug("failed to get sandbox memory events")
+	} else {
+		sample.host = stats.MemoryEvents
+		sample.hostOK = true
+	}
+
+	if pid, err := sandbox.GetHypervisorPid(); err == nil && pid > 0 {
+		sample.vmmAlive = syscall.Kill(pid, syscall.Signal(0)) != syscall.ESRCH
+	}
+
+	if sample.vmmAlive {
+		agentMetrics, err := sandbox.GetAgentMetrics(ctx)
+		if err != nil {
+			shimLog.WithError(err).Debug("failed to get guest memory metrics")
+		} else {
+			sample.guestOOMKills, sample.guestPressure, sample.guestOK = parseGuestMemoryMetrics(decodeAgentMetrics(agentMetrics))
+		}
+	}
+
+	return sample
+}
+
+// parseGuestMemoryMetrics returns the OOM kills and the memory pressure of the
+// guest found in the agent metrics.
+func parseGuestMemoryMetrics(list []*dto.MetricFamily) (oomKills uint64, pressure float64, ok bool) {
+	for _, mf := range list {
+		switch mf.GetName() {
+		case guestVMStatMetric:
+			for _, m := range mf.GetMetric() {
+				if metricLabels(m)["item"] == "oom_kill" {
+					oomKills = uint64(m.GetGauge().GetValue())
+					ok = true
+				}
+			}
+		case guestPressureMetric:
+			for _, m := range mf.GetMetric() {
+				labels := metricLabels(m)
+				if labels["resource"] == "memory" && labels["kind"] == "some" && labels["item"] == "avg10" {
+					pressure = m.GetGauge().GetValue()
+					ok = true
+				}
+			}
+		}
+	}
+
+	return oomKills, pressure, ok
+}
+
+func metricLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// check updates the metrics with the memory counters of the sample and returns
+// the events for their changes since the previous sample.
+func (m *memoryPressureMonitor) check(sample memorySample, now time.Time) []interface{} {
+	var events []interface{}
+
+	if sample.hostOK {
+		setSandboxMemoryEventsMetrics(sample.host)
+	}
+
+	if !m.primed {
+		// The counters only tell about the events since the
+		// sandbox was created, the first sample is the baseline.
+		m.last = sample
+		m.primed = sample.hostOK || sample.guestOK
+		return events
+	}
+
+	if sample.hostOK && m.last.hostOK {
+		if kills := counterIncrease(m.last.host.OOMKill, sample.host.OOMKill); kills > 0 {
+			// The VMM is the biggest process of the sandbox cgroup,
+			// it is the likely victim when it is gone.
+			process := oomProcessSandbox
+			if !sample.vmmAlive {
+				process = oomProcessVMM
+			}
+			events = append(events, m.oomEvent(now, memorySourceHost, process, kills))
+		}
+
+		throttled := counterIncrease(m.last.host.High, sample.host.High) + counterIncrease(m.last.host.Max, sample.host.Max)
+		if e := m.pressureEvent(now, memorySourceHost, throttled > 0, float64(throttled)); e != nil {
+			events = append(events, e)
+		}
+	}
+
+	if sample.guestOK && m.last.guestOK {
+		if kills := counterIncrease(m.last.guestOOMKills, sample.guestOOMKills); kills > 0 {
+			events = append(events, m.oomEvent(now, memorySourceGuest, oomProcessGuest, kills))
+		}
+
+		if e := m.pressureEvent(now, memorySourceGuest, sample.guestPressure > guestMemoryPressureThreshold, sample.guestPressure); e != nil {
+			events = append(events, e)
+		}
+	}
+
+	// Keep the last known counters of a source that could not be read,
+	// e.g. the agent is gone with the VM.
+	if !sample.hostOK {
+		sample.host, sample.hostOK = m.last.host, m.last.hostOK
+	}
+	if !sample.guestOK {
+		sample.guestOOMKills, sample.guestPressure, sample.guestOK = m.last.guestOOMKills, m.last.guestPressure, m.last.guestOK
+	}
+	m.last = sample
+
+	return events
+}
+
+func (m *memoryPressureMonitor) oomEvent(now time.Time, source, process string, kills uint64) *SandboxOOM {
+	katashimSandboxOOMKills.WithLabelValues(source, process).Add(float64(kills))
+
+	return &SandboxOOM{
+		Timestamp: now,
+		SandboxID: m.sandboxID,
+		Source:    source,
+		Process:   process,
+		Count:     kills,
+	}
+}
+
+// pressureEvent returns an event when the sandbox enters or leaves memory
+// pressure, nil otherwise.
+func (m *memoryPressureMonitor) pressureEvent(now time.Time, source string, underPressure bool, value float64) *SandboxMemoryPressure {
+	if underPressure {
+		katashimSandboxMemoryPressure.WithLabelValues(source).Set(1)
+	} else {
+		katashimSandboxMemoryPressure.WithLabelValues(source).Set(0)
+	}
+
+	if underPressure == m.underPressure[source] {
+		return nil
+	}
+	m.underPressure[source] = underPressure
+
+	return &SandboxMemoryPressure{
+		Timestamp:     now,
+		SandboxID:     m.sandboxID,
+		Source:        source,
+		UnderPressure: underPressure,
+		Value:         value,
+	}
+}
+
+// counterIncrease returns how much a counter increased, a counter that went
+// backwards was reset.
+func counterIncrease(previous, current uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
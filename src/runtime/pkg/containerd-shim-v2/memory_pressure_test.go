@@ -0,0 +1,154 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/vcmock"
+)
+
+const testGuestMemoryMetrics = `# HELP kata_guest_vm_stat Guest virtual memory statistics.
+# TYPE kata_guest_vm_stat gauge
+kata_guest_vm_stat{item="oom_kill"} 2
+kata_guest_vm_stat{item="pgfault"} 1000
+# HELP kata_guest_pressure Guest pressure stall information.
+# TYPE kata_guest_pressure gauge
+kata_guest_pressure{item="avg10",kind="some",resource="memory"} 12.5
+kata_guest_pressure{item="avg10",kind="full",resource="memory"} 3
+kata_guest_pressure{item="avg10",kind="some",resource="cpu"} 50
+`
+
+func TestParseGuestMemoryMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	oomKills, pressure, ok := parseGuestMemoryMetrics(decodeAgentMetrics(testGuestMemoryMetrics))
+	assert.True(ok)
+	assert.Equal(uint64(2), oomKills)
+	assert.Equal(12.5, pressure)
+
+	// Agents without the memory metrics
+	_, _, ok = parseGuestMemoryMetrics(decodeAgentMetrics("kata_guest_load{item=\"load1\"} 1\n"))
+	assert.False(ok)
+}
+
+func TestCollectMemorySample(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		StatsFunc: func() (vc.SandboxStats, error) {
+			return vc.SandboxStats{MemoryEvents: vc.SandboxMemoryEvents{High: 3, OOMKill: 1}}, nil
+		},
+		GetAgentMetricsFunc: func() (string, error) {
+			return testGuestMemoryMetrics, nil
+		},
+	}
+
+	sample := collectMemorySample(context.Background(), sandbox)
+	assert.True(sample.hostOK)
+	assert.True(sample.guestOK)
+	assert.True(sample.vmmAlive)
+	assert.Equal(uint64(3), sample.host.High)
+	assert.Equal(uint64(1), sample.host.OOMKill)
+	assert.Equal(uint64(2), sample.guestOOMKills)
+
+	sandbox.StatsFunc = func() (vc.SandboxStats, error) {
+		return vc.SandboxStats{}, errors.New("no cgroup")
+	}
+	sandbox.GetAgentMetricsFunc = func() (string, error) {
+		return "", errors.New("dead agent")
+	}
+	sample = collectMemorySample(context.Background(), sandbox)
+	assert.False(sample.hostOK)
+	assert.False(sample.guestOK)
+}
+
+func TestMemoryPressureMonitorCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	m := newMemoryPressureMonitor(testSandboxID)
+
+	// The first sample is the baseline
+	sample := memorySample{
+		host:          vc.SandboxMemoryEvents{OOMKill: 1},
+		guestOOMKills: 4,
+		hostOK:        true,
+		guestOK:       true,
+		vmmAlive:      true,
+	}
+	assert.Empty(m.check(sample, now))
+
+	// An OOM kill in the guest
+	sample.guestOOMKills = 5
+	events := m.check(sample, now)
+	assert.Equal([]interface{}{&SandboxOOM{
+		Timestamp: now,
+		SandboxID: testSandboxID,
+		Source:    memorySourceGuest,
+		Process:   oomProcessGuest,
+		Count:     1,
+	}}, events)
+	assert.Equal(SandboxOOMEventTopic, getTopic(events[0]))
+
+	// The guest enters memory pressure, once
+	sample.guestPressure = 42
+	events = m.check(sample, now)
+	assert.Len(events, 1)
+	e := events[0].(*SandboxMemoryPressure)
+	assert.Equal(memorySourceGuest, e.Source)
+	assert.True(e.UnderPressure)
+	assert.Equal(42.0, e.Value)
+	assert.Equal(SandboxMemoryPressureEventTopic, getTopic(e))
+	assert.Empty(m.check(sample, now))
+
+	// The guest leaves memory pressure
+	sample.guestPressure = 1
+	events = m.check(sample, now)
+	assert.Len(events, 1)
+	assert.False(events[0].(*SandboxMemoryPressure).UnderPressure)
+
+	// The memory of the sandbox cgroup is throttled on the host
+	sample.host.High = 2
+	events = m.check(sample, now)
+	assert.Len(events, 1)
+	e = events[0].(*SandboxMemoryPressure)
+	assert.Equal(memorySourceHost, e.Source)
+	assert.True(e.UnderPressure)
+	assert.Equal(2.0, e.Value)
+
+	// The VMM is OOM killed on the host, the agent is gone with it
+	sample.host.OOMKill = 2
+	sample.vmmAlive = false
+	sample.guestOK = false
+	events = m.check(sample, now)
+	assert.Len(events, 2)
+	assert.Equal(&SandboxOOM{
+		Timestamp: now,
+		SandboxID: testSandboxID,
+		Source:    memorySourceHost,
+		Process:   oomProcessVMM,
+		Count:     1,
+	}, events[0])
+	assert.False(events[1].(*SandboxMemoryPressure).UnderPressure)
+	assert.Equal(uint64(5), m.last.guestOOMKills)
+}
+
+func TestCounterIncrease(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(0), counterIncrease(3, 3))
+	assert.Equal(uint64(2), counterIncrease(3, 5))
+	// A counter that went backwards was reset
+	assert.Equal(uint64(1), counterIncrease(3, 1))
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/runc/options"
+	"github.com/containerd/typeurl/v2"
+	"github.com/stretchr/testify/assert"
+
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/vcmock"
+)
+
+func TestPids(t *testing.T) {
+	assert := assert.New(t)
+
+	var listErr error
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		ListProcessesFunc: func(contID string) ([]vc.GuestProcess, error) {
+			if listErr != nil {
+				return nil, listErr
+			}
+			return []vc.GuestProcess{
+				{Pid: 1, ExecID: testContainerID, Cmd: []string{"sh"}},
+				{Pid: 10, ExecID: "token", Cmd: []string{"top"}},
+				{Pid: 11, Cmd: []string{"sleep", "100"}},
+			}, nil
+		},
+	}
+
+	s := &service{
+		id:         testSandboxID,
+		sandbox:    sandbox,
+		hpid:       1234,
+		containers: make(map[string]*container),
+	}
+
+	var err error
+	s.containers[testContainerID], err = newContainer(s, &taskAPI.CreateTaskRequest{ID: testContainerID}, "", nil, false)
+	assert.NoError(err)
+	s.containers[testContainerID].execs["exec1"] = &exec{id: "token"}
+
+	ctx := context.Background()
+
+	// Unknown container
+	_, err = s.Pids(ctx, &taskAPI.PidsRequest{ID: "unknown"})
+	assert.Error(err)
+
+	resp, err := s.Pids(ctx, &taskAPI.PidsRequest{ID: testContainerID})
+	assert.NoError(err)
+	assert.Len(resp.Processes, 3)
+	assert.Equal(uint32(1), resp.Processes[0].Pid)
+	assert.Nil(resp.Processes[0].Info)
+	assert.Equal(uint32(11), resp.Processes[2].Pid)
+	assert.Nil(resp.Processes[2].Info)
+
+	// The exec process is reported with its containerd exec ID
+	assert.Equal(uint32(10), resp.Processes[1].Pid)
+	info, err := typeurl.UnmarshalAny(resp.Processes[1].Info)
+	assert.NoError(err)
+	assert.Equal("exec1", info.(*options.ProcessDetails).ExecID)
+
+	// Only the hypervisor is reported when the agent can't list the
+	// processes
+	listErr = errors.New("unsupported diagnostic log_type: process_list")
+	resp, err = s.Pids(ctx, &taskAPI.PidsRequest{ID: testContainerID})
+	assert.NoError(err)
+	assert.Len(resp.Processes, 1)
+	assert.Equal(uint32(1234), resp.Processes[0].Pid)
+}
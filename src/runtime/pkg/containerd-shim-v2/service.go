@@ -18,9 +18,11 @@ import (
 
 	eventstypes "github.com/containerd/containerd/api/events"
 	taskAPI "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/containerd/api/types/runc/options"
 	"github.com/containerd/containerd/api/types/task"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/protobuf"
 	cdruntime "github.com/containerd/containerd/runtime"
 	cdshim "github.com/containerd/containerd/runtime/v2/shim"
 	"github.com/containerd/typeurl/v2"
@@ -338,6 +340,14 @@ func getTopic(e interface{}) string {
 		return cdruntime.TaskResumedEventTopic
 	case *eventstypes.TaskCheckpointed:
 		return cdruntime.TaskCheckpointedEventTopic
+	case *SandboxRightsized:
+		return SandboxRightsizedEventTopic
+	case *HypervisorEvent:
+		return e.(*HypervisorEvent).Topic()
+	case *SandboxOOM:
+		return SandboxOOMEventTopic
+	case *SandboxMemoryPressure:
+		return SandboxMemoryPressureEventTopic
 	default:
 		shimLog.WithField("event-type", e).Warn("no topic for event type")
 	}
@@ -849,12 +859,13 @@ func (s *service) Kill(ctx context.Context, r *taskAPI.KillRequest) (_ *emptypb.
 }
 
 // Pids returns all pids inside the container
-// Since for kata, it cannot get the process's pid from VM,
-// thus only return the hypervisor's pid directly.
+// The pids are the ones of the processes of the container in the guest, as
+// listed by the agent. When the agent can't list them, only the hypervisor's
+// pid is returned.
 func (s *service) Pids(ctx context.Context, r *taskAPI.PidsRequest) (_ *taskAPI.PidsResponse, err error) {
 	shimLog.WithField("container", r.ID).Debug("Pids() start")
 	defer shimLog.WithField("container", r.ID).Debug("Pids() end")
-	span, _ := katatrace.Trace(s.rootCtx, shimLog, "Pids", shimTracingTags)
+	span, spanCtx := katatrace.Trace(s.rootCtx, shimLog, "Pids", shimTracingTags)
 	defer span.End()
 
 	var processes []*task.ProcessInfo
@@ -865,10 +876,48 @@ func (s *service) Pids(ctx context.Context, r *taskAPI.PidsRequest) (_ *taskAPI.
 		rpcDurationsHistogram.WithLabelValues("pids").Observe(float64(time.Since(start).Nanoseconds() / int64(time.Millisecond)))
 	}()
 
-	pInfo := task.ProcessInfo{
-		Pid: s.hpid,
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.getContainer(r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	guestProcesses, err := s.sandbox.ListProcesses(spanCtx, c.id)
+	if err != nil {
+		// Agents that can't list the processes of the container
+		// only get the hypervisor process reported.
+		shimLog.WithError(err).WithField("container", r.ID).Warn("failed to list the guest processes")
+		return &taskAPI.PidsResponse{
+			Processes: []*task.ProcessInfo{{Pid: s.hpid}},
+		}, nil
+	}
+
+	// The agent knows the exec processes by their token.
+	execIDs := make(map[string]string)
+	for id, e := range c.execs {
+		execIDs[e.id] = id
+	}
+
+	for _, p := range guestProcesses {
+		pInfo := task.ProcessInfo{
+			Pid: uint32(p.Pid),
+		}
+
+		// Only the exec processes have details, not the init process
+		// of the container nor the processes they forked.
+		if execID, ok := execIDs[p.ExecID]; ok {
+			d := &options.ProcessDetails{
+				ExecID: execID,
+			}
+			pInfo.Info, err = protobuf.MarshalAnyToProto(d)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to marshal process %d info", p.Pid)
+			}
+		}
+		processes = append(processes, &pInfo)
 	}
-	processes = append(processes, &pInfo)
 
 	return &taskAPI.PidsResponse{
 		Processes: processes,
@@ -1139,6 +1188,15 @@ func (s *service) checkProcesses(e exit) {
 	})
 }
 
+// hookPolicy returns the policy the OCI hooks are run with, the default one
+// until the configuration is loaded.
+func (s *service) hookPolicy() oci.HookPolicy {
+	if s.config == nil {
+		return oci.HookPolicy{}
+	}
+	return s.config.HookPolicy
+}
+
 func (s *service) getContainer(id string) (*container, error) {
 	c := s.containers[id]
 
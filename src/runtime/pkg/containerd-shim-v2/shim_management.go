@@ -22,6 +22,7 @@ import (
 	"google.golang.org/grpc/codes"
 
 	cdshim "github.com/containerd/containerd/runtime/v2/shim"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils"
 	mutils "github.com/kata-containers/kata-containers/src/runtime/pkg/utils"
 	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
 	vcAnnotations "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/annotations"
@@ -41,6 +42,14 @@ const (
 	PolicyURL             = "/policy"
 	IP6TablesURL          = "/ip6tables"
 	MetricsURL            = "/metrics"
+	VMMigrateURL          = "/vm/migrate"
+	VMSnapshotURL         = "/vm/snapshot"
+	VMRestoreURL          = "/vm/restore"
+	VMSnapshotDirKey      = "dir"
+	VMDumpURL             = "/vm/dump"
+	ProcessesURL          = "/processes"
+	ConsoleLogURL         = "/console-log"
+	ContainerIDKey        = "container"
 )
 
 var (
@@ -74,6 +83,11 @@ func (s *service) serveMetrics(w http.ResponseWriter, r *http.Request) {
 	// update metrics for shim process
 	updateShimMetrics()
 
+	// update metrics for the host resource usage of the sandbox
+	if stats, err := s.sandbox.Stats(context.Background()); err == nil {
+		setSandboxUsageMetrics(stats)
+	}
+
 	// metrics gathered by shim
 	mfs, err := prometheus.DefaultGatherer.Gather()
 	if err != nil {
@@ -226,6 +240,126 @@ func (s *service) policyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *service) vmMigrateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if err := s.sandbox.MigrateVM(context.Background()); err != nil {
+		shimMgtLog.WithError(err).Error("failed to migrate the VM")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte(""))
+}
+
+func (s *service) vmSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	s.genericVMSnapshotHandler(w, r, false)
+}
+
+func (s *service) vmRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	s.genericVMSnapshotHandler(w, r, true)
+}
+
+func (s *service) genericVMSnapshotHandler(w http.ResponseWriter, r *http.Request, restore bool) {
+	logger := shimMgtLog.WithFields(logrus.Fields{"handler": "vm-snapshot", "restore": restore})
+
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	dir := r.URL.Query().Get(VMSnapshotDirKey)
+	// The sandbox resolves the directory under the configured VM snapshot
+	// path, and rejects directories resolving outside of it.
+	if dir == "" || filepath.IsAbs(dir) {
+		msg := fmt.Sprintf("Required parameter %s must be a path relative to the VM snapshot path", VMSnapshotDirKey)
+		logger.Info(msg)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(msg))
+		return
+	}
+
+	var err error
+	if restore {
+		err = s.sandbox.RestoreVM(context.Background(), dir)
+	} else {
+		err = s.sandbox.SnapshotVM(context.Background(), dir)
+	}
+	if err != nil {
+		logger.WithError(err).WithField("dir", dir).Error("failed to snapshot or restore the VM")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte(""))
+}
+
+func (s *service) vmDumpHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	dir, err := s.sandbox.DumpGuest(context.Background())
+	if err != nil {
+		shimMgtLog.WithError(err).Error("failed to dump the guest memory")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write([]byte(dir))
+}
+
+// processesHandler returns the processes of a container running in the guest,
+// with their CPU and memory usage.
+func (s *service) processesHandler(w http.ResponseWriter, r *http.Request) {
+	containerID := r.URL.Query().Get(ContainerIDKey)
+	if containerID == "" {
+		msg := fmt.Sprintf("Required parameter %s not found", ContainerIDKey)
+		shimMgtLog.Info(msg)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(msg))
+		return
+	}
+
+	processes, err := s.sandbox.ListProcesses(context.Background(), containerID)
+	if err != nil {
+		shimMgtLog.WithError(err).WithField("container", containerID).Error("failed to list the guest processes")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	buf, err := json.Marshal(processes)
+	if err != nil {
+		shimMgtLog.WithError(err).Error("failed to marshal the guest processes")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write(buf)
+}
+
+// consoleLogHandler returns the guest console log of the sandbox.
+func (s *service) consoleLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	data, err := s.sandbox.ConsoleLog(context.Background())
+	if err != nil {
+		shimMgtLog.WithError(err).Error("failed to read the guest console log")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Write(data)
+}
+
 func (s *service) ip6TablesHandler(w http.ResponseWriter, r *http.Request) {
 	s.genericIPTablesHandler(w, r, true)
 }
@@ -295,6 +429,12 @@ func (s *service) startManagementServer(ctx context.Context, ociSpec *specs.Spec
 	m.Handle(IPTablesURL, http.HandlerFunc(s.ipTablesHandler))
 	m.Handle(PolicyURL, http.HandlerFunc(s.policyHandler))
 	m.Handle(IP6TablesURL, http.HandlerFunc(s.ip6TablesHandler))
+	m.Handle(VMMigrateURL, http.HandlerFunc(s.vmMigrateHandler))
+	m.Handle(VMSnapshotURL, http.HandlerFunc(s.vmSnapshotHandler))
+	m.Handle(VMRestoreURL, http.HandlerFunc(s.vmRestoreHandler))
+	m.Handle(VMDumpURL, http.HandlerFunc(s.vmDumpHandler))
+	m.Handle(ProcessesURL, http.HandlerFunc(s.processesHandler))
+	m.Handle(ConsoleLogURL, http.HandlerFunc(s.consoleLogHandler))
 	s.mountPprofHandle(m, ociSpec)
 
 	// register shim metrics
@@ -303,6 +443,9 @@ func (s *service) startManagementServer(ctx context.Context, ociSpec *specs.Spec
 	// register sandbox metrics
 	vc.RegisterMetrics()
 
+	// register OCI hook metrics
+	katautils.RegisterMetrics()
+
 	// start serve
 	svr := &http.Server{Handler: m}
 	svr.Serve(listener)
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/vcmock"
 
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,169 @@ go_threads 23
 	body = rr.Body.String()
 	assert.Equal(true, len(strings.Split(body, "\n")) > 0)
 }
+
+func TestVMSnapshotHandlers(t *testing.T) {
+	assert := assert.New(t)
+
+	var snapshotDir, restoreDir string
+	migrated := false
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		MigrateVMFunc: func() error {
+			migrated = true
+			return nil
+		},
+		SnapshotVMFunc: func(dir string) error {
+			snapshotDir = dir
+			return nil
+		},
+		RestoreVMFunc: func(dir string) error {
+			restoreDir = dir
+			return fmt.Errorf("restore failed")
+		},
+	}
+
+	s := &service{
+		id:         testSandboxID,
+		sandbox:    sandbox,
+		containers: make(map[string]*container),
+	}
+
+	rr := httptest.NewRecorder()
+	s.vmMigrateHandler(rr, httptest.NewRequest(http.MethodPut, VMMigrateURL, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.True(migrated)
+
+	rr = httptest.NewRecorder()
+	s.vmMigrateHandler(rr, httptest.NewRequest(http.MethodGet, VMMigrateURL, nil))
+	assert.Equal(http.StatusNotImplemented, rr.Code)
+
+	// The snapshot directory is required, and relative to the snapshot path
+	for _, query := range []string{"", "?dir=/snapshot"} {
+		rr = httptest.NewRecorder()
+		s.vmSnapshotHandler(rr, httptest.NewRequest(http.MethodPut, VMSnapshotURL+query, nil))
+		assert.Equal(http.StatusBadRequest, rr.Code)
+	}
+	assert.Empty(snapshotDir)
+
+	rr = httptest.NewRecorder()
+	s.vmSnapshotHandler(rr, httptest.NewRequest(http.MethodPut, VMSnapshotURL+"?dir=snapshot", nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("snapshot", snapshotDir)
+
+	rr = httptest.NewRecorder()
+	s.vmRestoreHandler(rr, httptest.NewRequest(http.MethodPut, VMRestoreURL+"?dir=snapshot", nil))
+	assert.Equal(http.StatusInternalServerError, rr.Code)
+	assert.Equal("snapshot", restoreDir)
+	assert.Equal("restore failed", rr.Body.String())
+}
+
+func TestVMDumpHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	dumpErr := fmt.Errorf("dump failed")
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		DumpGuestFunc: func() (string, error) {
+			if dumpErr != nil {
+				return "", dumpErr
+			}
+			return "/var/crash/kata/" + testSandboxID, nil
+		},
+	}
+
+	s := &service{
+		id:         testSandboxID,
+		sandbox:    sandbox,
+		containers: make(map[string]*container),
+	}
+
+	rr := httptest.NewRecorder()
+	s.vmDumpHandler(rr, httptest.NewRequest(http.MethodGet, VMDumpURL, nil))
+	assert.Equal(http.StatusNotImplemented, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.vmDumpHandler(rr, httptest.NewRequest(http.MethodPut, VMDumpURL, nil))
+	assert.Equal(http.StatusInternalServerError, rr.Code)
+	assert.Equal("dump failed", rr.Body.String())
+
+	dumpErr = nil
+	rr = httptest.NewRecorder()
+	s.vmDumpHandler(rr, httptest.NewRequest(http.MethodPut, VMDumpURL, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal("/var/crash/kata/"+testSandboxID, rr.Body.String())
+}
+
+func TestConsoleLogHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logErr := fmt.Errorf("the console log of the sandbox is disabled")
+	consoleLog := `{"time":"2026-01-01T00:00:00Z","line":"Linux version"}` + "\n"
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		ConsoleLogFunc: func() ([]byte, error) {
+			if logErr != nil {
+				return nil, logErr
+			}
+			return []byte(consoleLog), nil
+		},
+	}
+
+	s := &service{
+		id:         testSandboxID,
+		sandbox:    sandbox,
+		containers: make(map[string]*container),
+	}
+
+	rr := httptest.NewRecorder()
+	s.consoleLogHandler(rr, httptest.NewRequest(http.MethodPut, ConsoleLogURL, nil))
+	assert.Equal(http.StatusNotImplemented, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.consoleLogHandler(rr, httptest.NewRequest(http.MethodGet, ConsoleLogURL, nil))
+	assert.Equal(http.StatusInternalServerError, rr.Code)
+	assert.Equal(logErr.Error(), rr.Body.String())
+
+	logErr = nil
+	rr = httptest.NewRecorder()
+	s.consoleLogHandler(rr, httptest.NewRequest(http.MethodGet, ConsoleLogURL, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.Equal(consoleLog, rr.Body.String())
+}
+
+func TestProcessesHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	listErr := fmt.Errorf("invalid container id")
+	sandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+		ListProcessesFunc: func(contID string) ([]vc.GuestProcess, error) {
+			if listErr != nil {
+				return nil, listErr
+			}
+			return []vc.GuestProcess{{Pid: 1, State: "S", Cmd: []string{"sh"}, RSS: 4096}}, nil
+		},
+	}
+
+	s := &service{
+		id:         testSandboxID,
+		sandbox:    sandbox,
+		containers: make(map[string]*container),
+	}
+
+	rr := httptest.NewRecorder()
+	s.processesHandler(rr, httptest.NewRequest(http.MethodGet, ProcessesURL, nil))
+	assert.Equal(http.StatusBadRequest, rr.Code)
+
+	url := ProcessesURL + "?" + ContainerIDKey + "=" + testContainerID
+	rr = httptest.NewRecorder()
+	s.processesHandler(rr, httptest.NewRequest(http.MethodGet, url, nil))
+	assert.Equal(http.StatusInternalServerError, rr.Code)
+	assert.Equal("invalid container id", rr.Body.String())
+
+	listErr = nil
+	rr = httptest.NewRecorder()
+	s.processesHandler(rr, httptest.NewRequest(http.MethodGet, url, nil))
+	assert.Equal(http.StatusOK, rr.Code)
+	assert.JSONEq(`[{"state":"S","cmd":["sh"],"cpu_time_ns":0,"rss_bytes":4096,"start_time_ns":0,"threads":0,"pid":1,"ppid":0,"uid":0}]`, rr.Body.String())
+}
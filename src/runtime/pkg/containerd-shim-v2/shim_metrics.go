@@ -74,6 +74,46 @@ var (
 		Name:      "pod_overhead_memory_in_bytes",
 		Help:      "Kata Pod overhead for memory resources(bytes).",
 	})
+
+	katashimSandboxMemoryEvents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "sandbox_memory_events",
+		Help:      "Memory events of the sandbox cgroup on the host.",
+	},
+		[]string{"item"},
+	)
+
+	katashimSandboxOOMKills = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespaceKatashim,
+		Name:      "sandbox_oom_kills_total",
+		Help:      "Processes of the sandbox killed by the OOM killer.",
+	},
+		[]string{"source", "process"},
+	)
+
+	katashimSandboxMemoryPressure = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "sandbox_memory_pressure",
+		Help:      "Whether the sandbox is under memory pressure(1) or not(0).",
+	},
+		[]string{"source"},
+	)
+
+	katashimSandboxCPUTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "sandbox_cpu_time_seconds",
+		Help:      "Host CPU time used by the sandbox workload and by the Kata overhead, the split between both is approximate.",
+	},
+		[]string{"part"},
+	)
+
+	katashimSandboxMemoryUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "sandbox_memory_usage_bytes",
+		Help:      "Host memory used by the sandbox workload and by the Kata overhead, the split between both is approximate.",
+	},
+		[]string{"part"},
+	)
 )
 
 func registerMetrics() {
@@ -85,6 +125,11 @@ func registerMetrics() {
 	prometheus.MustRegister(katashimOpenFDs)
 	prometheus.MustRegister(katashimPodOverheadCPU)
 	prometheus.MustRegister(katashimPodOverheadMemory)
+	prometheus.MustRegister(katashimSandboxMemoryEvents)
+	prometheus.MustRegister(katashimSandboxOOMKills)
+	prometheus.MustRegister(katashimSandboxMemoryPressure)
+	prometheus.MustRegister(katashimSandboxCPUTime)
+	prometheus.MustRegister(katashimSandboxMemoryUsage)
 }
 
 // updateShimMetrics will update metrics for kata shim process itself
@@ -118,6 +163,28 @@ func updateShimMetrics() error {
 	return nil
 }
 
+// setSandboxMemoryEventsMetrics updates the metrics of the memory events of
+// the sandbox cgroup.
+func setSandboxMemoryEventsMetrics(events vc.SandboxMemoryEvents) {
+	katashimSandboxMemoryEvents.WithLabelValues("low").Set(float64(events.Low))
+	katashimSandboxMemoryEvents.WithLabelValues("high").Set(float64(events.High))
+	katashimSandboxMemoryEvents.WithLabelValues("max").Set(float64(events.Max))
+	katashimSandboxMemoryEvents.WithLabelValues("oom").Set(float64(events.OOM))
+	katashimSandboxMemoryEvents.WithLabelValues("oom_kill").Set(float64(events.OOMKill))
+}
+
+// setSandboxUsageMetrics updates the metrics of the host resource usage of the
+// sandbox workload, and of the Kata overhead when it is accounted apart.
+func setSandboxUsageMetrics(stats vc.SandboxStats) {
+	katashimSandboxCPUTime.WithLabelValues("workload").Set(float64(stats.Workload.CPUTime) / float64(time.Second))
+	katashimSandboxMemoryUsage.WithLabelValues("workload").Set(float64(stats.Workload.Memory))
+
+	if stats.Overhead != nil {
+		katashimSandboxCPUTime.WithLabelValues("overhead").Set(float64(stats.Overhead.CPUTime) / float64(time.Second))
+		katashimSandboxMemoryUsage.WithLabelValues("overhead").Set(float64(stats.Overhead.Memory))
+	}
+}
+
 // statsSandbox returns a detailed sandbox stats.
 func (s *service) statsSandbox(ctx context.Context) (vc.SandboxStats, []vc.ContainerStats, error) {
 	sandboxStats, err := s.sandbox.Stats(ctx)
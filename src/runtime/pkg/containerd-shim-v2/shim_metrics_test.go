@@ -12,6 +12,8 @@ import (
 	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/vcmock"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,3 +115,34 @@ func TestStatsSandbox(t *testing.T) {
 	//       = 50000
 	assert.Equal(float64(50000), mem)
 }
+
+func TestSetSandboxUsageMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	katashimSandboxCPUTime.Reset()
+	katashimSandboxMemoryUsage.Reset()
+
+	// The overhead is not accounted apart
+	setSandboxUsageMetrics(vc.SandboxStats{
+		Workload: vc.SandboxResourceUsage{CPUTime: 3e9, Memory: 1 << 30},
+	})
+	assert.Equal(float64(3), gaugeValue(t, katashimSandboxCPUTime.WithLabelValues("workload")))
+	assert.Equal(float64(1<<30), gaugeValue(t, katashimSandboxMemoryUsage.WithLabelValues("workload")))
+	ch := make(chan prometheus.Metric, 2)
+	katashimSandboxCPUTime.Collect(ch)
+	assert.Len(ch, 1)
+
+	setSandboxUsageMetrics(vc.SandboxStats{
+		Workload: vc.SandboxResourceUsage{CPUTime: 4e9, Memory: 1 << 30},
+		Overhead: &vc.SandboxResourceUsage{CPUTime: 5e8, Memory: 64 << 20},
+	})
+	assert.Equal(float64(4), gaugeValue(t, katashimSandboxCPUTime.WithLabelValues("workload")))
+	assert.Equal(float64(0.5), gaugeValue(t, katashimSandboxCPUTime.WithLabelValues("overhead")))
+	assert.Equal(float64(64<<20), gaugeValue(t, katashimSandboxMemoryUsage.WithLabelValues("overhead")))
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	var m dto.Metric
+	assert.NoError(t, g.Write(&m))
+	return m.GetGauge().GetValue()
+}
@@ -62,6 +62,12 @@ func startContainer(ctx context.Context, s *service, c *container) (retErr error
 		// We use s.ctx(`ctx` derived from `s.ctx`) to check for cancellation of the
 		// shim context and the context passed to startContainer for tracing.
 		go watchOOMEvents(ctx, s)
+
+		go watchRightsizingEvents(s)
+
+		go watchHypervisorEvents(s)
+
+		go watchMemoryPressure(s)
 	} else {
 		_, err := s.sandbox.StartContainer(ctx, c.id)
 		if err != nil {
@@ -71,7 +77,7 @@ func startContainer(ctx context.Context, s *service, c *container) (retErr error
 
 	// Run post-start OCI hooks.
 	err := katautils.EnterNetNS(s.sandbox.GetNetNs(), func() error {
-		return katautils.PostStartHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle)
+		return katautils.PostStartHooks(ctx, s.hookPolicy(), *c.spec, s.sandbox.ID(), c.bundle)
 	})
 	if err != nil {
 		// log warning and continue, as defined in oci runtime spec
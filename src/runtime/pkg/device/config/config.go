@@ -34,6 +34,9 @@ const (
 	// DeviceGeneric is a generic device type
 	DeviceGeneric DeviceType = "generic"
 
+	// DeviceVhostVDPA is the vhost-vdpa device type
+	DeviceVhostVDPA DeviceType = "vhost-vdpa"
+
 	//VhostUserSCSI - SCSI based vhost-user type
 	VhostUserSCSI = "vhost-user-scsi-pci"
 
@@ -509,9 +512,43 @@ type VhostUserDeviceAttrs struct {
 	ReconnectTime uint32
 }
 
+const (
+	// VirtioNetID is the virtio device ID of network devices
+	VirtioNetID uint32 = 1
+
+	// VirtioBlockID is the virtio device ID of block devices
+	VirtioBlockID uint32 = 2
+)
+
+// VhostVDPADeviceAttrs represents a vDPA device given to the guest through
+// its vhost-vdpa character device
+type VhostVDPADeviceAttrs struct {
+	DevID string
+
+	// VhostDevPath is the path of the vhost-vdpa character device on the host
+	VhostDevPath string
+
+	// MacAddress is only meaningful for network devices
+	MacAddress string
+
+	// PCIPath is the PCI path used to identify the slot at which
+	// the device is attached.
+	PCIPath vcTypes.PciPath
+
+	// VirtioID is the virtio device ID of the device, like VirtioNetID
+	VirtioID uint32
+
+	// NumQueues is the number of virtqueues of the device
+	NumQueues uint32
+}
+
 // GetHostPathFunc is function pointer used to mock GetHostPath in tests.
 var GetHostPathFunc = GetHostPath
 
+// GetVhostVDPADeviceInfoFunc is function pointer used to mock
+// GetVhostVDPADeviceInfo in tests.
+var GetVhostVDPADeviceInfoFunc = GetVhostVDPADeviceInfo
+
 // GetVhostUserNodeStatFunc is function pointer used to mock GetVhostUserNodeStat
 // in tests. Through this functon, user can get device type information.
 var GetVhostUserNodeStatFunc = GetVhostUserNodeStat
@@ -660,6 +697,9 @@ type DeviceState struct {
 	// BlockDrive is specific for block device driver
 	BlockDrive *BlockDrive `json:",omitempty"`
 
+	// VhostVDPADev is specific for vhost-vdpa device driver
+	VhostVDPADev *VhostVDPADeviceAttrs `json:",omitempty"`
+
 	ID string
 
 	// Type is used to specify driver type
@@ -12,6 +12,13 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// VHOST_VDPA_GET_DEVICE_ID and VHOST_VDPA_GET_VQS_COUNT from
+	// include/uapi/linux/vhost.h
+	vhostVDPAGetDeviceID = 0x8004AF70
+	vhostVDPAGetVQsCount = 0x8004AF80
+)
+
 // BlockDeviceIsReadOnly queries the host block device at path for its
 // read-only flag (BLKROGET). This reflects the device's actual writability,
 // which is the ground truth for whether the guest should see it read-only:
@@ -35,3 +42,24 @@ func BlockDeviceIsReadOnly(path string) (bool, error) {
 
 	return ro != 0, nil
 }
+
+// GetVhostVDPADeviceInfo queries the vhost-vdpa character device at path for
+// the virtio device ID of the vDPA device behind it, like VirtioNetID, and for
+// its number of virtqueues.
+func GetVhostVDPADeviceInfo(path string) (virtioID uint32, numQueues uint32, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	if virtioID, err = unix.IoctlGetUint32(int(f.Fd()), vhostVDPAGetDeviceID); err != nil {
+		return 0, 0, err
+	}
+
+	if numQueues, err = unix.IoctlGetUint32(int(f.Fd()), vhostVDPAGetVQsCount); err != nil {
+		return 0, 0, err
+	}
+
+	return virtioID, numQueues, nil
+}
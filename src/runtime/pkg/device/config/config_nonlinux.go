@@ -16,3 +16,9 @@ import "fmt"
 func BlockDeviceIsReadOnly(path string) (bool, error) {
 	return false, fmt.Errorf("BlockDeviceIsReadOnly is not supported on this platform")
 }
+
+// GetVhostVDPADeviceInfo is only meaningful on Linux, where vhost-vdpa devices
+// are available.
+func GetVhostVDPADeviceInfo(path string) (uint32, uint32, error) {
+	return 0, 0, fmt.Errorf("GetVhostVDPADeviceInfo is not supported on this platform")
+}
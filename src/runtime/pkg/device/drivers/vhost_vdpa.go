@@ -0,0 +1,134 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package drivers
+
+import (
+	"context"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/api"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// VhostVDPADevice is a vDPA device passed through its vhost-vdpa character
+// device, like /dev/vhost-vdpa-0
+type VhostVDPADevice struct {
+	*GenericDevice
+	VhostVDPADeviceAttrs *config.VhostVDPADeviceAttrs
+}
+
+// NewVhostVDPADevice creates a new vhost-vdpa device based on DeviceInfo
+func NewVhostVDPADevice(devInfo *config.DeviceInfo) *VhostVDPADevice {
+	return &VhostVDPADevice{
+		GenericDevice: &GenericDevice{
+			ID:         devInfo.ID,
+			DeviceInfo: devInfo,
+		},
+	}
+}
+
+//
+// VhostVDPADevice's implementation of the device interface:
+//
+
+// Attach is standard interface of api.Device, it's used to add device to some
+// DeviceReceiver
+func (device *VhostVDPADevice) Attach(ctx context.Context, devReceiver api.DeviceReceiver) (err error) {
+	skip, err := device.bumpAttachCount(true)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			device.bumpAttachCount(false)
+		}
+	}()
+
+	virtioID, numQueues, err := config.GetVhostVDPADeviceInfoFunc(device.DeviceInfo.HostPath)
+	if err != nil {
+		return err
+	}
+
+	vAttrs := &config.VhostVDPADeviceAttrs{
+		DevID:        utils.MakeNameID("vdpa", device.DeviceInfo.ID, maxDevIDSize),
+		VhostDevPath: device.DeviceInfo.HostPath,
+		VirtioID:     virtioID,
+		NumQueues:    numQueues,
+	}
+
+	deviceLogger().WithFields(logrus.Fields{
+		"device":    device.DeviceInfo.HostPath,
+		"virtio-id": virtioID,
+		"queues":    numQueues,
+	}).Info("Attaching device")
+
+	device.VhostVDPADeviceAttrs = vAttrs
+	if err = devReceiver.HotplugAddDevice(ctx, device, config.DeviceVhostVDPA); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Detach is standard interface of api.Device, it's used to remove device from some
+// DeviceReceiver
+func (device *VhostVDPADevice) Detach(ctx context.Context, devReceiver api.DeviceReceiver) (err error) {
+	skip, err := device.bumpAttachCount(false)
+	if err != nil {
+		return err
+	}
+	if skip {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			device.bumpAttachCount(true)
+		}
+	}()
+
+	deviceLogger().WithField("device", device.DeviceInfo.HostPath).Info("Unplugging vhost-vdpa device")
+
+	if err = devReceiver.HotplugRemoveDevice(ctx, device, config.DeviceVhostVDPA); err != nil {
+		deviceLogger().WithError(err).Error("Failed to unplug vhost-vdpa device")
+		return err
+	}
+	return nil
+}
+
+// DeviceType is standard interface of api.Device, it returns device type
+func (device *VhostVDPADevice) DeviceType() config.DeviceType {
+	return config.DeviceVhostVDPA
+}
+
+// GetDeviceInfo returns device information used for creating
+func (device *VhostVDPADevice) GetDeviceInfo() interface{} {
+	return device.VhostVDPADeviceAttrs
+}
+
+// Save converts Device to DeviceState
+func (device *VhostVDPADevice) Save() config.DeviceState {
+	ds := device.GenericDevice.Save()
+	ds.Type = string(device.DeviceType())
+	ds.VhostVDPADev = device.VhostVDPADeviceAttrs
+
+	return ds
+}
+
+// Load loads DeviceState and converts it to specific device
+func (device *VhostVDPADevice) Load(ds config.DeviceState) {
+	device.GenericDevice = &GenericDevice{}
+	device.GenericDevice.Load(ds)
+	device.VhostVDPADeviceAttrs = ds.VhostVDPADev
+}
+
+// It should implement GetAttachCount() and DeviceID() as api.Device implementation
+// here it shares function from *GenericDevice so we don't need duplicate codes
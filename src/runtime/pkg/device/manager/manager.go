@@ -137,6 +137,8 @@ func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device
 		devInfo.DriverOptions[config.BlockDriverOpt] = dm.blockDriver
 		devInfo.DriverOptions[config.VhostUserReconnectTimeOutOpt] = fmt.Sprintf("%d", dm.vhostUserReconnectTimeout)
 		return drivers.NewVhostUserBlkDevice(&devInfo), nil
+	} else if IsVhostVDPADevice(devInfo) {
+		return drivers.NewVhostVDPADevice(&devInfo), nil
 	} else if isBlock(devInfo) {
 		if devInfo.DriverOptions == nil {
 			devInfo.DriverOptions = make(map[string]string)
@@ -278,6 +280,8 @@ func (dm *deviceManager) LoadDevices(devStates []config.DeviceState) {
 			dev = &drivers.VhostUserBlkDevice{}
 		case config.VhostUserNet:
 			dev = &drivers.VhostUserNetDevice{}
+		case config.DeviceVhostVDPA:
+			dev = &drivers.VhostVDPADevice{}
 		default:
 			deviceLogger().WithField("device-type", ds.Type).Warning("unrecognized device type is detected")
 			// continue the for loop
@@ -303,6 +303,49 @@ func TestAttachBlockDevice(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAttachVhostVDPADevice(t *testing.T) {
+	dm := &deviceManager{
+		devices: make(map[string]api.Device),
+	}
+	path := "/dev/vhost-vdpa-0"
+	deviceInfo := config.DeviceInfo{
+		HostPath:      path,
+		ContainerPath: path,
+		DevType:       "c",
+	}
+
+	savedFunc := config.GetVhostVDPADeviceInfoFunc
+	defer func() {
+		config.GetVhostVDPADeviceInfoFunc = savedFunc
+	}()
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return config.VirtioBlockID, 1, nil
+	}
+
+	device, err := dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	vdpaDevice, ok := device.(*drivers.VhostVDPADevice)
+	assert.True(t, ok)
+
+	devReceiver := &api.MockDeviceReceiver{}
+	err = device.Attach(context.Background(), devReceiver)
+	assert.Nil(t, err)
+	assert.Equal(t, path, vdpaDevice.VhostVDPADeviceAttrs.VhostDevPath)
+	assert.Equal(t, config.VirtioBlockID, vdpaDevice.VhostVDPADeviceAttrs.VirtioID)
+	assert.Equal(t, uint32(1), vdpaDevice.VhostVDPADeviceAttrs.NumQueues)
+
+	dm2 := &deviceManager{
+		devices: make(map[string]api.Device),
+	}
+	dm2.LoadDevices([]config.DeviceState{device.Save()})
+	loaded, ok := dm2.devices[device.DeviceID()].(*drivers.VhostVDPADevice)
+	assert.True(t, ok)
+	assert.Equal(t, vdpaDevice.VhostVDPADeviceAttrs, loaded.VhostVDPADeviceAttrs)
+
+	err = device.Detach(context.Background(), devReceiver)
+	assert.Nil(t, err)
+}
+
 func TestAttachDetachDevice(t *testing.T) {
 	dm := NewDeviceManager(config.VirtioSCSI, false, "", 0, nil)
 
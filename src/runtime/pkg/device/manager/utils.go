@@ -15,6 +15,8 @@ import (
 
 const (
 	vfioPath = "/dev/vfio/"
+
+	vhostVDPADevPrefix = "vhost-vdpa-"
 )
 
 // IsVFIOControlDevice checks if the device provided is a vfio control device.
@@ -52,3 +54,8 @@ func IsVhostUserBlk(devInfo config.DeviceInfo) bool {
 func isVhostUserSCSI(devInfo config.DeviceInfo) bool {
 	return devInfo.DevType == "b" && devInfo.Major == config.VhostUserSCSIMajor
 }
+
+// IsVhostVDPADevice checks if the device is a vhost-vdpa character device.
+func IsVhostVDPADevice(devInfo config.DeviceInfo) bool {
+	return devInfo.DevType == "c" && strings.HasPrefix(filepath.Base(devInfo.HostPath), vhostVDPADevPrefix)
+}
@@ -103,3 +103,28 @@ func TestIsVhostUserSCSI(t *testing.T) {
 		assert.Equal(t, d.expected, isVhostUserSCSI)
 	}
 }
+
+func TestIsVhostVDPADevice(t *testing.T) {
+	type testData struct {
+		devType  string
+		hostPath string
+		expected bool
+	}
+
+	data := []testData{
+		{"c", "/dev/vhost-vdpa-0", true},
+		{"c", "/dev/vhost-vdpa-12", true},
+		{"b", "/dev/vhost-vdpa-0", false},
+		{"c", "/dev/vhost-net", false},
+		{"c", "/dev/vfio/1", false},
+	}
+
+	for _, d := range data {
+		isVhostVDPA := IsVhostVDPADevice(
+			config.DeviceInfo{
+				DevType:  d.devType,
+				HostPath: d.hostPath,
+			})
+		assert.Equal(t, d.expected, isVhostVDPA)
+	}
+}
@@ -148,6 +148,9 @@ const (
 	// VHostVSockPCI is a generic Vsock vhost device with PCI transport.
 	VHostVSockPCI DeviceDriver = "vhost-vsock-pci"
 
+	// VhostVDPAPCI is the generic vhost-vdpa device with PCI transport.
+	VhostVDPAPCI DeviceDriver = "vhost-vdpa-device-pci"
+
 	// PXBPCIe is a PCIe Expander Bridge that creates a new PCI root
 	// complex with NUMA node affinity.
 	PXBPCIe DeviceDriver = "pxb-pcie"
@@ -1489,6 +1492,55 @@ func (dev PVPanicDevice) QemuParams(config *Config) []string {
 	return []string{"-device", "pvpanic"}
 }
 
+// VhostVDPADevice represents a qemu vhost-vdpa device. It exposes the virtio
+// device of a vDPA device to the guest, whatever its type.
+type VhostVDPADevice struct {
+	// ID is the device identifier.
+	ID string
+
+	// VhostDev is the path of the vhost-vdpa character device on the host.
+	VhostDev string
+
+	// Bus is the bus the device is plugged to.
+	Bus string
+
+	// Addr is the slot of the device on Bus.
+	Addr string
+
+	// ROMFile specifies the ROM file being used for this device.
+	ROMFile string
+}
+
+// Valid returns true if the VhostVDPADevice structure is valid and complete.
+func (dev VhostVDPADevice) Valid() bool {
+	return dev.ID != "" && dev.VhostDev != ""
+}
+
+// QemuParams returns the qemu parameters built out of this vhost-vdpa device.
+func (dev VhostVDPADevice) QemuParams(config *Config) []string {
+	deviceParams := []string{
+		string(VhostVDPAPCI),
+		fmt.Sprintf("vhostdev=%s", dev.VhostDev),
+		fmt.Sprintf("id=%s", dev.ID),
+	}
+
+	if dev.Bus != "" {
+		deviceParams = append(deviceParams, fmt.Sprintf("bus=%s", dev.Bus))
+	} else if hasPCIeRoot(config) {
+		// Pin to pcie.0 (when present) so pxb-pcie can't capture
+		// this leaf device.  See hasPCIeRoot() for skipped machines.
+		deviceParams = append(deviceParams, "bus=pcie.0")
+	}
+	if dev.Addr != "" {
+		deviceParams = append(deviceParams, fmt.Sprintf("addr=%s", dev.Addr))
+	}
+	if dev.ROMFile != "" {
+		deviceParams = append(deviceParams, fmt.Sprintf("romfile=%s", dev.ROMFile))
+	}
+
+	return []string{"-device", strings.Join(deviceParams, ",")}
+}
+
 // LoaderDevice represents a qemu loader device.
 type LoaderDevice struct {
 	File string
@@ -1456,6 +1456,22 @@ func TestAppendPVPanicDevice(t *testing.T) {
 	}
 }
 
+func TestAppendVhostVDPADevice(t *testing.T) {
+	testCases := []struct {
+		dev Device
+		out string
+	}{
+		{VhostVDPADevice{ID: "vdpa0", VhostDev: "/dev/vhost-vdpa-0"}, "-device vhost-vdpa-device-pci,vhostdev=/dev/vhost-vdpa-0,id=vdpa0"},
+		{VhostVDPADevice{ID: "vdpa0", VhostDev: "/dev/vhost-vdpa-0", Bus: "rp0", Addr: "0x0", ROMFile: "efi-virtio.rom"}, "-device vhost-vdpa-device-pci,vhostdev=/dev/vhost-vdpa-0,id=vdpa0,bus=rp0,addr=0x0,romfile=efi-virtio.rom"},
+	}
+
+	for _, tc := range testCases {
+		testAppend(tc.dev, tc.out, t)
+	}
+
+	testAppendQ35(VhostVDPADevice{ID: "vdpa0", VhostDev: "/dev/vhost-vdpa-0"}, "-device vhost-vdpa-device-pci,vhostdev=/dev/vhost-vdpa-0,id=vdpa0,bus=pcie.0", t)
+}
+
 func TestLoaderDevice(t *testing.T) {
 	testCases := []struct {
 		dev Device
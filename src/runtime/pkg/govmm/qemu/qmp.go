@@ -1111,6 +1111,31 @@ func (q *QMP) ExecuteNetCCWDeviceAdd(ctx context.Context, netdevID, devID, macAd
 	return q.executeCommand(ctx, "device_add", args, nil)
 }
 
+// ExecuteNetDeviceAdd adds a network device of type driver to a QEMU compatible
+// instance using the device_add command. devID is the id of the device to add.
+// Must be valid QMP identifier. netdevID is the id of nic added by previous netdev_add.
+// Unlike ExecuteNetPCIDeviceAdd, only the given arguments are passed, both
+// bus and addr are optional.
+func (q *QMP) ExecuteNetDeviceAdd(ctx context.Context, netdevID, devID, driver, macAddr, addr, bus string) error {
+	args := map[string]interface{}{
+		"id":     devID,
+		"driver": driver,
+		"netdev": netdevID,
+	}
+
+	if macAddr != "" {
+		args["mac"] = macAddr
+	}
+	if bus != "" {
+		args["bus"] = bus
+	}
+	if addr != "" {
+		args["addr"] = addr
+	}
+
+	return q.executeCommand(ctx, "device_add", args, nil)
+}
+
 // ExecuteDeviceDel deletes guest portion of a QEMU device by sending a
 // device_del command.   devId is the identifier of the device to delete.
 // Typically it would match the devID parameter passed to an earlier call
@@ -1271,6 +1296,27 @@ func (q *QMP) ExecutePCIVFIODeviceAdd(ctx context.Context, devID, bdf, addr, bus
 	return q.executeCommand(ctx, "device_add", args, nil)
 }
 
+// ExecuteVhostVDPADeviceAdd adds a vhost-vdpa device to a QEMU instance using
+// the device_add command. devID is the id of the device to add. Must be valid QMP
+// identifier. vhostdev is the path of the vhost-vdpa character device on the host.
+// Both bus and addr are optional.
+func (q *QMP) ExecuteVhostVDPADeviceAdd(ctx context.Context, devID, vhostdev, addr, bus, romfile string) error {
+	args := map[string]interface{}{
+		"id":       devID,
+		"driver":   VhostVDPAPCI,
+		"vhostdev": vhostdev,
+		"romfile":  romfile,
+	}
+
+	if bus != "" {
+		args["bus"] = bus
+	}
+	if addr != "" {
+		args["addr"] = addr
+	}
+	return q.executeCommand(ctx, "device_add", args, nil)
+}
+
 // ExecutePCIVFIOMediatedDeviceAdd adds a VFIO mediated device to a QEMU instance using the device_add command.
 // This function can be used to hot plug VFIO mediated devices on PCI(E) bridges or root bus, unlike
 // ExecuteVFIODeviceAdd this function receives the bus and the device address on its parent bus.
@@ -1351,6 +1397,20 @@ func (q *QMP) ExecuteCPUDeviceAdd(ctx context.Context, driver, cpuID string, soc
 	return q.executeCommand(ctx, "device_add", args, nil)
 }
 
+// ExecuteGenericCPUDeviceAdd adds a CPU using the device_add command to a VMM
+// identifying the CPUs by index rather than by topology, like StratoVirt.
+// driver is the CPU model, devID must be a unique ID to identify the CPU device
+// and cpuID is the index of the CPU to add.
+func (q *QMP) ExecuteGenericCPUDeviceAdd(ctx context.Context, driver, devID string, cpuID int) error {
+	args := map[string]interface{}{
+		"driver": driver,
+		"id":     devID,
+		"cpu-id": cpuID,
+	}
+
+	return q.executeCommand(ctx, "device_add", args, nil)
+}
+
 // ExecuteQueryHotpluggableCPUs returns a slice with the list of hotpluggable CPUs
 func (q *QMP) ExecuteQueryHotpluggableCPUs(ctx context.Context) ([]HotpluggableCPU, error) {
 	response, err := q.executeCommandWithResponse(ctx, "query-hotpluggable-cpus", nil, nil, nil)
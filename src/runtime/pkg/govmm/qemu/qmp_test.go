@@ -636,6 +636,28 @@ func TestQMPNetPCIDeviceAdd(t *testing.T) {
 	<-disconnectedCh
 }
 
+func TestQMPNetDeviceAdd(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("device_add", map[string]interface{}{
+		"id":     "virtio-0",
+		"driver": "virtio-net-mmio",
+		"netdev": "br0",
+		"mac":    "02:42:ac:11:00:02",
+		"addr":   "1",
+	}, "return", nil)
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	checkVersion(t, connectedCh)
+	err := q.ExecuteNetDeviceAdd(context.Background(), "br0", "virtio-0", "virtio-net-mmio", "02:42:ac:11:00:02", "1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
+
 func TestQMPNetCCWDeviceAdd(t *testing.T) {
 	connectedCh := make(chan *QMPVersion)
 	disconnectedCh := make(chan struct{})
@@ -1190,6 +1212,23 @@ func TestQMPPCIVFIOMediatedDeviceAdd(t *testing.T) {
 	<-disconnectedCh
 }
 
+func TestQMPVhostVDPADeviceAdd(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("device_add", nil, "return", nil)
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	checkVersion(t, connectedCh)
+	devID := fmt.Sprintf("device_%s", volumeUUID)
+	err := q.ExecuteVhostVDPADeviceAdd(context.Background(), devID, "/dev/vhost-vdpa-0", "0x1", "", "")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
+
 func TestQMPPCIVFIOPCIeDeviceAdd(t *testing.T) {
 	connectedCh := make(chan *QMPVersion)
 	disconnectedCh := make(chan struct{})
@@ -1298,6 +1337,26 @@ func TestQMPCPUDeviceAdd(t *testing.T) {
 	}
 }
 
+func TestQMPGenericCPUDeviceAdd(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("device_add", map[string]interface{}{
+		"driver": "generic-x86-cpu",
+		"id":     "cpu-0",
+		"cpu-id": float64(2),
+	}, "return", nil)
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	checkVersion(t, connectedCh)
+	err := q.ExecuteGenericCPUDeviceAdd(context.Background(), "generic-x86-cpu", "cpu-0", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
+
 // Checks that hotpluggable CPUs are listed correctly
 func TestQMPExecuteQueryHotpluggableCPUs(t *testing.T) {
 	connectedCh := make(chan *QMPVersion)
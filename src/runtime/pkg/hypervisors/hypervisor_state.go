@@ -43,6 +43,7 @@ type HypervisorState struct {
 	HotpluggedVCPUs []CPUDevice
 
 	HotpluggedMemory  int
+	BalloonMemory     int
 	VirtiofsDaemonPid int
 	Pid               int
 	HotPlugVFIO       config.PCIePort
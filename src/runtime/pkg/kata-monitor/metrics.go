@@ -217,24 +217,7 @@ func (km *KataMonitor) aggregateSandboxMetrics(encoder expfmt.Encoder, filterFam
 		return nil
 	}
 
-	// metricsMap used to aggregate metrics from multiple sandboxes
-	// key is MetricFamily.Name, and value is list of MetricFamily from multiple sandboxes
-	metricsMap := make(map[string]*dto.MetricFamily)
-	// merge MetricFamily list for the same MetricFamily.Name from multiple sandboxes.
-	for i := range sandboxMetricsList {
-		sandboxMetrics := sandboxMetricsList[i]
-		for j := range sandboxMetrics {
-			mf := sandboxMetrics[j]
-			key := *mf.Name
-
-			// add MetricFamily.Metric to the exists MetricFamily instance
-			if oldmf, found := metricsMap[key]; found {
-				oldmf.Metric = append(oldmf.Metric, mf.Metric...)
-			} else {
-				metricsMap[key] = mf
-			}
-		}
-	}
+	metricsMap := mergeSandboxMetrics(sandboxMetricsList)
 
 	// write metrics to response.
 	if len(filterFamilies) > 0 {
@@ -258,6 +241,30 @@ func (km *KataMonitor) aggregateSandboxMetrics(encoder expfmt.Encoder, filterFam
 
 }
 
+// mergeSandboxMetrics aggregates the metrics from multiple sandboxes. The
+// key of the returned map is MetricFamily.Name, and its value holds the
+// metrics of all of the sandboxes, told apart by their sandbox_id label.
+func mergeSandboxMetrics(sandboxMetricsList [][]*dto.MetricFamily) map[string]*dto.MetricFamily {
+	metricsMap := make(map[string]*dto.MetricFamily)
+	// merge MetricFamily list for the same MetricFamily.Name from multiple sandboxes.
+	for i := range sandboxMetricsList {
+		sandboxMetrics := sandboxMetricsList[i]
+		for j := range sandboxMetrics {
+			mf := sandboxMetrics[j]
+			key := *mf.Name
+
+			// add MetricFamily.Metric to the exists MetricFamily instance
+			if oldmf, found := metricsMap[key]; found {
+				oldmf.Metric = append(oldmf.Metric, mf.Metric...)
+			} else {
+				metricsMap[key] = mf
+			}
+		}
+	}
+
+	return metricsMap
+}
+
 func getParsedMetrics(sandboxID string, sandboxMetadata sandboxCRIMetadata) ([]*dto.MetricFamily, error) {
 	body, err := shimclient.DoGet(sandboxID, defaultTimeout, containerdshim.MetricsURL)
 	if err != nil {
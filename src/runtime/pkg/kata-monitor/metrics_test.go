@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/assert"
 )
@@ -147,3 +148,38 @@ func TestEncodeMetricFamily(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeSandboxMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	deviceCountersBody := `# HELP kata_hypervisor_device_counters Cloud Hypervisor device counters.
+# TYPE kata_hypervisor_device_counters gauge
+kata_hypervisor_device_counters{device="_disk0",item="read_bytes",type="block"} 4096
+kata_hypervisor_device_counters{device="_net1",item="rx_frames",type="net"} 10
+`
+
+	var sandboxMetricsList [][]*dto.MetricFamily
+	for _, sandboxID := range []string{"sandbox-a", "sandbox-b"} {
+		list, err := parsePrometheusMetrics(sandboxID, sandboxCRIMetadata{"123", "pod-name", "pod-namespace"}, []byte(deviceCountersBody+shimMetricBody))
+		assert.NoError(err)
+		sandboxMetricsList = append(sandboxMetricsList, list)
+	}
+
+	metricsMap := mergeSandboxMetrics(sandboxMetricsList)
+	assert.Len(metricsMap, 5)
+
+	mf, ok := metricsMap["kata_hypervisor_device_counters"]
+	assert.True(ok)
+	// two devices for each sandbox
+	assert.Len(mf.Metric, 4)
+
+	sandboxes := make(map[string]int)
+	for _, m := range mf.Metric {
+		for _, label := range m.Label {
+			if label.GetName() == "sandbox_id" {
+				sandboxes[label.GetValue()]++
+			}
+		}
+	}
+	assert.Equal(map[string]int{"sandbox-a": 2, "sandbox-b": 2}, sandboxes)
+}
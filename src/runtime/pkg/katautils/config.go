@@ -10,11 +10,14 @@ package katautils
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
 	goruntime "runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
@@ -70,6 +73,7 @@ const (
 type tomlConfig struct {
 	Hypervisor map[string]hypervisor
 	Agent      map[string]agent
+	Profile    map[string]map[string]interface{}
 	Factory    factory
 	Runtime    runtime
 }
@@ -90,6 +94,15 @@ type hypervisor struct {
 	RootfsType                     string                    `toml:"rootfs_type"`
 	Firmware                       string                    `toml:"firmware"`
 	FirmwareVolume                 string                    `toml:"firmware_volume"`
+	HypervisorDigest               string                    `toml:"hypervisor_digest"`
+	JailerDigest                   string                    `toml:"jailer_digest"`
+	KernelDigest                   string                    `toml:"kernel_digest"`
+	InitrdDigest                   string                    `toml:"initrd_digest"`
+	ImageDigest                    string                    `toml:"image_digest"`
+	FirmwareDigest                 string                    `toml:"firmware_digest"`
+	FirmwareVolumeDigest           string                    `toml:"firmware_volume_digest"`
+	AssetManifest                  string                    `toml:"asset_manifest"`
+	AssetManifestPublicKey         string                    `toml:"asset_manifest_public_key"`
 	MachineAccelerators            string                    `toml:"machine_accelerators"`
 	CPUFeatures                    string                    `toml:"cpu_features"`
 	KernelParams                   string                    `toml:"kernel_params"`
@@ -103,7 +116,10 @@ type hypervisor struct {
 	VirtioFSCache                  string                    `toml:"virtio_fs_cache"`
 	VhostUserStorePath             string                    `toml:"vhost_user_store_path"`
 	GuestHookPath                  string                    `toml:"guest_hook_path"`
+	GuestHooksDir                  string                    `toml:"guest_hooks_dir"`
 	GuestMemoryDumpPath            string                    `toml:"guest_memory_dump_path"`
+	VMSnapshotPath                 string                    `toml:"vm_snapshot_path"`
+	ConsoleLogPath                 string                    `toml:"console_log_path"`
 	SeccompSandbox                 string                    `toml:"seccompsandbox"`
 	BlockDeviceAIO                 string                    `toml:"block_device_aio"`
 	RemoteHypervisorSocket         string                    `toml:"remote_hypervisor_socket"`
@@ -111,12 +127,14 @@ type hypervisor struct {
 	SnpIdAuth                      string                    `toml:"snp_id_auth"`
 	SnpGuestPolicy                 *uint64                   `toml:"snp_guest_policy"`
 	MeasurementAlgo                string                    `toml:"measurement_algo"`
+	MmdsIPv4Address                string                    `toml:"mmds_ipv4_address"`
 	HypervisorPathList             []string                  `toml:"valid_hypervisor_paths"`
 	JailerPathList                 []string                  `toml:"valid_jailer_paths"`
 	VirtioFSDaemonList             []string                  `toml:"valid_virtio_fs_daemon_paths"`
 	VirtioFSExtraArgs              []string                  `toml:"virtio_fs_extra_args"`
 	PFlashList                     []string                  `toml:"pflashes"`
 	VhostUserStorePathList         []string                  `toml:"valid_vhost_user_store_paths"`
+	GuestHooksPathList             []string                  `toml:"valid_guest_hook_paths"`
 	EntropySourceList              []string                  `toml:"valid_entropy_sources"`
 	EnableAnnotations              []string                  `toml:"enable_annotations"`
 	RxRateLimiterMaxRate           uint64                    `toml:"rx_rate_limiter_max_rate"`
@@ -157,10 +175,15 @@ type hypervisor struct {
 	IOMMUPlatform                  bool                      `toml:"enable_iommu_platform"`
 	NUMA                           bool                      `toml:"enable_numa"`
 	NUMAMapping                    []string                  `toml:"numa_mapping"`
+	MmdsMetadata                   []string                  `toml:"mmds_metadata"`
 	Debug                          bool                      `toml:"enable_debug"`
 	DisableNestingChecks           bool                      `toml:"disable_nesting_checks"`
 	EnableIOThreads                bool                      `toml:"enable_iothreads"`
 	IndepIOThreads                 uint32                    `toml:"indep_iothreads"`
+	GuestMemoryDumpMaxCount        uint32                    `toml:"guest_memory_dump_max_count"`
+	GuestMemoryDumpMaxSize         uint32                    `toml:"guest_memory_dump_max_size"`
+	ConsoleLogMaxSize              uint32                    `toml:"console_log_max_size"`
+	ConsoleLogMaxFiles             uint32                    `toml:"console_log_max_files"`
 	DisableImageNvdimm             bool                      `toml:"disable_image_nvdimm"`
 	HotPlugVFIO                    config.PCIePort           `toml:"hot_plug_vfio"`
 	ColdPlugVFIO                   config.PCIePort           `toml:"cold_plug_vfio"`
@@ -168,9 +191,13 @@ type hypervisor struct {
 	PCIeSwitchPort                 uint32                    `toml:"pcie_switch_port"`
 	DisableVhostNet                bool                      `toml:"disable_vhost_net"`
 	GuestMemoryDumpPaging          bool                      `toml:"guest_memory_dump_paging"`
+	EnableDiffSnapshots            bool                      `toml:"enable_diff_snapshots"`
+	EnableMemoryBalloon            bool                      `toml:"enable_memory_balloon"`
+	EnableMmds                     bool                      `toml:"enable_mmds"`
 	ConfidentialGuest              bool                      `toml:"confidential_guest"`
 	SevSnpGuest                    bool                      `toml:"sev_snp_guest"`
 	GuestSwap                      bool                      `toml:"enable_guest_swap"`
+	EnableVMMigration              bool                      `toml:"enable_vm_migration"`
 	Rootless                       bool                      `toml:"rootless"`
 	DisableSeccomp                 bool                      `toml:"disable_seccomp"`
 	DisableSeLinux                 bool                      `toml:"disable_selinux"`
@@ -195,6 +222,16 @@ type runtime struct {
 	Debug                     bool     `toml:"enable_debug"`
 	SandboxCgroupOnly         bool     `toml:"sandbox_cgroup_only"`
 	StaticSandboxResourceMgmt bool     `toml:"static_sandbox_resource_mgmt"`
+	Rightsizing               bool     `toml:"enable_sandbox_rightsizing"`
+	RightsizingInterval       uint32   `toml:"sandbox_rightsizing_interval"`
+	RightsizingHighWatermark  uint32   `toml:"sandbox_rightsizing_high_watermark"`
+	RightsizingLowWatermark   uint32   `toml:"sandbox_rightsizing_low_watermark"`
+	RightsizingStable         uint32   `toml:"sandbox_rightsizing_stable_intervals"`
+	GuestTimeSync             bool     `toml:"enable_guest_time_sync"`
+	GuestTimeSyncInterval     uint32   `toml:"guest_time_sync_interval"`
+	GuestClockDriftThreshold  uint32   `toml:"guest_clock_drift_threshold"`
+	MemoryPressureMonitor     bool     `toml:"enable_memory_pressure_monitor"`
+	MemoryPressureInterval    uint32   `toml:"memory_pressure_interval"`
 	EnablePprof               bool     `toml:"enable_pprof"`
 	DisableGuestEmptyDir      bool     `toml:"disable_guest_empty_dir"`
 	EmptyDirMode              string   `toml:"emptydir_mode"`
@@ -203,6 +240,9 @@ type runtime struct {
 	ForceGuestPull            bool     `toml:"experimental_force_guest_pull"`
 	PodResourceAPISock        string   `toml:"pod_resource_api_sock"`
 	KubeletRootDir            string   `toml:"kubelet_root_dir"`
+	ValidHookPaths            []string `toml:"valid_hook_paths"`
+	HookTimeout               uint32   `toml:"hook_timeout"`
+	ConfineHooks              bool     `toml:"confine_hooks"`
 }
 
 // emptyDirMode returns a valid emptydir_mode value, defaulting to shared-fs
@@ -221,6 +261,110 @@ func (r runtime) emptyDirMode() (string, error) {
 	}
 }
 
+// hookPolicy returns the policy the OCI hooks are run with on the host.
+func (r runtime) hookPolicy() (oci.HookPolicy, error) {
+	for _, glob := range r.ValidHookPaths {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return oci.HookPolicy{}, fmt.Errorf("invalid valid_hook_paths glob %q: %w", glob, err)
+		}
+	}
+
+	return oci.HookPolicy{
+		AllowedPaths: r.ValidHookPaths,
+		Timeout:      r.HookTimeout,
+		Confine:      r.ConfineHooks,
+	}, nil
+}
+
+// rightsizing returns the sandbox rightsizing controller configuration,
+// using the defaults for the unset TOML fields.
+func (r runtime) rightsizing() (vc.RightsizingConfig, error) {
+	if !r.Rightsizing {
+		return vc.RightsizingConfig{}, nil
+	}
+
+	if r.StaticSandboxResourceMgmt {
+		return vc.RightsizingConfig{}, errors.New("enable_sandbox_rightsizing requires static_sandbox_resource_mgmt to be disabled")
+	}
+
+	if r.EnableVCPUsPinning {
+		return vc.RightsizingConfig{}, errors.New("enable_sandbox_rightsizing cannot be used with enable_vcpus_pinning")
+	}
+
+	config := vc.RightsizingConfig{
+		Enabled:         true,
+		Interval:        vc.DefaultRightsizingInterval,
+		HighWatermark:   vc.DefaultRightsizingHighWatermark,
+		LowWatermark:    vc.DefaultRightsizingLowWatermark,
+		StableIntervals: vc.DefaultRightsizingStableIntervals,
+	}
+
+	if r.RightsizingInterval != 0 {
+		config.Interval = time.Duration(r.RightsizingInterval) * time.Second
+	}
+
+	if r.RightsizingHighWatermark != 0 {
+		config.HighWatermark = r.RightsizingHighWatermark
+	}
+
+	if r.RightsizingLowWatermark != 0 {
+		config.LowWatermark = r.RightsizingLowWatermark
+	}
+
+	if r.RightsizingStable != 0 {
+		config.StableIntervals = r.RightsizingStable
+	}
+
+	if config.HighWatermark > 100 || config.LowWatermark >= config.HighWatermark {
+		return vc.RightsizingConfig{}, fmt.Errorf("invalid sandbox rightsizing watermarks: low (%d) must be lower than high (%d), which must not exceed 100",
+			config.LowWatermark, config.HighWatermark)
+	}
+
+	return config, nil
+}
+
+// defaultMemoryPressureInterval is the default interval, in seconds, between
+// two checks of the memory pressure of the sandbox.
+const defaultMemoryPressureInterval = 10
+
+// memoryPressureInterval returns the interval, in seconds, between two checks
+// of the memory pressure of the sandbox, zero when they are disabled.
+func (r runtime) memoryPressureInterval() uint32 {
+	if !r.MemoryPressureMonitor {
+		return 0
+	}
+
+	if r.MemoryPressureInterval == 0 {
+		return defaultMemoryPressureInterval
+	}
+
+	return r.MemoryPressureInterval
+}
+
+// guestTimeSync returns the guest time synchronization configuration, using
+// the defaults for the unset TOML fields.
+func (r runtime) guestTimeSync() vc.GuestTimeSyncConfig {
+	if !r.GuestTimeSync {
+		return vc.GuestTimeSyncConfig{}
+	}
+
+	config := vc.GuestTimeSyncConfig{
+		Enabled:        true,
+		Interval:       vc.DefaultGuestTimeSyncInterval,
+		DriftThreshold: vc.DefaultGuestClockDriftThreshold,
+	}
+
+	if r.GuestTimeSyncInterval != 0 {
+		config.Interval = time.Duration(r.GuestTimeSyncInterval) * time.Second
+	}
+
+	if r.GuestClockDriftThreshold != 0 {
+		config.DriftThreshold = time.Duration(r.GuestClockDriftThreshold) * time.Millisecond
+	}
+
+	return config
+}
+
 type agent struct {
 	KernelModules        []string `toml:"kernel_modules"`
 	Debug                bool     `toml:"enable_debug"`
@@ -236,6 +380,7 @@ func (orig *tomlConfig) Clone() tomlConfig {
 	clone := *orig
 	clone.Hypervisor = make(map[string]hypervisor)
 	clone.Agent = make(map[string]agent)
+	clone.Profile = make(map[string]map[string]interface{})
 
 	for key, value := range orig.Hypervisor {
 		clone.Hypervisor[key] = value
@@ -243,6 +388,9 @@ func (orig *tomlConfig) Clone() tomlConfig {
 	for key, value := range orig.Agent {
 		clone.Agent[key] = value
 	}
+	for key, value := range orig.Profile {
+		clone.Profile[key] = value
+	}
 	return clone
 }
 
@@ -359,6 +507,107 @@ func (h hypervisor) firmwareVolume() (string, error) {
 	return ResolvePath(p)
 }
 
+func (h hypervisor) assetDigests() (map[types.AssetType]string, error) {
+	digests := map[types.AssetType]string{
+		types.HypervisorAsset:     h.HypervisorDigest,
+		types.JailerAsset:         h.JailerDigest,
+		types.KernelAsset:         h.KernelDigest,
+		types.InitrdAsset:         h.InitrdDigest,
+		types.ImageAsset:          h.ImageDigest,
+		types.FirmwareAsset:       h.FirmwareDigest,
+		types.FirmwareVolumeAsset: h.FirmwareVolumeDigest,
+	}
+
+	for t, digest := range digests {
+		if digest == "" {
+			delete(digests, t)
+			continue
+		}
+
+		algorithm, value, err := types.ParseAssetDigest(digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t, err)
+		}
+
+		digests[t] = algorithm + ":" + value
+	}
+
+	if len(digests) == 0 {
+		return nil, nil
+	}
+
+	return digests, nil
+}
+
+func (h hypervisor) assetManifest() (string, string, error) {
+	if h.AssetManifest == "" {
+		if h.AssetManifestPublicKey != "" {
+			return "", "", errors.New("asset_manifest_public_key requires asset_manifest")
+		}
+		return "", "", nil
+	}
+
+	if h.AssetManifestPublicKey == "" {
+		return "", "", errors.New("asset_manifest requires asset_manifest_public_key")
+	}
+
+	manifest, err := ResolvePath(h.AssetManifest)
+	if err != nil {
+		return "", "", err
+	}
+
+	publicKey, err := ResolvePath(h.AssetManifestPublicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return manifest, publicKey, nil
+}
+
+// updateHypervisorConfigConsoleLog sets how the guest console is captured to
+// the sandbox console log.
+func updateHypervisorConfigConsoleLog(h hypervisor, hConfig *vc.HypervisorConfig) {
+	hConfig.ConsoleLogPath = h.ConsoleLogPath
+	hConfig.ConsoleLogMaxSize = h.ConsoleLogMaxSize
+	hConfig.ConsoleLogMaxFiles = h.ConsoleLogMaxFiles
+}
+
+// updateHypervisorConfigGuestHooks sets the hooks shipped to the guest when
+// the sandbox starts.
+func updateHypervisorConfigGuestHooks(h hypervisor, hConfig *vc.HypervisorConfig) error {
+	if h.GuestHooksDir != "" {
+		hooks, err := vc.LoadGuestHooks(h.GuestHooksDir)
+		if err != nil {
+			return err
+		}
+		hConfig.GuestHooks = hooks
+	}
+
+	hConfig.GuestHooksPathList = h.GuestHooksPathList
+
+	return nil
+}
+
+// updateHypervisorConfigAssetIntegrity sets the digests the guest assets are
+// verified against before the VM is created.
+func updateHypervisorConfigAssetIntegrity(h hypervisor, hConfig *vc.HypervisorConfig) error {
+	digests, err := h.assetDigests()
+	if err != nil {
+		return err
+	}
+
+	manifest, publicKey, err := h.assetManifest()
+	if err != nil {
+		return err
+	}
+
+	hConfig.AssetDigests = digests
+	hConfig.AssetManifest = manifest
+	hConfig.AssetManifestPublicKey = publicKey
+
+	return nil
+}
+
 func (h hypervisor) PFlash() ([]string, error) {
 	pflashes := h.PFlashList
 
@@ -673,6 +922,13 @@ func (h hypervisor) indepiothreads() uint32 {
 	return h.IndepIOThreads
 }
 
+func (h hypervisor) mmdsMetadata() []string {
+	if h.MmdsMetadata == nil {
+		return append([]string(nil), vc.DefaultGuestMetadataFields...)
+	}
+	return h.MmdsMetadata
+}
+
 func (h hypervisor) guestHookPath() string {
 	if h.GuestHookPath == "" {
 		return defaultGuestHookPath
@@ -861,43 +1117,56 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		return vc.HypervisorConfig{}, err
 	}
 
+	// With the memory balloon, the VM is booted with default_maxmemory,
+	// which falls back to the host memory when unset.
+	if h.EnableMemoryBalloon && h.DefaultMaxMemorySize == 0 {
+		return vc.HypervisorConfig{}, errors.New("enable_memory_balloon requires default_maxmemory to be set")
+	}
+
 	rxRateLimiterMaxRate := h.getRxRateLimiterCfg()
 	txRateLimiterMaxRate := h.getTxRateLimiterCfg()
 
 	return vc.HypervisorConfig{
-		HypervisorPath:        hypervisor,
-		HypervisorPathList:    h.HypervisorPathList,
-		JailerPath:            jailer,
-		JailerPathList:        h.JailerPathList,
-		KernelPath:            kernel,
-		InitrdPath:            initrd,
-		ImagePath:             image,
-		RootfsType:            rootfsType,
-		FirmwarePath:          firmware,
-		KernelParams:          vc.DeserializeParams(vc.KernelParamFields(kernelParams)),
-		KernelVerityParams:    h.kernelVerityParams(),
-		NumVCPUsF:             h.defaultVCPUs(),
-		DefaultMaxVCPUs:       h.defaultMaxVCPUs(),
-		MemorySize:            h.defaultMemSz(),
-		MemSlots:              h.defaultMemSlots(),
-		DefaultMaxMemorySize:  h.defaultMaxMemSz(),
-		EntropySource:         h.GetEntropySource(),
-		EntropySourceList:     h.EntropySourceList,
-		DefaultBridges:        h.defaultBridges(),
-		DisableBlockDeviceUse: false, // shared fs is not supported in Firecracker,
-		HugePages:             h.HugePages,
-		Debug:                 h.Debug,
-		DisableNestingChecks:  h.DisableNestingChecks,
-		BlockDeviceDriver:     blockDriver,
-		EnableIOThreads:       h.EnableIOThreads,
-		IndepIOThreads:        h.indepiothreads(),
-		DisableVhostNet:       true, // vhost-net backend is not supported in Firecracker
-		GuestHookPath:         h.guestHookPath(),
-		RxRateLimiterMaxRate:  rxRateLimiterMaxRate,
-		TxRateLimiterMaxRate:  txRateLimiterMaxRate,
-		EnableAnnotations:     h.EnableAnnotations,
-		DisableSeLinux:        h.DisableSeLinux,
-		DisableGuestSeLinux:   true, // Guest SELinux is not supported in Firecracker
+		HypervisorPath:          hypervisor,
+		HypervisorPathList:      h.HypervisorPathList,
+		JailerPath:              jailer,
+		JailerPathList:          h.JailerPathList,
+		KernelPath:              kernel,
+		InitrdPath:              initrd,
+		ImagePath:               image,
+		RootfsType:              rootfsType,
+		FirmwarePath:            firmware,
+		KernelParams:            vc.DeserializeParams(vc.KernelParamFields(kernelParams)),
+		KernelVerityParams:      h.kernelVerityParams(),
+		NumVCPUsF:               h.defaultVCPUs(),
+		DefaultMaxVCPUs:         h.defaultMaxVCPUs(),
+		MemorySize:              h.defaultMemSz(),
+		MemSlots:                h.defaultMemSlots(),
+		DefaultMaxMemorySize:    h.defaultMaxMemSz(),
+		EntropySource:           h.GetEntropySource(),
+		EntropySourceList:       h.EntropySourceList,
+		DefaultBridges:          h.defaultBridges(),
+		DisableBlockDeviceUse:   false, // shared fs is not supported in Firecracker,
+		HugePages:               h.HugePages,
+		Debug:                   h.Debug,
+		DisableNestingChecks:    h.DisableNestingChecks,
+		BlockDeviceDriver:       blockDriver,
+		EnableIOThreads:         h.EnableIOThreads,
+		IndepIOThreads:          h.indepiothreads(),
+		DisableVhostNet:         true, // vhost-net backend is not supported in Firecracker
+		GuestHookPath:           h.guestHookPath(),
+		RxRateLimiterMaxRate:    rxRateLimiterMaxRate,
+		TxRateLimiterMaxRate:    txRateLimiterMaxRate,
+		EnableAnnotations:       h.EnableAnnotations,
+		DisableSeLinux:          h.DisableSeLinux,
+		DisableGuestSeLinux:     true, // Guest SELinux is not supported in Firecracker
+		EnableDiffSnapshots:     h.EnableDiffSnapshots,
+		EnableMemoryBalloon:     h.EnableMemoryBalloon,
+		ReclaimGuestFreedMemory: h.ReclaimGuestFreedMemory,
+		EnableMmds:              h.EnableMmds,
+		MmdsIPv4Address:         h.MmdsIPv4Address,
+		MmdsMetadata:            h.mmdsMetadata(),
+		Rootless:                h.Rootless,
 	}, nil
 }
 
@@ -1104,6 +1373,8 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		EnableAnnotations:             h.EnableAnnotations,
 		GuestMemoryDumpPath:           h.GuestMemoryDumpPath,
 		GuestMemoryDumpPaging:         h.GuestMemoryDumpPaging,
+		GuestMemoryDumpMaxCount:       h.GuestMemoryDumpMaxCount,
+		GuestMemoryDumpMaxSize:        h.GuestMemoryDumpMaxSize,
 		ConfidentialGuest:             h.ConfidentialGuest,
 		SevSnpGuest:                   h.SevSnpGuest,
 		GuestSwap:                     h.GuestSwap,
@@ -1211,6 +1482,8 @@ func newClhHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		MemPrealloc:                    h.MemPrealloc,
 		ReclaimGuestFreedMemory:        h.ReclaimGuestFreedMemory,
 		HugePages:                      h.HugePages,
+		GuestNUMANodes:                 h.defaultGuestNUMANodes(),
+		NUMAMapping:                    append([]string(nil), h.NUMAMapping...),
 		Debug:                          h.Debug,
 		DisableNestingChecks:           h.DisableNestingChecks,
 		BlockDeviceDriver:              blockDriver,
@@ -1226,6 +1499,11 @@ func newClhHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		PCIeSwitchPort:                 h.pcieSwitchPort(),
 		DisableVhostNet:                true,
 		GuestHookPath:                  h.guestHookPath(),
+		GuestMemoryDumpPath:            h.GuestMemoryDumpPath,
+		GuestMemoryDumpMaxCount:        h.GuestMemoryDumpMaxCount,
+		GuestMemoryDumpMaxSize:         h.GuestMemoryDumpMaxSize,
+		VMSnapshotPath:                 h.VMSnapshotPath,
+		EnableVMMigration:              h.EnableVMMigration,
 		VirtioFSExtraArgs:              h.VirtioFSExtraArgs,
 		SGXEPCSize:                     defaultSGXEPCSize,
 		EnableAnnotations:              h.EnableAnnotations,
@@ -1374,6 +1652,7 @@ func newStratovirtHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		DefaultMaxMemorySize:          h.defaultMaxMemSz(),
 		EntropySource:                 h.GetEntropySource(),
 		DefaultBridges:                h.defaultBridges(),
+		PCIeRootPort:                  h.pcieRootPort(),
 		DisableBlockDeviceUse:         h.DisableBlockDeviceUse,
 		SharedFS:                      sharedFS,
 		VirtioFSDaemon:                h.VirtioFSDaemon,
@@ -1394,6 +1673,8 @@ func newStratovirtHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		DisableSeccomp:                h.DisableSeccomp,
 		DisableSeLinux:                h.DisableSeLinux,
 		DisableGuestSeLinux:           h.DisableGuestSeLinux,
+		EnableMemoryBalloon:           h.EnableMemoryBalloon,
+		ReclaimGuestFreedMemory:       h.ReclaimGuestFreedMemory,
 	}, nil
 }
 
@@ -1460,6 +1741,16 @@ func updateRuntimeConfigHypervisor(configPath string, tomlConf tomlConfig, confi
 			return fmt.Errorf("%v: %v", configPath, err)
 		}
 
+		if err := updateHypervisorConfigAssetIntegrity(hypervisor, &hConfig); err != nil {
+			return fmt.Errorf("%v: %v", configPath, err)
+		}
+
+		if err := updateHypervisorConfigGuestHooks(hypervisor, &hConfig); err != nil {
+			return fmt.Errorf("%v: %v", configPath, err)
+		}
+
+		updateHypervisorConfigConsoleLog(hypervisor, &hConfig)
+
 		config.HypervisorConfig = hConfig
 	}
 
@@ -1664,6 +1955,14 @@ func initConfig() (config oci.RuntimeConfig, err error) {
 // All paths are resolved fully meaning if this function does not return an
 // error, all paths are valid at the time of the call.
 func LoadConfiguration(configPath string, ignoreLogging bool) (resolvedConfigPath string, config oci.RuntimeConfig, err error) {
+	return LoadConfigurationWithProfile(configPath, "", ignoreLogging)
+}
+
+// LoadConfigurationWithProfile is like LoadConfiguration but merges the
+// named configuration profile ([profile.<name>] table) over the base
+// configuration before converting it. An empty profile name selects the
+// base configuration unchanged.
+func LoadConfigurationWithProfile(configPath, profile string, ignoreLogging bool) (resolvedConfigPath string, config oci.RuntimeConfig, err error) {
 
 	config, err = initConfig()
 	if err != nil {
@@ -1675,6 +1974,11 @@ func LoadConfiguration(configPath string, ignoreLogging bool) (resolvedConfigPat
 		return "", oci.RuntimeConfig{}, err
 	}
 
+	if err = applyProfile(&tomlConf, profile); err != nil {
+		return "", oci.RuntimeConfig{}, fmt.Errorf("%v: %v", resolved, err)
+	}
+	config.Profile = profile
+
 	config.Debug = tomlConf.Runtime.Debug
 	if !tomlConf.Runtime.Debug {
 		// If debug is not required, switch back to the original
@@ -1708,8 +2012,9 @@ func LoadConfiguration(configPath string, ignoreLogging bool) (resolvedConfigPat
 
 		kataUtilsLogger.WithFields(
 			logrus.Fields{
-				"format": "TOML",
-				"file":   resolved,
+				"format":  "TOML",
+				"file":    resolved,
+				"profile": profile,
 			}).Info("loaded configuration")
 	}
 
@@ -1721,6 +2026,14 @@ func LoadConfiguration(configPath string, ignoreLogging bool) (resolvedConfigPat
 	config.EnableVCPUsPinning = tomlConf.Runtime.EnableVCPUsPinning
 	config.GuestSeLinuxLabel = tomlConf.Runtime.GuestSeLinuxLabel
 	config.StaticSandboxResourceMgmt = tomlConf.Runtime.StaticSandboxResourceMgmt
+	if config.Rightsizing, err = tomlConf.Runtime.rightsizing(); err != nil {
+		return "", config, err
+	}
+	if config.HookPolicy, err = tomlConf.Runtime.hookPolicy(); err != nil {
+		return "", config, err
+	}
+	config.GuestTimeSync = tomlConf.Runtime.guestTimeSync()
+	config.MemoryPressureInterval = tomlConf.Runtime.memoryPressureInterval()
 	config.SandboxCgroupOnly = tomlConf.Runtime.SandboxCgroupOnly
 	config.DisableNewNetNs = tomlConf.Runtime.DisableNewNetNs
 	config.EnablePprof = tomlConf.Runtime.EnablePprof
@@ -1846,6 +2159,13 @@ func updateFromDropIn(dropInFpath string, tomlConf *tomlConfig) error {
 		return fmt.Errorf("error reading file %q: %s", dropInFpath, err)
 	}
 
+	return mergeConfigData(fmt.Sprintf("drop-in file %q", dropInFpath), configData, tomlConf)
+}
+
+// mergeConfigData decodes a TOML fragment and merges it over tomlConf.
+// 'source' describes where the fragment came from and is only used in
+// messages.
+func mergeConfigData(source string, configData []byte, tomlConf *tomlConfig) error {
 	// Ordinarily, BurntSushi only updates fields of tomlConfig that are
 	// changed by the file and leaves the rest alone.  This doesn't apply
 	// though to tomlConfig substructures that are stored in maps.  Their
@@ -1855,27 +2175,27 @@ func updateFromDropIn(dropInFpath string, tomlConf *tomlConfig) error {
 	// changes afterwards, using reflection.
 	tomlConfOrig := tomlConf.Clone()
 
-	var md toml.MetaData
-	md, err = toml.Decode(string(configData), &tomlConf)
+	md, err := toml.Decode(string(configData), &tomlConf)
 
 	if err != nil {
-		return fmt.Errorf("error decoding file %q: %s", dropInFpath, err)
+		return fmt.Errorf("error decoding %s: %s", source, err)
 	}
 
 	if len(md.Undecoded()) > 0 {
-		msg := fmt.Sprintf("warning: undecoded keys in %q: %+v", dropInFpath, md.Undecoded())
+		msg := fmt.Sprintf("warning: undecoded keys in %s: %+v", source, md.Undecoded())
 		kataUtilsLogger.Warn(msg)
 	}
 
 	for _, key := range md.Keys() {
 		err = applyKey(*tomlConf, key, &tomlConfOrig)
 		if err != nil {
-			return fmt.Errorf("error applying key '%+v' from drop-in file %q: %s", key, dropInFpath, err)
+			return fmt.Errorf("error applying key '%+v' from %s: %s", key, source, err)
 		}
 	}
 
 	tomlConf.Hypervisor = tomlConfOrig.Hypervisor
 	tomlConf.Agent = tomlConfOrig.Agent
+	tomlConf.Profile = tomlConfOrig.Profile
 
 	return nil
 }
@@ -1892,6 +2212,8 @@ func applyKey(sourceConf tomlConfig, key []string, targetConf *tomlConfig) error
 		return applyAgentKey(sourceConf, key[1:], targetConf)
 	case "hypervisor":
 		return applyHypervisorKey(sourceConf, key[1:], targetConf)
+	case "profile":
+		return applyProfileKey(sourceConf, key[1:], targetConf)
 		// The table the 'key' is in is not stored in a map so no special handling
 		// is needed.
 	}
@@ -1998,6 +2320,14 @@ func checkConfig(config oci.RuntimeConfig) error {
 		return err
 	}
 
+	if err := checkMemoryBalloonConfig(config); err != nil {
+		return err
+	}
+
+	if err := checkMmdsConfig(config); err != nil {
+		return err
+	}
+
 	hotPlugVFIO := config.HypervisorConfig.HotPlugVFIO
 	coldPlugVFIO := config.HypervisorConfig.ColdPlugVFIO
 	machineType := config.HypervisorConfig.HypervisorMachineType
@@ -2020,7 +2350,9 @@ func checkNumaConfig(config oci.RuntimeConfig) error {
 		return fmt.Errorf("multi-NUMA support is only available on amd64 and arm64, got %q", goruntime.GOARCH)
 	}
 
-	if !config.StaticSandboxResourceMgmt {
+	// Cloud Hypervisor grows the memory zones of the guest NUMA nodes with
+	// virtio-mem, and assigns the hotplugged vCPUs to their NUMA node.
+	if !config.StaticSandboxResourceMgmt && config.HypervisorType != vc.ClhHypervisor {
 		return fmt.Errorf("NUMA support requires static_sandbox_resource_mgmt to be enabled; " +
 			"NUMA topology is not compatible with dynamic CPU/memory hotplug")
 	}
@@ -2028,6 +2360,50 @@ func checkNumaConfig(config oci.RuntimeConfig) error {
 	return nil
 }
 
+// checkMemoryBalloonConfig ensures the guest memory can be resized when the
+// memory balloon is enabled.
+func checkMemoryBalloonConfig(config oci.RuntimeConfig) error {
+	if !config.HypervisorConfig.EnableMemoryBalloon {
+		return nil
+	}
+
+	if config.StaticSandboxResourceMgmt {
+		return errors.New("enable_memory_balloon requires static_sandbox_resource_mgmt to be disabled")
+	}
+
+	if config.HypervisorConfig.DefaultMaxMemorySize < uint64(config.HypervisorConfig.MemorySize) {
+		return errors.New("enable_memory_balloon requires default_maxmemory to be at least default_memory")
+	}
+
+	return nil
+}
+
+// checkMmdsConfig ensures the MMDS address, when set, is one Firecracker
+// accepts, and that the published metadata fields exist.
+func checkMmdsConfig(config oci.RuntimeConfig) error {
+	if !config.HypervisorConfig.EnableMmds {
+		return nil
+	}
+
+	for _, field := range config.HypervisorConfig.MmdsMetadata {
+		if !slices.Contains(vc.GuestMetadataFields, field) {
+			return fmt.Errorf("unknown mmds_metadata field %q, valid fields are %v", field, vc.GuestMetadataFields)
+		}
+	}
+
+	address := config.HypervisorConfig.MmdsIPv4Address
+	if address == "" {
+		return nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil || ip.To4() == nil || !ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("mmds_ipv4_address %q is not an IPv4 link-local address", address)
+	}
+
+	return nil
+}
+
 // checkPCIeConfig ensures the PCIe configuration is valid.
 // Only allow one of the following settings for cold-plug:
 // no-port, root-port, switch-port
@@ -2086,8 +2462,24 @@ func checkNetNsConfig(config oci.RuntimeConfig) error {
 // checkFactoryConfig ensures the VM factory configuration is valid.
 func checkFactoryConfig(config oci.RuntimeConfig) error {
 	if config.FactoryConfig.VMCacheNumber > 0 {
-		if config.HypervisorType != vc.QemuHypervisor {
-			return errors.New("VM cache just support qemu")
+		if config.HypervisorType != vc.QemuHypervisor && config.HypervisorType != vc.FirecrackerHypervisor &&
+			config.HypervisorType != vc.StratovirtHypervisor {
+			return errors.New("VM cache just support qemu, firecracker and stratovirt")
+		}
+	}
+
+	factoryEnabled := config.FactoryConfig.Template || config.FactoryConfig.VMCacheNumber > 0
+	if factoryEnabled && config.HypervisorType == vc.FirecrackerHypervisor {
+		// Firecracker snapshots record the paths of the VM resources,
+		// only the jailer makes them identical across VMs.
+		if config.HypervisorConfig.JailerPath == "" {
+			return errors.New("VM factory with firecracker requires jailer_path to be set")
+		}
+
+		// Factory VMs get their network interfaces hotplugged, which
+		// firecracker does not support.
+		if config.InterNetworkModel != vc.NetXConnectNoneModel {
+			return errors.New("VM factory with firecracker requires internetworking_model=\"none\"")
 		}
 	}
 
@@ -0,0 +1,144 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katautils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Configuration profiles are named overlays defined in the configuration
+// file (or its drop-ins) as
+//
+//	[profile.<name>.<component>.<type>]
+//
+// for example
+//
+//	[profile.gpu.hypervisor.qemu]
+//	default_memory = 8192
+//
+//	[profile.gpu.runtime]
+//	static_sandbox_resource_mgmt = true
+//
+// A profile is selected per sandbox and is merged over the base
+// configuration exactly like a drop-in file would be.
+
+// profileNameRegexp restricts profile names to something that is safe to
+// log and to use in TOML table names without quoting.
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// applyProfile merges the named profile over tomlConf. An empty name is a
+// no-op.
+func applyProfile(tomlConf *tomlConfig, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid configuration profile name %q", name)
+	}
+
+	table, ok := tomlConf.Profile[name]
+	if !ok {
+		return fmt.Errorf("configuration profile %q is not defined", name)
+	}
+
+	if err := checkProfileTable(*tomlConf, name, table); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(table); err != nil {
+		return fmt.Errorf("error encoding configuration profile %q: %v", name, err)
+	}
+
+	return mergeConfigData(fmt.Sprintf("configuration profile %q", name), buf.Bytes(), tomlConf)
+}
+
+// checkProfileTable makes sure a profile only tunes the components that the
+// base configuration already defines. A profile that adds a new hypervisor
+// or agent table would otherwise leave the runtime with two of them.
+func checkProfileTable(tomlConf tomlConfig, name string, table map[string]interface{}) error {
+	for section, value := range table {
+		switch section {
+		case "hypervisor":
+			types, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("configuration profile %q: %q must be a table", name, section)
+			}
+			for t := range types {
+				if _, ok := tomlConf.Hypervisor[t]; !ok {
+					return fmt.Errorf("configuration profile %q: hypervisor %q is not defined in the base configuration", name, t)
+				}
+			}
+		case "agent":
+			types, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("configuration profile %q: %q must be a table", name, section)
+			}
+			for t := range types {
+				if _, ok := tomlConf.Agent[t]; !ok {
+					return fmt.Errorf("configuration profile %q: agent %q is not defined in the base configuration", name, t)
+				}
+			}
+		case "runtime", "factory":
+		default:
+			return fmt.Errorf("configuration profile %q: unsupported section %q", name, section)
+		}
+	}
+
+	return nil
+}
+
+// applyProfileKey copies a single profile value identified by 'key'
+// ([ profile_name section ... field ]) from 'sourceConf' into 'targetConf',
+// so that drop-in files can extend or override profiles defined in the
+// base configuration file instead of replacing them.
+func applyProfileKey(sourceConf tomlConfig, key []string, targetConf *tomlConfig) error {
+	profileName := key[0]
+	path := key[1:]
+
+	var value interface{} = sourceConf.Profile[profileName]
+	for _, k := range path {
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("key %q not found", k)
+		}
+		if value, ok = table[k]; !ok {
+			return fmt.Errorf("key %q not found", k)
+		}
+	}
+
+	// Tables are handled through the keys of their values.
+	if _, ok := value.(map[string]interface{}); ok {
+		return nil
+	}
+
+	if targetConf.Profile == nil {
+		targetConf.Profile = make(map[string]map[string]interface{})
+	}
+
+	table, ok := targetConf.Profile[profileName]
+	if !ok {
+		table = make(map[string]interface{})
+		targetConf.Profile[profileName] = table
+	}
+
+	for _, k := range path[:len(path)-1] {
+		next, ok := table[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			table[k] = next
+		}
+		table = next
+	}
+	table[path[len(path)-1]] = value
+
+	return nil
+}
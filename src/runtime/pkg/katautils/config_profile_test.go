@@ -0,0 +1,97 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katautils
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyProfile(t *testing.T) {
+	tmpdir := t.TempDir()
+
+	runtimeConfigFileData := `
+[hypervisor.qemu]
+path = "/usr/bin/qemu-kvm"
+default_memory = 2048
+default_bridges = 3
+[agent.kata]
+enable_debug = false
+[runtime]
+internetworking_model="tcfilter"
+
+[profile.gpu.hypervisor.qemu]
+default_memory = 8192
+[profile.gpu.runtime]
+static_sandbox_resource_mgmt = true
+
+[profile.rogue.hypervisor.clh]
+default_memory = 8192
+
+[profile.nested.profile.gpu]
+`
+	dropInData := `
+[profile.gpu.hypervisor.qemu]
+default_bridges = 5
+[profile.gpu.agent.kata]
+enable_debug = true
+[profile.small.hypervisor.qemu]
+default_memory = 512
+`
+
+	configPath := path.Join(tmpdir, "runtime.toml")
+	err := createConfig(configPath, runtimeConfigFileData)
+	assert.NoError(t, err)
+
+	dropInDir := path.Join(tmpdir, "config.d")
+	err = os.Mkdir(dropInDir, os.FileMode(0777))
+	assert.NoError(t, err)
+
+	err = createConfig(path.Join(dropInDir, "10-profiles"), dropInData)
+	assert.NoError(t, err)
+
+	type testData struct {
+		profile       string
+		expectError   bool
+		memory        uint32
+		bridges       uint32
+		agentDebug    bool
+		staticSandbox bool
+	}
+
+	data := []testData{
+		{"", false, 2048, 3, false, false},
+		{"gpu", false, 8192, 5, true, true},
+		{"small", false, 512, 3, false, false},
+		{"unknown", true, 0, 0, false, false},
+		{"rogue", true, 0, 0, false, false},
+		{"nested", true, 0, 0, false, false},
+		{"../gpu", true, 0, 0, false, false},
+	}
+
+	for i, d := range data {
+		config, _, err := decodeConfig(configPath)
+		assert.NoError(t, err)
+
+		err = applyProfile(&config, d.profile)
+		if d.expectError {
+			assert.Error(t, err, "test %d (%+v)", i, d)
+			continue
+		}
+
+		assert.NoError(t, err, "test %d (%+v)", i, d)
+		assert.Equal(t, d.memory, config.Hypervisor["qemu"].MemorySize, "test %d (%+v)", i, d)
+		assert.Equal(t, d.bridges, config.Hypervisor["qemu"].DefaultBridges, "test %d (%+v)", i, d)
+		assert.Equal(t, "/usr/bin/qemu-kvm", config.Hypervisor["qemu"].Path, "test %d (%+v)", i, d)
+		assert.Equal(t, d.agentDebug, config.Agent["kata"].Debug, "test %d (%+v)", i, d)
+		assert.Equal(t, d.staticSandbox, config.Runtime.StaticSandboxResourceMgmt, "test %d (%+v)", i, d)
+		assert.Equal(t, "tcfilter", config.Runtime.InterNetworkModel, "test %d (%+v)", i, d)
+		assert.Len(t, config.Hypervisor, 1, "test %d (%+v)", i, d)
+	}
+}
@@ -17,12 +17,14 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/govmm"
 	ktu "github.com/kata-containers/kata-containers/src/runtime/pkg/katatestutils"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
 	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
 	"github.com/pbnjay/memory"
 	"github.com/stretchr/testify/assert"
@@ -771,6 +773,7 @@ func TestNewFirecrackerHypervisorConfig(t *testing.T) {
 		BlockDeviceDriver:     blockDeviceDriver,
 		RxRateLimiterMaxRate:  rxRateLimiterMaxRate,
 		TxRateLimiterMaxRate:  txRateLimiterMaxRate,
+		Rootless:              true,
 	}
 
 	files := []string{hypervisorPath, kernelPath, imagePath, jailerPath}
@@ -823,6 +826,10 @@ func TestNewFirecrackerHypervisorConfig(t *testing.T) {
 		t.Errorf("Expected value for disable vhost net usage %v, got %v", disableVhostNet, config.DisableVhostNet)
 	}
 
+	if !config.Rootless {
+		t.Errorf("Expected rootless firecracker")
+	}
+
 	if config.RxRateLimiterMaxRate != rxRateLimiterMaxRate {
 		t.Errorf("Expected value for rx rate limiter %v, got %v", rxRateLimiterMaxRate, config.RxRateLimiterMaxRate)
 	}
@@ -830,6 +837,17 @@ func TestNewFirecrackerHypervisorConfig(t *testing.T) {
 	if config.TxRateLimiterMaxRate != txRateLimiterMaxRate {
 		t.Errorf("Expected value for tx rate limiter %v, got %v", txRateLimiterMaxRate, config.TxRateLimiterMaxRate)
 	}
+
+	// The memory balloon requires default_maxmemory to be set
+	hypervisor.EnableMemoryBalloon = true
+	if _, err := newFirecrackerHypervisorConfig(hypervisor); err == nil {
+		t.Errorf("Expected newFirecrackerHypervisorConfig to fail without default_maxmemory")
+	}
+
+	hypervisor.DefaultMaxMemorySize = 4096
+	if _, err := newFirecrackerHypervisorConfig(hypervisor); err != nil {
+		t.Errorf("Expected newFirecrackerHypervisorConfig to succeed with default_maxmemory, got %v", err)
+	}
 }
 
 func TestNewQemuHypervisorConfigImageAndInitrd(t *testing.T) {
@@ -1154,6 +1172,85 @@ func TestHypervisorDefaultsImage(t *testing.T) {
 	assert.Equal(p, "")
 }
 
+func TestHypervisorAssetIntegrity(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir := t.TempDir()
+
+	kernelDigest := "sha256:" + strings.Repeat("AB", 32)
+
+	h := hypervisor{KernelDigest: kernelDigest}
+	hConfig := vc.HypervisorConfig{}
+	assert.NoError(updateHypervisorConfigAssetIntegrity(h, &hConfig))
+	assert.Equal(map[types.AssetType]string{types.KernelAsset: strings.ToLower(kernelDigest)}, hConfig.AssetDigests)
+	assert.Empty(hConfig.AssetManifest)
+
+	h = hypervisor{ImageDigest: "sha512:1234"}
+	assert.Error(updateHypervisorConfigAssetIntegrity(h, &hConfig))
+
+	manifest := filepath.Join(tmpdir, "assets.manifest")
+	publicKey := filepath.Join(tmpdir, "assets.pub")
+	assert.NoError(createEmptyFile(manifest))
+	assert.NoError(createEmptyFile(publicKey))
+
+	h = hypervisor{AssetManifest: manifest}
+	assert.Error(updateHypervisorConfigAssetIntegrity(h, &hConfig))
+
+	h = hypervisor{AssetManifestPublicKey: publicKey}
+	assert.Error(updateHypervisorConfigAssetIntegrity(h, &hConfig))
+
+	h = hypervisor{AssetManifest: manifest, AssetManifestPublicKey: publicKey}
+	hConfig = vc.HypervisorConfig{}
+	assert.NoError(updateHypervisorConfigAssetIntegrity(h, &hConfig))
+	assert.Nil(hConfig.AssetDigests)
+	assert.Equal(manifest, hConfig.AssetManifest)
+	assert.Equal(publicKey, hConfig.AssetManifestPublicKey)
+}
+
+func TestHypervisorGuestHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir := t.TempDir()
+
+	h := hypervisor{GuestHooksPathList: []string{"/usr/libexec/oci/*"}}
+	hConfig := vc.HypervisorConfig{}
+	assert.NoError(updateHypervisorConfigGuestHooks(h, &hConfig))
+	assert.Empty(hConfig.GuestHooks)
+	assert.Equal([]string{"/usr/libexec/oci/*"}, hConfig.GuestHooksPathList)
+
+	hook := `{"version": "1.0.0", "hook": {"path": "/usr/libexec/oci/hook"}, "when": {"always": true}, "stages": ["prestart"]}`
+	assert.NoError(os.WriteFile(filepath.Join(tmpdir, "hook.json"), []byte(hook), 0644))
+
+	h = hypervisor{GuestHooksDir: tmpdir}
+	assert.NoError(updateHypervisorConfigGuestHooks(h, &hConfig))
+	assert.Len(hConfig.GuestHooks, 1)
+	assert.Equal("/usr/libexec/oci/hook", hConfig.GuestHooks[0].Hook.Path)
+
+	assert.NoError(os.WriteFile(filepath.Join(tmpdir, "invalid.json"), []byte(`{"version": "1.0.0"}`), 0644))
+	assert.Error(updateHypervisorConfigGuestHooks(h, &hConfig))
+}
+
+func TestHypervisorConsoleLog(t *testing.T) {
+	assert := assert.New(t)
+
+	hConfig := vc.HypervisorConfig{}
+	updateHypervisorConfigConsoleLog(hypervisor{}, &hConfig)
+	assert.False(hConfig.ConsoleLogEnabled())
+	assert.Zero(hConfig.ConsoleLogMaxSize)
+	assert.Zero(hConfig.ConsoleLogMaxFiles)
+
+	h := hypervisor{
+		ConsoleLogPath:     "/var/log/kata/console",
+		ConsoleLogMaxSize:  4,
+		ConsoleLogMaxFiles: 5,
+	}
+	updateHypervisorConfigConsoleLog(h, &hConfig)
+	assert.True(hConfig.ConsoleLogEnabled())
+	assert.Equal("/var/log/kata/console", hConfig.ConsoleLogPath)
+	assert.Equal(uint32(4), hConfig.ConsoleLogMaxSize)
+	assert.Equal(uint32(5), hConfig.ConsoleLogMaxFiles)
+}
+
 func TestHypervisorDefaultsGuestHookPath(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1688,6 +1785,71 @@ func TestCheckEmptyDirMode(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestHookPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	r := runtime{}
+	policy, err := r.hookPolicy()
+	assert.NoError(err)
+	assert.Equal(oci.HookPolicy{}, policy)
+
+	r = runtime{
+		ValidHookPaths: []string{"/usr/libexec/oci/hooks.d/*"},
+		HookTimeout:    10,
+		ConfineHooks:   true,
+	}
+	policy, err = r.hookPolicy()
+	assert.NoError(err)
+	assert.Equal(oci.HookPolicy{
+		AllowedPaths: []string{"/usr/libexec/oci/hooks.d/*"},
+		Timeout:      10,
+		Confine:      true,
+	}, policy)
+
+	// Invalid glob
+	r = runtime{ValidHookPaths: []string{"/usr/libexec/oci/hooks.d/["}}
+	_, err = r.hookPolicy()
+	assert.Error(err)
+}
+
+func TestGuestTimeSync(t *testing.T) {
+	assert := assert.New(t)
+
+	r := runtime{}
+	assert.Equal(vc.GuestTimeSyncConfig{}, r.guestTimeSync())
+
+	r = runtime{GuestTimeSync: true}
+	assert.Equal(vc.GuestTimeSyncConfig{
+		Enabled:        true,
+		Interval:       vc.DefaultGuestTimeSyncInterval,
+		DriftThreshold: vc.DefaultGuestClockDriftThreshold,
+	}, r.guestTimeSync())
+
+	r = runtime{
+		GuestTimeSync:            true,
+		GuestTimeSyncInterval:    10,
+		GuestClockDriftThreshold: 500,
+	}
+	assert.Equal(vc.GuestTimeSyncConfig{
+		Enabled:        true,
+		Interval:       10 * time.Second,
+		DriftThreshold: 500 * time.Millisecond,
+	}, r.guestTimeSync())
+}
+
+func TestMemoryPressureInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	r := runtime{MemoryPressureInterval: 30}
+	assert.Zero(r.memoryPressureInterval())
+
+	r = runtime{MemoryPressureMonitor: true}
+	assert.Equal(uint32(defaultMemoryPressureInterval), r.memoryPressureInterval())
+
+	r = runtime{MemoryPressureMonitor: true, MemoryPressureInterval: 30}
+	assert.Equal(uint32(30), r.memoryPressureInterval())
+}
+
 func TestCheckFactoryConfig(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1730,6 +1892,160 @@ func TestCheckFactoryConfig(t *testing.T) {
 	}
 }
 
+func TestCheckFactoryConfigHypervisor(t *testing.T) {
+	assert := assert.New(t)
+
+	// nolint: govet
+	type testData struct {
+		hypervisorType vc.HypervisorType
+		template       bool
+		vmCacheNumber  uint
+		jailerPath     string
+		netModel       vc.NetInterworkingModel
+		expectError    bool
+	}
+
+	data := []testData{
+		{vc.QemuHypervisor, false, 1, "", vc.DefaultNetInterworkingModel, false},
+		{vc.ClhHypervisor, false, 1, "", vc.DefaultNetInterworkingModel, true},
+
+		{vc.FirecrackerHypervisor, false, 0, "", vc.DefaultNetInterworkingModel, false},
+		{vc.FirecrackerHypervisor, true, 0, "", vc.NetXConnectNoneModel, true},
+		{vc.FirecrackerHypervisor, true, 0, "/usr/bin/jailer", vc.DefaultNetInterworkingModel, true},
+		{vc.FirecrackerHypervisor, true, 0, "/usr/bin/jailer", vc.NetXConnectNoneModel, false},
+		{vc.FirecrackerHypervisor, false, 1, "/usr/bin/jailer", vc.NetXConnectNoneModel, false},
+
+		{vc.StratovirtHypervisor, true, 0, "", vc.DefaultNetInterworkingModel, false},
+		{vc.StratovirtHypervisor, false, 1, "", vc.DefaultNetInterworkingModel, false},
+	}
+
+	for i, d := range data {
+		config := oci.RuntimeConfig{
+			HypervisorType: d.hypervisorType,
+			HypervisorConfig: vc.HypervisorConfig{
+				JailerPath: d.jailerPath,
+			},
+			InterNetworkModel: d.netModel,
+			FactoryConfig: oci.FactoryConfig{
+				Template:      d.template,
+				VMCacheNumber: d.vmCacheNumber,
+			},
+		}
+
+		err := checkFactoryConfig(config)
+
+		if d.expectError {
+			assert.Error(err, "test %d (%+v)", i, d)
+		} else {
+			assert.NoError(err, "test %d (%+v)", i, d)
+		}
+	}
+}
+
+func TestCheckMemoryBalloonConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			MemorySize:           2048,
+			DefaultMaxMemorySize: 8192,
+		},
+		StaticSandboxResourceMgmt: true,
+	}
+
+	// The balloon is disabled
+	assert.NoError(checkMemoryBalloonConfig(config))
+
+	config.HypervisorConfig.EnableMemoryBalloon = true
+	assert.Error(checkMemoryBalloonConfig(config))
+
+	config.StaticSandboxResourceMgmt = false
+	assert.NoError(checkMemoryBalloonConfig(config))
+
+	config.HypervisorConfig.DefaultMaxMemorySize = 2048
+	assert.NoError(checkMemoryBalloonConfig(config))
+
+	config.HypervisorConfig.DefaultMaxMemorySize = 1024
+	assert.Error(checkMemoryBalloonConfig(config))
+}
+
+func TestCheckNumaConfig(t *testing.T) {
+	if goruntime.GOARCH != "amd64" && goruntime.GOARCH != "arm64" {
+		t.Skip("multi-NUMA is only supported on amd64 and arm64")
+	}
+
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.QemuHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			GuestNUMANodes: []types.GuestNUMANode{{HostNodes: "0"}},
+		},
+	}
+
+	// A single NUMA node
+	assert.NoError(checkNumaConfig(config))
+
+	config.HypervisorConfig.GuestNUMANodes = append(config.HypervisorConfig.GuestNUMANodes, types.GuestNUMANode{HostNodes: "1"})
+	assert.Error(checkNumaConfig(config))
+
+	config.StaticSandboxResourceMgmt = true
+	assert.NoError(checkNumaConfig(config))
+
+	// Cloud Hypervisor resizes the memory zones of the NUMA nodes
+	config.HypervisorType = vc.ClhHypervisor
+	config.StaticSandboxResourceMgmt = false
+	assert.NoError(checkNumaConfig(config))
+}
+
+func TestCheckMmdsConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			MmdsIPv4Address: "10.0.0.1",
+		},
+	}
+
+	// MMDS is disabled
+	assert.NoError(checkMmdsConfig(config))
+
+	config.HypervisorConfig.EnableMmds = true
+	assert.Error(checkMmdsConfig(config))
+
+	config.HypervisorConfig.MmdsIPv4Address = "fe80::1"
+	assert.Error(checkMmdsConfig(config))
+
+	config.HypervisorConfig.MmdsIPv4Address = "169.254.170.2"
+	assert.NoError(checkMmdsConfig(config))
+
+	// Firecracker picks the default address
+	config.HypervisorConfig.MmdsIPv4Address = ""
+	assert.NoError(checkMmdsConfig(config))
+
+	config.HypervisorConfig.MmdsMetadata = []string{vc.GuestMetadataSandboxID, vc.GuestMetadataPolicy}
+	assert.NoError(checkMmdsConfig(config))
+
+	config.HypervisorConfig.MmdsMetadata = []string{"secrets"}
+	assert.Error(checkMmdsConfig(config))
+}
+
+func TestHypervisorMmdsMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	// The policy and the initdata are only published when listed
+	h := hypervisor{}
+	assert.Equal(vc.DefaultGuestMetadataFields, h.mmdsMetadata())
+	assert.NotContains(h.mmdsMetadata(), vc.GuestMetadataPolicy)
+	assert.NotContains(h.mmdsMetadata(), vc.GuestMetadataInitdata)
+
+	h.MmdsMetadata = []string{}
+	assert.Empty(h.mmdsMetadata())
+
+	h.MmdsMetadata = []string{vc.GuestMetadataInitdata}
+	assert.Equal([]string{vc.GuestMetadataInitdata}, h.mmdsMetadata())
+}
+
 func TestValidateBindMounts(t *testing.T) {
 	assert := assert.New(t)
 
@@ -191,12 +191,12 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec specs.Spec, runtimeCo
 
 	sandbox, err := vci.CreateSandbox(ctx, sandboxConfig, func(ctx context.Context) error {
 		// Run pre-start OCI hooks, in the runtime namespace.
-		if err := PreStartHooks(ctx, ociSpec, containerID, bundlePath); err != nil {
+		if err := PreStartHooks(ctx, runtimeConfig.HookPolicy, ociSpec, containerID, bundlePath); err != nil {
 			return err
 		}
 
 		// Run create runtime OCI hooks, in the runtime namespace.
-		if err := CreateRuntimeHooks(ctx, ociSpec, containerID, bundlePath); err != nil {
+		if err := CreateRuntimeHooks(ctx, runtimeConfig.HookPolicy, ociSpec, containerID, bundlePath); err != nil {
 			return err
 		}
 
@@ -248,7 +248,7 @@ func checkForFIPS(sandboxConfig *vc.SandboxConfig) error {
 }
 
 // CreateContainer create a container
-func CreateContainer(ctx context.Context, sandbox vc.VCSandbox, ociSpec specs.Spec, rootFs vc.RootFs, containerID, bundlePath string, disableOutput bool, disableGuestEmptyDir bool) (vc.Process, error) {
+func CreateContainer(ctx context.Context, sandbox vc.VCSandbox, ociSpec specs.Spec, rootFs vc.RootFs, containerID, bundlePath string, disableOutput bool, disableGuestEmptyDir bool, hookPolicy oci.HookPolicy) (vc.Process, error) {
 	var c vc.VCContainer
 
 	span, ctx := katatrace.Trace(ctx, nil, "CreateContainer", createTracingTags)
@@ -299,12 +299,12 @@ func CreateContainer(ctx context.Context, sandbox vc.VCSandbox, ociSpec specs.Sp
 
 	err = EnterNetNS(sandbox.GetNetNs(), func() error {
 		// Run pre-start OCI hooks, in the runtime namespace.
-		if err := PreStartHooks(ctx, ociSpec, containerID, bundlePath); err != nil {
+		if err := PreStartHooks(ctx, hookPolicy, ociSpec, containerID, bundlePath); err != nil {
 			return err
 		}
 
 		// Run create runtime OCI hooks, in the runtime namespace.
-		if err := CreateRuntimeHooks(ctx, ociSpec, containerID, bundlePath); err != nil {
+		if err := CreateRuntimeHooks(ctx, hookPolicy, ociSpec, containerID, bundlePath); err != nil {
 			return err
 		}
 
@@ -10,15 +10,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils/katatrace"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
 	syscallWrapper "github.com/kata-containers/kata-containers/src/runtime/pkg/syscall"
 	vc "github.com/kata-containers/kata-containers/src/runtime/virtcontainers"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -34,11 +38,105 @@ func hookLogger() *logrus.Entry {
 	return kataUtilsLogger.WithField("subsystem", "hook")
 }
 
-func runHook(ctx context.Context, spec specs.Spec, hook specs.Hook, cid, bundlePath string) error {
+// hookOutputLimit is the size of the output of a hook that is kept, for each
+// of stdout and stderr.
+const hookOutputLimit = 4096
+
+// hookWaitDelay is how long the output of a hook is waited for once it
+// exited, the processes it left behind may keep its stdout and stderr open.
+const hookWaitDelay = time.Second
+
+// The results of the hooks, as reported by the hook metrics.
+const (
+	hookResultSuccess = "success"
+	hookResultError   = "error"
+	hookResultTimeout = "timeout"
+)
+
+var hookDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "kata_shim",
+	Name:      "hook_duration_seconds",
+	Help:      "Duration of the OCI hooks run on the host.",
+	Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+},
+	[]string{"type", "result"},
+)
+
+// RegisterMetrics registers the metrics of the OCI hooks.
+func RegisterMetrics() {
+	prometheus.MustRegister(hookDurations)
+}
+
+// HookError is the error of an OCI hook that failed, with the beginning of
+// its output.
+type HookError struct {
+	Err    error
+	Type   string
+	Path   string
+	Stdout string
+	Stderr string
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %s: %s: stdout: %s, stderr: %s", e.Type, e.Path, e.Err, e.Stdout, e.Stderr)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// limitedBuffer keeps the first bytes written to it, up to its limit, and
+// discards the others.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// hookAllowed returns whether the policy allows the hook at path.
+func hookAllowed(policy oci.HookPolicy, path string) bool {
+	if len(policy.AllowedPaths) == 0 {
+		return true
+	}
+
+	for _, glob := range policy.AllowedPaths {
+		if match, _ := filepath.Match(glob, filepath.Clean(path)); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hookTimeout returns the timeout of the hook, its own or the default one of
+// the policy. No timeout when zero.
+func hookTimeout(policy oci.HookPolicy, hook specs.Hook) time.Duration {
+	if hook.Timeout != nil && *hook.Timeout > 0 {
+		return time.Duration(*hook.Timeout) * time.Second
+	}
+	return time.Duration(policy.Timeout) * time.Second
+}
+
+// runHook runs the hook, named name when confined, according to the policy.
+func runHook(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, hook specs.Hook, cid, bundlePath, hookType, name string) error {
 	span, _ := katatrace.Trace(ctx, hookLogger(), "runHook", hookTracingTags)
 	defer span.End()
 	katatrace.AddTags(span, "path", hook.Path, "args", hook.Args)
 
+	if !hookAllowed(policy, hook.Path) {
+		return &HookError{
+			Err:  errors.New("not allowed by the hook policy"),
+			Type: hookType,
+			Path: hook.Path,
+		}
+	}
+
 	pid, ok := ctx.Value(vc.HypervisorPidKey{}).(int)
 	if !ok || pid == 0 {
 		hookLogger().Info("no hypervisor pid")
@@ -59,55 +157,99 @@ func runHook(ctx context.Context, spec specs.Spec, hook specs.Hook, cid, bundleP
 		return err
 	}
 
-	var stdout, stderr bytes.Buffer
+	stdout := &limitedBuffer{limit: hookOutputLimit}
+	stderr := &limitedBuffer{limit: hookOutputLimit}
 	cmd := &exec.Cmd{
-		Path:   hook.Path,
-		Args:   hook.Args,
-		Env:    hook.Env,
-		Stdin:  bytes.NewReader(stateJSON),
-		Stdout: &stdout,
-		Stderr: &stderr,
+		Path:      hook.Path,
+		Args:      hook.Args,
+		Env:       hook.Env,
+		Stdin:     bytes.NewReader(stateJSON),
+		Stdout:    stdout,
+		Stderr:    stderr,
+		WaitDelay: hookWaitDelay,
+	}
+
+	// Kills the process of the hook, or all the processes of its cgroup
+	// when confined.
+	kill := func() error {
+		return syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if policy.Confine {
+		cgroup, err := confineHook(cmd, name)
+		if err != nil {
+			return &HookError{Err: err, Type: hookType, Path: hook.Path}
+		}
+		defer cgroup.release()
+		kill = cgroup.kill
 	}
 
+	hookErr := func(err error) error {
+		return &HookError{
+			Err:    err,
+			Type:   hookType,
+			Path:   hook.Path,
+			Stdout: stdout.String(),
+			Stderr: stderr.String(),
+		}
+	}
+
+	start := time.Now()
+	result := hookResultSuccess
+	defer func() {
+		hookDurations.WithLabelValues(hookType, result).Observe(time.Since(start).Seconds())
+	}()
+
 	if err := cmd.Start(); err != nil {
-		return err
+		result = hookResultError
+		return hookErr(err)
 	}
 
-	if hook.Timeout == nil {
-		if err := cmd.Wait(); err != nil {
-			return fmt.Errorf("%s: stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		close(done)
+	}()
+
+	var timeout <-chan time.Time
+	if d := hookTimeout(policy, hook); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			result = hookResultError
+			return hookErr(err)
 		}
-	} else {
-		done := make(chan error, 1)
-		go func() {
-			done <- cmd.Wait()
-			close(done)
-		}()
-
-		select {
-		case err := <-done:
-			if err != nil {
-				return fmt.Errorf("%s: stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
-			}
-		case <-time.After(time.Duration(*hook.Timeout) * time.Second):
-			if err := syscall.Kill(cmd.Process.Pid, syscall.SIGKILL); err != nil {
-				return err
+	case <-timeout:
+		result = hookResultTimeout
+		if err := kill(); err != nil {
+			// The output is still being written while the hook runs.
+			return &HookError{
+				Err:  fmt.Errorf("failed to kill the hook after its timeout: %w", err),
+				Type: hookType,
+				Path: hook.Path,
 			}
-
-			return fmt.Errorf("Hook timeout")
 		}
+		// The output is only complete once the hook exited.
+		<-done
+
+		return hookErr(fmt.Errorf("timeout after %s", hookTimeout(policy, hook)))
 	}
 
 	return nil
 }
 
-func runHooks(ctx context.Context, spec specs.Spec, hooks []specs.Hook, cid, bundlePath, hookType string) error {
+func runHooks(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, hooks []specs.Hook, cid, bundlePath, hookType string) error {
 	span, ctx := katatrace.Trace(ctx, hookLogger(), "runHooks", hookTracingTags)
 	katatrace.AddTags(span, "type", hookType)
 	defer span.End()
 
-	for _, hook := range hooks {
-		if err := runHook(ctx, spec, hook, cid, bundlePath); err != nil {
+	for i, hook := range hooks {
+		name := fmt.Sprintf("%s-%s-%d", cid, hookType, i)
+		if err := runHook(ctx, policy, spec, hook, cid, bundlePath, hookType, name); err != nil {
 			hookLogger().WithFields(logrus.Fields{
 				"hook-type": hookType,
 				"error":     err,
@@ -120,41 +262,43 @@ func runHooks(ctx context.Context, spec specs.Spec, hooks []specs.Hook, cid, bun
 	return nil
 }
 
-func CreateRuntimeHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+// CreateRuntimeHooks run the hooks after the runtime environment of the
+// container has been created
+func CreateRuntimeHooks(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, cid, bundlePath string) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec, spec.Hooks.CreateRuntime, cid, bundlePath, "createRuntime")
+	return runHooks(ctx, policy, spec, spec.Hooks.CreateRuntime, cid, bundlePath, "createRuntime")
 }
 
 // PreStartHooks run the hooks before start container
-func PreStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+func PreStartHooks(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, cid, bundlePath string) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec, spec.Hooks.Prestart, cid, bundlePath, "pre-start") //nolint:all
+	return runHooks(ctx, policy, spec, spec.Hooks.Prestart, cid, bundlePath, "pre-start") //nolint:all
 }
 
 // PostStartHooks run the hooks just after start container
-func PostStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+func PostStartHooks(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, cid, bundlePath string) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec, spec.Hooks.Poststart, cid, bundlePath, "post-start")
+	return runHooks(ctx, policy, spec, spec.Hooks.Poststart, cid, bundlePath, "post-start")
 }
 
 // PostStopHooks run the hooks after stop container
-func PostStopHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+func PostStopHooks(ctx context.Context, policy oci.HookPolicy, spec specs.Spec, cid, bundlePath string) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec, spec.Hooks.Poststop, cid, bundlePath, "post-stop")
+	return runHooks(ctx, policy, spec, spec.Hooks.Poststop, cid, bundlePath, "post-stop")
 }
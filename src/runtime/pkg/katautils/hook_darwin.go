@@ -0,0 +1,26 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katautils
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// hookCgroup is the cgroup of a confined hook.
+type hookCgroup struct{}
+
+// confineHook is not supported on Darwin.
+func confineHook(cmd *exec.Cmd, name string) (*hookCgroup, error) {
+	return nil, errors.New("confining hooks is not supported")
+}
+
+func (h *hookCgroup) kill() error {
+	return nil
+}
+
+func (h *hookCgroup) release() {
+}
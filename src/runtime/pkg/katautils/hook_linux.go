@@ -0,0 +1,108 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katautils
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/containerd/cgroups"
+	cgroupsv2 "github.com/containerd/cgroups/v2"
+	resCtrl "github.com/kata-containers/kata-containers/src/runtime/pkg/resourcecontrol"
+	"golang.org/x/sys/unix"
+)
+
+// hookCgroupPrefix prefixes the names of the cgroups of the confined hooks,
+// which are children of the cgroup of the runtime.
+const hookCgroupPrefix = "kata_hook-"
+
+// hookCgroupRoot is the mount point of the cgroup v2 unified hierarchy.
+const hookCgroupRoot = "/sys/fs/cgroup"
+
+// hookCgroupDeleteTimeout is how long the processes of a confined hook are
+// waited for once killed, before giving up on removing its cgroup.
+const hookCgroupDeleteTimeout = 5 * time.Second
+
+// hookCgroup is the cgroup of a confined hook.
+type hookCgroup struct {
+	cgroup *resCtrl.CgroupV2
+	fd     int
+}
+
+// confineHook sets cmd up to run in its own mount namespace, so that the
+// mounts of the hook do not leak to the host, and in its own cgroup named
+// after name under the one of the runtime, so that all the processes of the
+// hook can be killed and are accounted with the runtime. Only the cgroup v2
+// hierarchy allows starting a process in a cgroup.
+func confineHook(cmd *exec.Cmd, name string) (*hookCgroup, error) {
+	if cgroups.Mode() != cgroups.Unified {
+		return nil, errors.New("confining hooks requires the cgroup v2 unified hierarchy")
+	}
+
+	path, err := cgroupsv2.NestedGroupPath(hookCgroupPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ancestors of the cgroup are not owned by the runtime, so no
+	// controller is enabled for it: the hook is only grouped and accounted
+	// with the runtime.
+	if err := os.Mkdir(filepath.Join(hookCgroupRoot, path), 0755); err != nil {
+		return nil, err
+	}
+	cgroup, err := resCtrl.LoadCgroupV2(hookCgroupRoot, path)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Open(cgroup.Dir(), unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		cgroup.Delete()
+		return nil, err
+	}
+
+	// The mount namespace is made private before the hook is executed.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Unshareflags: syscall.CLONE_NEWNS,
+		UseCgroupFD:  true,
+		CgroupFD:     fd,
+	}
+
+	return &hookCgroup{
+		cgroup: cgroup,
+		fd:     fd,
+	}, nil
+}
+
+// kill kills all the processes of the hook.
+func (h *hookCgroup) kill() error {
+	return h.cgroup.Kill()
+}
+
+// release kills the processes left behind by the hook and removes its
+// cgroup.
+func (h *hookCgroup) release() {
+	unix.Close(h.fd)
+
+	if err := h.cgroup.Kill(); err != nil {
+		hookLogger().WithError(err).WithField("cgroup", h.cgroup.ID()).Warn("failed to kill the hook processes")
+	}
+
+	// The cgroup can only be removed once the processes exited.
+	deadline := time.Now().Add(hookCgroupDeleteTimeout)
+	err := h.cgroup.Delete()
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		err = h.cgroup.Delete()
+	}
+	if err != nil {
+		hookLogger().WithError(err).WithField("cgroup", h.cgroup.ID()).Warn("failed to remove the hook cgroup")
+	}
+}
@@ -8,11 +8,19 @@ package katautils
 
 import (
 	"context"
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/containerd/cgroups"
+	cgroupsv2 "github.com/containerd/cgroups/v2"
 	ktu "github.com/kata-containers/kata-containers/src/runtime/pkg/katatestutils"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/oci"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -67,26 +75,163 @@ func TestRunHook(t *testing.T) {
 
 	// Run with timeout 0
 	hook := createHook(0)
-	err := runHook(ctx, spec, hook, testSandboxID, testBundlePath)
+	err := runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "test", testSandboxID)
 	assert.NoError(err)
 
 	// Run with timeout 1
 	hook = createHook(1)
-	err = runHook(ctx, spec, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "test", testSandboxID)
 	assert.NoError(err)
 
 	// Run timeout failure
 	hook = createHook(1)
 	hook.Args = append(hook.Args, "2")
-	err = runHook(ctx, spec, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "test", testSandboxID)
 	assert.Error(err)
 
 	// Failure due to wrong hook
 	hook = createWrongHook()
-	err = runHook(ctx, spec, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "test", testSandboxID)
 	assert.Error(err)
 }
 
+// createScriptHook returns a hook running the shell script.
+func createScriptHook(t *testing.T, script string) specs.Hook {
+	path := filepath.Join(t.TempDir(), "hook")
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+
+	return specs.Hook{
+		Path: path,
+		Args: []string{path},
+	}
+}
+
+func TestHookAllowed(t *testing.T) {
+	assert := assert.New(t)
+
+	// All the hooks are allowed without allow-list
+	assert.True(hookAllowed(oci.HookPolicy{}, "/usr/bin/hook"))
+
+	policy := oci.HookPolicy{
+		AllowedPaths: []string{"/usr/libexec/oci/hooks.d/*", "/usr/bin/nvidia-container-runtime-hook"},
+	}
+	assert.True(hookAllowed(policy, "/usr/libexec/oci/hooks.d/hook"))
+	assert.True(hookAllowed(policy, "/usr/bin/nvidia-container-runtime-hook"))
+	assert.False(hookAllowed(policy, "/usr/bin/hook"))
+	assert.False(hookAllowed(policy, "/usr/libexec/oci/hooks.d/../../../bin/hook"))
+}
+
+func TestHookTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := oci.HookPolicy{Timeout: 10}
+	assert.Equal(10*time.Second, hookTimeout(policy, specs.Hook{}))
+
+	// The timeout of the hook takes precedence
+	timeout := 2
+	assert.Equal(2*time.Second, hookTimeout(policy, specs.Hook{Timeout: &timeout}))
+
+	// No timeout
+	assert.Zero(hookTimeout(oci.HookPolicy{}, specs.Hook{}))
+}
+
+func TestRunHookPolicy(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	spec := specs.Spec{}
+
+	durations := func(result string) int {
+		count := 0
+		ch := make(chan prometheus.Metric, 64)
+		hookDurations.Collect(ch)
+		close(ch)
+		for m := range ch {
+			var metric dto.Metric
+			assert.NoError(m.Write(&metric))
+			labels := make(map[string]string)
+			for _, l := range metric.Label {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["type"] == "policy-test" && labels["result"] == result {
+				count += int(metric.Histogram.GetSampleCount())
+			}
+		}
+		return count
+	}
+
+	hook := createScriptHook(t, "cat > /dev/null; echo hello\n")
+	assert.NoError(runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "policy-test", testSandboxID))
+	assert.Equal(1, durations(hookResultSuccess))
+
+	// Hooks outside of the allow-list are not run
+	policy := oci.HookPolicy{AllowedPaths: []string{"/usr/libexec/oci/hooks.d/*"}}
+	err := runHook(ctx, policy, spec, hook, testSandboxID, testBundlePath, "policy-test", testSandboxID)
+	assert.Error(err)
+	assert.Contains(err.Error(), "not allowed")
+
+	// The output of the failed hooks is part of the error
+	hook = createScriptHook(t, "echo out; echo err >&2; exit 3\n")
+	err = runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "policy-test", testSandboxID)
+	var hookErr *HookError
+	assert.True(errors.As(err, &hookErr))
+	assert.Equal("policy-test", hookErr.Type)
+	assert.Equal(hook.Path, hookErr.Path)
+	assert.Equal("out\n", hookErr.Stdout)
+	assert.Equal("err\n", hookErr.Stderr)
+	assert.Equal(1, durations(hookResultError))
+
+	// The output kept is limited
+	hook = createScriptHook(t, "head -c 10000 /dev/zero\n")
+	assert.NoError(runHook(ctx, oci.HookPolicy{}, spec, hook, testSandboxID, testBundlePath, "policy-test", testSandboxID))
+
+	// The default timeout of the policy applies to the hooks without one
+	hook = createScriptHook(t, "echo started; exec sleep 60\n")
+	start := time.Now()
+	err = runHook(ctx, oci.HookPolicy{Timeout: 1}, spec, hook, testSandboxID, testBundlePath, "policy-test", testSandboxID)
+	assert.True(errors.As(err, &hookErr))
+	assert.Contains(hookErr.Err.Error(), "timeout")
+	assert.Equal("started\n", hookErr.Stdout)
+	assert.Less(time.Since(start), 30*time.Second)
+	assert.Equal(1, durations(hookResultTimeout))
+}
+
+func TestRunHookConfined(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+	if cgroups.Mode() != cgroups.Unified {
+		t.Skip("confining hooks requires cgroup v2")
+	}
+
+	assert := assert.New(t)
+	ctx := context.Background()
+	policy := oci.HookPolicy{Confine: true, Timeout: 1}
+
+	name := "confined-test"
+	path, err := cgroupsv2.NestedGroupPath(hookCgroupPrefix + name)
+	assert.NoError(err)
+
+	// No controller is enabled under the cgroup of the runtime
+	subtreeControl := filepath.Join(hookCgroupRoot, filepath.Dir(path), "cgroup.subtree_control")
+	controllers, err := os.ReadFile(subtreeControl)
+	assert.NoError(err)
+
+	// The hook runs in its own cgroup
+	hook := createScriptHook(t, "cat /proc/self/cgroup\n")
+	err = runHook(ctx, policy, specs.Spec{}, hook, testSandboxID, testBundlePath, "test", name)
+	assert.NoError(err)
+
+	current, err := os.ReadFile(subtreeControl)
+	assert.NoError(err)
+	assert.Equal(string(controllers), string(current))
+
+	// The processes left behind by a hook are killed with it
+	hook = createScriptHook(t, "sleep 60 &\nexec sleep 60\n")
+	err = runHook(ctx, policy, specs.Spec{}, hook, testSandboxID, testBundlePath, "test", name)
+	assert.Error(err)
+	assert.NoDirExists(filepath.Join(hookCgroupRoot, path))
+}
+
 func TestPreStartHooks(t *testing.T) {
 	if tc.NotValid(ktu.NeedRoot()) {
 		t.Skip(ktu.TestDisabledNeedRoot)
@@ -99,14 +244,14 @@ func TestPreStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PreStartHooks(ctx, spec, "", "")
+	err := PreStartHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PreStartHooks(ctx, spec, "", "")
+	err = PreStartHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -116,7 +261,7 @@ func TestPreStartHooks(t *testing.T) {
 			Prestart: []specs.Hook{hook}, //nolint:all
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -126,7 +271,7 @@ func TestPreStartHooks(t *testing.T) {
 			Prestart: []specs.Hook{hook}, //nolint:all
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.Error(err)
 }
 
@@ -142,14 +287,14 @@ func TestPostStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PostStartHooks(ctx, spec, "", "")
+	err := PostStartHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PostStartHooks(ctx, spec, "", "")
+	err = PostStartHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -159,7 +304,7 @@ func TestPostStartHooks(t *testing.T) {
 			Poststart: []specs.Hook{hook},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -169,7 +314,7 @@ func TestPostStartHooks(t *testing.T) {
 			Poststart: []specs.Hook{hook},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.Error(err)
 }
 
@@ -185,14 +330,14 @@ func TestPostStopHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PostStopHooks(ctx, spec, "", "")
+	err := PostStopHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PostStopHooks(ctx, spec, "", "")
+	err = PostStopHooks(ctx, oci.HookPolicy{}, spec, "", "")
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -202,7 +347,7 @@ func TestPostStopHooks(t *testing.T) {
 			Poststop: []specs.Hook{hook},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -212,6 +357,6 @@ func TestPostStopHooks(t *testing.T) {
 			Poststop: []specs.Hook{hook},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, oci.HookPolicy{}, spec, testSandboxID, testBundlePath)
 	assert.Error(err)
 }
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"regexp"
 	goruntime "runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -106,6 +107,21 @@ type FactoryConfig struct {
 	Template bool
 }
 
+// HookPolicy is the policy the OCI hooks of the containers are run with on
+// the host.
+type HookPolicy struct {
+	// AllowedPaths are the globs the paths of the hooks must match. All
+	// the hooks are allowed when empty.
+	AllowedPaths []string
+
+	// Timeout is the timeout of the hooks that do not set one, in
+	// seconds. No timeout when zero.
+	Timeout uint32
+
+	// Confine runs each hook in its own mount namespace and cgroup.
+	Confine bool
+}
+
 // RuntimeConfig aggregates all runtime specific settings
 // nolint: govet
 type RuntimeConfig struct {
@@ -120,6 +136,10 @@ type RuntimeConfig struct {
 	JaegerPassword string
 	HypervisorType vc.HypervisorType
 
+	// Profile is the name of the configuration profile merged over the
+	// base configuration, empty if none was selected.
+	Profile string
+
 	FactoryConfig    FactoryConfig
 	HypervisorConfig vc.HypervisorConfig
 	AgentConfig      vc.KataAgentConfig
@@ -153,6 +173,22 @@ type RuntimeConfig struct {
 	// any later resource updates.
 	StaticSandboxResourceMgmt bool
 
+	// Rightsizing configures the controller resizing the sandbox according
+	// to the guest usage.
+	Rightsizing vc.RightsizingConfig
+
+	// GuestTimeSync configures the periodic synchronization of the guest
+	// clock with the host one.
+	GuestTimeSync vc.GuestTimeSyncConfig
+
+	// HookPolicy is the policy the OCI hooks are run with on the host.
+	HookPolicy HookPolicy
+
+	// MemoryPressureInterval is the interval, in seconds, between two
+	// checks of the OOM kills and of the memory pressure of the sandbox.
+	// The checks are disabled when zero.
+	MemoryPressureInterval uint32
+
 	// Determines if create a netns for hypervisor process
 	DisableNewNetNs bool
 
@@ -501,6 +537,12 @@ func GetSandboxConfigPath(annotations map[string]string) string {
 	return annotations[vcAnnotations.SandboxConfigPathKey]
 }
 
+// GetSandboxConfigProfile returns the configuration profile requested for
+// the sandbox, if any.
+func GetSandboxConfigProfile(annotations map[string]string) string {
+	return annotations[vcAnnotations.SandboxConfigProfileKey]
+}
+
 // SandboxID determines the sandbox ID related to an OCI configuration. This function
 // is expected to be called only when the container type is "PodContainer".
 func SandboxID(spec specs.Spec) (string, error) {
@@ -691,6 +733,22 @@ func addHypervisorPathOverrides(ocispec specs.Spec, config *vc.SandboxConfig, ru
 		config.HypervisorConfig.JailerPath = value
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.GuestHooks]; ok {
+		hooks, err := vc.ParseGuestHooks([]byte(value))
+		if err != nil {
+			return fmt.Errorf("Error parsing guest hooks in annotation guest_hooks: %v", err)
+		}
+		for _, hook := range hooks {
+			if hook.Digest == "" {
+				return fmt.Errorf("guest hook %v required from annotation has no digest", hook.Hook.Path)
+			}
+			if !checkPathIsInGlobs(runtime.HypervisorConfig.GuestHooksPathList, hook.Hook.Path) {
+				return fmt.Errorf("guest hook %v required from annotation is not valid", hook.Hook.Path)
+			}
+		}
+		config.HypervisorConfig.GuestHooks = slices.Concat(config.HypervisorConfig.GuestHooks, hooks)
+	}
+
 	if value, ok := ocispec.Annotations[vcAnnotations.KernelParams]; ok {
 		if value != "" {
 			params := vc.DeserializeParams(strings.Fields(value))
@@ -1163,6 +1221,23 @@ func addRuntimeConfigOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig, r
 		return err
 	}
 
+	if err := newAnnotationConfiguration(ocispec, vcAnnotations.PodOverheadCPU).setFloat32WithCheck(func(cpus float32) error {
+		sbConfig.SandboxResources.OverheadCPUs = cpus
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := newAnnotationConfiguration(ocispec, vcAnnotations.PodOverheadMemory).setUintWithCheck(func(memMB uint64) error {
+		if memMB > math.MaxUint32 {
+			return fmt.Errorf(errAnnotationNumericKeyIsTooBig, vcAnnotations.PodOverheadMemory)
+		}
+		sbConfig.SandboxResources.OverheadMemMB = uint32(memMB)
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	if err := newAnnotationConfiguration(ocispec, vcAnnotations.CreateContainerTimeout).setUint(func(createContainerTimeout uint64) {
 		sbConfig.CreateContainerTimeout = createContainerTimeout
 	}); err != nil {
@@ -1302,6 +1377,10 @@ func SandboxConfig(ocispec specs.Spec, runtime RuntimeConfig, bundlePath, cid st
 
 		StaticResourceMgmt: runtime.StaticSandboxResourceMgmt,
 
+		Rightsizing: runtime.Rightsizing,
+
+		GuestTimeSync: runtime.GuestTimeSync,
+
 		ShmSize: shmSize,
 
 		VfioMode: runtime.VfioMode,
@@ -625,6 +625,64 @@ func TestAddAssetAnnotations(t *testing.T) {
 	assert.Exactly(expectedAnnotations, config.Annotations)
 }
 
+func TestAddGuestHooksAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir := t.TempDir()
+	hookPath := filepath.Join(tmpdir, "hook")
+	assert.NoError(os.WriteFile(hookPath, []byte{}, 0755))
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	runtimeConfig := RuntimeConfig{
+		HypervisorType: vc.QemuHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			EnableAnnotations: []string{"guest_hooks"},
+			GuestHooks: []vc.GuestHook{
+				{
+					Version: "1.0.0",
+					Hook:    specs.Hook{Path: "/usr/libexec/hook"},
+					Stages:  []string{vc.GuestHookPrestart},
+				},
+			},
+		},
+	}
+
+	addGuestHooks := func(value string) (vc.SandboxConfig, error) {
+		config := vc.SandboxConfig{
+			Annotations:      make(map[string]string),
+			HypervisorConfig: runtimeConfig.HypervisorConfig,
+		}
+		ocispec := specs.Spec{
+			Annotations: map[string]string{
+				vcAnnotations.GuestHooks: value,
+			},
+		}
+		return config, addAnnotations(ocispec, &config, runtimeConfig)
+	}
+
+	hooks := `[{"version": "1.0.0", "hook": {"path": "` + hookPath + `"}, "stages": ["poststart"], "digest": "` + digest + `"}]`
+
+	// The path of the hook is not valid
+	_, err := addGuestHooks(hooks)
+	assert.Error(err)
+
+	runtimeConfig.HypervisorConfig.GuestHooksPathList = []string{tmpdir + "/*"}
+	config, err := addGuestHooks(hooks)
+	assert.NoError(err)
+	assert.Len(config.HypervisorConfig.GuestHooks, 2)
+	assert.Equal(hookPath, config.HypervisorConfig.GuestHooks[1].Hook.Path)
+	assert.Equal(digest, config.HypervisorConfig.GuestHooks[1].Digest)
+	// The configured hooks are not modified
+	assert.Len(runtimeConfig.HypervisorConfig.GuestHooks, 1)
+
+	// The hooks required from annotations must have a digest
+	_, err = addGuestHooks(`[{"version": "1.0.0", "hook": {"path": "` + hookPath + `"}, "stages": ["poststart"]}]`)
+	assert.Error(err)
+
+	_, err = addGuestHooks(`{"version": "1.0.0"}`)
+	assert.Error(err)
+}
+
 func TestAddAgentAnnotations(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1054,6 +1112,8 @@ func TestAddRuntimeAnnotations(t *testing.T) {
 	ocispec.Annotations[vcAnnotations.DisableNewNetNs] = "true"
 	ocispec.Annotations[vcAnnotations.InterNetworkModel] = "macvtap"
 	ocispec.Annotations[vcAnnotations.CreateContainerTimeout] = "100"
+	ocispec.Annotations[vcAnnotations.PodOverheadCPU] = "0.25"
+	ocispec.Annotations[vcAnnotations.PodOverheadMemory] = "160"
 
 	// Note that the initdata annotation parsing logic will extract it into plaintext
 	ocispec.Annotations[vcAnnotations.Initdata] = "H4sIAFlC92cAAytLLSrOzM9TsFVQMtAz1DNQ4krMSc8vyizJyAWJFWckGpmaKXFFpySWJMZyKSUm6pXk5+YoAeXU1dW5QJhLKTklA4toQX5OZnKlXlFqej6yBABS/5JkcQAAAA=="
@@ -1064,6 +1124,8 @@ func TestAddRuntimeAnnotations(t *testing.T) {
 	assert.Equal(config.NetworkConfig.DisableNewNetwork, true)
 	assert.Equal(config.NetworkConfig.InterworkingModel, vc.NetXConnectMacVtapModel)
 	assert.Equal(config.CreateContainerTimeout, uint64(100))
+	assert.Equal(config.SandboxResources.OverheadCPUs, float32(0.25))
+	assert.Equal(config.SandboxResources.OverheadMemMB, uint32(160))
 	assert.Equal(config.HypervisorConfig.Initdata, `version = "0.1.0"
 algorithm = "sha256"
 [data]
@@ -15,7 +15,6 @@ import (
 	"sync"
 
 	"github.com/containerd/cgroups"
-	cgroupsv2 "github.com/containerd/cgroups/v2"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 )
@@ -29,6 +28,9 @@ const (
 
 	// cgroup v2 mount point
 	unifiedMountpoint = "/sys/fs/cgroup"
+
+	// The name of the overhead sub-controller of a sandbox controller.
+	overheadResourceControllerName = "overhead"
 )
 
 func RenameCgroupPath(path string) (string, error) {
@@ -41,12 +43,13 @@ func RenameCgroupPath(path string) (string, error) {
 	return filepath.Join(cgroupPathDir, cgroupPathName), nil
 }
 
+// LinuxCgroup is a resource controller managing the cgroups of the cgroup v1
+// hierarchies, the cgroups of the unified hierarchy are managed by CgroupV2.
 type LinuxCgroup struct {
-	cgroup            interface{}
-	path              string
-	cpusets           *specs.LinuxCPU
-	devices           []specs.LinuxDeviceCgroup
-	sandboxCgroupOnly bool
+	cgroup  interface{}
+	path    string
+	cpusets *specs.LinuxCPU
+	devices []specs.LinuxDeviceCgroup
 
 	sync.Mutex
 }
@@ -170,10 +173,7 @@ func NewResourceController(path string, resources *specs.LinuxResources) (Resour
 		if err != nil {
 			return nil, err
 		}
-		cgroup, err = cgroupsv2.NewManager(unifiedMountpoint, cgroupPath, cgroupsv2.ToResources(resources))
-		if err != nil {
-			return nil, err
-		}
+		return NewCgroupV2(unifiedMountpoint, cgroupPath, resources)
 	} else {
 		return nil, ErrCgroupMode
 	}
@@ -202,6 +202,12 @@ func NewSandboxResourceController(path string, resources *specs.LinuxResources,
 		return NewResourceController(path, &sandboxResources)
 	}
 
+	if cgroups.Mode() == cgroups.Unified {
+		// The runtime process is added to the unit, it makes calling
+		// setupCgroups redundant
+		return NewSystemdCgroupV2(unifiedMountpoint, path, os.Getpid(), &sandboxResources)
+	}
+
 	var cgroup interface{}
 
 	slice, unit, err := getSliceAndUnit(path)
@@ -232,15 +238,6 @@ func NewSandboxResourceController(path string, resources *specs.LinuxResources,
 			}
 		}
 		cgroup = cg
-	} else if cgroups.Mode() == cgroups.Unified {
-		// load created cgroup and update with resources
-		cg, err := cgroupsv2.LoadSystemd(slice, unit)
-		if err != nil {
-			if cg.Update(cgroupsv2.ToResources(&sandboxResources)); err != nil {
-				return nil, err
-			}
-		}
-		cgroup = cg
 	} else {
 		return nil, ErrCgroupMode
 	}
@@ -254,7 +251,6 @@ func NewSandboxResourceController(path string, resources *specs.LinuxResources,
 }
 
 func LoadResourceController(path string, sandboxCgroupOnly bool) (ResourceController, error) {
-	var err error
 	var cgroup interface{}
 
 	// load created cgroup and update with resources
@@ -270,28 +266,16 @@ func LoadResourceController(path string, sandboxCgroupOnly bool) (ResourceContro
 		}
 	} else if cgroups.Mode() == cgroups.Unified {
 		if IsSystemdCgroup(path) && sandboxCgroupOnly {
-			slice, unit, err := getSliceAndUnit(path)
-			if err != nil {
-				return nil, err
-			}
-			cgroup, err = cgroupsv2.LoadSystemd(slice, unit)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			cgroup, err = cgroupsv2.LoadManager(unifiedMountpoint, path)
-			if err != nil {
-				return nil, err
-			}
+			return LoadSystemdCgroupV2(unifiedMountpoint, path)
 		}
+		return LoadCgroupV2(unifiedMountpoint, path)
 	} else {
 		return nil, ErrCgroupMode
 	}
 
 	return &LinuxCgroup{
-		sandboxCgroupOnly: sandboxCgroupOnly,
-		path:              path,
-		cgroup:            cgroup,
+		path:   path,
+		cgroup: cgroup,
 	}, nil
 }
 
@@ -303,13 +287,6 @@ func (c *LinuxCgroup) Delete() error {
 	switch cg := c.cgroup.(type) {
 	case cgroups.Cgroup:
 		return cg.Delete()
-	case *cgroupsv2.Manager:
-		if IsSystemdCgroup(c.ID()) && c.sandboxCgroupOnly {
-			if err := cg.DeleteSystemd(); err != nil {
-				return err
-			}
-		}
-		return cg.Delete()
 	default:
 		return ErrCgroupMode
 	}
@@ -319,8 +296,6 @@ func (c *LinuxCgroup) Stat() (interface{}, error) {
 	switch cg := c.cgroup.(type) {
 	case cgroups.Cgroup:
 		return cg.Stat(cgroups.IgnoreNotExist)
-	case *cgroupsv2.Manager:
-		return cg.Stat()
 	default:
 		return nil, ErrCgroupMode
 	}
@@ -330,8 +305,6 @@ func (c *LinuxCgroup) AddProcess(pid int, subsystems ...string) error {
 	switch cg := c.cgroup.(type) {
 	case cgroups.Cgroup:
 		return cg.AddProc(uint64(pid))
-	case *cgroupsv2.Manager:
-		return cg.AddProc(uint64(pid))
 	default:
 		return ErrCgroupMode
 	}
@@ -341,8 +314,6 @@ func (c *LinuxCgroup) AddThread(pid int, subsystems ...string) error {
 	switch cg := c.cgroup.(type) {
 	case cgroups.Cgroup:
 		return cg.AddTask(cgroups.Process{Pid: pid})
-	case *cgroupsv2.Manager:
-		return cg.AddProc(uint64(pid))
 	default:
 		return ErrCgroupMode
 	}
@@ -352,8 +323,6 @@ func (c *LinuxCgroup) Update(resources *specs.LinuxResources) error {
 	switch cg := c.cgroup.(type) {
 	case cgroups.Cgroup:
 		return cg.Update(resources)
-	case *cgroupsv2.Manager:
-		return cg.Update(cgroupsv2.ToResources(resources))
 	default:
 		return ErrCgroupMode
 	}
@@ -371,12 +340,6 @@ func (c *LinuxCgroup) MoveTo(path string) error {
 			return err
 		}
 		return cg.MoveTo(newCgroup)
-	case *cgroupsv2.Manager:
-		newCgroup, err := cgroupsv2.LoadManager(unifiedMountpoint, path)
-		if err != nil {
-			return err
-		}
-		return cg.MoveTo(newCgroup)
 	default:
 		return ErrCgroupMode
 	}
@@ -400,12 +363,6 @@ func (c *LinuxCgroup) AddDevice(deviceHostPath string) error {
 		}); err != nil {
 			return err
 		}
-	case *cgroupsv2.Manager:
-		if err := cg.Update(cgroupsv2.ToResources(&specs.LinuxResources{
-			Devices: c.devices,
-		})); err != nil {
-			return err
-		}
 	default:
 		return ErrCgroupMode
 	}
@@ -437,12 +394,6 @@ func (c *LinuxCgroup) RemoveDevice(deviceHostPath string) error {
 		}); err != nil {
 			return err
 		}
-	case *cgroupsv2.Manager:
-		if err := cg.Update(cgroupsv2.ToResources(&specs.LinuxResources{
-			Devices: c.devices,
-		})); err != nil {
-			return err
-		}
 	default:
 		return ErrCgroupMode
 	}
@@ -477,10 +428,6 @@ func (c *LinuxCgroup) UpdateCpuSet(cpuset, memset string) error {
 		return cg.Update(&specs.LinuxResources{
 			CPU: c.cpusets,
 		})
-	case *cgroupsv2.Manager:
-		return cg.Update(cgroupsv2.ToResources(&specs.LinuxResources{
-			CPU: c.cpusets,
-		}))
 	default:
 		return ErrCgroupMode
 	}
@@ -497,3 +444,17 @@ func (c *LinuxCgroup) ID() string {
 func (c *LinuxCgroup) Parent() string {
 	return filepath.Dir(c.path)
 }
+
+// NewOverheadResourceController creates the overhead controller of a sandbox
+// as a sub-controller of its sandbox controller, for the Kata processes that
+// are not running the workload. It requires the cgroup v2 unified hierarchy:
+// cgroup v1 charges the memory of the VMM to the cgroup of its main thread,
+// which can't be told apart from the vCPU threads.
+func NewOverheadResourceController(sandbox ResourceController, resources *specs.LinuxResources) (ResourceController, error) {
+	cgroup, ok := sandbox.(*CgroupV2)
+	if !ok {
+		return nil, ErrCgroupMode
+	}
+
+	return cgroup.NewChild(overheadResourceControllerName, resources)
+}
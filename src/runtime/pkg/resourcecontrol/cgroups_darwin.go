@@ -25,6 +25,10 @@ func NewSandboxResourceController(path string, resources *specs.LinuxResources,
 	return &DarwinResourceController{}, nil
 }
 
+func NewOverheadResourceController(sandbox ResourceController, resources *specs.LinuxResources) (ResourceController, error) {
+	return &DarwinResourceController{}, nil
+}
+
 func LoadResourceController(path string) (ResourceController, error) {
 	return &DarwinResourceController{}, nil
 }
@@ -0,0 +1,991 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package resourcecontrol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	cgroupsv2 "github.com/containerd/cgroups/v2"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// The controllers enabled for the cgroups created by the runtime, when the
+// kernel provides them.
+var cgroupV2Controllers = []string{"cpuset", "cpu", "io", "memory", "hugetlb", "pids"}
+
+// The child holding the processes of a cgroup that has other children: only
+// a cgroup without processes can distribute its resources to its children.
+const cgroupV2LeafName = "leaf"
+
+// cgroupV2DeviceFilter is an eBPF device filter program.
+type cgroupV2DeviceFilter interface {
+	Close() error
+}
+
+// cgroupV2DeviceFilterOps loads eBPF device filters and attaches them to
+// cgroups, or detaches them. They are replaced by the tests.
+type cgroupV2DeviceFilterOps struct {
+	load   func(devices []specs.LinuxDeviceCgroup) (cgroupV2DeviceFilter, error)
+	attach func(dirFD int, filter cgroupV2DeviceFilter) error
+	detach func(dirFD int, filter cgroupV2DeviceFilter) error
+}
+
+var cgroupV2DeviceFilters = cgroupV2DeviceFilterOps{
+	load: func(devices []specs.LinuxDeviceCgroup) (cgroupV2DeviceFilter, error) {
+		insts, license, err := cgroupsv2.DeviceFilter(devices)
+		if err != nil {
+			return nil, err
+		}
+
+		return ebpf.NewProgram(&ebpf.ProgramSpec{
+			Type:         ebpf.CGroupDevice,
+			Instructions: insts,
+			License:      license,
+		})
+	},
+	attach: func(dirFD int, filter cgroupV2DeviceFilter) error {
+		return link.RawAttachProgram(link.RawAttachProgramOptions{
+			Target:  dirFD,
+			Program: filter.(*ebpf.Program),
+			Attach:  ebpf.AttachCGroupDevice,
+			Flags:   unix.BPF_F_ALLOW_MULTI,
+		})
+	},
+	detach: func(dirFD int, filter cgroupV2DeviceFilter) error {
+		return link.RawDetachProgram(link.RawDetachProgramOptions{
+			Target:  dirFD,
+			Program: filter.(*ebpf.Program),
+			Attach:  ebpf.AttachCGroupDevice,
+		})
+	},
+}
+
+// setCgroupV2Devices replaces previous, the device filter the runtime
+// attached to a cgroup if any, with a filter allowing devices, and returns
+// the filter now attached. Device filters are eBPF programs that can only be
+// attached to a real cgroup. A device is only allowed when all the filters
+// attached to the cgroup allow it: the filters attached by others, e.g. by
+// systemd, are left alone, and so is the one of a previous runtime instance.
+var setCgroupV2Devices = func(dir string, devices []specs.LinuxDeviceCgroup, previous cgroupV2DeviceFilter) (cgroupV2DeviceFilter, error) {
+	if len(devices) == 0 {
+		return previous, nil
+	}
+
+	dirFD, err := unix.Open(dir, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0600)
+	if err != nil {
+		return previous, fmt.Errorf("cannot get dir FD for %s: %w", dir, err)
+	}
+	defer unix.Close(dirFD)
+
+	ops := cgroupV2DeviceFilters
+
+	filter, err := ops.load(devices)
+	if err != nil {
+		return previous, err
+	}
+
+	if err := ops.attach(dirFD, filter); err != nil {
+		filter.Close()
+		return previous, fmt.Errorf("failed to attach the device filter to %s: %w", dir, err)
+	}
+
+	if previous != nil {
+		defer previous.Close()
+		if err := ops.detach(dirFD, previous); err != nil {
+			return filter, fmt.Errorf("failed to detach the previous device filter from %s: %w", dir, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// removeCgroupV2Dir removes an empty cgroup. The interface files of a cgroup
+// are not removed with it, unlike the files of a fake cgroupfs.
+var removeCgroupV2Dir = unix.Rmdir
+
+// CgroupV2 is a resource controller managing a cgroup of the cgroup v2
+// unified hierarchy through its interface files. The cgroup is either created
+// by the runtime, or created by systemd as a transient unit whose subtree is
+// delegated to the runtime.
+type CgroupV2 struct {
+	// Manages the systemd unit of the cgroup, nil when the cgroup is
+	// not managed by systemd.
+	systemd systemdUnitManager
+	// The cgroup v2 mount point, e.g. /sys/fs/cgroup.
+	root string
+	// The cgroup path, either relative to the mount point or a systemd
+	// slice:prefix:name path.
+	path string
+	// The cgroup directory.
+	dir string
+	// The systemd unit of the cgroup.
+	unit    string
+	cpusets *specs.LinuxCPU
+	devices []specs.LinuxDeviceCgroup
+	// The device filter the runtime attached to the cgroup, nil when
+	// none.
+	deviceFilter cgroupV2DeviceFilter
+
+	sync.Mutex
+}
+
+// NewCgroupV2 creates the cgroup at path under the root mount point, enables
+// the controllers for it and sets its resources.
+func NewCgroupV2(root, path string, resources *specs.LinuxResources) (*CgroupV2, error) {
+	dir := filepath.Join(root, path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := enableCgroupV2Controllers(root, filepath.Dir(dir)); err != nil {
+		return nil, err
+	}
+
+	c := &CgroupV2{
+		root: root,
+		path: path,
+		dir:  dir,
+	}
+
+	if resources != nil {
+		c.devices = resources.Devices
+		c.cpusets = resources.CPU
+		if err := c.Update(resources); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// NewSystemdCgroupV2 creates the systemd transient unit of the slice:prefix:name
+// path, with pid as its first process and with its subtree delegated to the
+// runtime, and sets its resources.
+func NewSystemdCgroupV2(root, path string, pid int, resources *specs.LinuxResources) (*CgroupV2, error) {
+	slice, unit, err := getSliceAndUnit(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := systemdCgroupV2Dir(root, slice, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	v2Resources := ToCgroupV2Resources(resources)
+	manager := newSystemdUnitManager()
+	if err := manager.StartTransientUnit(unit, systemdUnitProperties(slice, unit, pid, v2Resources)); err != nil {
+		return nil, fmt.Errorf("failed to start systemd unit %s: %w", unit, err)
+	}
+
+	c := &CgroupV2{
+		systemd: manager,
+		root:    root,
+		path:    path,
+		dir:     dir,
+		unit:    unit,
+	}
+
+	// systemd does not know about all the resources, the delegated
+	// cgroup is updated directly.
+	if resources != nil {
+		c.devices = resources.Devices
+		c.cpusets = resources.CPU
+		if err := c.Update(resources); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadCgroupV2 loads the existing cgroup at path under the root mount point.
+func LoadCgroupV2(root, path string) (*CgroupV2, error) {
+	dir := filepath.Join(root, path)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	return &CgroupV2{
+		root: root,
+		path: path,
+		dir:  dir,
+	}, nil
+}
+
+// LoadSystemdCgroupV2 loads the existing cgroup of the systemd unit of the
+// slice:prefix:name path.
+func LoadSystemdCgroupV2(root, path string) (*CgroupV2, error) {
+	slice, unit, err := getSliceAndUnit(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := systemdCgroupV2Dir(root, slice, unit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	return &CgroupV2{
+		systemd: newSystemdUnitManager(),
+		root:    root,
+		path:    path,
+		dir:     dir,
+		unit:    unit,
+	}, nil
+}
+
+func systemdCgroupV2Dir(root, slice, unit string) (string, error) {
+	slicePath, err := systemd.ExpandSlice(slice)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(root, slicePath, unit), nil
+}
+
+// enableCgroupV2Controllers enables the controllers of the runtime for the
+// children of dir and of all its ancestors up to the root mount point.
+func enableCgroupV2Controllers(root, dir string) error {
+	available, err := os.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	var controllers []string
+	for _, c := range strings.Fields(string(available)) {
+		for _, wanted := range cgroupV2Controllers {
+			if c == wanted {
+				controllers = append(controllers, "+"+c)
+			}
+		}
+	}
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("cgroup %s is not under %s", dir, root)
+	}
+
+	current := root
+	elems := []string{}
+	if rel != "." {
+		elems = strings.Split(rel, string(filepath.Separator))
+	}
+	for i := 0; i <= len(elems); i++ {
+		if i > 0 {
+			current = filepath.Join(current, elems[i-1])
+		}
+		enableCgroupV2ControllersIn(current, controllers)
+	}
+
+	return nil
+}
+
+// enableCgroupV2ControllersIn enables the controllers for the children of
+// dir. The controllers that can't be enabled, e.g. because an ancestor does
+// not enable them or because dir has processes, are left disabled.
+func enableCgroupV2ControllersIn(dir string, controllers []string) {
+	subtreeControl := filepath.Join(dir, "cgroup.subtree_control")
+	if err := os.WriteFile(subtreeControl, []byte(strings.Join(controllers, " ")), 0644); err == nil {
+		return
+	}
+
+	for _, c := range controllers {
+		if err := os.WriteFile(subtreeControl, []byte(c), 0644); err != nil {
+			controllerLogger.WithFields(logrus.Fields{
+				"source":     "cgroups",
+				"cgroup":     dir,
+				"controller": c,
+			}).WithError(err).Debug("failed to enable cgroup controller")
+		}
+	}
+}
+
+func (c *CgroupV2) Logger() *logrus.Entry {
+	return controllerLogger.WithFields(logrus.Fields{"source": "cgroups", "cgroup": c.path})
+}
+
+func (c *CgroupV2) Type() ResourceControllerType {
+	return LinuxCgroupsV2
+}
+
+func (c *CgroupV2) ID() string {
+	return c.path
+}
+
+// Parent returns the path of the parent cgroup, relative to the mount point.
+// The parent of a systemd unit is its slice.
+func (c *CgroupV2) Parent() string {
+	if slice, _, err := getSliceAndUnit(c.path); err == nil {
+		if slicePath, err := systemd.ExpandSlice(slice); err == nil {
+			return slicePath
+		}
+	}
+
+	return filepath.Dir(c.path)
+}
+
+// Dir returns the directory of the cgroup.
+func (c *CgroupV2) Dir() string {
+	return c.dir
+}
+
+// NewChild creates a cgroup named name under c, with its own resources. When
+// c is a systemd unit, the child lives in the subtree delegated to the
+// runtime. The processes of c, and the ones added to c afterwards, are moved
+// to its leaf child.
+func (c *CgroupV2) NewChild(name string, resources *specs.LinuxResources) (*CgroupV2, error) {
+	if name == "" || name == cgroupV2LeafName || strings.Contains(name, "/") || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid cgroup name %q", name)
+	}
+
+	leaf := filepath.Join(c.dir, cgroupV2LeafName)
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return nil, err
+	}
+	if err := moveCgroupV2Processes([]string{c.dir}, leaf); err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Rel(c.root, filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCgroupV2(c.root, "/"+path, resources)
+}
+
+func (c *CgroupV2) Delete() error {
+	// The cgroup holds its own reference to the attached device filter.
+	if c.deviceFilter != nil {
+		c.deviceFilter.Close()
+		c.deviceFilter = nil
+	}
+
+	if c.systemd != nil {
+		if err := c.systemd.StopUnit(c.unit); err != nil {
+			c.Logger().WithError(err).Warn("failed to stop the systemd unit")
+		}
+	}
+
+	// Remove the children first, a cgroup with children can't be removed.
+	dirs, err := cgroupV2Dirs(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := removeCgroupV2Dir(dirs[i]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove cgroup %s: %w", dirs[i], err)
+		}
+	}
+
+	return nil
+}
+
+func (c *CgroupV2) Stat() (interface{}, error) {
+	return c.Stats()
+}
+
+// Stats returns the statistics of the cgroup.
+func (c *CgroupV2) Stats() (*CgroupV2Stats, error) {
+	stats := &CgroupV2Stats{}
+
+	cpu, err := c.readKeyedValues("cpu.stat")
+	if err != nil {
+		return nil, err
+	}
+	stats.CPU = CgroupV2CPUStats{
+		UsageUsec:     cpu["usage_usec"],
+		UserUsec:      cpu["user_usec"],
+		SystemUsec:    cpu["system_usec"],
+		NrPeriods:     cpu["nr_periods"],
+		NrThrottled:   cpu["nr_throttled"],
+		ThrottledUsec: cpu["throttled_usec"],
+	}
+
+	memory := &stats.Memory
+	for file, value := range map[string]*uint64{
+		"memory.current":      &memory.Current,
+		"memory.swap.current": &memory.SwapCurrent,
+		"memory.min":          &memory.Min,
+		"memory.low":          &memory.Low,
+		"memory.high":         &memory.High,
+		"memory.max":          &memory.Max,
+		"pids.current":        &stats.Pids.Current,
+		"pids.max":            &stats.Pids.Max,
+	} {
+		if *value, err = c.readUint(file); err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := c.readKeyedValues("memory.events")
+	if err != nil {
+		return nil, err
+	}
+	memory.Events = CgroupV2MemoryEvents{
+		Low:     events["low"],
+		High:    events["high"],
+		Max:     events["max"],
+		OOM:     events["oom"],
+		OOMKill: events["oom_kill"],
+	}
+
+	if memory.Stat, err = c.readKeyedValues("memory.stat"); err != nil {
+		return nil, err
+	}
+
+	if stats.IO, err = c.readIOStats(); err != nil {
+		return nil, err
+	}
+
+	if stats.HugeTLB, err = c.readHugeTLBStats(); err != nil {
+		return nil, err
+	}
+
+	for file, pressure := range map[string]**Pressure{
+		"cpu.pressure":    &stats.Pressure.CPU,
+		"memory.pressure": &stats.Pressure.Memory,
+		"io.pressure":     &stats.Pressure.IO,
+	} {
+		if *pressure, err = c.readPressure(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+func (c *CgroupV2) AddProcess(pid int, subsystems ...string) error {
+	return addCgroupV2Process(c.dir, strconv.Itoa(pid))
+}
+
+// AddThread moves the process of the thread to the cgroup, threads can only be
+// moved on their own within a threaded subtree.
+func (c *CgroupV2) AddThread(tid int, subsystems ...string) error {
+	return addCgroupV2Process(c.dir, strconv.Itoa(tid))
+}
+
+// addCgroupV2Process moves a process to the cgroup at dir, or to its leaf
+// child when it has one.
+func addCgroupV2Process(dir, pid string) error {
+	if _, err := os.Stat(filepath.Join(dir, cgroupV2LeafName)); err == nil {
+		dir = filepath.Join(dir, cgroupV2LeafName)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(pid), 0644); err != nil {
+		return fmt.Errorf("failed to move process %s to %s: %w", pid, dir, err)
+	}
+	return nil
+}
+
+// moveCgroupV2Processes moves the processes of the cgroups at dirs to the
+// cgroup at target. The processes that exited in the meantime are ignored.
+func moveCgroupV2Processes(dirs []string, target string) error {
+	for _, dir := range dirs {
+		procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, pid := range strings.Fields(string(procs)) {
+			if err := addCgroupV2Process(target, pid); err != nil && !errors.Is(err, unix.ESRCH) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *CgroupV2) Update(resources *specs.LinuxResources) error {
+	if resources == nil {
+		return nil
+	}
+
+	if err := c.UpdateResources(ToCgroupV2Resources(resources)); err != nil {
+		return err
+	}
+
+	return c.setDevices(resources.Devices)
+}
+
+// setDevices replaces the device filter the runtime attached to the cgroup
+// with one allowing devices.
+func (c *CgroupV2) setDevices(devices []specs.LinuxDeviceCgroup) error {
+	filter, err := setCgroupV2Devices(c.dir, devices, c.deviceFilter)
+	c.deviceFilter = filter
+	return err
+}
+
+// UpdateResources writes the resources to the interface files of the cgroup.
+func (c *CgroupV2) UpdateResources(r *CgroupV2Resources) error {
+	if r == nil {
+		return nil
+	}
+
+	// The CPUs and memory nodes come first, the other controllers may
+	// depend on them.
+	if r.Cpus != "" {
+		if err := c.writeFile("cpuset.cpus", r.Cpus); err != nil {
+			return err
+		}
+	}
+	if r.Mems != "" {
+		if err := c.writeFile("cpuset.mems", r.Mems); err != nil {
+			return err
+		}
+	}
+
+	if r.CPUWeight != nil {
+		if err := c.writeFile("cpu.weight", strconv.FormatUint(*r.CPUWeight, 10)); err != nil {
+			return err
+		}
+	}
+	if r.CPUIdle != nil {
+		idle := "0"
+		if *r.CPUIdle {
+			idle = "1"
+		}
+		if err := c.writeFile("cpu.idle", idle); err != nil {
+			return err
+		}
+	}
+	if r.CPUQuota != nil || r.CPUPeriod != nil {
+		cpuMax, err := c.cpuMax(r.CPUQuota, r.CPUPeriod)
+		if err != nil {
+			return err
+		}
+		if err := c.writeFile("cpu.max", cpuMax); err != nil {
+			return err
+		}
+	}
+
+	for file, limit := range map[string]*int64{
+		"memory.min":      r.MemoryMin,
+		"memory.low":      r.MemoryLow,
+		"memory.high":     r.MemoryHigh,
+		"memory.max":      r.MemoryMax,
+		"memory.swap.max": r.MemorySwapMax,
+		"pids.max":        r.PidsMax,
+	} {
+		if limit == nil {
+			continue
+		}
+		if err := c.writeFile(file, cgroupV2Limit(*limit)); err != nil {
+			return err
+		}
+	}
+
+	for _, io := range r.IOMax {
+		if err := c.writeFile("io.max", io.String()); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range r.HugeTLBMax {
+		if err := c.writeFile("hugetlb."+h.PageSize+".max", strconv.FormatUint(h.Max, 10)); err != nil {
+			return err
+		}
+	}
+
+	files := make([]string, 0, len(r.Unified))
+	for file := range r.Unified {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if strings.Contains(file, "/") || strings.HasPrefix(file, "cgroup.") {
+			return fmt.Errorf("invalid unified resource %q", file)
+		}
+		if err := c.writeFile(file, r.Unified[file]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cpuMax returns the cpu.max value for the quota and the period, the current
+// value is kept for the one that is not set.
+func (c *CgroupV2) cpuMax(quota *int64, period *uint64) (string, error) {
+	current := []string{"max", "100000"}
+	if data, err := os.ReadFile(filepath.Join(c.dir, "cpu.max")); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) == 2 {
+			current = fields
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if quota != nil {
+		current[0] = cgroupV2Limit(*quota)
+	}
+	if period != nil {
+		current[1] = strconv.FormatUint(*period, 10)
+	}
+
+	return strings.Join(current, " "), nil
+}
+
+func cgroupV2Limit(limit int64) string {
+	if limit < 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// String returns the io.max line of the device.
+func (io CgroupV2IOMax) String() string {
+	limit := func(l uint64) string {
+		if l == 0 {
+			return "max"
+		}
+		return strconv.FormatUint(l, 10)
+	}
+
+	return fmt.Sprintf("%d:%d rbps=%s wbps=%s riops=%s wiops=%s", io.Major, io.Minor,
+		limit(io.Rbps), limit(io.Wbps), limit(io.Riops), limit(io.Wiops))
+}
+
+// cgroupV2Dirs returns the directory of a cgroup and the ones of its
+// descendants, parents first.
+func cgroupV2Dirs(dir string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// MoveTo moves the processes of the cgroup, and of its children, to the cgroup
+// at path.
+func (c *CgroupV2) MoveTo(path string) error {
+	dirs, err := cgroupV2Dirs(c.dir)
+	if err != nil {
+		return err
+	}
+
+	return moveCgroupV2Processes(dirs, filepath.Join(c.root, path))
+}
+
+// Kill kills the processes of the cgroup, and of its children. The processes
+// are killed asynchronously, the cgroup can only be deleted once they exited.
+func (c *CgroupV2) Kill() error {
+	if _, err := os.Stat(filepath.Join(c.dir, "cgroup.kill")); err == nil {
+		return c.writeFile("cgroup.kill", "1")
+	}
+
+	// cgroup.kill is only there from Linux 5.14, kill the processes one
+	// by one otherwise.
+	dirs, err := cgroupV2Dirs(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, field := range strings.Fields(string(procs)) {
+			pid, err := strconv.Atoi(field)
+			if err != nil {
+				return fmt.Errorf("invalid pid %q in %s: %w", field, dir, err)
+			}
+			if err := unix.Kill(pid, unix.SIGKILL); err != nil && !errors.Is(err, unix.ESRCH) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *CgroupV2) AddDevice(deviceHostPath string) error {
+	deviceResource, err := DeviceToLinuxDevice(deviceHostPath)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.devices = append(c.devices, deviceResource)
+
+	return c.setDevices(c.devices)
+}
+
+func (c *CgroupV2) RemoveDevice(deviceHostPath string) error {
+	deviceResource, err := DeviceToLinuxDevice(deviceHostPath)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	devices := c.devices[:0]
+	for _, d := range c.devices {
+		if d.Type == deviceResource.Type && d.Major != nil && d.Minor != nil &&
+			*d.Major == *deviceResource.Major && *d.Minor == *deviceResource.Minor {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	c.devices = devices
+
+	return c.setDevices(c.devices)
+}
+
+func (c *CgroupV2) UpdateCpuSet(cpuset, memset string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.cpusets == nil {
+		c.cpusets = &specs.LinuxCPU{}
+	}
+	if len(cpuset) > 0 {
+		c.cpusets.Cpus = cpuset
+	}
+	if len(memset) > 0 {
+		c.cpusets.Mems = memset
+	}
+
+	return c.UpdateResources(&CgroupV2Resources{
+		Cpus: c.cpusets.Cpus,
+		Mems: c.cpusets.Mems,
+	})
+}
+
+func (c *CgroupV2) writeFile(file, value string) error {
+	if err := os.WriteFile(filepath.Join(c.dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write %q to %s: %w", value, file, err)
+	}
+	return nil
+}
+
+// readUint reads a single value interface file, a missing file or "max" read
+// as zero.
+func (c *CgroupV2) readUint(file string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readKeyedValues reads a flat keyed interface file, e.g. cpu.stat.
+func (c *CgroupV2) readKeyedValues(file string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+
+	f, err := os.Open(filepath.Join(c.dir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+
+	return values, scanner.Err()
+}
+
+// readIOStats reads io.stat, e.g.
+//
+//	8:0 rbytes=90430464 wbytes=0 rios=1490 wios=0 dbytes=0 dios=0
+func (c *CgroupV2) readIOStats() ([]CgroupV2IOStats, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, "io.stat"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []CgroupV2IOStats
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var s CgroupV2IOStats
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &s.Major, &s.Minor); err != nil {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				s.Rbytes = v
+			case "wbytes":
+				s.Wbytes = v
+			case "rios":
+				s.Rios = v
+			case "wios":
+				s.Wios = v
+			}
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// readHugeTLBStats reads the hugetlb.<page size>.current and .max interface
+// files of each huge page size.
+func (c *CgroupV2) readHugeTLBStats() ([]CgroupV2HugeTLBStats, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "hugetlb.*.current"))
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []CgroupV2HugeTLBStats
+	for _, file := range files {
+		pageSize := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "hugetlb."), ".current")
+		// Skip the reservations, e.g. hugetlb.2MB.rsvd.current
+		if strings.Contains(pageSize, ".") {
+			continue
+		}
+
+		s := CgroupV2HugeTLBStats{PageSize: pageSize}
+		if s.Current, err = c.readUint("hugetlb." + pageSize + ".current"); err != nil {
+			return nil, err
+		}
+		if s.Max, err = c.readUint("hugetlb." + pageSize + ".max"); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// readPressure reads a pressure interface file, nil when the kernel does not
+// report the pressure stall information.
+func (c *CgroupV2) readPressure(file string) (*Pressure, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, file))
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.EOPNOTSUPP) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePressure(string(data)), nil
+}
+
+// parsePressure parses pressure stall information, e.g.
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(data string) *Pressure {
+	p := &Pressure{}
+
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var values *PressureValues
+		switch fields[0] {
+		case "some":
+			values = &p.Some
+		case "full":
+			values = &p.Full
+		default:
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				values.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				values.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				values.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				values.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package resourcecontrol
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+// systemdUnitManager starts and stops the systemd units of the cgroups.
+type systemdUnitManager interface {
+	StartTransientUnit(unit string, properties []systemdDbus.Property) error
+	StopUnit(unit string) error
+}
+
+// newSystemdUnitManager returns the manager of the systemd units, it is
+// replaced by the tests.
+var newSystemdUnitManager = func() systemdUnitManager {
+	return dbusUnitManager{}
+}
+
+// dbusUnitManager manages the systemd units over D-Bus.
+type dbusUnitManager struct{}
+
+func (dbusUnitManager) StartTransientUnit(unit string, properties []systemdDbus.Property) error {
+	ctx := context.TODO()
+	conn, err := systemdDbus.NewWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch := make(chan string)
+	if _, err := conn.StartTransientUnitContext(ctx, unit, "replace", properties, ch); err != nil {
+		return err
+	}
+	if result := <-ch; result != "done" {
+		return fmt.Errorf("unit start job %s", result)
+	}
+
+	return nil
+}
+
+func (dbusUnitManager) StopUnit(unit string) error {
+	ctx := context.TODO()
+	conn, err := systemdDbus.NewWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch := make(chan string)
+	if _, err := conn.StopUnitContext(ctx, unit, "replace", ch); err != nil {
+		return err
+	}
+	if result := <-ch; result != "done" {
+		return fmt.Errorf("unit stop job %s", result)
+	}
+
+	return nil
+}
+
+// systemdLimit converts a cgroup v2 limit to a systemd one, where no limit is
+// infinity.
+func systemdLimit(limit int64) uint64 {
+	if limit < 0 {
+		return math.MaxUint64
+	}
+	return uint64(limit)
+}
+
+// systemdUnitProperties returns the properties of the transient unit of a
+// cgroup. The subtree of the unit is delegated to the runtime, which manages
+// the resources systemd does not know about through the cgroup interface
+// files.
+func systemdUnitProperties(slice, unit string, pid int, r *CgroupV2Resources) []systemdDbus.Property {
+	properties := append(systemdBaseProperties(slice, unit, pid), newProperty("TasksAccounting", true))
+
+	if r == nil {
+		return properties
+	}
+
+	if r.CPUWeight != nil {
+		properties = append(properties, newProperty("CPUWeight", *r.CPUWeight))
+	}
+	if r.CPUQuota != nil && *r.CPUQuota > 0 {
+		period := uint64(100000)
+		if r.CPUPeriod != nil && *r.CPUPeriod > 0 {
+			period = *r.CPUPeriod
+		}
+		// The CPU time per second, rounded up to systemd's 10ms
+		// granularity.
+		quotaPerSec := uint64(*r.CPUQuota) * 1000000 / period
+		if quotaPerSec%10000 != 0 {
+			quotaPerSec = (quotaPerSec/10000 + 1) * 10000
+		}
+		properties = append(properties, newProperty("CPUQuotaPerSecUSec", quotaPerSec))
+	}
+
+	for name, limit := range map[string]*int64{
+		"MemoryMin":  r.MemoryMin,
+		"MemoryLow":  r.MemoryLow,
+		"MemoryHigh": r.MemoryHigh,
+		"MemoryMax":  r.MemoryMax,
+		"TasksMax":   r.PidsMax,
+	} {
+		if limit != nil {
+			properties = append(properties, newProperty(name, systemdLimit(*limit)))
+		}
+	}
+
+	return properties
+}
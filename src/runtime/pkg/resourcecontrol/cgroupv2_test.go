@@ -0,0 +1,529 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package resourcecontrol
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeCgroupfs returns the mount point of a fake cgroup v2 hierarchy, where
+// devices filters are recorded and cgroups are removed with their files.
+func newFakeCgroupfs(t *testing.T) (string, *[]specs.LinuxDeviceCgroup) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cgroup.controllers"), []byte("cpuset cpu io memory hugetlb pids rdma misc\n"), 0644))
+
+	var devices []specs.LinuxDeviceCgroup
+	savedSetDevices := setCgroupV2Devices
+	savedRemoveDir := removeCgroupV2Dir
+	setCgroupV2Devices = func(dir string, d []specs.LinuxDeviceCgroup, previous cgroupV2DeviceFilter) (cgroupV2DeviceFilter, error) {
+		devices = append([]specs.LinuxDeviceCgroup{}, d...)
+		return previous, nil
+	}
+	removeCgroupV2Dir = os.RemoveAll
+	t.Cleanup(func() {
+		setCgroupV2Devices = savedSetDevices
+		removeCgroupV2Dir = savedRemoveDir
+	})
+
+	return root, &devices
+}
+
+func readCgroupFile(t *testing.T, dir, file string) string {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+type fakeSystemdUnitManager struct {
+	properties map[string][]systemdDbus.Property
+	stopped    []string
+}
+
+func (m *fakeSystemdUnitManager) StartTransientUnit(unit string, properties []systemdDbus.Property) error {
+	m.properties[unit] = properties
+	return nil
+}
+
+func (m *fakeSystemdUnitManager) StopUnit(unit string) error {
+	m.stopped = append(m.stopped, unit)
+	return nil
+}
+
+func TestToCgroupV2Resources(t *testing.T) {
+	assert := assert.New(t)
+
+	shares := uint64(1024)
+	quota := int64(50000)
+	period := uint64(100000)
+	idle := int64(1)
+	limit := int64(256 << 20)
+	reservation := int64(128 << 20)
+	swap := int64(512 << 20)
+
+	r := ToCgroupV2Resources(&specs.LinuxResources{
+		CPU: &specs.LinuxCPU{
+			Shares: &shares,
+			Quota:  &quota,
+			Period: &period,
+			Idle:   &idle,
+			Cpus:   "0-1",
+			Mems:   "0",
+		},
+		Memory: &specs.LinuxMemory{
+			Limit:       &limit,
+			Reservation: &reservation,
+			Swap:        &swap,
+		},
+		Pids: &specs.LinuxPids{Limit: 100},
+		BlockIO: &specs.LinuxBlockIO{
+			ThrottleReadBpsDevice:   []specs.LinuxThrottleDevice{{Rate: 1048576}},
+			ThrottleWriteIOPSDevice: []specs.LinuxThrottleDevice{{Rate: 100}},
+		},
+		HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 4 << 20}},
+		Unified:        map[string]string{"memory.oom.group": "1"},
+	})
+
+	assert.Equal(uint64(39), *r.CPUWeight)
+	assert.Equal(quota, *r.CPUQuota)
+	assert.Equal(period, *r.CPUPeriod)
+	assert.True(*r.CPUIdle)
+	assert.Equal("0-1", r.Cpus)
+	assert.Equal("0", r.Mems)
+	assert.Equal(limit, *r.MemoryMax)
+	assert.Equal(reservation, *r.MemoryLow)
+	assert.Equal(swap-limit, *r.MemorySwapMax)
+	assert.Nil(r.MemoryHigh)
+	assert.Equal(int64(100), *r.PidsMax)
+	assert.Equal([]CgroupV2IOMax{{Rbps: 1048576, Wiops: 100}}, r.IOMax)
+	assert.Equal([]CgroupV2HugeTLBMax{{PageSize: "2MB", Max: 4 << 20}}, r.HugeTLBMax)
+	assert.Equal(map[string]string{"memory.oom.group": "1"}, r.Unified)
+
+	assert.Equal(&CgroupV2Resources{}, ToCgroupV2Resources(nil))
+
+	assert.Equal(uint64(1), cpuSharesToWeight(2))
+	assert.Equal(uint64(10000), cpuSharesToWeight(262144))
+}
+
+func TestNewCgroupV2(t *testing.T) {
+	assert := assert.New(t)
+	root, devices := newFakeCgroupfs(t)
+
+	major, minor := int64(10), int64(200)
+	quota := int64(20000)
+	c, err := NewCgroupV2(root, "/kata/sandbox", &specs.LinuxResources{
+		CPU:     &specs.LinuxCPU{Quota: &quota},
+		Devices: []specs.LinuxDeviceCgroup{{Allow: true, Type: "c", Major: &major, Minor: &minor, Access: "rwm"}},
+	})
+	assert.NoError(err)
+
+	assert.Equal(LinuxCgroupsV2, c.Type())
+	assert.Equal("/kata/sandbox", c.ID())
+	assert.Equal("/kata", c.Parent())
+	assert.Equal(filepath.Join(root, "kata", "sandbox"), c.Dir())
+
+	// The controllers are enabled down to the parent of the cgroup
+	controllers := "+cpuset +cpu +io +memory +hugetlb +pids"
+	assert.Equal(controllers, readCgroupFile(t, root, "cgroup.subtree_control"))
+	assert.Equal(controllers, readCgroupFile(t, filepath.Join(root, "kata"), "cgroup.subtree_control"))
+	assert.NoFileExists(filepath.Join(c.Dir(), "cgroup.subtree_control"))
+
+	assert.Equal("20000 100000", readCgroupFile(t, c.Dir(), "cpu.max"))
+	assert.Len(*devices, 1)
+
+	loaded, err := LoadCgroupV2(root, "/kata/sandbox")
+	assert.NoError(err)
+	assert.Equal(c.Dir(), loaded.Dir())
+
+	_, err = LoadCgroupV2(root, "/kata/unknown")
+	assert.Error(err)
+}
+
+func TestCgroupV2UpdateResources(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	c, err := NewCgroupV2(root, "/sandbox", nil)
+	assert.NoError(err)
+
+	weight := uint64(200)
+	idle := true
+	quota := int64(-1)
+	period := uint64(50000)
+	min := int64(64 << 20)
+	high := int64(256 << 20)
+	max := int64(-1)
+	pids := int64(1024)
+
+	assert.NoError(c.UpdateResources(&CgroupV2Resources{
+		CPUWeight:  &weight,
+		CPUIdle:    &idle,
+		CPUQuota:   &quota,
+		CPUPeriod:  &period,
+		Cpus:       "0-3",
+		Mems:       "0",
+		MemoryMin:  &min,
+		MemoryHigh: &high,
+		MemoryMax:  &max,
+		PidsMax:    &pids,
+		IOMax:      []CgroupV2IOMax{{Major: 8, Minor: 0, Rbps: 1048576, Wiops: 100}},
+		HugeTLBMax: []CgroupV2HugeTLBMax{{PageSize: "1GB", Max: 1 << 30}},
+		Unified:    map[string]string{"memory.oom.group": "1"},
+	}))
+
+	for file, expected := range map[string]string{
+		"cpu.weight":       "200",
+		"cpu.idle":         "1",
+		"cpu.max":          "max 50000",
+		"cpuset.cpus":      "0-3",
+		"cpuset.mems":      "0",
+		"memory.min":       "67108864",
+		"memory.high":      "268435456",
+		"memory.max":       "max",
+		"pids.max":         "1024",
+		"io.max":           "8:0 rbps=1048576 wbps=max riops=max wiops=100",
+		"hugetlb.1GB.max":  "1073741824",
+		"memory.oom.group": "1",
+	} {
+		assert.Equal(expected, readCgroupFile(t, c.Dir(), file), file)
+	}
+	assert.NoFileExists(filepath.Join(c.Dir(), "memory.low"))
+
+	// The period is kept when only the quota is updated
+	quota = 25000
+	assert.NoError(c.UpdateResources(&CgroupV2Resources{CPUQuota: &quota}))
+	assert.Equal("25000 50000", readCgroupFile(t, c.Dir(), "cpu.max"))
+
+	assert.NoError(c.UpdateCpuSet("1", ""))
+	assert.Equal("1", readCgroupFile(t, c.Dir(), "cpuset.cpus"))
+	assert.Equal("0", readCgroupFile(t, c.Dir(), "cpuset.mems"))
+
+	// The unified resources can't escape the cgroup
+	assert.Error(c.UpdateResources(&CgroupV2Resources{Unified: map[string]string{"../cpu.max": "max"}}))
+	assert.Error(c.UpdateResources(&CgroupV2Resources{Unified: map[string]string{"cgroup.procs": "1"}}))
+}
+
+func TestCgroupV2Stats(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	c, err := NewCgroupV2(root, "/sandbox", nil)
+	assert.NoError(err)
+
+	for file, content := range map[string]string{
+		"cpu.stat":                 "usage_usec 1000\nuser_usec 600\nsystem_usec 400\nnr_periods 10\nnr_throttled 2\nthrottled_usec 50\n",
+		"memory.current":           "4096\n",
+		"memory.swap.current":      "0\n",
+		"memory.high":              "max\n",
+		"memory.max":               "8192\n",
+		"memory.events":            "low 0\nhigh 3\nmax 1\noom 1\noom_kill 1\n",
+		"memory.stat":              "anon 1024\nfile 2048\n",
+		"pids.current":             "5\n",
+		"pids.max":                 "max\n",
+		"io.stat":                  "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n",
+		"hugetlb.2MB.current":      "2097152\n",
+		"hugetlb.2MB.max":          "max\n",
+		"hugetlb.2MB.rsvd.current": "0\n",
+		"memory.pressure":          "some avg10=12.50 avg60=3.00 avg300=0.75 total=123456\nfull avg10=1.00 avg60=0.00 avg300=0.00 total=789\n",
+	} {
+		assert.NoError(os.WriteFile(filepath.Join(c.Dir(), file), []byte(content), 0644))
+	}
+
+	stat, err := c.Stat()
+	assert.NoError(err)
+	stats, ok := stat.(*CgroupV2Stats)
+	assert.True(ok)
+
+	assert.Equal(CgroupV2CPUStats{
+		UsageUsec:     1000,
+		UserUsec:      600,
+		SystemUsec:    400,
+		NrPeriods:     10,
+		NrThrottled:   2,
+		ThrottledUsec: 50,
+	}, stats.CPU)
+
+	assert.Equal(uint64(4096), stats.Memory.Current)
+	assert.Equal(uint64(0), stats.Memory.High)
+	assert.Equal(uint64(8192), stats.Memory.Max)
+	assert.Equal(CgroupV2MemoryEvents{High: 3, Max: 1, OOM: 1, OOMKill: 1}, stats.Memory.Events)
+	assert.Equal(map[string]uint64{"anon": 1024, "file": 2048}, stats.Memory.Stat)
+
+	assert.Equal(CgroupV2PidsStats{Current: 5}, stats.Pids)
+	assert.Equal([]CgroupV2IOStats{{Major: 8, Rbytes: 4096, Wbytes: 8192, Rios: 1, Wios: 2}}, stats.IO)
+	assert.Equal([]CgroupV2HugeTLBStats{{PageSize: "2MB", Current: 2097152}}, stats.HugeTLB)
+
+	assert.Nil(stats.Pressure.CPU)
+	assert.Nil(stats.Pressure.IO)
+	assert.Equal(&Pressure{
+		Some: PressureValues{Avg10: 12.5, Avg60: 3, Avg300: 0.75, Total: 123456},
+		Full: PressureValues{Avg10: 1, Total: 789},
+	}, stats.Pressure.Memory)
+}
+
+func TestCgroupV2Processes(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	c, err := NewCgroupV2(root, "/kata/overhead", nil)
+	assert.NoError(err)
+
+	assert.NoError(c.AddProcess(42))
+	assert.Equal("42", readCgroupFile(t, c.Dir(), "cgroup.procs"))
+	assert.NoError(c.AddThread(43))
+	assert.Equal("43", readCgroupFile(t, c.Dir(), "cgroup.procs"))
+
+	assert.NoError(c.MoveTo(c.Parent()))
+	assert.Equal("43", readCgroupFile(t, filepath.Join(root, "kata"), "cgroup.procs"))
+}
+
+func TestCgroupV2Children(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	c, err := NewCgroupV2(root, "/sandbox", nil)
+	assert.NoError(err)
+	assert.NoError(c.AddProcess(42))
+
+	high := int64(1 << 20)
+	child, err := c.NewChild("overhead", nil)
+	assert.NoError(err)
+	assert.NoError(child.UpdateResources(&CgroupV2Resources{MemoryHigh: &high}))
+	assert.Equal("/sandbox/overhead", child.ID())
+	assert.Equal("+cpuset +cpu +io +memory +hugetlb +pids", readCgroupFile(t, c.Dir(), "cgroup.subtree_control"))
+	assert.Equal("1048576", readCgroupFile(t, child.Dir(), "memory.high"))
+
+	// The processes of the cgroup live in its leaf child
+	leaf := filepath.Join(c.Dir(), cgroupV2LeafName)
+	assert.Equal("42", readCgroupFile(t, leaf, "cgroup.procs"))
+	assert.NoError(c.AddThread(43))
+	assert.Equal("43", readCgroupFile(t, leaf, "cgroup.procs"))
+
+	// The processes of the children are moved with the ones of the cgroup
+	assert.NoError(child.AddProcess(44))
+	assert.NoError(c.MoveTo("/"))
+	assert.Equal("44", readCgroupFile(t, root, "cgroup.procs"))
+
+	for _, name := range []string{"", ".", "..", "a/b", cgroupV2LeafName} {
+		_, err := c.NewChild(name, nil)
+		assert.Error(err, name)
+	}
+
+	// The children are removed with the cgroup
+	assert.NoError(c.Delete())
+	assert.NoDirExists(c.Dir())
+
+	// Deleting a removed cgroup is not an error
+	assert.NoError(c.Delete())
+}
+
+func TestCgroupV2Kill(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	c, err := NewCgroupV2(root, "/hook", nil)
+	assert.NoError(err)
+
+	// The processes are killed one by one without cgroup.kill
+	cmd := exec.Command("sleep", "60")
+	assert.NoError(cmd.Start())
+	child, err := c.NewChild("child", nil)
+	assert.NoError(err)
+	assert.NoError(child.AddProcess(cmd.Process.Pid))
+	assert.NoError(c.Kill())
+	err = cmd.Wait()
+	assert.Error(err)
+	assert.Equal(syscall.SIGKILL, cmd.ProcessState.Sys().(syscall.WaitStatus).Signal())
+
+	// Exited processes are ignored
+	assert.NoError(c.Kill())
+
+	// The kernel kills them all otherwise
+	assert.NoError(os.WriteFile(filepath.Join(c.Dir(), "cgroup.kill"), nil, 0644))
+	assert.NoError(c.Kill())
+	assert.Equal("1", readCgroupFile(t, c.Dir(), "cgroup.kill"))
+}
+
+func TestNewOverheadResourceController(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	sandbox, err := NewCgroupV2(root, "/kata_sandbox", nil)
+	assert.NoError(err)
+
+	limit := int64(64 << 20)
+	overhead, err := NewOverheadResourceController(sandbox, &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit},
+	})
+	assert.NoError(err)
+	assert.Equal("/kata_sandbox/overhead", overhead.ID())
+	assert.Equal(sandbox.ID(), overhead.Parent())
+	assert.Equal("67108864", readCgroupFile(t, filepath.Join(sandbox.Dir(), "overhead"), "memory.max"))
+
+	// Not supported with cgroup v1
+	_, err = NewOverheadResourceController(&LinuxCgroup{path: "/kata_sandbox"}, nil)
+	assert.Error(err)
+}
+
+func TestSystemdCgroupV2(t *testing.T) {
+	assert := assert.New(t)
+	root, _ := newFakeCgroupfs(t)
+
+	manager := &fakeSystemdUnitManager{properties: make(map[string][]systemdDbus.Property)}
+	savedNewManager := newSystemdUnitManager
+	newSystemdUnitManager = func() systemdUnitManager { return manager }
+	defer func() { newSystemdUnitManager = savedNewManager }()
+
+	// The unit cgroup is created by systemd
+	unitDir := filepath.Join(root, "system.slice", "kata-sandbox.scope")
+	assert.NoError(os.MkdirAll(unitDir, 0755))
+
+	limit := int64(512 << 20)
+	quota := int64(150000)
+	c, err := NewSystemdCgroupV2(root, "system.slice:kata:sandbox", 1234, &specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Quota: &quota},
+		Memory: &specs.LinuxMemory{Limit: &limit},
+	})
+	assert.NoError(err)
+	assert.Equal(unitDir, c.Dir())
+	assert.Equal("system.slice:kata:sandbox", c.ID())
+
+	properties := make(map[string]interface{})
+	for _, p := range manager.properties["kata-sandbox.scope"] {
+		properties[p.Name] = p.Value.Value()
+	}
+	assert.Equal(true, properties["Delegate"])
+	assert.Equal("system.slice", properties["Slice"])
+	assert.Equal([]uint32{1234}, properties["PIDs"])
+	assert.Equal(uint64(limit), properties["MemoryMax"])
+	assert.Equal(uint64(1500000), properties["CPUQuotaPerSecUSec"])
+
+	// The resources are also written to the delegated cgroup
+	assert.Equal("536870912", readCgroupFile(t, unitDir, "memory.max"))
+
+	// The subtree is delegated to the runtime
+	child, err := c.NewChild("overhead", nil)
+	assert.NoError(err)
+	assert.Equal("/system.slice/kata-sandbox.scope/overhead", child.ID())
+
+	// The parent of the unit is its slice
+	assert.Equal("/system.slice", c.Parent())
+
+	loaded, err := LoadSystemdCgroupV2(root, "system.slice:kata:sandbox")
+	assert.NoError(err)
+	assert.NoError(loaded.Delete())
+	assert.Equal([]string{"kata-sandbox.scope"}, manager.stopped)
+	assert.NoDirExists(unitDir)
+}
+
+// fakeDeviceFilter is a device filter attached by a fake kernel.
+type fakeDeviceFilter struct {
+	devices []specs.LinuxDeviceCgroup
+	closed  bool
+}
+
+func (f *fakeDeviceFilter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeDeviceFilter) allows(device specs.LinuxDeviceCgroup) bool {
+	for _, d := range f.devices {
+		if d.Allow && d.Type == device.Type &&
+			(d.Major == nil || *d.Major == *device.Major) &&
+			(d.Minor == nil || *d.Minor == *device.Minor) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCgroupV2DeviceFilters(t *testing.T) {
+	assert := assert.New(t)
+
+	// The fake kernel allows a device when all the attached filters
+	// allow it.
+	var attached []*fakeDeviceFilter
+	allowed := func(path string) bool {
+		device, err := DeviceToLinuxDevice(path)
+		assert.NoError(err)
+		for _, f := range attached {
+			if !f.allows(device) {
+				return false
+			}
+		}
+		return len(attached) > 0
+	}
+
+	saved := cgroupV2DeviceFilters
+	defer func() { cgroupV2DeviceFilters = saved }()
+	cgroupV2DeviceFilters = cgroupV2DeviceFilterOps{
+		load: func(devices []specs.LinuxDeviceCgroup) (cgroupV2DeviceFilter, error) {
+			return &fakeDeviceFilter{devices: append([]specs.LinuxDeviceCgroup{}, devices...)}, nil
+		},
+		attach: func(_ int, filter cgroupV2DeviceFilter) error {
+			attached = append(attached, filter.(*fakeDeviceFilter))
+			return nil
+		},
+		detach: func(_ int, filter cgroupV2DeviceFilter) error {
+			for i, f := range attached {
+				if f == filter {
+					attached = append(attached[:i], attached[i+1:]...)
+					return nil
+				}
+			}
+			return os.ErrNotExist
+		},
+	}
+
+	null, err := DeviceToLinuxDevice("/dev/null")
+	assert.NoError(err)
+
+	zero, err := DeviceToLinuxDevice("/dev/zero")
+	assert.NoError(err)
+
+	// A filter attached by another manager, e.g. systemd
+	foreign := &fakeDeviceFilter{devices: []specs.LinuxDeviceCgroup{null, zero}}
+	attached = append(attached, foreign)
+
+	c := &CgroupV2{dir: t.TempDir(), devices: []specs.LinuxDeviceCgroup{null}}
+	assert.NoError(c.setDevices(c.devices))
+	assert.True(allowed("/dev/null"))
+	assert.False(allowed("/dev/zero"))
+	assert.Len(attached, 2)
+
+	// A device added later is allowed, the previous filter of the runtime
+	// is detached and the foreign one is left alone.
+	previous := c.deviceFilter.(*fakeDeviceFilter)
+	assert.NoError(c.AddDevice("/dev/zero"))
+	assert.True(allowed("/dev/null"))
+	assert.True(allowed("/dev/zero"))
+	assert.Len(attached, 2)
+	assert.Contains(attached, foreign)
+	assert.NotContains(attached, previous)
+	assert.True(previous.closed)
+
+	assert.NoError(c.RemoveDevice("/dev/null"))
+	assert.False(allowed("/dev/null"))
+	assert.True(allowed("/dev/zero"))
+	assert.Len(attached, 2)
+	assert.Contains(attached, foreign)
+	assert.False(foreign.closed)
+
+	// The filter of the runtime is released with the cgroup
+	current := c.deviceFilter.(*fakeDeviceFilter)
+	assert.NoError(c.Delete())
+	assert.True(current.closed)
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package resourcecontrol
+
+import (
+	"sort"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// CgroupV2Resources describes the resources of a cgroup v2, as written to its
+// interface files. Unset values are left untouched, a negative limit means no
+// limit ("max").
+type CgroupV2Resources struct {
+	// cpu.weight, from 1 to 10000.
+	CPUWeight *uint64
+	// cpu.idle, the cgroup only runs when the CPUs are otherwise idle.
+	CPUIdle *bool
+	// cpu.max, the CPU time the cgroup can use in each period, in
+	// microseconds.
+	CPUQuota  *int64
+	CPUPeriod *uint64
+
+	// cpuset.cpus and cpuset.mems.
+	Cpus string
+	Mems string
+
+	// memory.min, memory.low, memory.high, memory.max and
+	// memory.swap.max, in bytes.
+	MemoryMin     *int64
+	MemoryLow     *int64
+	MemoryHigh    *int64
+	MemoryMax     *int64
+	MemorySwapMax *int64
+
+	// pids.max.
+	PidsMax *int64
+
+	// io.max, by device.
+	IOMax []CgroupV2IOMax
+
+	// hugetlb.<page size>.max.
+	HugeTLBMax []CgroupV2HugeTLBMax
+
+	// Interface files written as is, after the resources above, e.g.
+	// from the unified resources of an OCI spec.
+	Unified map[string]string
+}
+
+// CgroupV2IOMax limits the I/O of a cgroup on a block device. A zero limit
+// means no limit.
+type CgroupV2IOMax struct {
+	Major int64
+	Minor int64
+	// Bytes per second.
+	Rbps uint64
+	Wbps uint64
+	// I/O operations per second.
+	Riops uint64
+	Wiops uint64
+}
+
+// CgroupV2HugeTLBMax limits the huge pages of a size used by a cgroup.
+type CgroupV2HugeTLBMax struct {
+	// The page size, as named by the hugetlb controller, e.g. 2MB or 1GB.
+	PageSize string
+	// In bytes.
+	Max uint64
+}
+
+// CgroupV2Stats are the statistics of a cgroup v2, as read from its interface
+// files. The statistics of the controllers that are not enabled are zero.
+type CgroupV2Stats struct {
+	Memory   CgroupV2MemoryStats
+	HugeTLB  []CgroupV2HugeTLBStats
+	IO       []CgroupV2IOStats
+	Pressure CgroupV2PressureStats
+	CPU      CgroupV2CPUStats
+	Pids     CgroupV2PidsStats
+}
+
+// CgroupV2CPUStats are read from cpu.stat, in microseconds.
+type CgroupV2CPUStats struct {
+	UsageUsec     uint64
+	UserUsec      uint64
+	SystemUsec    uint64
+	NrPeriods     uint64
+	NrThrottled   uint64
+	ThrottledUsec uint64
+}
+
+// CgroupV2MemoryStats are read from the memory interface files, in bytes. A
+// limit of zero means no limit.
+type CgroupV2MemoryStats struct {
+	// memory.stat
+	Stat        map[string]uint64
+	Current     uint64
+	SwapCurrent uint64
+	Min         uint64
+	Low         uint64
+	High        uint64
+	Max         uint64
+	Events      CgroupV2MemoryEvents
+}
+
+// CgroupV2MemoryEvents are read from memory.events.
+type CgroupV2MemoryEvents struct {
+	Low     uint64
+	High    uint64
+	Max     uint64
+	OOM     uint64
+	OOMKill uint64
+}
+
+// CgroupV2PidsStats are read from pids.current and pids.max. A limit of zero
+// means no limit.
+type CgroupV2PidsStats struct {
+	Current uint64
+	Max     uint64
+}
+
+// CgroupV2IOStats are read from io.stat, by block device.
+type CgroupV2IOStats struct {
+	Major  int64
+	Minor  int64
+	Rbytes uint64
+	Wbytes uint64
+	Rios   uint64
+	Wios   uint64
+}
+
+// CgroupV2HugeTLBStats are read from the hugetlb interface files of a page
+// size, in bytes.
+type CgroupV2HugeTLBStats struct {
+	PageSize string
+	Current  uint64
+	Max      uint64
+}
+
+// CgroupV2PressureStats are the pressure stall information of the cgroup.
+// They are nil when the kernel does not report them.
+type CgroupV2PressureStats struct {
+	CPU    *Pressure
+	Memory *Pressure
+	IO     *Pressure
+}
+
+// Pressure is the pressure stall information of a resource: the share of time
+// some, or all, tasks were stalled on the resource.
+type Pressure struct {
+	Some PressureValues
+	Full PressureValues
+}
+
+// PressureValues are the percentages of time tasks were stalled over the last
+// 10, 60 and 300 seconds, and the total stall time in microseconds.
+type PressureValues struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  uint64
+}
+
+// cpuSharesToWeight converts cgroup v1 CPU shares, from 2 to 262144, to a
+// cgroup v2 CPU weight, from 1 to 10000.
+func cpuSharesToWeight(shares uint64) uint64 {
+	if shares == 0 {
+		return 0
+	}
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// ToCgroupV2Resources converts the resources of an OCI spec to the resources
+// of a cgroup v2.
+func ToCgroupV2Resources(resources *specs.LinuxResources) *CgroupV2Resources {
+	r := &CgroupV2Resources{}
+	if resources == nil {
+		return r
+	}
+
+	if cpu := resources.CPU; cpu != nil {
+		if cpu.Shares != nil && *cpu.Shares > 0 {
+			weight := cpuSharesToWeight(*cpu.Shares)
+			r.CPUWeight = &weight
+		}
+		if cpu.Quota != nil && *cpu.Quota != 0 {
+			quota := *cpu.Quota
+			r.CPUQuota = &quota
+		}
+		if cpu.Period != nil && *cpu.Period != 0 {
+			period := *cpu.Period
+			r.CPUPeriod = &period
+		}
+		if cpu.Idle != nil {
+			idle := *cpu.Idle != 0
+			r.CPUIdle = &idle
+		}
+		r.Cpus = cpu.Cpus
+		r.Mems = cpu.Mems
+	}
+
+	if memory := resources.Memory; memory != nil {
+		if memory.Limit != nil && *memory.Limit != 0 {
+			limit := *memory.Limit
+			r.MemoryMax = &limit
+		}
+		if memory.Reservation != nil && *memory.Reservation != 0 {
+			low := *memory.Reservation
+			r.MemoryLow = &low
+		}
+		// The OCI swap limit is the limit of memory and swap, the
+		// cgroup v2 one is the limit of swap only.
+		if memory.Swap != nil && *memory.Swap != 0 {
+			swap := *memory.Swap
+			if swap > 0 && memory.Limit != nil && *memory.Limit > 0 {
+				swap -= *memory.Limit
+				if swap < 0 {
+					swap = 0
+				}
+			}
+			r.MemorySwapMax = &swap
+		}
+	}
+
+	if pids := resources.Pids; pids != nil && pids.Limit != 0 {
+		limit := pids.Limit
+		r.PidsMax = &limit
+	}
+
+	if blockIO := resources.BlockIO; blockIO != nil {
+		devices := make(map[[2]int64]*CgroupV2IOMax)
+		ioMax := func(major, minor int64) *CgroupV2IOMax {
+			key := [2]int64{major, minor}
+			if _, ok := devices[key]; !ok {
+				devices[key] = &CgroupV2IOMax{Major: major, Minor: minor}
+			}
+			return devices[key]
+		}
+		for _, d := range blockIO.ThrottleReadBpsDevice {
+			ioMax(d.Major, d.Minor).Rbps = d.Rate
+		}
+		for _, d := range blockIO.ThrottleWriteBpsDevice {
+			ioMax(d.Major, d.Minor).Wbps = d.Rate
+		}
+		for _, d := range blockIO.ThrottleReadIOPSDevice {
+			ioMax(d.Major, d.Minor).Riops = d.Rate
+		}
+		for _, d := range blockIO.ThrottleWriteIOPSDevice {
+			ioMax(d.Major, d.Minor).Wiops = d.Rate
+		}
+		for _, d := range devices {
+			r.IOMax = append(r.IOMax, *d)
+		}
+		sort.Slice(r.IOMax, func(i, j int) bool {
+			if r.IOMax[i].Major != r.IOMax[j].Major {
+				return r.IOMax[i].Major < r.IOMax[j].Major
+			}
+			return r.IOMax[i].Minor < r.IOMax[j].Minor
+		})
+	}
+
+	for _, l := range resources.HugepageLimits {
+		r.HugeTLBMax = append(r.HugeTLBMax, CgroupV2HugeTLBMax{
+			PageSize: l.Pagesize,
+			Max:      l.Limit,
+		})
+	}
+
+	if len(resources.Unified) > 0 {
+		r.Unified = make(map[string]string, len(resources.Unified))
+		for k, v := range resources.Unified {
+			r.Unified[k] = v
+		}
+	}
+
+	return r
+}
@@ -26,6 +26,7 @@ type ResourceControllerType string
 
 const (
 	LinuxCgroups                 ResourceControllerType = "cgroups"
+	LinuxCgroupsV2               ResourceControllerType = "cgroupsv2"
 	DarwinResourceControllerType ResourceControllerType = "darwin"
 )
 
@@ -34,6 +35,8 @@ func (rType *ResourceControllerType) String() string {
 	switch *rType {
 	case LinuxCgroups:
 		return string(LinuxCgroups)
+	case LinuxCgroupsV2:
+		return string(LinuxCgroupsV2)
 	default:
 		return "Unknown controller type"
 	}
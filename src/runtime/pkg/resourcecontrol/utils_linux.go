@@ -73,14 +73,9 @@ func cgroupHierarchy(path string) (cgroups.Hierarchy, cgroups.Path, error) {
 	}
 }
 
-func createCgroupsSystemd(slice string, unit string, pid int) error {
-	ctx := context.TODO()
-	conn, err := systemdDbus.NewWithContext(ctx)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
+// systemdBaseProperties returns the properties of the transient systemd unit
+// of the slice:unit cgroup, with pid as its first process when not -1.
+func systemdBaseProperties(slice string, unit string, pid int) []systemdDbus.Property {
 	properties := []systemdDbus.Property{
 		systemdDbus.PropDescription("cgroup " + unit),
 		newProperty("DefaultDependencies", false),
@@ -104,6 +99,19 @@ func createCgroupsSystemd(slice string, unit string, pid int) error {
 		properties = append(properties, systemdDbus.PropPids(uint32(pid)))
 	}
 
+	return properties
+}
+
+func createCgroupsSystemd(slice string, unit string, pid int) error {
+	ctx := context.TODO()
+	conn, err := systemdDbus.NewWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	properties := systemdBaseProperties(slice, unit, pid)
+
 	ch := make(chan string)
 	// https://www.freedesktop.org/wiki/Software/systemd/ControlGroupInterface/
 	_, err = conn.StartTransientUnitContext(ctx, unit, "replace", properties, ch)
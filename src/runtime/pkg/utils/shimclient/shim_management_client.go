@@ -72,20 +72,27 @@ func DoGet(sandboxID string, timeout time.Duration, urlPath string) ([]byte, err
 
 // DoPut will make a PUT request to the shim endpoint that handles the given sandbox ID
 func DoPut(sandboxID string, timeout time.Duration, urlPath, contentType string, payload []byte) error {
+	_, err := DoPutWithResponse(sandboxID, timeout, urlPath, contentType, payload)
+	return err
+}
+
+// DoPutWithResponse will make a PUT request to the shim endpoint that handles the given sandbox ID,
+// and return the response data
+func DoPutWithResponse(sandboxID string, timeout time.Duration, urlPath, contentType string, payload []byte) ([]byte, error) {
 	client, err := BuildShimClient(sandboxID, timeout)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://shim%s", urlPath), bytes.NewBuffer(payload))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", contentType)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer func() {
@@ -94,12 +101,12 @@ func DoPut(sandboxID string, timeout time.Duration, urlPath, contentType string,
 		}
 	}()
 
+	data, err := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		data, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("error sending put: url: %s, status code: %d, response data: %s", urlPath, resp.StatusCode, string(data))
+		return nil, fmt.Errorf("error sending put: url: %s, status code: %d, response data: %s", urlPath, resp.StatusCode, string(data))
 	}
 
-	return nil
+	return data, err
 }
 
 // DoPost will make a POST request to the shim endpoint that handles the given sandbox ID
@@ -42,7 +42,10 @@ var containerAnnotations = map[string]string{
 }
 
 func init() {
-	rootless.IsRootless = func() bool { return false }
+	// The tests run in a user namespace keep the rootless detection
+	if os.Getenv(userNSTestEnv) == "" {
+		rootless.IsRootless = func() bool { return false }
+	}
 }
 
 func newEmptySpec() *specs.Spec {
@@ -0,0 +1,367 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils/katatrace"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/rootless"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+)
+
+// AssetManifestSignatureSuffix is appended to the manifest path to find its
+// detached, base64 encoded ed25519 signature.
+const AssetManifestSignatureSuffix = ".sig"
+
+const defaultAssetDigestCacheDir = "/run/kata-containers/asset-digests"
+
+// The function is declared this way for mocking in unit tests
+var assetDigestCacheDir = func() string {
+	if rootless.IsRootless() {
+		return filepath.Join(rootless.GetRootlessDir(), defaultAssetDigestCacheDir)
+	}
+	return defaultAssetDigestCacheDir
+}
+
+var assetLogger = logrus.WithField("source", "virtcontainers/asset")
+
+// assetDigestCacheEntry records the digest of a file along with the file
+// attributes that must be unchanged for the digest to be reused.
+type assetDigestCacheEntry struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest"`
+	Dev    uint64 `json:"dev"`
+	Inode  uint64 `json:"inode"`
+	Size   int64  `json:"size"`
+	Mtime  int64  `json:"mtime_ns"`
+	Ctime  int64  `json:"ctime_ns"`
+}
+
+func newAssetDigestCacheEntry(path string) (assetDigestCacheEntry, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return assetDigestCacheEntry{}, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return assetDigestCacheEntry{}, fmt.Errorf("asset %s is not a regular file", path)
+	}
+
+	return assetDigestCacheEntry{
+		Path:  path,
+		Dev:   uint64(st.Dev),
+		Inode: st.Ino,
+		Size:  st.Size,
+		Mtime: st.Mtim.Nano(),
+		Ctime: st.Ctim.Nano(),
+	}, nil
+}
+
+func assetDigestCachePath(path, algorithm string) string {
+	key := sha256.Sum256([]byte(algorithm + ":" + path))
+	return filepath.Join(assetDigestCacheDir(), hex.EncodeToString(key[:])+".json")
+}
+
+// AssetDigest returns the digest of the file at path using the given
+// algorithm. Digests are cached by device, inode, size and modification
+// times so that large guest images are only hashed again after they change.
+func AssetDigest(path, algorithm string) (string, error) {
+	entry, err := newAssetDigestCacheEntry(path)
+	if err != nil {
+		return "", err
+	}
+
+	cachePath := assetDigestCachePath(path, algorithm)
+
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cached assetDigestCacheEntry
+		if json.Unmarshal(data, &cached) == nil && strings.HasPrefix(cached.Digest, algorithm+":") {
+			digest := cached.Digest
+			cached.Digest = ""
+			if cached == entry {
+				return digest, nil
+			}
+		}
+	}
+
+	digest, err := types.ComputeFileDigest(path, algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	// The file may have been replaced while it was being hashed, in
+	// which case the digest must not be associated with the new inode.
+	after, err := newAssetDigestCacheEntry(path)
+	if err != nil || after != entry {
+		return digest, nil
+	}
+
+	entry.Digest = digest
+
+	// The cache is an optimisation only: failing to update it is not an
+	// error.
+	if err := writeAssetDigestCache(cachePath, entry); err != nil {
+		assetLogger.WithError(err).WithField("asset", path).Debug("could not cache asset digest")
+	}
+
+	return digest, nil
+}
+
+func writeAssetDigestCache(cachePath string, entry assetDigestCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".digest-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), cachePath)
+}
+
+// VerifyAssetDigest checks that the file at path matches the expected
+// "<algorithm>:<hex>" digest.
+func VerifyAssetDigest(path, expected string) error {
+	algorithm, value, err := types.ParseAssetDigest(expected)
+	if err != nil {
+		return err
+	}
+
+	digest, err := AssetDigest(path, algorithm)
+	if err != nil {
+		return err
+	}
+
+	if digest != algorithm+":"+value {
+		return fmt.Errorf("asset %s digest mismatch: expected %s, got %s", path, expected, digest)
+	}
+
+	return nil
+}
+
+// LoadAssetManifest verifies the signature of an asset manifest and returns
+// the digest it lists for each path.
+//
+// The manifest has one "<algorithm>:<hex> <absolute path>" entry per line.
+// Empty lines and lines starting with '#' are ignored. The signature is
+// read from the manifest path with AssetManifestSignatureSuffix appended.
+func LoadAssetManifest(manifestPath, publicKeyPath string) (map[string]string, error) {
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyAssetManifestSignature(manifest, manifestPath+AssetManifestSignatureSuffix, publicKeyPath); err != nil {
+		return nil, err
+	}
+
+	return parseAssetManifest(manifest)
+}
+
+func verifyAssetManifestSignature(manifest []byte, signaturePath, publicKeyPath string) error {
+	if publicKeyPath == "" {
+		return fmt.Errorf("asset manifest requires a public key")
+	}
+
+	keyData, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return fmt.Errorf("no PEM data found in %s", publicKeyPath)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key %s: %v", publicKeyPath, err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("public key %s is not an ed25519 key", publicKeyPath)
+	}
+
+	encoded, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid asset manifest signature %s: %v", signaturePath, err)
+	}
+
+	if !ed25519.Verify(publicKey, manifest, signature) {
+		return fmt.Errorf("asset manifest signature %s does not match", signaturePath)
+	}
+
+	return nil
+}
+
+func parseAssetManifest(manifest []byte) (map[string]string, error) {
+	digests := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("asset manifest line %d: expected <digest> <path>", line)
+		}
+
+		algorithm, value, err := types.ParseAssetDigest(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("asset manifest line %d: %v", line, err)
+		}
+
+		path := fields[1]
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("asset manifest line %d: %s is not an absolute path", line, path)
+		}
+
+		path = filepath.Clean(path)
+		if _, ok := digests[path]; ok {
+			return nil, fmt.Errorf("asset manifest line %d: duplicate entry for %s", line, path)
+		}
+
+		digests[path] = algorithm + ":" + value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
+
+// ExpectedAssetDigests returns the digest every asset used by the
+// configuration must match, keyed by asset type. Assets without a known
+// digest are omitted.
+//
+// A digest set for an asset type applies to the configured path only: an
+// asset overridden through annotations must be listed in the manifest. When
+// a manifest is configured, every asset in use must be listed in it or have
+// a digest set for its type.
+func (conf *HypervisorConfig) ExpectedAssetDigests() (map[types.AssetType]string, error) {
+	var manifest map[string]string
+
+	if conf.AssetManifest != "" {
+		var err error
+		if manifest, err = LoadAssetManifest(conf.AssetManifest, conf.AssetManifestPublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	expected := make(map[types.AssetType]string)
+
+	for _, t := range types.AssetTypes() {
+		path, err := conf.assetPath(t)
+		if err != nil {
+			return nil, err
+		}
+
+		if path == "" {
+			continue
+		}
+
+		if digest, ok := conf.AssetDigests[t]; ok && !conf.isCustomAsset(t) {
+			expected[t] = digest
+			continue
+		}
+
+		if manifest == nil {
+			continue
+		}
+
+		digest, ok := manifest[filepath.Clean(path)]
+		if !ok {
+			return nil, fmt.Errorf("%s asset %s is not listed in asset manifest %s", t, path, conf.AssetManifest)
+		}
+
+		expected[t] = digest
+	}
+
+	return expected, nil
+}
+
+// verifyAssets checks every asset used by the configuration against its
+// expected digest.
+func (conf *HypervisorConfig) verifyAssets() error {
+	expected, err := conf.ExpectedAssetDigests()
+	if err != nil {
+		return err
+	}
+
+	for t, digest := range expected {
+		path, err := conf.assetPath(t)
+		if err != nil {
+			return err
+		}
+
+		if err := VerifyAssetDigest(path, digest); err != nil {
+			return fmt.Errorf("%s asset verification failed: %v", t, err)
+		}
+
+		assetLogger.WithFields(logrus.Fields{
+			"asset":  t,
+			"path":   path,
+			"digest": digest,
+		}).Debug("asset verified")
+	}
+
+	return nil
+}
+
+// verifySandboxAssets checks the integrity of the guest assets before the VM
+// is created. Remote hypervisors do not use assets from the host.
+func verifySandboxAssets(ctx context.Context, sandboxConfig *SandboxConfig) error {
+	span, _ := katatrace.Trace(ctx, nil, "verifySandboxAssets", sandboxTracingTags, map[string]string{"sandbox_id": sandboxConfig.ID})
+	defer span.End()
+
+	if sandboxConfig.HypervisorType == RemoteHypervisor {
+		return nil
+	}
+
+	return sandboxConfig.HypervisorConfig.verifyAssets()
+}
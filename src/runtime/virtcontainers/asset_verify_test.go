@@ -0,0 +1,237 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockAssetDigestCacheDir(t *testing.T) string {
+	dir := t.TempDir()
+
+	saved := assetDigestCacheDir
+	assetDigestCacheDir = func() string {
+		return dir
+	}
+	t.Cleanup(func() {
+		assetDigestCacheDir = saved
+	})
+
+	return dir
+}
+
+func writeSignedAssetManifest(t *testing.T, dir, contents string) (string, string, ed25519.PrivateKey) {
+	assert := assert.New(t)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(err)
+
+	keyPath := filepath.Join(dir, "assets.pub")
+	assert.NoError(os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	manifestPath := filepath.Join(dir, "assets.manifest")
+	assert.NoError(os.WriteFile(manifestPath, []byte(contents), 0600))
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(contents)))
+	assert.NoError(os.WriteFile(manifestPath+AssetManifestSignatureSuffix, []byte(signature+"\n"), 0600))
+
+	return manifestPath, keyPath, privateKey
+}
+
+func TestAssetDigestCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cacheDir := mockAssetDigestCacheDir(t)
+
+	path := filepath.Join(t.TempDir(), "image")
+	assert.NoError(os.WriteFile(path, []byte("image"), 0600))
+
+	digest, err := AssetDigest(path, types.SHA256)
+	assert.NoError(err)
+
+	expected, err := types.ComputeFileDigest(path, types.SHA256)
+	assert.NoError(err)
+	assert.Equal(expected, digest)
+
+	// The cached digest is returned while the file attributes are
+	// unchanged, whatever the file contains.
+	cachePath := assetDigestCachePath(path, types.SHA256)
+	assert.Equal(cacheDir, filepath.Dir(cachePath))
+
+	data, err := os.ReadFile(cachePath)
+	assert.NoError(err)
+
+	var entry assetDigestCacheEntry
+	assert.NoError(json.Unmarshal(data, &entry))
+	assert.Equal(digest, entry.Digest)
+
+	entry.Digest = types.SHA256 + ":" + fmt.Sprintf("%064x", 0)
+	data, err = json.Marshal(entry)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(cachePath, data, 0600))
+
+	digest, err = AssetDigest(path, types.SHA256)
+	assert.NoError(err)
+	assert.Equal(entry.Digest, digest)
+
+	// Modifying the file invalidates the cache entry.
+	assert.NoError(os.WriteFile(path, []byte("new image"), 0600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(path, later, later))
+
+	digest, err = AssetDigest(path, types.SHA256)
+	assert.NoError(err)
+
+	expected, err = types.ComputeFileDigest(path, types.SHA256)
+	assert.NoError(err)
+	assert.Equal(expected, digest)
+
+	// Directories are not assets.
+	_, err = AssetDigest(filepath.Dir(path), types.SHA256)
+	assert.Error(err)
+}
+
+func TestVerifyAssetDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAssetDigestCacheDir(t)
+
+	path := filepath.Join(t.TempDir(), "kernel")
+	assert.NoError(os.WriteFile(path, []byte("kernel"), 0600))
+
+	digest, err := types.ComputeFileDigest(path, types.SHA512)
+	assert.NoError(err)
+
+	assert.NoError(VerifyAssetDigest(path, digest))
+	assert.Error(VerifyAssetDigest(path, types.SHA512+":"+fmt.Sprintf("%0128x", 0)))
+	assert.Error(VerifyAssetDigest(path, "sha512"))
+	assert.Error(VerifyAssetDigest(path+"-missing", digest))
+}
+
+func TestLoadAssetManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	digest := types.SHA256 + ":" + fmt.Sprintf("%064x", 1)
+
+	contents := fmt.Sprintf("# guest assets\n\n%s /opt/kata/share/kata-containers/../kata-containers/vmlinux\n", digest)
+	manifestPath, keyPath, privateKey := writeSignedAssetManifest(t, dir, contents)
+
+	digests, err := LoadAssetManifest(manifestPath, keyPath)
+	assert.NoError(err)
+	assert.Equal(map[string]string{"/opt/kata/share/kata-containers/vmlinux": digest}, digests)
+
+	_, err = LoadAssetManifest(manifestPath, "")
+	assert.Error(err)
+
+	// tampered manifest
+	assert.NoError(os.WriteFile(manifestPath, []byte(contents+digest+" /usr/bin/qemu\n"), 0600))
+	_, err = LoadAssetManifest(manifestPath, keyPath)
+	assert.Error(err)
+
+	// correctly signed but invalid manifests
+	for _, invalid := range []string{
+		digest + "\n",
+		digest + " relative/path\n",
+		"md5:0 /vmlinux\n",
+		digest + " /vmlinux\n" + digest + " /vmlinux\n",
+	} {
+		assert.NoError(os.WriteFile(manifestPath, []byte(invalid), 0600))
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(invalid)))
+		assert.NoError(os.WriteFile(manifestPath+AssetManifestSignatureSuffix, []byte(signature), 0600))
+
+		_, err = LoadAssetManifest(manifestPath, keyPath)
+		assert.Error(err, "manifest %q", invalid)
+	}
+}
+
+func TestHypervisorConfigVerifyAssets(t *testing.T) {
+	assert := assert.New(t)
+
+	mockAssetDigestCacheDir(t)
+
+	dir := t.TempDir()
+
+	kernel := filepath.Join(dir, "vmlinux")
+	image := filepath.Join(dir, "image")
+	customImage := filepath.Join(dir, "custom-image")
+
+	for _, path := range []string{kernel, image, customImage} {
+		assert.NoError(os.WriteFile(path, []byte(filepath.Base(path)), 0600))
+	}
+
+	kernelDigest, err := types.ComputeFileDigest(kernel, types.SHA256)
+	assert.NoError(err)
+
+	imageDigest, err := types.ComputeFileDigest(image, types.SHA512)
+	assert.NoError(err)
+
+	conf := &HypervisorConfig{
+		KernelPath: kernel,
+		ImagePath:  image,
+		AssetDigests: map[types.AssetType]string{
+			types.KernelAsset: kernelDigest,
+		},
+	}
+
+	// assets without digests are not verified
+	assert.NoError(conf.verifyAssets())
+
+	conf.AssetDigests[types.ImageAsset] = types.SHA512 + ":" + fmt.Sprintf("%0128x", 0)
+	assert.Error(conf.verifyAssets())
+
+	conf.AssetDigests[types.ImageAsset] = imageDigest
+	assert.NoError(conf.verifyAssets())
+
+	// Once a manifest is configured, all assets must be covered.
+	manifestPath, keyPath, _ := writeSignedAssetManifest(t, dir, fmt.Sprintf("%s %s\n", imageDigest, image))
+	conf.AssetDigests = nil
+	conf.AssetManifest = manifestPath
+	conf.AssetManifestPublicKey = keyPath
+
+	_, err = conf.ExpectedAssetDigests()
+	assert.Error(err)
+
+	manifestPath, keyPath, _ = writeSignedAssetManifest(t, dir, fmt.Sprintf("%s %s\n%s %s\n", imageDigest, image, kernelDigest, kernel))
+	conf.AssetManifest = manifestPath
+	conf.AssetManifestPublicKey = keyPath
+
+	expected, err := conf.ExpectedAssetDigests()
+	assert.NoError(err)
+	assert.Equal(map[types.AssetType]string{
+		types.KernelAsset: kernelDigest,
+		types.ImageAsset:  imageDigest,
+	}, expected)
+	assert.NoError(conf.verifyAssets())
+
+	// The digest set for a type does not apply to an asset selected
+	// through annotations, which must be listed in the manifest.
+	conf.AssetDigests = map[types.AssetType]string{types.ImageAsset: imageDigest}
+	conf.customAssets = map[types.AssetType]*types.Asset{}
+	a, err := types.NewAsset(map[string]string{annotations.ImagePath: customImage}, types.ImageAsset)
+	assert.NoError(err)
+	assert.NoError(conf.AddCustomAsset(a))
+
+	_, err = conf.ExpectedAssetDigests()
+	assert.Error(err)
+}
@@ -87,8 +87,14 @@ const (
 	clhStopSandboxTimeoutConfidentialGuest = 10
 	clhSocket                              = "clh.sock"
 	clhAPISocket                           = "clh-api.sock"
+	clhMigrationAPISocket                  = "clh-api-migration.sock"
+	clhMigrationSocket                     = "clh-migration.sock"
+	clhSnapshotState                       = "snapshot-state.json"
 	virtioFsSocket                         = "virtiofsd.sock"
 	defaultClhPath                         = "/usr/local/bin/cloud-hypervisor"
+	// Timeout for migrating or snapshotting a VM, which copies the guest
+	// memory unless it is shared with the destination VMM.
+	clhMigrationTimeout = 60
 )
 
 // Interface that hides the implementation of openAPI client
@@ -109,10 +115,18 @@ type clhClient interface {
 	BootVM(ctx context.Context) (*http.Response, error)
 	// Add/remove CPUs to/from the VM
 	VmResizePut(ctx context.Context, vmResize chclient.VmResize) (*http.Response, error)
+	// Resize a memory zone of the VM
+	VmResizeZonePut(ctx context.Context, vmResizeZone chclient.VmResizeZone) (*http.Response, error)
 	// Add VFIO PCI device to the VM
 	VmAddDevicePut(ctx context.Context, deviceConfig chclient.DeviceConfig) (chclient.PciDeviceInfo, *http.Response, error)
 	// Add a new disk device to the VM
 	VmAddDiskPut(ctx context.Context, diskConfig chclient.DiskConfig) (chclient.PciDeviceInfo, *http.Response, error)
+	// Add a new vDPA device to the VM
+	VmAddVdpaPut(ctx context.Context, vdpaConfig chclient.VdpaConfig) (chclient.PciDeviceInfo, *http.Response, error)
+	// Add a new pmem device to the VM
+	VmAddPmemPut(ctx context.Context, pmemConfig chclient.PmemConfig) (chclient.PciDeviceInfo, *http.Response, error)
+	// Resize a disk of the VM
+	VmResizeDiskPut(ctx context.Context, vmResizeDisk chclient.VmResizeDisk) (*http.Response, error)
 	// Pause the VM
 	VmPausePut(ctx context.Context) (*http.Response, error)
 	// Create a snapshot of the VM
@@ -123,6 +137,14 @@ type clhClient interface {
 	VmRestorePut(ctx context.Context, restoreConfig chclient.RestoreConfig) (*http.Response, error)
 	// Resume a paused VM
 	ResumeVM(ctx context.Context) (*http.Response, error)
+	// Send the VM to a migration destination
+	VmSendMigrationPut(ctx context.Context, sendMigrationData chclient.SendMigrationData) (*http.Response, error)
+	// Receive a VM from a migration source
+	VmReceiveMigrationPut(ctx context.Context, receiveMigrationData chclient.ReceiveMigrationData) (*http.Response, error)
+	// Get the counters of the VM devices
+	VmCountersGet(ctx context.Context) (map[string]map[string]int64, *http.Response, error)
+	// Write the guest memory to an ELF core file
+	VmCoredumpPut(ctx context.Context, vmCoredumpData chclient.VmCoredumpData) (*http.Response, error)
 }
 
 type clhClientApi struct {
@@ -154,6 +176,10 @@ func (c *clhClientApi) VmResizePut(ctx context.Context, vmResize chclient.VmResi
 	return c.ApiInternal.VmResizePut(ctx).VmResize(vmResize).Execute()
 }
 
+func (c *clhClientApi) VmResizeZonePut(ctx context.Context, vmResizeZone chclient.VmResizeZone) (*http.Response, error) {
+	return c.ApiInternal.VmResizeZonePut(ctx).VmResizeZone(vmResizeZone).Execute()
+}
+
 func (c *clhClientApi) VmAddDevicePut(ctx context.Context, deviceConfig chclient.DeviceConfig) (chclient.PciDeviceInfo, *http.Response, error) {
 	return c.ApiInternal.VmAddDevicePut(ctx).DeviceConfig(deviceConfig).Execute()
 }
@@ -162,6 +188,18 @@ func (c *clhClientApi) VmAddDiskPut(ctx context.Context, diskConfig chclient.Dis
 	return c.ApiInternal.VmAddDiskPut(ctx).DiskConfig(diskConfig).Execute()
 }
 
+func (c *clhClientApi) VmAddVdpaPut(ctx context.Context, vdpaConfig chclient.VdpaConfig) (chclient.PciDeviceInfo, *http.Response, error) {
+	return c.ApiInternal.VmAddVdpaPut(ctx).VdpaConfig(vdpaConfig).Execute()
+}
+
+func (c *clhClientApi) VmAddPmemPut(ctx context.Context, pmemConfig chclient.PmemConfig) (chclient.PciDeviceInfo, *http.Response, error) {
+	return c.ApiInternal.VmAddPmemPut(ctx).PmemConfig(pmemConfig).Execute()
+}
+
+func (c *clhClientApi) VmResizeDiskPut(ctx context.Context, vmResizeDisk chclient.VmResizeDisk) (*http.Response, error) {
+	return c.ApiInternal.VmResizeDiskPut(ctx).VmResizeDisk(vmResizeDisk).Execute()
+}
+
 func (c *clhClientApi) VmPausePut(ctx context.Context) (*http.Response, error) {
 	return c.ApiInternal.PauseVM(ctx).Execute()
 }
@@ -182,6 +220,53 @@ func (c *clhClientApi) ResumeVM(ctx context.Context) (*http.Response, error) {
 	return c.ApiInternal.ResumeVM(ctx).Execute()
 }
 
+func (c *clhClientApi) VmSendMigrationPut(ctx context.Context, sendMigrationData chclient.SendMigrationData) (*http.Response, error) {
+	return c.ApiInternal.VmSendMigrationPut(ctx).SendMigrationData(sendMigrationData).Execute()
+}
+
+func (c *clhClientApi) VmReceiveMigrationPut(ctx context.Context, receiveMigrationData chclient.ReceiveMigrationData) (*http.Response, error) {
+	return c.ApiInternal.VmReceiveMigrationPut(ctx).ReceiveMigrationData(receiveMigrationData).Execute()
+}
+
+//nolint:golint
+func (c *clhClientApi) VmCountersGet(ctx context.Context) (map[string]map[string]int64, *http.Response, error) {
+	return c.ApiInternal.VmCountersGet(ctx).Execute()
+}
+
+//nolint:golint
+func (c *clhClientApi) VmCoredumpPut(ctx context.Context, vmCoredumpData chclient.VmCoredumpData) (*http.Response, error) {
+	return c.ApiInternal.VmCoredumpPut(ctx).VmCoredumpData(vmCoredumpData).Execute()
+}
+
+// newClhClient returns a client of the cloud-hypervisor API served on the
+// socket returned by socketPath, which is called for every connection.
+func newClhClient(socketPath func() string) clhClient {
+	cfg := chclient.NewConfiguration()
+	cfg.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, path string) (net.Conn, error) {
+				addr, err := net.ResolveUnixAddr("unix", socketPath())
+				if err != nil {
+					return nil, err
+				}
+
+				return net.DialUnix("unix", nil, addr)
+			},
+		},
+	}
+
+	return &clhClientApi{
+		ApiInternal: chclient.NewAPIClient(cfg).DefaultApi,
+	}
+}
+
+// This is done in order to be able to override such a function as part of
+// our unit tests, as the VMM a VM is migrated from is no longer the one
+// clh.client() talks to.
+var clhClientForSocket = func(socketPath string) clhClient {
+	return newClhClient(func() string { return socketPath })
+}
+
 // This is done in order to be able to override such a function as part of
 // our unit tests, as when testing bootVM we're on a mocked scenario already.
 var vmAddNetPutRequest = func(clh *cloudHypervisor) ([]chclient.PciDeviceInfo, error) {
@@ -304,6 +389,10 @@ type cloudHypervisor struct {
 	config          HypervisorConfig
 	stopped         int32
 	mu              sync.Mutex
+	// pmemCount is the number of pmem devices hotplugged to the VM, the
+	// guest names them /dev/pmem0, /dev/pmem1... in the order they are added.
+	pmemCount int
+	events    hypervisorEventStream
 }
 
 var clhKernelParams = []Param{
@@ -496,7 +585,18 @@ func (clh *cloudHypervisor) enableProtection() error {
 	}
 }
 
-func getNonUserDefinedKernelParams(rootfstype string, disableNvdimm bool, dax bool, debug bool, confidential bool, iommu bool, kernelVerityParams string) ([]Param, error) {
+// clhConsoleKernelParams returns the kernel parameters setting the guest
+// console up.
+func clhConsoleKernelParams(confidential bool) []Param {
+	if confidential {
+		return clhDebugConfidentialGuestKernelParams
+	} else if runtime.GOARCH == "arm64" {
+		return clhArmDebugKernelParams
+	}
+	return clhDebugKernelParams
+}
+
+func getNonUserDefinedKernelParams(rootfstype string, disableNvdimm bool, dax bool, debug bool, console bool, confidential bool, iommu bool, kernelVerityParams string) ([]Param, error) {
 	params, err := GetKernelRootParams(rootfstype, disableNvdimm, dax, kernelVerityParams)
 	if err != nil {
 		return []Param{}, err
@@ -510,19 +610,19 @@ func getNonUserDefinedKernelParams(rootfstype string, disableNvdimm bool, dax bo
 	if !debug {
 		// start the guest kernel with 'quiet' in non-debug mode
 		params = append(params, Param{"quiet", ""})
+
+		// The quiet output of the kernel is still captured to the
+		// sandbox console log.
+		if console {
+			params = append(params, clhConsoleKernelParams(confidential)...)
+		}
 		return params, nil
 	}
 
 	// In case of debug ...
 
 	// Followed by extra debug parameters if debug enabled in configuration file
-	if confidential {
-		params = append(params, clhDebugConfidentialGuestKernelParams...)
-	} else if runtime.GOARCH == "arm64" {
-		params = append(params, clhArmDebugKernelParams...)
-	} else {
-		params = append(params, clhDebugKernelParams...)
-	}
+	params = append(params, clhConsoleKernelParams(confidential)...)
 	params = append(params, clhDebugKernelParamsCommon...)
 	return params, nil
 }
@@ -536,6 +636,8 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 	clh.ctx = newCtx
 	defer span.End()
 
+	maybeRightSizeAutoNUMA(hypervisorConfig, clh.Logger())
+
 	if err := clh.setConfig(hypervisorConfig); err != nil {
 		return err
 	}
@@ -556,6 +658,10 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 		}
 		clh.virtiofsDaemon = virtiofsDaemon
 
+		// Talk to the VMM the sandbox was last running on, which
+		// changes when the VM is migrated.
+		clh.APIClient = newClhClient(func() string { return clh.state.apiSocket })
+
 		return nil
 	}
 
@@ -632,6 +738,11 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 		clh.vmconfig.Memory.Zones = &[]chclient.MemoryZoneConfig{
 			*memoryZoneConfig,
 		}
+	} else if clh.numaEnabled() {
+		// Each guest NUMA node gets its own memory zone
+		if err := clh.setNUMATopology(); err != nil {
+			return err
+		}
 	} else { // Normal (non-template) VM creation
 		// Create the VM memory config via the constructor to ensure default values are properly assigned
 		clh.vmconfig.Memory = chclient.NewMemoryConfig(int64((utils.MemUnit(clh.config.MemorySize) * utils.MiB).ToBytes()))
@@ -671,7 +782,7 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 	disableNvdimm := true
 	enableDax := false
 
-	params, err := getNonUserDefinedKernelParams(hypervisorConfig.RootfsType, disableNvdimm, enableDax, clh.config.Debug, clh.config.ConfidentialGuest, clh.config.IOMMU, hypervisorConfig.KernelVerityParams)
+	params, err := getNonUserDefinedKernelParams(hypervisorConfig.RootfsType, disableNvdimm, enableDax, clh.config.Debug, clh.config.GuestConsoleEnabled(), clh.config.ConfidentialGuest, clh.config.IOMMU, hypervisorConfig.KernelVerityParams)
 	if err != nil {
 		return err
 	}
@@ -680,6 +791,10 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 
 	clh.vmconfig.Payload.SetCmdline(kernelParamsToString(params))
 
+	if clh.config.IfPVPanicEnabled() {
+		clh.vmconfig.SetPvpanic(true)
+	}
+
 	// set random device generator to hypervisor
 	clh.vmconfig.Rng = chclient.NewRngConfig(clh.config.EntropySource)
 	clh.vmconfig.Rng.SetIommu(clh.config.IOMMU)
@@ -714,7 +829,7 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 	if clh.config.ConfidentialGuest {
 		// Use HVC as the guest console only in debug mode, only
 		// for Confidential Guests
-		if clh.config.Debug {
+		if clh.config.GuestConsoleEnabled() {
 			clh.vmconfig.Console = chclient.NewConsoleConfig(cctTTY)
 		} else {
 			clh.vmconfig.Console = chclient.NewConsoleConfig(cctOFF)
@@ -722,9 +837,10 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 
 		clh.vmconfig.Serial = chclient.NewConsoleConfig(cctOFF)
 	} else {
-		// Use serial port as the guest console only in debug mode,
-		// so that we can gather early OS booting log
-		if clh.config.Debug {
+		// Use serial port as the guest console in debug mode, or to
+		// capture it to the sandbox console log, so that we can gather
+		// early OS booting log
+		if clh.config.GuestConsoleEnabled() {
 			clh.vmconfig.Serial = chclient.NewConsoleConfig(cctTTY)
 		} else {
 			clh.vmconfig.Serial = chclient.NewConsoleConfig(cctOFF)
@@ -749,23 +865,7 @@ func (clh *cloudHypervisor) CreateVM(ctx context.Context, id string, network Net
 	}
 	clh.state.apiSocket = apiSocketPath
 
-	cfg := chclient.NewConfiguration()
-	cfg.HTTPClient = &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, path string) (net.Conn, error) {
-				addr, err := net.ResolveUnixAddr("unix", clh.state.apiSocket)
-				if err != nil {
-					return nil, err
-				}
-
-				return net.DialUnix("unix", nil, addr)
-			},
-		},
-	}
-
-	clh.APIClient = &clhClientApi{
-		ApiInternal: chclient.NewAPIClient(cfg).DefaultApi,
-	}
+	clh.APIClient = newClhClient(func() string { return clh.state.apiSocket })
 
 	clh.virtiofsDaemon, err = clh.createVirtiofsDaemon(filepath.Join(GetSharePath(clh.id)))
 	if err != nil {
@@ -980,6 +1080,14 @@ func (clh *cloudHypervisor) StartVM(ctx context.Context, timeout int) error {
 			return fmt.Errorf("failed to update vsock socket path: %v", err)
 		}
 
+		// The VM directory is owned by the VMM user when rootless,
+		// cloud-hypervisor needs to read the copies.
+		for _, path := range []string{dstConfig, dstState} {
+			if err := utils.ChownToParent(path); err != nil {
+				return err
+			}
+		}
+
 		if err := clh.restoreVM(ctx); err != nil {
 			return err
 		}
@@ -1140,6 +1248,12 @@ func (clh *cloudHypervisor) hotplugAddBlockDevice(drive *config.BlockDrive) erro
 		return fmt.Errorf("cloudHypervisor doesn't support swap")
 	}
 
+	// drive can be a pmem device, in which case it's used as backing file
+	// for a virtio-pmem device
+	if drive.Pmem {
+		return clh.hotplugAddPmemDevice(drive)
+	}
+
 	if clh.config.BlockDeviceDriver != config.VirtioBlock {
 		return fmt.Errorf("incorrect hypervisor configuration on 'block_device_driver':"+
 			" using '%v' but only support '%v'", clh.config.BlockDeviceDriver, config.VirtioBlock)
@@ -1153,10 +1267,6 @@ func (clh *cloudHypervisor) hotplugAddBlockDevice(drive *config.BlockDrive) erro
 
 	driveID := clhDriveIndexToID(drive.Index)
 
-	if drive.Pmem {
-		return fmt.Errorf("pmem device hotplug not supported")
-	}
-
 	// Create the clh disk config via the constructor to ensure default values are properly assigned
 	clhDisk := *chclient.NewDiskConfig()
 	clhDisk.Path = &drive.File
@@ -1190,6 +1300,63 @@ func (clh *cloudHypervisor) hotplugAddBlockDevice(drive *config.BlockDrive) erro
 	return err
 }
 
+func (clh *cloudHypervisor) hotplugAddPmemDevice(drive *config.BlockDrive) error {
+	cl := clh.client()
+	ctx, cancel := context.WithTimeout(context.Background(), clhHotPlugAPITimeout*time.Second)
+	defer cancel()
+
+	driveID := clhDriveIndexToID(drive.Index)
+
+	// The size of the device is the one of the backing file, writes are
+	// discarded for read-only volumes.
+	clhPmem := *chclient.NewPmemConfig(drive.File)
+	clhPmem.SetId(driveID)
+	clhPmem.SetDiscardWrites(drive.ReadOnly)
+	clhPmem.SetIommu(clh.config.IOMMU)
+
+	pciInfo, _, err := cl.VmAddPmemPut(ctx, clhPmem)
+	if err != nil {
+		return fmt.Errorf("failed to hotplug pmem device %+v %s", drive, openAPIClientError(err))
+	}
+
+	clh.devicesIds[driveID] = pciInfo.GetId()
+	drive.NvdimmID = strconv.Itoa(clh.pmemCount)
+	clh.pmemCount++
+
+	return nil
+}
+
+// resizeDisk grows or shrinks the virtio-blk device of drive to size bytes,
+// the guest is notified of the new capacity of the disk.
+func (clh *cloudHypervisor) resizeDisk(ctx context.Context, drive *config.BlockDrive, size uint64) error {
+	span, _ := katatrace.Trace(ctx, clh.Logger(), "resizeDisk", clhTracingTags, map[string]string{"sandbox_id": clh.id})
+	defer span.End()
+
+	if drive.Pmem {
+		return fmt.Errorf("pmem device %s cannot be resized", drive.File)
+	}
+
+	deviceID, ok := clh.devicesIds[clhDriveIndexToID(drive.Index)]
+	if !ok {
+		return fmt.Errorf("block device %s is not plugged to the VM", drive.File)
+	}
+
+	cl := clh.client()
+	ctx, cancel := context.WithTimeout(context.Background(), clhHotPlugAPITimeout*time.Second)
+	defer cancel()
+
+	resize := *chclient.NewVmResizeDisk()
+	resize.SetId(deviceID)
+	resize.SetDesiredSize(int64(size))
+	if _, err := cl.VmResizeDiskPut(ctx, resize); err != nil {
+		return fmt.Errorf("failed to resize block device %s to %d bytes: %s", drive.File, size, openAPIClientError(err))
+	}
+
+	clh.Logger().WithFields(log.Fields{"drive": drive.File, "size": size}).Info("Resized block device")
+
+	return nil
+}
+
 // coldPlugVFIODevice appends a VFIO device to the VM configuration so that it
 // is present when the VM is created (before boot). Cloud Hypervisor's CreateVM
 // API accepts a list of devices that are attached at VM creation time, which
@@ -1266,6 +1433,53 @@ func (clh *cloudHypervisor) hotPlugVFIODevice(device *config.VFIODev) error {
 	return err
 }
 
+func (clh *cloudHypervisor) newVdpaConfig(device *config.VhostVDPADeviceAttrs) chclient.VdpaConfig {
+	numQueues := int32(device.NumQueues)
+	if numQueues == 0 {
+		numQueues = 1
+	}
+
+	vdpa := *chclient.NewVdpaConfig(device.VhostDevPath, numQueues)
+	vdpa.SetIommu(clh.config.IOMMU)
+	vdpa.SetId(device.DevID)
+
+	return vdpa
+}
+
+func (clh *cloudHypervisor) coldPlugVhostVDPADevice(device *config.VhostVDPADeviceAttrs) error {
+	clh.Logger().WithFields(log.Fields{
+		"device": device.DevID,
+		"path":   device.VhostDevPath,
+	}).Info("Cold-plugging vDPA device into VM config")
+
+	vdpa := clh.newVdpaConfig(device)
+	if clh.vmconfig.Vdpa != nil {
+		*clh.vmconfig.Vdpa = append(*clh.vmconfig.Vdpa, vdpa)
+	} else {
+		clh.vmconfig.Vdpa = &[]chclient.VdpaConfig{vdpa}
+	}
+
+	clh.devicesIds[device.DevID] = device.DevID
+
+	return nil
+}
+
+func (clh *cloudHypervisor) hotPlugVhostVDPADevice(device *config.VhostVDPADeviceAttrs) error {
+	cl := clh.client()
+	ctx, cancel := context.WithTimeout(context.Background(), clhHotPlugAPITimeout*time.Second)
+	defer cancel()
+
+	pciInfo, _, err := cl.VmAddVdpaPut(ctx, clh.newVdpaConfig(device))
+	if err != nil {
+		return fmt.Errorf("Failed to hotplug vDPA device %+v %s", device, openAPIClientError(err))
+	}
+	clh.devicesIds[device.DevID] = pciInfo.GetId()
+
+	device.PCIPath, err = clhPciInfoToPath(pciInfo)
+
+	return err
+}
+
 func (clh *cloudHypervisor) hotplugAddNetDevice(e Endpoint) error {
 	err := clh.addNet(e)
 	if err != nil {
@@ -1310,6 +1524,9 @@ func (clh *cloudHypervisor) HotplugAddDevice(ctx context.Context, devInfo interf
 	case NetDev:
 		device := devInfo.(Endpoint)
 		return nil, clh.hotplugAddNetDevice(device)
+	case VhostVDPADev:
+		device := devInfo.(*config.VhostVDPADeviceAttrs)
+		return nil, clh.hotPlugVhostVDPADevice(device)
 	default:
 		return nil, fmt.Errorf("cannot hotplug device: unsupported device type '%v'", devType)
 	}
@@ -1327,6 +1544,8 @@ func (clh *cloudHypervisor) HotplugRemoveDevice(ctx context.Context, devInfo int
 		deviceID = clhDriveIndexToID(devInfo.(*config.BlockDrive).Index)
 	case VfioDev:
 		deviceID = devInfo.(*config.VFIODev).ID
+	case VhostVDPADev:
+		deviceID = devInfo.(*config.VhostVDPADeviceAttrs).DevID
 	default:
 		clh.Logger().WithFields(log.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Error("HotplugRemoveDevice: unsupported device")
@@ -1372,6 +1591,12 @@ func (clh *cloudHypervisor) ResizeMemory(ctx context.Context, reqMemMB uint32, m
 		return 0, MemoryDevice{}, err
 	}
 
+	// The memory of a VM with a NUMA topology lives in its memory zones,
+	// which are resized with virtio-mem.
+	if info.Config.Memory.GetHotplugMethod() == clhVirtioMemHotplugMethod && info.Config.Memory.Zones != nil {
+		return clh.resizeMemoryZones(ctx, *info.Config.Memory.Zones, reqMemMB, memoryBlockSizeMB)
+	}
+
 	// HotplugSize can be nil in cases where Hotplug is not supported, as Cloud Hypervisor API
 	// does *not* allow us to set 0 as the HotplugSize.
 	maxHotplugSize := 0 * utils.Byte
@@ -1689,6 +1914,8 @@ func (clh *cloudHypervisor) AddDevice(ctx context.Context, devInfo interface{},
 		err = clh.addVolume(v)
 	case config.VFIODev:
 		err = clh.coldPlugVFIODevice(&v)
+	case config.VhostVDPADeviceAttrs:
+		err = clh.coldPlugVhostVDPADevice(&v)
 	default:
 		clh.Logger().WithField("function", "AddDevice").Warnf("Add device of type %v is not supported.", v)
 		return fmt.Errorf("Not implemented support for %s", v)
@@ -1863,6 +2090,19 @@ func (clh *cloudHypervisor) launchClh() error {
 		args = append(args, "--seccomp", "false")
 	}
 
+	// The event monitor reports the lifecycle events of the VM, and the
+	// guest panics notified to the pvpanic device so that the guest memory
+	// can be dumped.
+	events, eventMonitor, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer eventMonitor.Close()
+	go clh.watchEvents(events)
+
+	// The first extra file is the fd 3 of the child.
+	args = append(args, "--event-monitor", "fd=3")
+
 	clh.Logger().WithField("path", clhPath).Info()
 	clh.Logger().WithField("args", strings.Join(args, " ")).Info()
 
@@ -1870,13 +2110,24 @@ func (clh *cloudHypervisor) launchClh() error {
 	if clh.config.Debug {
 		cmdHypervisor.Env = os.Environ()
 		cmdHypervisor.Env = append(cmdHypervisor.Env, "RUST_BACKTRACE=full")
-		if clh.console != nil {
-			cmdHypervisor.Stderr = clh.console
-			cmdHypervisor.Stdout = clh.console
-		}
+	}
+	// The console is only set up when the sandbox reads it.
+	if clh.console != nil {
+		cmdHypervisor.Stdout = clh.console
 	}
 	cmdHypervisor.Stderr = cmdHypervisor.Stdout
 
+	// The errors of cloud-hypervisor are kept apart from the guest
+	// console captured to the sandbox console log.
+	if clh.config.ConsoleLogEnabled() {
+		vmmLog, err := openVMMLog(&clh.config, clh.id)
+		if err != nil {
+			return err
+		}
+		defer vmmLog.Close()
+		cmdHypervisor.Stderr = vmmLog
+	}
+
 	attr := syscall.SysProcAttr{}
 	attr.Credential = &syscall.Credential{
 		Uid:    clh.config.Uid,
@@ -1885,6 +2136,8 @@ func (clh *cloudHypervisor) launchClh() error {
 	}
 	cmdHypervisor.SysProcAttr = &attr
 
+	cmdHypervisor.ExtraFiles = []*os.File{eventMonitor}
+
 	err = utils.StartCmd(cmdHypervisor)
 	if err != nil {
 		return err
@@ -2102,6 +2355,334 @@ func (clh *cloudHypervisor) restoreVM(ctx context.Context) error {
 	return nil
 }
 
+// checkMigration returns an error if the VM cannot be moved to another VMM
+// process.
+func (clh *cloudHypervisor) checkMigration() error {
+	if !clh.config.EnableVMMigration {
+		return errors.New("VM migration and snapshots are not enabled in the cloud-hypervisor configuration")
+	}
+
+	// virtiofsd serves a single VMM, and exits when it goes away.
+	if clh.config.SharedFS == config.VirtioFS || clh.config.SharedFS == config.VirtioFSNydus {
+		return errors.New("cloud-hypervisor cannot migrate or restore a VM sharing files with virtio-fs")
+	}
+
+	if clh.config.ConfidentialGuest {
+		return errors.New("cloud-hypervisor cannot migrate or restore a confidential guest")
+	}
+
+	// The tap devices are handed to the VMM as file descriptors, which the
+	// migration and restore APIs have no way to pass again.
+	if clh.netDevices != nil && len(*clh.netDevices) > 0 {
+		return errors.New("cloud-hypervisor cannot migrate or restore a VM with network devices")
+	}
+
+	return nil
+}
+
+// memoryShared tells if the guest memory is shared with other processes,
+// which lets a local migration hand it over instead of copying it.
+func (clh *cloudHypervisor) memoryShared() bool {
+	return clh.config.SharedFS != config.NoSharedFS || clh.config.HugePages
+}
+
+// migrationAPISocketPath returns the API socket of the VMM a VM served on
+// apiSocket is migrated to. Migrations alternate between the two sockets.
+func (clh *cloudHypervisor) migrationAPISocketPath(apiSocket string) (string, error) {
+	socket := clhMigrationAPISocket
+	if filepath.Base(apiSocket) == clhMigrationAPISocket {
+		socket = clhAPISocket
+	}
+
+	return utils.BuildSocketPath(clh.config.VMStorePath, clh.id, socket)
+}
+
+// waitForSocket waits for a unix socket to be created at path.
+func waitForSocket(ctx context.Context, path string) error {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("socket %s was not created: %w", path, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// migrateVM moves the VM to a new cloud-hypervisor process of the same host,
+// through a unix socket. The new process serves the API on another socket,
+// which clh.state and the state saved by Save() then point to.
+func (clh *cloudHypervisor) migrateVM(ctx context.Context) (err error) {
+	span, ctx := katatrace.Trace(ctx, clh.Logger(), "migrateVM", clhTracingTags, map[string]string{"sandbox_id": clh.id})
+	defer span.End()
+
+	if err := clh.checkMigration(); err != nil {
+		return err
+	}
+
+	srcPID := clh.state.PID
+	srcSocket := clh.state.apiSocket
+	dstSocket, err := clh.migrationAPISocketPath(srcSocket)
+	if err != nil {
+		return err
+	}
+
+	migrationSocket, err := utils.BuildSocketPath(clh.config.VMStorePath, clh.id, clhMigrationSocket)
+	if err != nil {
+		return err
+	}
+	receiverURL := "unix:" + migrationSocket
+	defer os.Remove(migrationSocket)
+
+	// The destination binds the vsock socket of the VM again. Moving the
+	// one of the source out of the way keeps it reachable until the
+	// migration succeeds.
+	vsockSocket, err := clh.vsockSocketPath(clh.id)
+	if err != nil {
+		return err
+	}
+	srcVsockSocket := vsockSocket + ".migrating"
+	if err := os.Rename(vsockSocket, srcVsockSocket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	clh.Logger().WithFields(log.Fields{
+		"source":      srcSocket,
+		"destination": dstSocket,
+	}).Info("Migrating VM")
+
+	ctx, cancel := context.WithTimeout(ctx, clhMigrationTimeout*time.Second)
+	defer cancel()
+
+	clh.state.apiSocket = dstSocket
+	defer func() {
+		if err == nil {
+			os.Remove(srcVsockSocket)
+			return
+		}
+
+		if clh.state.PID > 0 && clh.state.PID != srcPID {
+			if err := syscall.Kill(clh.state.PID, syscall.SIGKILL); err != nil {
+				clh.Logger().WithError(err).Warn("Failed to kill the migration destination")
+			}
+		}
+		clh.state.PID = srcPID
+		clh.state.apiSocket = srcSocket
+		os.Remove(dstSocket)
+		if err := os.Rename(srcVsockSocket, vsockSocket); err != nil && !os.IsNotExist(err) {
+			clh.Logger().WithError(err).Error("Failed to restore the vsock socket")
+		}
+	}()
+
+	if err = clh.launchClh(); err != nil {
+		return fmt.Errorf("failed to launch the migration destination: %w", err)
+	}
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := clh.client().VmReceiveMigrationPut(ctx, *chclient.NewReceiveMigrationData(receiverURL))
+		received <- openAPIClientError(err)
+	}()
+
+	if err = waitForSocket(ctx, migrationSocket); err != nil {
+		return err
+	}
+
+	src := clhClientForSocket(srcSocket)
+	sendMigrationData := chclient.NewSendMigrationData(receiverURL)
+	sendMigrationData.SetLocal(clh.memoryShared())
+	if _, err = src.VmSendMigrationPut(ctx, *sendMigrationData); err != nil {
+		clh.Logger().WithError(err).Error("Failed to send VM")
+		return openAPIClientError(err)
+	}
+
+	if err = <-received; err != nil {
+		clh.Logger().WithError(err).Error("Failed to receive VM")
+		return err
+	}
+
+	// The source VMM has no VM left to run.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), clh.getClhStopSandboxTimeout()*time.Second)
+	defer shutdownCancel()
+	if _, err := src.ShutdownVMM(shutdownCtx); err != nil {
+		clh.Logger().WithError(err).Warn("Failed to shut the migration source down")
+	}
+	if err := utils.WaitLocalProcess(srcPID, uint(clh.getClhStopSandboxTimeout()), syscall.Signal(0), clh.Logger()); err != nil {
+		clh.Logger().WithError(err).Warn("Failed to wait for the migration source")
+	}
+	os.Remove(srcSocket)
+
+	clh.Logger().WithField("pid", clh.state.PID).Info("VM migrated")
+
+	return nil
+}
+
+// clhSnapshotVMState is saved along with a VM snapshot, for restoreVMSnapshot
+// to check that the snapshot was taken from the VM it replaces.
+type clhSnapshotVMState struct {
+	SandboxID  string
+	Hypervisor hv.HypervisorState
+	VCPUs      int32
+	MemorySize int64
+}
+
+// snapshotState returns the state saved along with a snapshot of the VM.
+func (clh *cloudHypervisor) snapshotState() (clhSnapshotVMState, error) {
+	info, err := clh.vmInfo()
+	if err != nil {
+		return clhSnapshotVMState{}, err
+	}
+
+	return clhSnapshotVMState{
+		SandboxID:  clh.id,
+		Hypervisor: clh.Save(),
+		VCPUs:      info.Config.GetCpus().BootVcpus,
+		MemorySize: info.Config.GetMemory().Size,
+	}, nil
+}
+
+// checkSnapshotState returns an error if a snapshot taken with the given
+// state cannot replace the VM. The sandbox keeps the containers and devices
+// of the current VM, so the snapshot must come from the same sandbox, and
+// from a VM with the same resources.
+func (clh *cloudHypervisor) checkSnapshotState(saved clhSnapshotVMState) error {
+	if saved.Hypervisor.Type != string(ClhHypervisor) {
+		return fmt.Errorf("cannot restore a %q snapshot with cloud-hypervisor", saved.Hypervisor.Type)
+	}
+
+	if saved.SandboxID != clh.id {
+		return fmt.Errorf("cannot restore a snapshot of sandbox %q in sandbox %q", saved.SandboxID, clh.id)
+	}
+
+	current, err := clh.snapshotState()
+	if err != nil {
+		return err
+	}
+
+	if saved.VCPUs != current.VCPUs || saved.MemorySize != current.MemorySize {
+		return fmt.Errorf("cannot restore a snapshot of a VM with %d vCPUs and %d bytes of memory in a VM with %d vCPUs and %d bytes of memory",
+			saved.VCPUs, saved.MemorySize, current.VCPUs, current.MemorySize)
+	}
+
+	return nil
+}
+
+// snapshotVM saves the VM to dir, along with the state restoreVMSnapshot
+// checks the snapshot against. The VM is paused while it is saved.
+func (clh *cloudHypervisor) snapshotVM(ctx context.Context, dir string) (err error) {
+	span, ctx := katatrace.Trace(ctx, clh.Logger(), "snapshotVM", clhTracingTags, map[string]string{"sandbox_id": clh.id})
+	defer span.End()
+
+	if err := clh.checkMigration(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+
+	// cloud-hypervisor writes the snapshot itself.
+	if rootless.IsRootless() {
+		if err := os.Chown(dir, int(clh.config.Uid), int(clh.config.Gid)); err != nil {
+			return err
+		}
+	}
+
+	if err := clh.PauseVM(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if resumeErr := clh.ResumeVM(ctx); resumeErr != nil && err == nil {
+			err = resumeErr
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, clhMigrationTimeout*time.Second)
+	defer cancel()
+
+	vmSnapshotConfig := *chclient.NewVmSnapshotConfig()
+	vmSnapshotConfig.SetDestinationUrl("file://" + dir)
+	if _, err := clh.client().VmSnapshotPut(ctx, vmSnapshotConfig); err != nil {
+		clh.Logger().WithError(err).Error("Failed to save VM snapshot")
+		return openAPIClientError(err)
+	}
+
+	state, err := clh.snapshotState()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, clhSnapshotState), data, 0600)
+}
+
+// restoreVMSnapshot replaces the VM with the one saved to dir by snapshotVM.
+// The VMM is restarted, as the VM it runs cannot be replaced in place.
+func (clh *cloudHypervisor) restoreVMSnapshot(ctx context.Context, dir string) error {
+	span, ctx := katatrace.Trace(ctx, clh.Logger(), "restoreVMSnapshot", clhTracingTags, map[string]string{"sandbox_id": clh.id})
+	defer span.End()
+
+	if err := clh.checkMigration(); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, clhSnapshotState))
+	if err != nil {
+		return err
+	}
+
+	var state clhSnapshotVMState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	if err := clh.checkSnapshotState(state); err != nil {
+		return err
+	}
+
+	// The restored VM binds the sockets of the current one.
+	stopCtx, cancel := context.WithTimeout(ctx, clh.getClhStopSandboxTimeout()*time.Second)
+	defer cancel()
+	if _, err := clh.client().ShutdownVMM(stopCtx); err != nil {
+		clh.Logger().WithError(err).Warn("Failed to shut the VMM down")
+	}
+	if err := utils.WaitLocalProcess(clh.state.PID, uint(clh.getClhStopSandboxTimeout()), syscall.Signal(0), clh.Logger()); err != nil {
+		return err
+	}
+
+	vsockSocket, err := clh.vsockSocketPath(clh.id)
+	if err != nil {
+		return err
+	}
+	for _, socket := range []string{clh.state.apiSocket, vsockSocket} {
+		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := clh.launchClh(); err != nil {
+		return fmt.Errorf("failed to launch cloud-hypervisor: %w", err)
+	}
+
+	ctx, cancel = context.WithTimeout(ctx, clhMigrationTimeout*time.Second)
+	defer cancel()
+
+	if _, err := clh.client().VmRestorePut(ctx, *chclient.NewRestoreConfig("file://" + dir)); err != nil {
+		clh.Logger().WithError(err).Error("Failed to restore VM snapshot")
+		return openAPIClientError(err)
+	}
+
+	// A restored VM is paused.
+	return clh.ResumeVM(ctx)
+}
+
 func (clh *cloudHypervisor) addVSock(cid int64, path string) {
 	clh.Logger().WithFields(log.Fields{
 		"path": path,
@@ -2349,3 +2930,101 @@ func pathExists(path string) bool {
 func (clh *cloudHypervisor) ResolveColdPlugVFIOGuestPciPaths(_ context.Context, _ []*config.VFIODev) error {
 	return nil
 }
+
+// clhEvent is an event written by cloud-hypervisor to its event monitor.
+type clhEvent struct {
+	Properties map[string]string `json:"properties"`
+	Source     string            `json:"source"`
+	Event      string            `json:"event"`
+}
+
+// watchEvents reads the events of the event monitor until cloud-hypervisor
+// exits.
+func (clh *cloudHypervisor) watchEvents(events io.ReadCloser) {
+	defer events.Close()
+
+	decoder := json.NewDecoder(events)
+	for {
+		var event clhEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err != io.EOF {
+				clh.Logger().WithError(err).Warn("failed to read cloud-hypervisor events")
+			}
+			return
+		}
+
+		clh.Logger().WithField("event", event).Debug("got cloud-hypervisor event")
+		if hypervisorEvent, ok := event.hypervisorEvent(); ok {
+			clh.events.publish(clh.Logger(), hypervisorEvent)
+		}
+		if event.Source == "guest" && event.Event == "panic" && clh.config.IfPVPanicEnabled() {
+			go clh.handleGuestPanic()
+		}
+	}
+}
+
+// clhEventTypes maps the cloud-hypervisor events, identified by their source
+// and name, to the lifecycle events of the VM.
+var clhEventTypes = map[string]HypervisorEventType{
+	"guest/panic":       HypervisorEventGuestPanic,
+	"vm/rebooted":       HypervisorEventReset,
+	"vm/shutdown":       HypervisorEventShutdown,
+	"vmm/shutdown":      HypervisorEventShutdown,
+	"vm/device-removed": HypervisorEventDeviceDeleted,
+}
+
+// hypervisorEvent converts the cloud-hypervisor event to a lifecycle event
+// of the VM. The reason of the event is the component it comes from.
+func (e clhEvent) hypervisorEvent() (HypervisorEvent, bool) {
+	eventType, ok := clhEventTypes[e.Source+"/"+e.Event]
+	if !ok {
+		return HypervisorEvent{}, false
+	}
+
+	return HypervisorEvent{
+		Type:    eventType,
+		Reason:  e.Source,
+		Details: e.Properties,
+	}, true
+}
+
+// HypervisorEvents returns the lifecycle events of the VM reported by the
+// event monitor.
+func (clh *cloudHypervisor) HypervisorEvents() <-chan HypervisorEvent {
+	return clh.events.channel()
+}
+
+// handleGuestPanic dumps the guest memory when the guest kernel panics.
+// Unlike QEMU, cloud-hypervisor does not stop the guest on panic, hence the
+// dump must start before the guest reboots, see the panic kernel parameter.
+func (clh *cloudHypervisor) handleGuestPanic() {
+	if _, err := dumpGuest(context.Background(), clh, clh.id, guestDumpReasonPanic); err != nil {
+		clh.Logger().WithError(err).Error("failed to dump guest memory")
+	}
+}
+
+// dumpGuestMemory writes the guest memory to coreFile. The VM is paused
+// while cloud-hypervisor, which must be built with the guest_debug feature,
+// writes the core file.
+func (clh *cloudHypervisor) dumpGuestMemory(ctx context.Context, coreFile string) (err error) {
+	span, ctx := katatrace.Trace(ctx, clh.Logger(), "dumpGuestMemory", clhTracingTags, map[string]string{"sandbox_id": clh.id})
+	defer span.End()
+
+	if err := clh.PauseVM(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if resumeErr := clh.ResumeVM(ctx); resumeErr != nil && err == nil {
+			err = resumeErr
+		}
+	}()
+
+	coredump := *chclient.NewVmCoredumpData()
+	coredump.SetDestinationUrl("file://" + coreFile)
+	if _, err := clh.client().VmCoredumpPut(ctx, coredump); err != nil {
+		clh.Logger().WithError(err).Error("Failed to dump guest memory")
+		return openAPIClientError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// cloud-hypervisor device counters, as returned by the vm.counters API
+	clhDeviceCounters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespaceHypervisor,
+		Name:      "device_counters",
+		Help:      "Cloud Hypervisor device counters.",
+	},
+		[]string{"device", "type", "item"},
+	)
+
+	registerClhMetricsOnce sync.Once
+)
+
+// registerClhMetrics registers the cloud-hypervisor metrics, only once as
+// the VM factory runs several VMs in the same process.
+func registerClhMetrics() {
+	registerClhMetricsOnce.Do(func() {
+		prometheus.MustRegister(clhDeviceCounters)
+	})
+}
+
+// clhDeviceType returns the type of a device from the identifier
+// cloud-hypervisor gives it, like "_disk0" or "_net1".
+func clhDeviceType(id string) string {
+	name := strings.TrimRight(strings.TrimPrefix(id, "_"), "0123456789")
+
+	switch name {
+	case "disk":
+		return "block"
+	case "fs":
+		return "virtio-fs"
+	default:
+		return name
+	}
+}
+
+// updateClhDeviceCounters sets the device counters metrics. Devices are
+// hot-plugged and unplugged, so the previous values are all discarded.
+func updateClhDeviceCounters(counters map[string]map[string]int64) {
+	clhDeviceCounters.Reset()
+
+	for device, items := range counters {
+		deviceType := clhDeviceType(device)
+		for item, value := range items {
+			clhDeviceCounters.WithLabelValues(device, deviceType, item).Set(float64(value))
+		}
+	}
+}
+
+// updateHypervisorMetrics polls the VM device counters.
+func (clh *cloudHypervisor) updateHypervisorMetrics(ctx context.Context) error {
+	registerClhMetrics()
+
+	ctx, cancel := context.WithTimeout(ctx, clh.getClhAPITimeout()*time.Second)
+	defer cancel()
+
+	counters, _, err := clh.client().VmCountersGet(ctx)
+	if err != nil {
+		return openAPIClientError(err)
+	}
+
+	updateClhDeviceCounters(counters)
+
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClhDeviceType(t *testing.T) {
+	assert := assert.New(t)
+
+	for id, deviceType := range map[string]string{
+		"_disk0":   "block",
+		"_disk12":  "block",
+		"_net1":    "net",
+		"_fs0":     "virtio-fs",
+		"_vsock2":  "vsock",
+		"_pmem0":   "pmem",
+		"_balloon": "balloon",
+	} {
+		assert.Equal(deviceType, clhDeviceType(id), id)
+	}
+}
+
+func clhDeviceCounter(t *testing.T, device, deviceType, item string) float64 {
+	var m dto.Metric
+	assert.NoError(t, clhDeviceCounters.WithLabelValues(device, deviceType, item).Write(&m))
+	return m.GetGauge().GetValue()
+}
+
+func TestClhUpdateHypervisorMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	mockClient := &clhClientMock{
+		counters: map[string]map[string]int64{
+			"_disk0": {
+				"read_bytes":  4096,
+				"write_bytes": 512,
+			},
+			"_net1": {
+				"rx_frames": 10,
+			},
+		},
+	}
+	clh := &cloudHypervisor{
+		APIClient: mockClient,
+	}
+
+	assert.NoError(clh.updateHypervisorMetrics(context.Background()))
+	assert.Equal(float64(4096), clhDeviceCounter(t, "_disk0", "block", "read_bytes"))
+	assert.Equal(float64(512), clhDeviceCounter(t, "_disk0", "block", "write_bytes"))
+	assert.Equal(float64(10), clhDeviceCounter(t, "_net1", "net", "rx_frames"))
+
+	// Unplugged devices are no longer reported
+	delete(mockClient.counters, "_net1")
+	assert.NoError(clh.updateHypervisorMetrics(context.Background()))
+	assert.True(clhDeviceCounters.DeleteLabelValues("_disk0", "block", "read_bytes"))
+	assert.False(clhDeviceCounters.DeleteLabelValues("_net1", "net", "rx_frames"))
+}
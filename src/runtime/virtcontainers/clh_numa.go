@@ -0,0 +1,267 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	chclient "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/cloud-hypervisor/client"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/cpuset"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Memory zones can only be resized when their memory is hotplugged
+	// with virtio-mem.
+	clhVirtioMemHotplugMethod = "VirtioMem"
+
+	// The memory hotplugged with virtio-mem is a multiple of 128 MiB.
+	clhVirtioMemAlignMB = 128
+)
+
+func clhMemoryZoneID(node int) string {
+	return fmt.Sprintf("numa%d", node)
+}
+
+// numaEnabled tells if the VM has a guest NUMA topology, each guest NUMA node
+// getting its own memory zone.
+func (clh *cloudHypervisor) numaEnabled() bool {
+	nodes := clh.config.GuestNUMANodes
+	if !numaPlacementActive(nodes) {
+		return false
+	}
+
+	if clh.config.DefaultMaxVCPUs < uint32(len(nodes)) {
+		clh.Logger().WithFields(log.Fields{
+			"vcpus":      clh.config.DefaultMaxVCPUs,
+			"numa-nodes": len(nodes),
+		}).Warn("DefaultMaxVCPUs < NUMA node count; skipping multi-NUMA topology")
+		return false
+	}
+
+	return true
+}
+
+// numaVCPUsPerNode returns the number of vCPUs of each guest NUMA node. The
+// vCPUs are distributed the way checkVCPUsPinning() pins the vCPU threads to
+// the host NUMA nodes, and the memory of each node follows its vCPUs.
+func (clh *cloudHypervisor) numaVCPUsPerNode() ([]uint32, error) {
+	return utils.DistributeVCPUsProportionally(clh.config.GuestNUMANodes, clh.config.DefaultMaxVCPUs)
+}
+
+// clhHostNUMANode returns the host NUMA node the memory of the guest NUMA node
+// is bound to. A memory zone can only be bound to a single host node.
+func clhHostNUMANode(node types.GuestNUMANode) (int32, bool) {
+	hostNodes, err := cpuset.Parse(node.HostNodes)
+	if err != nil || hostNodes.Size() != 1 {
+		return 0, false
+	}
+
+	return int32(hostNodes.ToSlice()[0]), true
+}
+
+// setNUMATopology configures a memory zone for each guest NUMA node, bound to
+// the host NUMA node of the guest node. The memory of the VM, and the memory
+// that can be hotplugged to it, are spread across the zones proportionally to
+// the vCPUs of each node.
+func (clh *cloudHypervisor) setNUMATopology() error {
+	nodes := clh.config.GuestNUMANodes
+
+	vcpusPerNode, err := clh.numaVCPUsPerNode()
+	if err != nil {
+		return fmt.Errorf("failed to distribute vCPUs across NUMA nodes: %w", err)
+	}
+
+	var memAlign uint64 = 1
+	if clh.config.HugePages {
+		memAlign = 2
+	}
+
+	bootMemPerNode, err := utils.DistributeMemoryProportionally(vcpusPerNode, uint64(clh.config.MemorySize), memAlign)
+	if err != nil {
+		return err
+	}
+
+	// Memory zones are resized with virtio-mem, in 128 MiB steps.
+	var hotplugMemPerNode []uint64
+	if !clh.config.ConfidentialGuest && clh.config.DefaultMaxMemorySize > uint64(clh.config.MemorySize) {
+		hotplugMem := (clh.config.DefaultMaxMemorySize - uint64(clh.config.MemorySize)) / clhVirtioMemAlignMB * clhVirtioMemAlignMB
+		if hotplugMem >= uint64(len(nodes))*clhVirtioMemAlignMB {
+			hotplugMemPerNode, err = utils.DistributeMemoryProportionally(vcpusPerNode, hotplugMem, clhVirtioMemAlignMB)
+			if err != nil {
+				return err
+			}
+		} else {
+			clh.Logger().WithField("hotplug-memory", hotplugMem).Warn("not enough memory to hotplug to each NUMA node, memory hotplug disabled")
+		}
+	}
+
+	shared := clh.memoryShared()
+	clh.vmconfig.Memory = chclient.NewMemoryConfig(0)
+	clh.vmconfig.Memory.SetShared(shared)
+	clh.vmconfig.Memory.SetHugepages(clh.config.HugePages)
+	if hotplugMemPerNode != nil {
+		clh.vmconfig.Memory.SetHotplugMethod(clhVirtioMemHotplugMethod)
+	}
+
+	distances := make(map[uint32][]chclient.NumaDistance)
+	for _, d := range utils.GetHostNUMADistances(nodes) {
+		distances[d.Src] = append(distances[d.Src], chclient.NumaDistance{
+			Destination: int32(d.Dst),
+			Distance:    int32(d.Val),
+		})
+	}
+
+	var zones []chclient.MemoryZoneConfig
+	var numaConfigs []chclient.NumaConfig
+	var cpuOffset int32
+	for i, node := range nodes {
+		zoneID := clhMemoryZoneID(i)
+
+		zone := chclient.NewMemoryZoneConfig(zoneID, int64((utils.MemUnit(bootMemPerNode[i]) * utils.MiB).ToBytes()))
+		zone.SetShared(shared)
+		zone.SetHugepages(clh.config.HugePages)
+		if hostNode, ok := clhHostNUMANode(node); ok {
+			zone.SetHostNumaNode(hostNode)
+		} else {
+			clh.Logger().WithFields(log.Fields{
+				"numa-node":  i,
+				"host-nodes": node.HostNodes,
+			}).Warn("guest NUMA node does not map to a single host NUMA node, its memory is not bound")
+		}
+		if hotplugMemPerNode != nil {
+			zone.SetHotplugSize(int64((utils.MemUnit(hotplugMemPerNode[i]) * utils.MiB).ToBytes()))
+		}
+		zones = append(zones, *zone)
+
+		cpus := make([]int32, vcpusPerNode[i])
+		for c := range cpus {
+			cpus[c] = cpuOffset + int32(c)
+		}
+		cpuOffset += int32(vcpusPerNode[i])
+
+		numaConfig := chclient.NewNumaConfig(int32(i))
+		numaConfig.SetCpus(cpus)
+		numaConfig.SetMemoryZones([]string{zoneID})
+		if d, ok := distances[uint32(i)]; ok {
+			numaConfig.SetDistances(d)
+		}
+		numaConfigs = append(numaConfigs, *numaConfig)
+	}
+
+	clh.vmconfig.Memory.SetZones(zones)
+	clh.vmconfig.SetNuma(numaConfigs)
+
+	return nil
+}
+
+// spreadZoneGrowth spreads growthMB of memory across memory zones, in alignMB
+// steps, proportionally to the weight of each zone and within the memory that
+// can still be hotplugged to each zone. It returns the memory added to each
+// zone.
+func spreadZoneGrowth(growthMB uint64, weights []uint32, capacityMB []uint64, alignMB uint64) []uint64 {
+	added := make([]uint64, len(weights))
+
+	for steps := growthMB / alignMB; steps > 0; steps-- {
+		// Give the next step to the zone that is the furthest behind
+		// its share of the memory.
+		best := -1
+		for i, w := range weights {
+			if w == 0 || added[i]+alignMB > capacityMB[i] {
+				continue
+			}
+			if best < 0 || (added[i]+alignMB)*uint64(weights[best]) < (added[best]+alignMB)*uint64(w) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		added[best] += alignMB
+	}
+
+	return added
+}
+
+// resizeMemoryZones grows the memory of the VM to reqMemMB by resizing its
+// memory zones.
+func (clh *cloudHypervisor) resizeMemoryZones(ctx context.Context, zones []chclient.MemoryZoneConfig, reqMemMB uint32, memoryBlockSizeMB uint32) (uint32, MemoryDevice, error) {
+	var currentMB, maxMB uint64
+	zoneMB := make([]uint64, len(zones))
+	capacityMB := make([]uint64, len(zones))
+	for i, zone := range zones {
+		size := uint64((utils.MemUnit(zone.Size) * utils.Byte).ToMiB())
+		hotplugged := uint64((utils.MemUnit(zone.GetHotpluggedSize()) * utils.Byte).ToMiB())
+		hotplugSize := uint64((utils.MemUnit(zone.GetHotplugSize()) * utils.Byte).ToMiB())
+
+		zoneMB[i] = size + hotplugged
+		capacityMB[i] = hotplugSize - hotplugged
+		currentMB += zoneMB[i]
+		maxMB += size + hotplugSize
+	}
+
+	if uint64(reqMemMB) > maxMB {
+		clh.Logger().WithFields(log.Fields{"request": reqMemMB, "max-memory": maxMB}).Warn("exceeding the memory of the memory zones (resizing to the max memory)")
+		reqMemMB = uint32(maxMB)
+	}
+
+	if uint64(reqMemMB) == currentMB {
+		clh.Logger().WithField("memory", reqMemMB).Debugf("VM already has requested memory")
+		return uint32(currentMB), MemoryDevice{}, nil
+	}
+
+	if uint64(reqMemMB) < currentMB {
+		clh.Logger().Warn("Remove memory is not supported, nothing to do")
+		return uint32(currentMB), MemoryDevice{}, nil
+	}
+
+	alignMB := uint64(clhVirtioMemAlignMB)
+	if uint64(memoryBlockSizeMB) > alignMB {
+		alignMB = uint64(memoryBlockSizeMB)
+	}
+	growthMB := (uint64(reqMemMB) - currentMB + alignMB - 1) / alignMB * alignMB
+
+	weights := make([]uint32, len(zones))
+	if vcpusPerNode, err := clh.numaVCPUsPerNode(); err == nil && len(vcpusPerNode) == len(zones) {
+		copy(weights, vcpusPerNode)
+	} else {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	added := spreadZoneGrowth(growthMB, weights, capacityMB, alignMB)
+
+	cl := clh.client()
+	ctx, cancelResize := context.WithTimeout(ctx, clh.getClhAPITimeout()*time.Second)
+	defer cancelResize()
+
+	var addedMB uint64
+	for i, zone := range zones {
+		if added[i] == 0 {
+			continue
+		}
+
+		desiredMB := zoneMB[i] + added[i]
+		resize := *chclient.NewVmResizeZone()
+		resize.SetId(zone.Id)
+		resize.SetDesiredRam(int64((utils.MemUnit(desiredMB) * utils.MiB).ToBytes()))
+		clh.Logger().WithFields(log.Fields{"zone": zone.Id, "current-memory": zoneMB[i], "new-memory": desiredMB}).Debug("updating memory zone")
+		if _, err := cl.VmResizeZonePut(ctx, resize); err != nil {
+			err = fmt.Errorf("Failed to resize memory zone %s from %d to %d MiB: %s", zone.Id, zoneMB[i], desiredMB, openAPIClientError(err))
+			return uint32(currentMB + addedMB), MemoryDevice{SizeMB: int(addedMB)}, err
+		}
+		addedMB += added[i]
+	}
+
+	return uint32(currentMB + addedMB), MemoryDevice{SizeMB: int(addedMB)}, nil
+}
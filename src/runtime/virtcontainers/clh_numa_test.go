@@ -0,0 +1,171 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"testing"
+
+	chclient "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/cloud-hypervisor/client"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClhNUMAConfig(t *testing.T) HypervisorConfig {
+	clhConfig, err := newClhConfig()
+	assert.NoError(t, err)
+
+	clhConfig.DefaultMaxVCPUs = 8
+	clhConfig.MemorySize = 2048
+	clhConfig.DefaultMaxMemorySize = 4096
+	clhConfig.GuestNUMANodes = []types.GuestNUMANode{
+		{HostNodes: "0", HostCPUs: "0-5"},
+		{HostNodes: "1-2", HostCPUs: "6-7"},
+	}
+
+	return clhConfig
+}
+
+func TestCloudHypervisorNUMAEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := &cloudHypervisor{config: newClhNUMAConfig(t)}
+	assert.True(clh.numaEnabled())
+
+	clh.config.DefaultMaxVCPUs = 1
+	assert.False(clh.numaEnabled())
+
+	clh.config.GuestNUMANodes = nil
+	assert.False(clh.numaEnabled())
+}
+
+func TestCloudHypervisorSetNUMATopology(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := &cloudHypervisor{config: newClhNUMAConfig(t)}
+	clh.vmconfig = *chclient.NewVmConfig(*chclient.NewPayloadConfig())
+
+	err := clh.setNUMATopology()
+	assert.NoError(err)
+
+	memory := clh.vmconfig.Memory
+	assert.Equal(int64(0), memory.Size)
+	assert.True(memory.GetShared())
+	assert.Equal(clhVirtioMemHotplugMethod, memory.GetHotplugMethod())
+
+	zones := memory.GetZones()
+	assert.Len(zones, 2)
+
+	assert.Equal("numa0", zones[0].Id)
+	assert.Equal(int64(1536*utils.MiB), zones[0].Size)
+	assert.Equal(int64(1536*utils.MiB), zones[0].GetHotplugSize())
+	assert.Equal(int32(0), zones[0].GetHostNumaNode())
+
+	// A memory zone can only be bound to a single host node
+	assert.Equal("numa1", zones[1].Id)
+	assert.Equal(int64(512*utils.MiB), zones[1].Size)
+	assert.Equal(int64(512*utils.MiB), zones[1].GetHotplugSize())
+	assert.False(zones[1].HasHostNumaNode())
+
+	numa := clh.vmconfig.GetNuma()
+	assert.Len(numa, 2)
+	assert.Equal(int32(0), numa[0].GuestNumaId)
+	assert.Equal([]int32{0, 1, 2, 3, 4, 5}, numa[0].GetCpus())
+	assert.Equal([]string{"numa0"}, numa[0].GetMemoryZones())
+	assert.Equal(int32(1), numa[1].GuestNumaId)
+	assert.Equal([]int32{6, 7}, numa[1].GetCpus())
+	assert.Equal([]string{"numa1"}, numa[1].GetMemoryZones())
+
+	// Not enough memory to hotplug to each node
+	clh.config.DefaultMaxMemorySize = 2048 + 128
+	err = clh.setNUMATopology()
+	assert.NoError(err)
+	assert.NotEqual(clhVirtioMemHotplugMethod, clh.vmconfig.Memory.GetHotplugMethod())
+	assert.False(clh.vmconfig.Memory.GetZones()[0].HasHotplugSize())
+
+	// Confidential guests can't hotplug memory
+	clh.config.DefaultMaxMemorySize = 4096
+	clh.config.ConfidentialGuest = true
+	err = clh.setNUMATopology()
+	assert.NoError(err)
+	assert.NotEqual(clhVirtioMemHotplugMethod, clh.vmconfig.Memory.GetHotplugMethod())
+}
+
+func TestSpreadZoneGrowth(t *testing.T) {
+	assert := assert.New(t)
+
+	// The growth follows the weights of the zones
+	assert.Equal([]uint64{384, 128}, spreadZoneGrowth(512, []uint32{6, 2}, []uint64{1536, 512}, 128))
+	assert.Equal([]uint64{256, 256}, spreadZoneGrowth(512, []uint32{1, 1}, []uint64{1536, 512}, 128))
+
+	// Ties go to the first zone
+	assert.Equal([]uint64{128, 0}, spreadZoneGrowth(128, []uint32{1, 1}, []uint64{512, 512}, 128))
+
+	// A full zone leaves the growth to the others
+	assert.Equal([]uint64{128, 384}, spreadZoneGrowth(512, []uint32{1, 1}, []uint64{128, 512}, 128))
+
+	// The growth can't exceed the zones capacity
+	assert.Equal([]uint64{128, 128}, spreadZoneGrowth(1024, []uint32{1, 1}, []uint64{128, 128}, 128))
+}
+
+func TestCloudHypervisorResizeMemoryZones(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := &cloudHypervisor{config: newClhNUMAConfig(t)}
+	clh.vmconfig = *chclient.NewVmConfig(*chclient.NewPayloadConfig())
+	assert.NoError(clh.setNUMATopology())
+
+	newMockClient := func() *clhClientMock {
+		mockClient := &clhClientMock{}
+		mockClient.vmInfo.Config = clh.vmconfig
+		clh.APIClient = mockClient
+		return mockClient
+	}
+
+	// The growth is spread across the zones
+	mockClient := newMockClient()
+	newMem, memDev, err := clh.ResizeMemory(context.Background(), 2048+500, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(2048+512), newMem)
+	assert.Equal(MemoryDevice{SizeMB: 512}, memDev)
+	assert.Len(mockClient.resizeZones, 2)
+	assert.Equal("numa0", mockClient.resizeZones[0].GetId())
+	assert.Equal(int64((1536+384)*utils.MiB), mockClient.resizeZones[0].GetDesiredRam())
+	assert.Equal("numa1", mockClient.resizeZones[1].GetId())
+	assert.Equal(int64((512+128)*utils.MiB), mockClient.resizeZones[1].GetDesiredRam())
+
+	// The request is capped to the memory of the zones
+	mockClient = newMockClient()
+	newMem, memDev, err = clh.ResizeMemory(context.Background(), 8192, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(4096), newMem)
+	assert.Equal(MemoryDevice{SizeMB: 2048}, memDev)
+	assert.Len(mockClient.resizeZones, 2)
+
+	// The memory hotplugged to the zones is accounted for
+	zones := clh.vmconfig.Memory.GetZones()
+	zones[0].SetHotpluggedSize(int64(384 * utils.MiB))
+	zones[1].SetHotpluggedSize(int64(128 * utils.MiB))
+	clh.vmconfig.Memory.SetZones(zones)
+
+	mockClient = newMockClient()
+	newMem, memDev, err = clh.ResizeMemory(context.Background(), 2048+512, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(2048+512), newMem)
+	assert.Equal(MemoryDevice{}, memDev)
+	assert.Empty(mockClient.resizeZones)
+
+	// Memory can't be removed
+	mockClient = newMockClient()
+	newMem, memDev, err = clh.ResizeMemory(context.Background(), 1024, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(2048+512), newMem)
+	assert.Equal(MemoryDevice{}, memDev)
+	assert.Empty(mockClient.resizeZones)
+}
@@ -9,13 +9,19 @@ package virtcontainers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist"
@@ -77,6 +83,14 @@ type clhClientMock struct {
 	vmInfo          chclient.VmInfo
 	restoreRequest  *chclient.RestoreConfig
 	snapshotRequest *chclient.VmSnapshotConfig
+	sendRequest     *chclient.SendMigrationData
+	receiveRequest  *chclient.ReceiveMigrationData
+	counters        map[string]map[string]int64
+	coredumpRequest *chclient.VmCoredumpData
+	vdpaRequest     *chclient.VdpaConfig
+	pmemRequest     *chclient.PmemConfig
+	resizeDisk      *chclient.VmResizeDisk
+	resizeZones     []chclient.VmResizeZone
 }
 
 func (c *clhClientMock) VmmPingGet(ctx context.Context) (chclient.VmmPingResponse, *http.Response, error) {
@@ -107,6 +121,12 @@ func (c *clhClientMock) VmResizePut(ctx context.Context, vmResize chclient.VmRes
 	return nil, nil
 }
 
+//nolint:golint
+func (c *clhClientMock) VmResizeZonePut(ctx context.Context, vmResizeZone chclient.VmResizeZone) (*http.Response, error) {
+	c.resizeZones = append(c.resizeZones, vmResizeZone)
+	return nil, nil
+}
+
 //nolint:golint
 func (c *clhClientMock) VmAddDevicePut(ctx context.Context, deviceConfig chclient.DeviceConfig) (chclient.PciDeviceInfo, *http.Response, error) {
 	return chclient.PciDeviceInfo{}, nil, nil
@@ -117,6 +137,24 @@ func (c *clhClientMock) VmAddDiskPut(ctx context.Context, diskConfig chclient.Di
 	return chclient.PciDeviceInfo{Bdf: "0000:00:0a.0"}, nil, nil
 }
 
+//nolint:golint
+func (c *clhClientMock) VmAddVdpaPut(ctx context.Context, vdpaConfig chclient.VdpaConfig) (chclient.PciDeviceInfo, *http.Response, error) {
+	c.vdpaRequest = &vdpaConfig
+	return chclient.PciDeviceInfo{Id: vdpaConfig.GetId(), Bdf: "0000:00:0b.0"}, nil, nil
+}
+
+//nolint:golint
+func (c *clhClientMock) VmAddPmemPut(ctx context.Context, pmemConfig chclient.PmemConfig) (chclient.PciDeviceInfo, *http.Response, error) {
+	c.pmemRequest = &pmemConfig
+	return chclient.PciDeviceInfo{Id: pmemConfig.GetId(), Bdf: "0000:00:0c.0"}, nil, nil
+}
+
+//nolint:golint
+func (c *clhClientMock) VmResizeDiskPut(ctx context.Context, vmResizeDisk chclient.VmResizeDisk) (*http.Response, error) {
+	c.resizeDisk = &vmResizeDisk
+	return nil, nil
+}
+
 //nolint:golint
 func (c *clhClientMock) VmPausePut(ctx context.Context) (*http.Response, error) {
 	c.vmInfo.State = clhStatePaused
@@ -146,6 +184,34 @@ func (c *clhClientMock) ResumeVM(ctx context.Context) (*http.Response, error) {
 	return nil, nil
 }
 
+//nolint:golint
+func (c *clhClientMock) VmSendMigrationPut(ctx context.Context, sendMigrationData chclient.SendMigrationData) (*http.Response, error) {
+	c.sendRequest = &sendMigrationData
+	return nil, nil
+}
+
+//nolint:golint
+func (c *clhClientMock) VmReceiveMigrationPut(ctx context.Context, receiveMigrationData chclient.ReceiveMigrationData) (*http.Response, error) {
+	c.receiveRequest = &receiveMigrationData
+	// The destination listens for the migration on the receiver URL.
+	if err := os.WriteFile(strings.TrimPrefix(receiveMigrationData.ReceiverUrl, "unix:"), nil, 0600); err != nil {
+		return nil, err
+	}
+	c.vmInfo.State = clhStateRunning
+	return nil, nil
+}
+
+//nolint:golint
+func (c *clhClientMock) VmCountersGet(ctx context.Context) (map[string]map[string]int64, *http.Response, error) {
+	return c.counters, nil, nil
+}
+
+//nolint:golint
+func (c *clhClientMock) VmCoredumpPut(ctx context.Context, vmCoredumpData chclient.VmCoredumpData) (*http.Response, error) {
+	c.coredumpRequest = &vmCoredumpData
+	return nil, nil
+}
+
 func TestCloudHypervisorAddVSock(t *testing.T) {
 	assert := assert.New(t)
 	clh := cloudHypervisor{}
@@ -469,6 +535,38 @@ func TestCloudHypervisorCleanupVM(t *testing.T) {
 	assert.True(os.IsNotExist(err), "persist.GetDriver() unexpected error")
 }
 
+func TestClhConsoleKernelParams(t *testing.T) {
+	assert := assert.New(t)
+
+	hasParams := func(params, expected []Param) bool {
+		for _, p := range expected {
+			if !slices.Contains(params, p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Quiet kernel without console
+	params, err := getNonUserDefinedKernelParams("", true, false, false, false, false, false, "")
+	assert.NoError(err)
+	assert.Contains(params, Param{"quiet", ""})
+	assert.False(hasParams(params, clhConsoleKernelParams(false)))
+
+	// Quiet kernel captured to the sandbox console log
+	params, err = getNonUserDefinedKernelParams("", true, false, false, true, false, false, "")
+	assert.NoError(err)
+	assert.Contains(params, Param{"quiet", ""})
+	assert.True(hasParams(params, clhConsoleKernelParams(false)))
+	assert.False(hasParams(params, clhDebugKernelParamsCommon))
+
+	params, err = getNonUserDefinedKernelParams("", true, false, true, true, true, false, "")
+	assert.NoError(err)
+	assert.NotContains(params, Param{"quiet", ""})
+	assert.True(hasParams(params, clhDebugConfidentialGuestKernelParams))
+	assert.True(hasParams(params, clhDebugKernelParamsCommon))
+}
+
 func TestClhCreateVM(t *testing.T) {
 	assert := assert.New(t)
 
@@ -618,6 +716,187 @@ func TestClhSaveVM(t *testing.T) {
 	}
 }
 
+// exitedPid returns the PID of a process that already exited, to stand for
+// a VMM that is gone.
+func exitedPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	assert.NoError(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestClhCheckMigration(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := &cloudHypervisor{
+		config: HypervisorConfig{
+			SharedFS: config.NoSharedFS,
+		},
+	}
+	// Migrations must be enabled explicitly
+	assert.Error(clh.checkMigration())
+
+	clh.config.EnableVMMigration = true
+	clh.config.SharedFS = config.VirtioFS
+	assert.Error(clh.checkMigration())
+
+	clh.config.SharedFS = config.NoSharedFS
+	assert.NoError(clh.checkMigration())
+	assert.False(clh.memoryShared())
+
+	clh.config.HugePages = true
+	assert.True(clh.memoryShared())
+
+	clh.config.ConfidentialGuest = true
+	assert.Error(clh.checkMigration())
+
+	clh.config.ConfidentialGuest = false
+	clh.netDevices = &[]chclient.NetConfig{*chclient.NewNetConfig()}
+	assert.Error(clh.checkMigration())
+}
+
+func TestClhMigrationAPISocketPath(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := &cloudHypervisor{
+		id: "sandbox",
+		config: HypervisorConfig{
+			VMStorePath: "/run/vc/vm",
+		},
+	}
+
+	socket, err := clh.migrationAPISocketPath("/run/vc/vm/sandbox/" + clhAPISocket)
+	assert.NoError(err)
+	assert.Equal("/run/vc/vm/sandbox/"+clhMigrationAPISocket, socket)
+
+	// Migrating again goes back to the first socket
+	socket, err = clh.migrationAPISocketPath(socket)
+	assert.NoError(err)
+	assert.Equal("/run/vc/vm/sandbox/"+clhAPISocket, socket)
+}
+
+func TestClhMigrateVM(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+	clhConfig.SharedFS = config.NoSharedFS
+	clhConfig.EnableVMMigration = true
+	clhConfig.VMStorePath = t.TempDir()
+
+	dstClient := &clhClientMock{}
+	clh := &cloudHypervisor{
+		id:        "migrate",
+		config:    clhConfig,
+		APIClient: dstClient,
+	}
+	vmPath := filepath.Join(clhConfig.VMStorePath, clh.id)
+	assert.NoError(os.MkdirAll(vmPath, DirMode))
+
+	srcClient := &clhClientMock{}
+	savedClhClientForSocket := clhClientForSocket
+	clhClientForSocket = func(socketPath string) clhClient {
+		assert.Equal(filepath.Join(vmPath, clhAPISocket), socketPath)
+		return srcClient
+	}
+	defer func() {
+		clhClientForSocket = savedClhClientForSocket
+	}()
+
+	clh.state.apiSocket = filepath.Join(vmPath, clhAPISocket)
+	clh.state.PID = exitedPid(t)
+
+	vsockSocket := filepath.Join(vmPath, clhSocket)
+	assert.NoError(os.WriteFile(vsockSocket, nil, 0600))
+
+	assert.NoError(clh.migrateVM(context.Background()))
+
+	receiverURL := "unix:" + filepath.Join(vmPath, clhMigrationSocket)
+	if assert.NotNil(dstClient.receiveRequest) {
+		assert.Equal(receiverURL, dstClient.receiveRequest.ReceiverUrl)
+	}
+	if assert.NotNil(srcClient.sendRequest) {
+		assert.Equal(receiverURL, srcClient.sendRequest.DestinationUrl)
+		assert.False(srcClient.sendRequest.GetLocal())
+	}
+
+	// The hypervisor state follows the destination VMM
+	state := clh.Save()
+	assert.Equal(os.Getpid(), state.Pid)
+	assert.Equal(filepath.Join(vmPath, clhMigrationAPISocket), state.APISocket)
+
+	assert.NoFileExists(filepath.Join(vmPath, clhMigrationSocket))
+	assert.NoFileExists(vsockSocket + ".migrating")
+}
+
+func TestClhSnapshotAndRestoreVM(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+	clhConfig.SharedFS = config.NoSharedFS
+	clhConfig.EnableVMMigration = true
+	clhConfig.VMStorePath = t.TempDir()
+
+	mockClient := &clhClientMock{}
+	mockClient.vmInfo.Config.Cpus = chclient.NewCpusConfig(2, 4)
+	mockClient.vmInfo.Config.Memory = chclient.NewMemoryConfig(2 << 30)
+	clh := &cloudHypervisor{
+		id:        "snapshot",
+		config:    clhConfig,
+		APIClient: mockClient,
+	}
+	clh.state.apiSocket = filepath.Join(clhConfig.VMStorePath, clh.id, clhAPISocket)
+	clh.state.PID = exitedPid(t)
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	assert.NoError(clh.snapshotVM(context.Background(), dir))
+
+	if assert.NotNil(mockClient.snapshotRequest) {
+		assert.Equal("file://"+dir, mockClient.snapshotRequest.GetDestinationUrl())
+	}
+	assert.Equal(clhStateRunning, mockClient.vmInfo.State)
+
+	data, err := os.ReadFile(filepath.Join(dir, clhSnapshotState))
+	assert.NoError(err)
+	var state clhSnapshotVMState
+	assert.NoError(json.Unmarshal(data, &state))
+	assert.Equal(clhSnapshotVMState{
+		SandboxID:  clh.id,
+		Hypervisor: clh.Save(),
+		VCPUs:      2,
+		MemorySize: 2 << 30,
+	}, state)
+
+	assert.NoError(clh.restoreVMSnapshot(context.Background(), dir))
+	if assert.NotNil(mockClient.restoreRequest) {
+		assert.Equal("file://"+dir, mockClient.restoreRequest.GetSourceUrl())
+	}
+	assert.Equal(clhStateRunning, mockClient.vmInfo.State)
+	assert.Equal(os.Getpid(), clh.state.PID)
+
+	writeState := func(state clhSnapshotVMState) {
+		data, err := json.Marshal(state)
+		assert.NoError(err)
+		assert.NoError(os.WriteFile(filepath.Join(dir, clhSnapshotState), data, 0600))
+	}
+
+	// Snapshots of other hypervisors, other sandboxes or VMs with other
+	// resources cannot be restored
+	for _, modify := range []func(*clhSnapshotVMState){
+		func(s *clhSnapshotVMState) { s.Hypervisor.Type = string(QemuHypervisor) },
+		func(s *clhSnapshotVMState) { s.SandboxID = "other" },
+		func(s *clhSnapshotVMState) { s.VCPUs = 4 },
+		func(s *clhSnapshotVMState) { s.MemorySize = 4 << 30 },
+	} {
+		mockClient.restoreRequest = nil
+		invalid := state
+		modify(&invalid)
+		writeState(invalid)
+		assert.Error(clh.restoreVMSnapshot(context.Background(), dir))
+		assert.Nil(mockClient.restoreRequest)
+	}
+}
+
 func TestCloudHypervisorStartSandbox(t *testing.T) {
 	assert := assert.New(t)
 	clhConfig, err := newClhConfig()
@@ -755,14 +1034,74 @@ func TestCloudHypervisorHotplugAddBlockDevice(t *testing.T) {
 	err = clh.hotplugAddBlockDevice(&config.BlockDrive{Pmem: false})
 	assert.NoError(err, "Hotplug disk block device expected no error")
 
-	err = clh.hotplugAddBlockDevice(&config.BlockDrive{Pmem: true})
-	assert.Error(err, "Hotplug pmem block device expected error")
-
 	clh.config.BlockDeviceDriver = config.VirtioSCSI
 	err = clh.hotplugAddBlockDevice(&config.BlockDrive{Pmem: false})
 	assert.Error(err, "Hotplug block device not using 'virtio-blk' expected error")
 }
 
+func TestCloudHypervisorHotplugAddPmemDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+
+	mockClient := &clhClientMock{}
+	clh := &cloudHypervisor{}
+	clh.config = clhConfig
+	clh.APIClient = mockClient
+	clh.devicesIds = make(map[string]string)
+
+	for i := 0; i < 2; i++ {
+		drive := &config.BlockDrive{
+			File:     fmt.Sprintf("/run/image%d.img", i),
+			Index:    i,
+			Pmem:     true,
+			ReadOnly: true,
+		}
+
+		// pmem devices do not depend on the block device driver
+		_, err = clh.HotplugAddDevice(context.Background(), drive, BlockDev)
+		assert.NoError(err)
+		assert.Equal(strconv.Itoa(i), drive.NvdimmID)
+
+		assert.NotNil(mockClient.pmemRequest)
+		assert.Equal(drive.File, mockClient.pmemRequest.File)
+		assert.Equal(clhDriveIndexToID(i), mockClient.pmemRequest.GetId())
+		assert.True(mockClient.pmemRequest.GetDiscardWrites())
+		assert.Equal(clhDriveIndexToID(i), clh.devicesIds[clhDriveIndexToID(i)])
+	}
+}
+
+func TestCloudHypervisorResizeDisk(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+
+	mockClient := &clhClientMock{}
+	clh := &cloudHypervisor{}
+	clh.config = clhConfig
+	clh.APIClient = mockClient
+	clh.devicesIds = make(map[string]string)
+
+	drive := &config.BlockDrive{File: "/dev/loop0", Index: 1}
+
+	// The drive was not plugged
+	err = clh.resizeDisk(context.Background(), drive, 1<<30)
+	assert.Error(err)
+	assert.Nil(mockClient.resizeDisk)
+
+	clh.devicesIds[clhDriveIndexToID(1)] = "_disk1"
+	err = clh.resizeDisk(context.Background(), drive, 1<<30)
+	assert.NoError(err)
+	assert.NotNil(mockClient.resizeDisk)
+	assert.Equal("_disk1", mockClient.resizeDisk.GetId())
+	assert.Equal(int64(1<<30), mockClient.resizeDisk.GetDesiredSize())
+
+	err = clh.resizeDisk(context.Background(), &config.BlockDrive{Index: 1, Pmem: true}, 1<<30)
+	assert.Error(err)
+}
+
 func TestCloudHypervisorHotplugRemoveDevice(t *testing.T) {
 	assert := assert.New(t)
 
@@ -872,6 +1211,50 @@ func TestCloudHypervisorAddDeviceVFIO(t *testing.T) {
 	assert.Len(*clh.vmconfig.Devices, 1)
 }
 
+func TestCloudHypervisorVhostVDPADevice(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+
+	mockClient := &clhClientMock{}
+	clh := &cloudHypervisor{}
+	clh.config = clhConfig
+	clh.APIClient = mockClient
+	clh.devicesIds = make(map[string]string)
+	clh.vmconfig = *chclient.NewVmConfig(*chclient.NewPayloadConfig())
+
+	// Cold plug
+	err = clh.AddDevice(context.Background(), config.VhostVDPADeviceAttrs{
+		DevID:        "vdpa0",
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		NumQueues:    4,
+	}, VhostVDPADev)
+	assert.NoError(err)
+	assert.NotNil(clh.vmconfig.Vdpa)
+	assert.Len(*clh.vmconfig.Vdpa, 1)
+	assert.Equal("/dev/vhost-vdpa-0", (*clh.vmconfig.Vdpa)[0].Path)
+	assert.Equal(int32(4), (*clh.vmconfig.Vdpa)[0].NumQueues)
+	assert.Equal("vdpa0", clh.devicesIds["vdpa0"])
+
+	// Hot plug
+	device := &config.VhostVDPADeviceAttrs{
+		DevID:        "vdpa1",
+		VhostDevPath: "/dev/vhost-vdpa-1",
+	}
+	_, err = clh.HotplugAddDevice(context.Background(), device, VhostVDPADev)
+	assert.NoError(err)
+	assert.NotNil(mockClient.vdpaRequest)
+	assert.Equal("/dev/vhost-vdpa-1", mockClient.vdpaRequest.Path)
+	assert.Equal(int32(1), mockClient.vdpaRequest.NumQueues)
+	assert.Equal("0b", device.PCIPath.String())
+	assert.Equal("vdpa1", clh.devicesIds["vdpa1"])
+
+	_, err = clh.HotplugRemoveDevice(context.Background(), device, VhostVDPADev)
+	assert.NoError(err)
+	assert.NotContains(clh.devicesIds, "vdpa1")
+}
+
 func TestClhGenerateSocket(t *testing.T) {
 	assert := assert.New(t)
 
@@ -947,4 +1330,85 @@ func TestClhCapabilities(t *testing.T) {
 
 	assert.True(c.IsNetworkDeviceHotplugSupported())
 	assert.True(c.IsBlockDeviceHotplugSupported())
+
+	// memory cannot be hot unplugged, nor taken back with the balloon
+	assert.False(c.IsMemoryShrinkSupported())
+}
+
+func TestClhDumpGuestMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	mock := &clhClientMock{}
+	mock.vmInfo.State = clhStateRunning
+	clh := &cloudHypervisor{APIClient: mock}
+
+	coreFile := filepath.Join(t.TempDir(), guestDumpCoreFile)
+	assert.NoError(clh.dumpGuestMemory(context.Background(), coreFile))
+	assert.NotNil(mock.coredumpRequest)
+	assert.Equal("file://"+coreFile, mock.coredumpRequest.GetDestinationUrl())
+	assert.Equal(clhStateRunning, mock.vmInfo.State)
+}
+
+func TestClhWatchEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	dumpPath := t.TempDir()
+	clh := &cloudHypervisor{
+		id:        testSandboxID,
+		APIClient: &clhClientMock{},
+		config: HypervisorConfig{
+			HypervisorPath:      "/bin/echo",
+			RunStorePath:        t.TempDir(),
+			GuestMemoryDumpPath: dumpPath,
+		},
+	}
+
+	// cloud-hypervisor pretty prints the events.
+	events := `{
+  "timestamp": {"secs": 0, "nanos": 1},
+  "source": "vm",
+  "event": "booted",
+  "properties": null
+}
+
+{
+  "timestamp": {"secs": 1, "nanos": 1},
+  "source": "guest",
+  "event": "panic",
+  "properties": null
+}
+
+`
+	clh.watchEvents(io.NopCloser(strings.NewReader(events)))
+
+	// Only the guest panic is a lifecycle event of the VM
+	hypervisorEvents := clh.HypervisorEvents()
+	assert.Len(hypervisorEvents, 1)
+	event := <-hypervisorEvents
+	assert.Equal(HypervisorEventGuestPanic, event.Type)
+	assert.Equal("guest", event.Reason)
+	assert.False(event.Timestamp.IsZero())
+
+	assert.Eventually(func() bool {
+		files, _ := filepath.Glob(filepath.Join(dumpPath, testSandboxID, "*", guestDumpMetadataFile))
+		return len(files) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClhEventHypervisorEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	event, ok := clhEvent{Source: "vm", Event: "device-removed", Properties: map[string]string{"id": "_disk1"}}.hypervisorEvent()
+	assert.True(ok)
+	assert.Equal(HypervisorEventDeviceDeleted, event.Type)
+	assert.Equal("vm", event.Reason)
+	assert.Equal("_disk1", event.Details["id"])
+
+	event, ok = clhEvent{Source: "vmm", Event: "shutdown"}.hypervisorEvent()
+	assert.True(ok)
+	assert.Equal(HypervisorEventShutdown, event.Type)
+	assert.Equal("vmm", event.Reason)
+
+	_, ok = clhEvent{Source: "vm", Event: "booted"}.hypervisorEvent()
+	assert.False(ok)
 }
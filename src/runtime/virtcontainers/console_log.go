@@ -0,0 +1,246 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+)
+
+// Default values of the sandbox console log settings.
+const (
+	DefaultConsoleLogMaxSize  = 1
+	DefaultConsoleLogMaxFiles = 2
+)
+
+const (
+	// consoleLogFile is the name of the current console log file. The
+	// rotated files are suffixed with their generation, .1 being the
+	// most recent one.
+	consoleLogFile = "console.log"
+
+	// vmmLogFile is the name of the file the hypervisor errors are
+	// written to, along the console log of the sandbox.
+	vmmLogFile = "vmm.log"
+
+	// Number of console lines logged when the VM fails to start.
+	consoleLogTailLines = 50
+)
+
+// ConsoleLogEntry is a line of the sandbox console log. The console log is
+// made of JSON encoded entries, one per line.
+type ConsoleLogEntry struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// consoleLog writes the guest console lines to size capped files, rotated
+// when the current file is full.
+type consoleLog struct {
+	file *os.File
+
+	dir      string
+	maxSize  int64
+	size     int64
+	maxFiles int
+
+	mu sync.Mutex
+}
+
+// consoleLogDir returns the directory of the console log of a sandbox.
+func consoleLogDir(conf *HypervisorConfig, sandboxID string) string {
+	return filepath.Join(conf.ConsoleLogPath, sandboxID)
+}
+
+// openVMMLog opens the file the errors of the hypervisor of a sandbox are
+// appended to, so that they do not mix with the guest console.
+func openVMMLog(conf *HypervisorConfig, sandboxID string) (*os.File, error) {
+	dir := consoleLogDir(conf, sandboxID)
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(filepath.Join(dir, vmmLogFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+}
+
+func consoleLogPath(dir string, generation int) string {
+	path := filepath.Join(dir, consoleLogFile)
+	if generation == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, generation)
+}
+
+// newConsoleLog opens the console log of dir, creating it if needed. A zero
+// maxSizeMB or maxFiles means the default value.
+func newConsoleLog(dir string, maxSizeMB, maxFiles uint32) (*consoleLog, error) {
+	if maxSizeMB == 0 {
+		maxSizeMB = DefaultConsoleLogMaxSize
+	}
+
+	if maxFiles == 0 {
+		maxFiles = DefaultConsoleLogMaxFiles
+	}
+
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return nil, err
+	}
+
+	l := &consoleLog{
+		dir:      dir,
+		maxSize:  int64(maxSizeMB) << utils.MibToBytesShift,
+		maxFiles: int(maxFiles),
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *consoleLog) open() error {
+	file, err := os.OpenFile(consoleLogPath(l.dir, 0), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file = file
+	l.size = info.Size()
+
+	return nil
+}
+
+// rotate shifts the generation of the console log files, dropping the
+// oldest one, and starts a new current file.
+func (l *consoleLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	if err := os.Remove(consoleLogPath(l.dir, l.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	for generation := l.maxFiles - 1; generation >= 0; generation-- {
+		err := os.Rename(consoleLogPath(l.dir, generation), consoleLogPath(l.dir, generation+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return l.open()
+}
+
+// write appends a line to the console log, rotating it first if the line
+// does not fit in the current file.
+func (l *consoleLog) write(entry ConsoleLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("console log is closed")
+	}
+
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+
+	return err
+}
+
+func (l *consoleLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
+
+// readConsoleLog returns the content of the console log of dir, from the
+// oldest rotated file to the current one.
+func readConsoleLog(dir string) ([]byte, error) {
+	var generations []int
+	for generation := 0; ; generation++ {
+		if _, err := os.Stat(consoleLogPath(dir, generation)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			return nil, err
+		}
+		generations = append(generations, generation)
+	}
+
+	if len(generations) == 0 {
+		return nil, fmt.Errorf("no console log in %s", dir)
+	}
+
+	var buf bytes.Buffer
+	for i := len(generations) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(consoleLogPath(dir, generations[i]))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ParseConsoleLog parses the entries of a console log.
+func ParseConsoleLog(data []byte) ([]ConsoleLogEntry, error) {
+	var entries []ConsoleLogEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry ConsoleLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleLogRotate(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := filepath.Join(t.TempDir(), "console")
+	l, err := newConsoleLog(dir, 0, 2)
+	assert.NoError(err)
+	assert.Equal(int64(DefaultConsoleLogMaxSize)<<20, l.maxSize)
+
+	// Rotate every other line
+	l.maxSize = 150
+	line := strings.Repeat("x", 20)
+	for i := 0; i < 8; i++ {
+		assert.NoError(l.write(ConsoleLogEntry{Time: time.Unix(int64(i), 0).UTC(), Line: line}))
+	}
+	assert.NoError(l.close())
+	assert.Error(l.write(ConsoleLogEntry{Line: line}))
+
+	for generation := 0; generation <= 2; generation++ {
+		info, err := os.Stat(consoleLogPath(dir, generation))
+		assert.NoError(err)
+		assert.LessOrEqual(info.Size(), int64(150))
+	}
+	// Only two rotated files are kept
+	assert.NoFileExists(consoleLogPath(dir, 3))
+
+	data, err := readConsoleLog(dir)
+	assert.NoError(err)
+
+	entries, err := ParseConsoleLog(data)
+	assert.NoError(err)
+	assert.Len(entries, 6)
+	for i, entry := range entries {
+		assert.Equal(time.Unix(int64(i+2), 0).UTC(), entry.Time)
+		assert.Equal(line, entry.Line)
+	}
+
+	// Appended to when reopened
+	l, err = newConsoleLog(dir, 1, 2)
+	assert.NoError(err)
+	assert.NoError(l.write(ConsoleLogEntry{Line: "reopened"}))
+	assert.NoError(l.close())
+
+	data, err = readConsoleLog(dir)
+	assert.NoError(err)
+	entries, err = ParseConsoleLog(data)
+	assert.NoError(err)
+	assert.Len(entries, 7)
+	assert.Equal("reopened", entries[6].Line)
+}
+
+func TestOpenVMMLog(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &HypervisorConfig{ConsoleLogPath: t.TempDir()}
+	for _, line := range []string{"first error\n", "second error\n"} {
+		f, err := openVMMLog(conf, "sandbox")
+		assert.NoError(err)
+		_, err = f.WriteString(line)
+		assert.NoError(err)
+		assert.NoError(f.Close())
+	}
+
+	// The hypervisor errors are appended apart from the console log
+	data, err := os.ReadFile(filepath.Join(conf.ConsoleLogPath, "sandbox", vmmLogFile))
+	assert.NoError(err)
+	assert.Equal("first error\nsecond error\n", string(data))
+	assert.NoFileExists(filepath.Join(conf.ConsoleLogPath, "sandbox", consoleLogFile))
+}
+
+func TestReadConsoleLog(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := readConsoleLog(t.TempDir())
+	assert.Error(err)
+
+	_, err = ParseConsoleLog([]byte("not json\n"))
+	assert.Error(err)
+
+	entries, err := ParseConsoleLog(nil)
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func TestConsoleWatcherLog(t *testing.T) {
+	assert := assert.New(t)
+
+	logPath := t.TempDir()
+	s := &Sandbox{
+		id: "console-log",
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				ConsoleLogPath:     logPath,
+				ConsoleLogMaxFiles: 1,
+			},
+		},
+	}
+
+	consoleURL := filepath.Join(t.TempDir(), "console.sock")
+	listener, err := net.Listen("unix", consoleURL)
+	assert.NoError(err)
+	defer listener.Close()
+
+	cw := &consoleWatcher{proto: consoleProtoUnix, consoleURL: consoleURL}
+	assert.NoError(cw.start(s))
+	defer cw.stop()
+	assert.Error(cw.start(s))
+
+	conn, err := listener.Accept()
+	assert.NoError(err)
+	fmt.Fprintf(conn, "first line\n\nsecond line\n")
+	conn.Close()
+
+	var entries []ConsoleLogEntry
+	assert.Eventually(func() bool {
+		data, err := s.ConsoleLog(context.Background())
+		if err != nil {
+			return false
+		}
+		entries, err = ParseConsoleLog(data)
+		return err == nil && len(entries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal("first line", entries[0].Line)
+	assert.Equal("second line", entries[1].Line)
+	assert.False(entries[0].Time.IsZero())
+	assert.FileExists(filepath.Join(logPath, s.id, consoleLogFile))
+
+	s.config.HypervisorConfig.ConsoleLogPath = ""
+	_, err = s.ConsoleLog(context.Background())
+	assert.Error(err)
+}
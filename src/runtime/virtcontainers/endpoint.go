@@ -74,6 +74,11 @@ const (
 	// does not need a host network interface and instead has its network network configured
 	// through DAN.
 	VfioEndpointType EndpointType = "vfio"
+
+	// VdpaEndpointType is a vDPA network device given to the guest VM through
+	// its vhost-vdpa character device, either backing a physical interface of
+	// the network namespace or configured through DAN.
+	VdpaEndpointType EndpointType = "vhost-vdpa"
 )
 
 // Set sets an endpoint type based on the input string.
@@ -106,6 +111,9 @@ func (endpointType *EndpointType) Set(value string) error {
 	case "vfio":
 		*endpointType = VfioEndpointType
 		return nil
+	case "vhost-vdpa":
+		*endpointType = VdpaEndpointType
+		return nil
 	default:
 		return fmt.Errorf("Unknown endpoint type %s", value)
 	}
@@ -132,6 +140,8 @@ func (endpointType *EndpointType) String() string {
 		return string(IPVlanEndpointType)
 	case VfioEndpointType:
 		return string(VfioEndpointType)
+	case VdpaEndpointType:
+		return string(VdpaEndpointType)
 	default:
 		return ""
 	}
@@ -16,7 +16,9 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,10 +28,12 @@ import (
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	hv "github.com/kata-containers/kata-containers/src/runtime/pkg/hypervisors"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils/katatrace"
+	pkgUtils "github.com/kata-containers/kata-containers/src/runtime/pkg/utils"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/fs"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/firecracker/client"
 	models "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/firecracker/client/models"
 	ops "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/firecracker/client/operations"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/rootless"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
 
@@ -84,11 +88,26 @@ const (
 	fcMetricsFifo = "metrics.fifo"
 
 	defaultFcConfig = "fcConfig.json"
+
+	// Interval in seconds between refreshes of the balloon statistics
+	fcBalloonStatsInterval = 5
+
+	// Names of the snapshot files within jailer root
+	fcSnapshotState = "snapshot.state"
+	fcSnapshotMem   = "snapshot.mem"
+
+	// MMDS version, v2 requires the guest to get a session token first
+	fcMmdsVersion = "V2"
+	// Name of the file holding the MMDS contents the VM boots with
+	fcMmdsMetadata = "mmds.json"
 )
 
 // Specify the minimum version of firecracker supported
 var fcMinSupportedVersion = semver.MustParse("0.21.1")
 
+// Specify the minimum version of firecracker supporting MMDS v2
+var fcMmdsMinVersion = semver.MustParse("1.0.0")
+
 var fcKernelParams = []Param{
 	// The boot source is the first partition of the first block device added
 	{"pci", "off"},
@@ -161,7 +180,15 @@ type firecracker struct {
 	config HypervisorConfig
 	state  firecrackerState
 
-	jailed bool //Set to true if jailer is enabled
+	jailed    bool //Set to true if jailer is enabled
+	restoring bool //Set to true if the VM is loaded from a snapshot instead of booted
+
+	snapshotMemPath string //Memory file of the last snapshot, base of diff snapshots
+	balloonMemory   uint32 //Guest memory in MiB held back by the balloon
+
+	mmdsMetadata map[string]interface{} //MMDS contents the VM boots with
+
+	events hypervisorEventStream //Lifecycle events of the VM
 }
 
 type firecrackerDevice struct {
@@ -212,13 +239,17 @@ func (fc *firecracker) CreateVM(ctx context.Context, id string, network Network,
 
 	fc.setPaths(&fc.config)
 
+	// VMs created from a template are loaded from its snapshot
+	// rather than booted.
+	fc.restoring = fc.config.BootFromTemplate
+
 	// So we need to repopulate this at StartVM where it is valid
 	fc.netNSPath = network.NetworkID()
 
-	// Till we create lower privileged kata user run as root
-	// https://github.com/kata-containers/runtime/issues/1869
-	fc.uid = "0"
-	fc.gid = "0"
+	// The VMM runs as the unprivileged user of the sandbox when rootless,
+	// as root otherwise.
+	fc.uid = strconv.FormatUint(uint64(fc.config.Uid), 10)
+	fc.gid = strconv.FormatUint(uint64(fc.config.Gid), 10)
 
 	fc.fcConfig = &types.FcConfig{}
 	fc.fcConfigPath = filepath.Join(fc.vmPath, defaultFcConfig)
@@ -238,6 +269,11 @@ func (fc *firecracker) setPaths(hypervisorConfig *HypervisorConfig) {
 	hypervisorName := filepath.Base(hypervisorConfig.HypervisorPath)
 	//fs.RunStoragePath cannot be used as we need exec perms
 	fc.chrootBaseDir = filepath.Join("/run", fs.StoragePathSuffix)
+	if rootless.IsRootless() {
+		// The unprivileged VMM user can only create its sockets
+		// in the directories it owns.
+		fc.chrootBaseDir = filepath.Join(rootless.GetRootlessDir(), fs.StoragePathSuffix)
+	}
 
 	fc.vmPath = filepath.Join(fc.chrootBaseDir, hypervisorName, fc.id)
 	fc.jailerRoot = filepath.Join(fc.vmPath, "root") // auto created by jailer
@@ -338,7 +374,13 @@ func (fc *firecracker) waitVMMRunning(ctx context.Context, timeout int) error {
 
 	timeStart := time.Now()
 	for {
-		if fc.vmRunning(ctx) {
+		if fc.restoring {
+			// The VM is only running once the snapshot is loaded,
+			// which requires the API to be up.
+			if _, err := fc.client(ctx).Operations.DescribeInstance(nil); err == nil {
+				return nil
+			}
+		} else if fc.vmRunning(ctx) {
 			return nil
 		}
 
@@ -364,47 +406,49 @@ func (fc *firecracker) fcInit(ctx context.Context, timeout int) error {
 		return err
 	}
 
-	var cmd *exec.Cmd
-	var args []string
+	if fc.fcConfig.MmdsConfig != nil {
+		if err := fc.checkMmdsVersion(fc.info.Version); err != nil {
+			return err
+		}
+	}
 
 	if fc.fcConfigPath, err = fc.fcJailResource(fc.fcConfigPath, defaultFcConfig); err != nil {
 		return err
 	}
 
-	//https://github.com/firecracker-microvm/firecracker/blob/master/docs/jailer.md#jailer-usage
-	//--seccomp-level specifies whether seccomp filters should be installed and how restrictive they should be. Possible values are:
-	//0 : disabled.
-	//1 : basic filtering. This prohibits syscalls not whitelisted by Firecracker.
-	//2 (default): advanced filtering. This adds further checks on some of the parameters of the allowed syscalls.
-	if fc.jailed {
-		jailedArgs := []string{
-			"--id", fc.id,
-			"--exec-file", fc.config.HypervisorPath,
-			"--uid", "0", //https://github.com/kata-containers/runtime/issues/1869
-			"--gid", "0",
-			"--chroot-base-dir", fc.chrootBaseDir,
-			"--daemonize",
-		}
-		args = append(args, jailedArgs...)
-		if fc.netNSPath != "" {
-			args = append(args, "--netns", fc.netNSPath)
+	// The initial MMDS contents are given on the command line, so that
+	// they are there as soon as the guest boots.
+	var configArgs []string
+	if !fc.restoring {
+		configArgs = []string{"--config-file", fc.fcConfigPath}
+		if fc.fcConfig.MmdsConfig != nil && fc.mmdsMetadata != nil {
+			metadataPath, err := fc.fcJailResource(filepath.Join(fc.vmPath, fcMmdsMetadata), fcMmdsMetadata)
+			if err != nil {
+				return err
+			}
+			configArgs = append(configArgs, "--metadata", metadataPath)
 		}
-		args = append(args, "--", "--config-file", fc.fcConfigPath)
-
-		cmd = exec.Command(fc.config.JailerPath, args...)
-	} else {
-		args = append(args,
-			"--api-sock", fc.socketPath,
-			"--config-file", fc.fcConfigPath)
-		cmd = exec.Command(fc.config.HypervisorPath, args...)
 	}
 
-	if fc.config.Debug {
+	cmd := fc.vmmCommand(configArgs)
+	// The console is only set up when the sandbox reads it.
+	if fc.console != nil {
 		cmd.Stderr = fc.console
 		cmd.Stdout = fc.console
 	}
 
-	fc.Logger().WithField("hypervisor args", args).Debug()
+	// The errors of firecracker are kept apart from the guest console
+	// captured to the sandbox console log.
+	if fc.config.ConsoleLogEnabled() {
+		vmmLog, err := openVMMLog(&fc.config, fc.id)
+		if err != nil {
+			return err
+		}
+		defer vmmLog.Close()
+		cmd.Stderr = vmmLog
+	}
+
+	fc.Logger().WithField("hypervisor args", cmd.Args[1:]).Debug()
 	fc.Logger().WithField("hypervisor cmd", cmd).Debug()
 
 	fc.Logger().Info("Starting VM")
@@ -452,6 +496,53 @@ func (fc *firecracker) fcInit(ctx context.Context, timeout int) error {
 	return nil
 }
 
+// vmmCommand returns the command starting firecracker, through the jailer
+// when there is one, with the configuration arguments configArgs.
+func (fc *firecracker) vmmCommand(configArgs []string) *exec.Cmd {
+	//https://github.com/firecracker-microvm/firecracker/blob/master/docs/jailer.md#jailer-usage
+	//--seccomp-level specifies whether seccomp filters should be installed and how restrictive they should be. Possible values are:
+	//0 : disabled.
+	//1 : basic filtering. This prohibits syscalls not whitelisted by Firecracker.
+	//2 (default): advanced filtering. This adds further checks on some of the parameters of the allowed syscalls.
+	if fc.jailed {
+		// The jailer drops to the uid and gid of the VMM itself,
+		// after giving them the devices of the jail.
+		args := []string{
+			"--id", fc.id,
+			"--exec-file", fc.config.HypervisorPath,
+			"--uid", fc.uid,
+			"--gid", fc.gid,
+			"--chroot-base-dir", fc.chrootBaseDir,
+			"--daemonize",
+		}
+		if fc.netNSPath != "" {
+			args = append(args, "--netns", fc.netNSPath)
+		}
+		// Without a configuration file, firecracker waits for the
+		// snapshot to be loaded through its API.
+		if len(configArgs) > 0 {
+			args = append(args, "--")
+			args = append(args, configArgs...)
+		}
+
+		return exec.Command(fc.config.JailerPath, args...)
+	}
+
+	args := append([]string{"--api-sock", fc.socketPath}, configArgs...)
+	cmd := exec.Command(fc.config.HypervisorPath, args...)
+	if rootless.IsRootless() {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{
+				Uid:    fc.config.Uid,
+				Gid:    fc.config.Gid,
+				Groups: fc.config.Groups,
+			},
+		}
+	}
+
+	return cmd
+}
+
 // readJailedFirecrackerPID returns the PID firecracker writes to
 // <jailerRoot>/firecracker.pid after the jailer's fork+exec.
 func (fc *firecracker) readJailedFirecrackerPID() (int, error) {
@@ -518,6 +609,10 @@ func (fc *firecracker) createJailedDrive(name string) (string, error) {
 	}
 	f.Close()
 
+	if err := fc.chownToVMM(r); err != nil {
+		return "", err
+	}
+
 	if fc.jailed {
 		// use path relative to the jail
 		r = filepath.Join("/", name)
@@ -526,6 +621,16 @@ func (fc *firecracker) createJailedDrive(name string) (string, error) {
 	return r, nil
 }
 
+// chownToVMM gives path to the VMM user when it does not run as root, so that
+// firecracker can open the resources the runtime creates for it.
+func (fc *firecracker) chownToVMM(path string) error {
+	if !rootless.IsRootless() {
+		return nil
+	}
+
+	return os.Chown(path, int(fc.config.Uid), int(fc.config.Gid))
+}
+
 // when running with jailer, firecracker binary will firstly be copied into fc.jailerRoot,
 // and then being executed there. Therefore we need to ensure fc.JailerRoot has exec permissions.
 func (fc *firecracker) fcRemountJailerRootWithExec() error {
@@ -625,6 +730,11 @@ func (fc *firecracker) fcSetVMBaseConfig(ctx context.Context, mem int64, vcpus i
 		VcpuCount:  &vcpus,
 	}
 
+	if fc.config.EnableDiffSnapshots {
+		trackDirtyPages := true
+		cfg.TrackDirtyPages = &trackDirtyPages
+	}
+
 	fc.fcConfig.MachineConfig = cfg
 }
 
@@ -638,7 +748,7 @@ func (fc *firecracker) fcSetLogger(ctx context.Context) error {
 	}
 
 	// listen to log fifo file and transfer error info
-	jailedLogFifo, err := fc.fcListenToFifo(fcLogFifo, nil)
+	jailedLogFifo, err := fc.fcListenToFifo(fcLogFifo, fc.handleLog)
 	if err != nil {
 		return fmt.Errorf("Failed setting log: %s", err)
 	}
@@ -675,17 +785,78 @@ func (fc *firecracker) updateMetrics(line string) {
 		return
 	}
 	updateFirecrackerMetrics(&fm)
+
+	// Firecracker flushes the increments of its counters since the last
+	// flush, hence any reset or failed block request is a new one.
+	if fm.I8042.ResetCount > 0 {
+		fc.events.publish(fc.Logger(), HypervisorEvent{
+			Type:    HypervisorEventReset,
+			Reason:  "guest",
+			Details: map[string]string{"i8042-reset-count": strconv.FormatUint(fm.I8042.ResetCount, 10)},
+		})
+	}
+	if fm.Block.ExecuteFails > 0 {
+		fc.events.publish(fc.Logger(), HypervisorEvent{
+			Type:    HypervisorEventBlockIOError,
+			Details: map[string]string{"execute-fails": strconv.FormatUint(fm.Block.ExecuteFails, 10)},
+		})
+	}
+
+	// Balloon statistics are only available through the API, refresh
+	// them along with the metrics firecracker periodically flushes.
+	if fc.balloonEnabled() {
+		fc.updateBalloonMetrics()
+	}
+}
+
+func (fc *firecracker) updateBalloonMetrics() {
+	resp, err := fc.client(context.Background()).Operations.DescribeBalloonStats(nil)
+	if err != nil {
+		fc.Logger().WithError(err).Debug("failed to get balloon statistics")
+		return
+	}
+	updateFirecrackerBalloonMetrics(resp.Payload)
+}
+
+// fcExitCodeRegex matches the exit code firecracker logs when it exits.
+var fcExitCodeRegex = regexp.MustCompile(`Firecracker exiting.*exit_code=(\d+)`)
+
+// handleLog reports the exit of firecracker, which shuts the VM down, as a
+// lifecycle event of the VM.
+func (fc *firecracker) handleLog(line string) {
+	fc.Logger().WithFields(logrus.Fields{
+		"fifoName": fcLogFifo,
+		"contents": line}).Debug("read firecracker fifo")
+
+	if match := fcExitCodeRegex.FindStringSubmatch(line); match != nil {
+		fc.events.publish(fc.Logger(), HypervisorEvent{
+			Type:    HypervisorEventShutdown,
+			Reason:  "vmm-exit",
+			Details: map[string]string{"exit-code": match[1]},
+		})
+	}
+}
+
+// HypervisorEvents returns the lifecycle events of the VM reported by the
+// firecracker logs and metrics.
+func (fc *firecracker) HypervisorEvents() <-chan HypervisorEvent {
+	return fc.events.channel()
 }
 
 type fifoConsumer func(string)
 
 func (fc *firecracker) fcListenToFifo(fifoName string, consumer fifoConsumer) (string, error) {
 	fcFifoPath := filepath.Join(fc.vmPath, fifoName)
-	fcFifo, err := fifo.OpenFifo(context.Background(), fcFifoPath, syscall.O_CREAT|syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	fcFifo, err := fifo.OpenFifo(context.Background(), fcFifoPath, syscall.O_CREAT|syscall.O_RDONLY|syscall.O_NONBLOCK, 0600)
 	if err != nil {
 		return "", fmt.Errorf("Failed to open/create fifo file %s", err)
 	}
 
+	if err := fc.chownToVMM(fcFifoPath); err != nil {
+		fcFifo.Close()
+		return "", err
+	}
+
 	jailedFifoPath, err := fc.fcJailResource(fcFifoPath, fifoName)
 	if err != nil {
 		return "", err
@@ -717,8 +888,8 @@ func (fc *firecracker) fcListenToFifo(fifoName string, consumer fifoConsumer) (s
 
 func (fc *firecracker) fcInitConfiguration(ctx context.Context) error {
 	// Firecracker API socket(firecracker.socket) is automatically created
-	// under /run dir.
-	err := os.MkdirAll(filepath.Join(fc.jailerRoot, "run"), DirMode)
+	// under /run dir, which is owned by the VMM user when rootless.
+	err := utils.MkdirAllWithInheritedOwner(filepath.Join(fc.jailerRoot, "run"), DirMode)
 	if err != nil {
 		return err
 	}
@@ -737,9 +908,19 @@ func (fc *firecracker) fcInitConfiguration(ctx context.Context) error {
 		}
 	}
 
-	fc.fcSetVMBaseConfig(ctx, int64(fc.config.MemorySize),
+	// Snapshots record the paths of the VM resources, the jailer
+	// is what keeps them identical from one VM to another.
+	if fc.restoring && !fc.jailed {
+		return errors.New("restoring a firecracker VM from a snapshot requires the jailer")
+	}
+
+	fc.fcSetVMBaseConfig(ctx, int64(fc.bootMemoryMB()),
 		int64(fc.config.NumVCPUs()), false)
 
+	if fc.balloonEnabled() {
+		fc.fcSetBalloon(ctx)
+	}
+
 	kernelPath, err := fc.config.KernelAssetPath()
 	if err != nil {
 		return err
@@ -757,6 +938,15 @@ func (fc *firecracker) fcInitConfiguration(ctx context.Context) error {
 	fcKernelParams = append(params, fcKernelParams...)
 	if fc.config.Debug {
 		fcKernelParams = append(fcKernelParams, Param{"console", "ttyS0"})
+	} else if fc.config.ConsoleLogEnabled() {
+		// The serial port is only kept to capture the quiet output of
+		// the kernel to the sandbox console log.
+		fcKernelParams = append(fcKernelParams, []Param{
+			{"quiet", ""},
+			{"console", "ttyS0"},
+			// Tell agent where to send the logs
+			{"agent.log_vport", fmt.Sprintf("%d", vSockLogsPort)},
+		}...)
 	} else {
 		fcKernelParams = append(fcKernelParams, []Param{
 			{"8250.nr_uarts", "0"},
@@ -800,6 +990,12 @@ func (fc *firecracker) fcInitConfiguration(ctx context.Context) error {
 		}
 	}
 
+	// MMDS is reached through the network interfaces, which
+	// need to be known at this point.
+	if fc.config.EnableMmds && !fc.restoring {
+		fc.fcSetMmdsConfig(ctx)
+	}
+
 	// register firecracker specificed metrics
 	registerFirecrackerMetrics()
 
@@ -826,6 +1022,26 @@ func (fc *firecracker) StartVM(ctx context.Context, timeout int) error {
 		return err
 	}
 
+	if err := fc.chownToVMM(fc.fcConfigPath); err != nil {
+		return err
+	}
+
+	if fc.fcConfig.MmdsConfig != nil && fc.mmdsMetadata != nil {
+		metadata, err := json.Marshal(fc.mmdsMetadata)
+		if err != nil {
+			return err
+		}
+
+		metadataPath := filepath.Join(fc.vmPath, fcMmdsMetadata)
+		if err := os.WriteFile(metadataPath, metadata, 0640); err != nil {
+			return err
+		}
+
+		if err := fc.chownToVMM(metadataPath); err != nil {
+			return err
+		}
+	}
+
 	var err error
 	defer func() {
 		if err != nil {
@@ -850,6 +1066,13 @@ func (fc *firecracker) StartVM(ctx context.Context, timeout int) error {
 		return err
 	}
 
+	if fc.restoring {
+		err = fc.fcLoadSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	// make sure 'others' don't have access to this socket
 	err = os.Chmod(fc.hybridSocketPath, 0640)
 	if err != nil {
@@ -922,6 +1145,7 @@ func (fc *firecracker) cleanupJail(ctx context.Context) {
 	fc.umountResource(fcLogFifo)
 	fc.umountResource(fcMetricsFifo)
 	fc.umountResource(defaultFcConfig)
+	fc.umountResource(fcMmdsMetadata)
 	// if running with jailer, we also need to umount fc.jailerRoot
 	if fc.config.JailerPath != "" {
 		if err := syscall.Unmount(fc.jailerRoot, syscall.MNT_DETACH); err != nil {
@@ -943,16 +1167,162 @@ func (fc *firecracker) StopVM(ctx context.Context, waitOnly bool) (err error) {
 	return fc.fcEnd(ctx, waitOnly)
 }
 
+func (fc *firecracker) fcSetVMState(ctx context.Context, state string) error {
+	params := ops.NewPatchVMParams()
+	params.SetBody(&models.VM{
+		State: &state,
+	})
+
+	if _, err := fc.client(ctx).Operations.PatchVM(params); err != nil {
+		fc.Logger().WithError(err).WithField("state", state).Error("Failed to change VM state")
+		return err
+	}
+
+	return nil
+}
+
 func (fc *firecracker) PauseVM(ctx context.Context) error {
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "PauseVM", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	return fc.fcSetVMState(ctx, models.VMStatePaused)
+}
+
+// fcCreateSnapshot writes the state of the paused VM to statePath and its
+// memory to memPath. A diff snapshot only writes the guest pages dirtied
+// since the previous snapshot, on top of the memory file of that snapshot.
+func (fc *firecracker) fcCreateSnapshot(ctx context.Context, snapshotType, statePath, memPath string) error {
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "fcCreateSnapshot", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	// The snapshot files are bind mounted into the jail, so they have
+	// to exist beforehand.
+	for _, path := range []string{statePath, memPath} {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		f.Close()
+
+		if err := fc.chownToVMM(path); err != nil {
+			return err
+		}
+	}
+
+	jailedState, err := fc.fcJailResource(statePath, fcSnapshotState)
+	if err != nil {
+		return err
+	}
+	defer fc.umountResource(fcSnapshotState)
+
+	jailedMem, err := fc.fcJailResource(memPath, fcSnapshotMem)
+	if err != nil {
+		return err
+	}
+	defer fc.umountResource(fcSnapshotMem)
+
+	params := ops.NewCreateSnapshotParams()
+	params.SetBody(&models.SnapshotCreateParams{
+		SnapshotPath: &jailedState,
+		MemFilePath:  &jailedMem,
+		SnapshotType: snapshotType,
+	})
+
+	if _, err := fc.client(ctx).Operations.CreateSnapshot(params); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// fcLoadSnapshot loads the VM from the snapshot of its template into a
+// firecracker started without configuration file. Logging and metrics are
+// not part of the snapshot and are set up first. The VM is left paused.
+func (fc *firecracker) fcLoadSnapshot(ctx context.Context) error {
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "fcLoadSnapshot", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	cl := fc.client(ctx).Operations
+
+	loggerParams := ops.NewPutLoggerParams()
+	loggerParams.SetBody(fc.fcConfig.Logger)
+	if _, err := cl.PutLogger(loggerParams); err != nil {
+		return err
+	}
+
+	metricsParams := ops.NewPutMetricsParams()
+	metricsParams.SetBody(fc.fcConfig.Metrics)
+	if _, err := cl.PutMetrics(metricsParams); err != nil {
+		return err
+	}
+
+	// Firecracker maps the memory file privately, the template memory
+	// is never written to.
+	jailedState, err := fc.fcJailResource(fc.config.DevicesStatePath, fcSnapshotState)
+	if err != nil {
+		return err
+	}
+	defer fc.umountResource(fcSnapshotState)
+
+	jailedMem, err := fc.fcJailResource(fc.config.MemoryPath, fcSnapshotMem)
+	if err != nil {
+		return err
+	}
+	defer fc.umountResource(fcSnapshotMem)
+
+	backendType := models.MemoryBackendBackendTypeFile
+	params := ops.NewLoadSnapshotParams()
+	params.SetBody(&models.SnapshotLoadParams{
+		SnapshotPath: &jailedState,
+		MemBackend: &models.MemoryBackend{
+			BackendPath: &jailedMem,
+			BackendType: &backendType,
+		},
+		EnableDiffSnapshots: fc.config.EnableDiffSnapshots,
+	})
+
+	if _, err := cl.LoadSnapshot(params); err != nil {
+		fc.Logger().WithError(err).Error("Failed to load VM snapshot")
+		return err
+	}
+
 	return nil
 }
 
+// SaveVM snapshots the paused VM to MemoryPath and DevicesStatePath. With
+// diff snapshots enabled, only the first snapshot to a memory file is a full
+// one.
 func (fc *firecracker) SaveVM() error {
+	if fc.config.MemoryPath == "" || fc.config.DevicesStatePath == "" {
+		return errors.New("firecracker snapshot requires both a memory and a state file")
+	}
+
+	snapshotType := models.SnapshotCreateParamsSnapshotTypeFull
+	if fc.config.EnableDiffSnapshots && fc.snapshotMemPath == fc.config.MemoryPath {
+		snapshotType = models.SnapshotCreateParamsSnapshotTypeDiff
+	}
+
+	fc.Logger().WithFields(logrus.Fields{
+		"type":   snapshotType,
+		"state":  fc.config.DevicesStatePath,
+		"memory": fc.config.MemoryPath,
+	}).Info("Save VM snapshot")
+
+	if err := fc.fcCreateSnapshot(context.Background(), snapshotType, fc.config.DevicesStatePath, fc.config.MemoryPath); err != nil {
+		fc.Logger().WithError(err).Error("Failed to save VM snapshot")
+		return err
+	}
+
+	fc.snapshotMemPath = fc.config.MemoryPath
+
 	return nil
 }
 
 func (fc *firecracker) ResumeVM(ctx context.Context) error {
-	return nil
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "ResumeVM", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	return fc.fcSetVMState(ctx, models.VMStateResumed)
 }
 
 func (fc *firecracker) fcAddVsock(ctx context.Context, hvs types.HybridVSock) {
@@ -1215,6 +1585,9 @@ func (fc *firecracker) Capabilities(ctx context.Context) types.Capabilities {
 	defer span.End()
 	var caps types.Capabilities
 	caps.SetBlockDeviceHotplugSupport()
+	if fc.config.EnableMemoryBalloon {
+		caps.SetMemoryShrinkSupport()
+	}
 
 	return caps
 }
@@ -1223,12 +1596,160 @@ func (fc *firecracker) HypervisorConfig() HypervisorConfig {
 	return fc.config
 }
 
-func (fc *firecracker) GetTotalMemoryMB(ctx context.Context) uint32 {
+// balloonEnabled returns whether the VM has a balloon device, to resize the
+// guest memory or to reclaim the memory freed by the guest.
+func (fc *firecracker) balloonEnabled() bool {
+	return fc.config.EnableMemoryBalloon || fc.config.ReclaimGuestFreedMemory
+}
+
+// bootMemoryMB returns the memory the VM is booted with. Firecracker cannot
+// hotplug memory, with the memory balloon the VM is over-provisioned instead.
+func (fc *firecracker) bootMemoryMB() uint32 {
+	if fc.config.EnableMemoryBalloon && fc.config.DefaultMaxMemorySize > uint64(fc.config.MemorySize) {
+		return uint32(fc.config.DefaultMaxMemorySize)
+	}
+
 	return fc.config.MemorySize
 }
 
+// fcSetBalloon adds a balloon holding back the memory the VM is
+// over-provisioned with.
+func (fc *firecracker) fcSetBalloon(ctx context.Context) {
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "fcSetBalloon", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	amount := int64(fc.bootMemoryMB() - fc.config.MemorySize)
+	deflateOnOom := true
+
+	fc.fcConfig.Balloon = &models.Balloon{
+		AmountMib:             &amount,
+		DeflateOnOom:          &deflateOnOom,
+		StatsPollingIntervals: fcBalloonStatsInterval,
+		FreePageReporting:     fc.config.ReclaimGuestFreedMemory,
+	}
+	fc.balloonMemory = uint32(amount)
+}
+
+func (fc *firecracker) fcUpdateBalloon(ctx context.Context, amountMB uint32) error {
+	if amountMB == fc.balloonMemory {
+		return nil
+	}
+
+	amount := int64(amountMB)
+	params := ops.NewPatchBalloonParams()
+	params.SetBody(&models.BalloonUpdate{
+		AmountMib: &amount,
+	})
+
+	if _, err := fc.client(ctx).Operations.PatchBalloon(params); err != nil {
+		fc.Logger().WithError(err).WithField("amount-mib", amountMB).Error("Failed to resize balloon")
+		return err
+	}
+	fc.balloonMemory = amountMB
+
+	fc.events.publish(fc.Logger(), HypervisorEvent{
+		Type:    HypervisorEventBalloonChange,
+		Details: map[string]string{"balloon-mib": strconv.FormatUint(uint64(amountMB), 10)},
+	})
+
+	return nil
+}
+
+func (fc *firecracker) checkMmdsVersion(version string) error {
+	v, err := semver.Make(version)
+	if err != nil {
+		return fmt.Errorf("Malformed firecracker version: %v", err)
+	}
+
+	if v.LT(fcMmdsMinVersion) {
+		return fmt.Errorf("version %v does not support MMDS %v. Minimum required version of firecracker is %v", v.String(), fcMmdsVersion, fcMmdsMinVersion.String())
+	}
+
+	return nil
+}
+
+// fcSetMmdsConfig makes the metadata service reachable from all of the
+// VM network interfaces.
+func (fc *firecracker) fcSetMmdsConfig(ctx context.Context) {
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "fcSetMmdsConfig", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	var ifaces []string
+	for _, iface := range fc.fcConfig.NetworkInterfaces {
+		ifaces = append(ifaces, *iface.IfaceID)
+	}
+
+	if len(ifaces) == 0 {
+		fc.Logger().Warn("MMDS requires a network interface, not configuring it")
+		return
+	}
+
+	version := fcMmdsVersion
+	cfg := &models.MmdsConfig{
+		NetworkInterfaces: ifaces,
+		Version:           &version,
+	}
+	if fc.config.MmdsIPv4Address != "" {
+		address := fc.config.MmdsIPv4Address
+		cfg.IPV4Address = &address
+	}
+
+	fc.fcConfig.MmdsConfig = cfg
+}
+
+// setGuestMetadata sets the MMDS contents the VM boots with.
+func (fc *firecracker) setGuestMetadata(metadata map[string]interface{}) {
+	fc.mmdsMetadata = metadata
+}
+
+// updateGuestMetadata merges metadata into the MMDS contents of the running
+// VM, following the JSON merge patch semantics.
+func (fc *firecracker) updateGuestMetadata(ctx context.Context, metadata map[string]interface{}) error {
+	if fc.fcConfig.MmdsConfig == nil {
+		return nil
+	}
+
+	params := ops.NewPatchMmdsParams()
+	params.SetBody(metadata)
+
+	if _, err := fc.client(ctx).Operations.PatchMmds(params); err != nil {
+		fc.Logger().WithError(err).Error("Failed to update MMDS contents")
+		return err
+	}
+
+	return nil
+}
+
+func (fc *firecracker) GetTotalMemoryMB(ctx context.Context) uint32 {
+	return fc.bootMemoryMB() - fc.balloonMemory
+}
+
+// ResizeMemory inflates or deflates the memory balloon so that the guest gets
+// reqMemMB. The guest memory stays between its default size and the memory
+// the VM was booted with.
 func (fc *firecracker) ResizeMemory(ctx context.Context, reqMemMB uint32, memoryBlockSizeMB uint32, probe bool) (uint32, MemoryDevice, error) {
-	return 0, MemoryDevice{}, nil
+	span, _ := katatrace.Trace(ctx, fc.Logger(), "ResizeMemory", fcTracingTags, map[string]string{"sandbox_id": fc.id})
+	defer span.End()
+
+	if !fc.config.EnableMemoryBalloon {
+		return fc.GetTotalMemoryMB(ctx), MemoryDevice{}, nil
+	}
+
+	bootMemory := fc.bootMemoryMB()
+	if reqMemMB > bootMemory {
+		fc.Logger().Warnf("Requested %dMB of memory, the VM is limited to %dMB", reqMemMB, bootMemory)
+		reqMemMB = bootMemory
+	}
+
+	if reqMemMB < fc.config.MemorySize {
+		reqMemMB = fc.config.MemorySize
+	}
+
+	if err := fc.fcUpdateBalloon(ctx, bootMemory-reqMemMB); err != nil {
+		return fc.GetTotalMemoryMB(ctx), MemoryDevice{}, err
+	}
+
+	return reqMemMB, MemoryDevice{}, nil
 }
 
 func (fc *firecracker) ResizeVCPUs(ctx context.Context, reqVCPUs uint32) (currentVCPUs uint32, newVCPUs uint32, err error) {
@@ -1279,6 +1800,32 @@ func (fc *firecracker) GetThreadIDs(ctx context.Context) (VcpuThreadIDs, error)
 
 func (fc *firecracker) Cleanup(ctx context.Context) error {
 	fc.cleanupJail(ctx)
+
+	if rootless.IsRootless() {
+		if _, err := user.Lookup(fc.config.User); err != nil {
+			fc.Logger().WithError(err).WithFields(
+				logrus.Fields{
+					"user": fc.config.User,
+					"uid":  fc.config.Uid,
+				}).Warn("failed to find the user, it might have been removed")
+			return nil
+		}
+
+		if err := pkgUtils.RemoveVmmUser(fc.config.User); err != nil {
+			fc.Logger().WithError(err).WithFields(
+				logrus.Fields{
+					"user": fc.config.User,
+					"uid":  fc.config.Uid,
+				}).Warn("failed to delete the user")
+			return nil
+		}
+		fc.Logger().WithFields(
+			logrus.Fields{
+				"user": fc.config.User,
+				"uid":  fc.config.Uid,
+			}).Debug("successfully removed the non root user")
+	}
+
 	return nil
 }
 
@@ -1290,22 +1837,67 @@ func (fc *firecracker) GetVirtioFsPid() *int {
 	return nil
 }
 
+// firecrackerGrpc is what a runtime needs to take over a firecracker VM
+// created by the VM cache server.
+type firecrackerGrpc struct {
+	ID        string
+	NetNSPath string
+	// UID and GID the VMM of the VM was started as, which the jailed
+	// resources of the VM belong to.
+	UID    string
+	GID    string
+	Info   FirecrackerInfo
+	Jailed bool
+}
+
 func (fc *firecracker) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
-	return errors.New("firecracker is not supported by VM cache")
+	var fp firecrackerGrpc
+	if err := json.Unmarshal(j, &fp); err != nil {
+		return err
+	}
+
+	fc.ctx = ctx
+	fc.id = fp.ID
+	if err := fc.setConfig(hypervisorConfig); err != nil {
+		return err
+	}
+	fc.setPaths(&fc.config)
+
+	fc.netNSPath = fp.NetNSPath
+	fc.info = fp.Info
+	fc.jailed = fp.Jailed
+	fc.uid = fp.UID
+	fc.gid = fp.GID
+	fc.fcConfig = &types.FcConfig{}
+	fc.fcConfigPath = filepath.Join(fc.vmPath, defaultFcConfig)
+	fc.state.set(vmReady)
+
+	return nil
 }
 
 func (fc *firecracker) toGrpc(ctx context.Context) ([]byte, error) {
-	return nil, errors.New("firecracker is not supported by VM cache")
+	fp := firecrackerGrpc{
+		ID:        fc.id,
+		NetNSPath: fc.netNSPath,
+		UID:       fc.uid,
+		GID:       fc.gid,
+		Info:      fc.info,
+		Jailed:    fc.jailed,
+	}
+
+	return json.Marshal(&fp)
 }
 
 func (fc *firecracker) Save() (s hv.HypervisorState) {
 	s.Pid = fc.info.PID
 	s.Type = string(FirecrackerHypervisor)
+	s.BalloonMemory = int(fc.balloonMemory)
 	return
 }
 
 func (fc *firecracker) Load(s hv.HypervisorState) {
 	fc.info.PID = s.Pid
+	fc.balloonMemory = uint32(s.BalloonMemory)
 }
 
 func (fc *firecracker) Check() error {
@@ -8,6 +8,9 @@
 package virtcontainers
 
 import (
+	"sync"
+
+	models "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/firecracker/client/models"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -150,10 +153,25 @@ var (
 	},
 		[]string{"item"},
 	)
+
+	balloonStatsMetrics = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: fcMetricsNS,
+		Name:      "balloon_stats",
+		Help:      "Balloon device statistics reported by the guest.",
+	},
+		[]string{"item"},
+	)
 )
 
-// registerFirecrackerMetrics register all metrics to prometheus.
+var registerFirecrackerMetricsOnce sync.Once
+
+// registerFirecrackerMetrics register all metrics to prometheus. The VM cache
+// server starts several VMs within the same process, so this is only done once.
 func registerFirecrackerMetrics() {
+	registerFirecrackerMetricsOnce.Do(doRegisterFirecrackerMetrics)
+}
+
+func doRegisterFirecrackerMetrics() {
 	prometheus.MustRegister(apiServerMetrics)
 	prometheus.MustRegister(blockDeviceMetrics)
 	prometheus.MustRegister(getRequestsMetrics)
@@ -171,7 +189,27 @@ func registerFirecrackerMetrics() {
 	prometheus.MustRegister(serialDeviceMetrics)
 	prometheus.MustRegister(signalMetrics)
 	prometheus.MustRegister(vsockDeviceMetrics)
+	prometheus.MustRegister(balloonStatsMetrics)
+}
 
+// updateFirecrackerBalloonMetrics update the balloon statistics to the latest values.
+func updateFirecrackerBalloonMetrics(stats *models.BalloonStats) {
+	if stats.TargetMib != nil {
+		balloonStatsMetrics.WithLabelValues("target_mib").Set(float64(*stats.TargetMib))
+	}
+	if stats.ActualMib != nil {
+		balloonStatsMetrics.WithLabelValues("actual_mib").Set(float64(*stats.ActualMib))
+	}
+	balloonStatsMetrics.WithLabelValues("total_memory").Set(float64(stats.TotalMemory))
+	balloonStatsMetrics.WithLabelValues("available_memory").Set(float64(stats.AvailableMemory))
+	balloonStatsMetrics.WithLabelValues("free_memory").Set(float64(stats.FreeMemory))
+	balloonStatsMetrics.WithLabelValues("disk_caches").Set(float64(stats.DiskCaches))
+	balloonStatsMetrics.WithLabelValues("major_faults").Set(float64(stats.MajorFaults))
+	balloonStatsMetrics.WithLabelValues("minor_faults").Set(float64(stats.MinorFaults))
+	balloonStatsMetrics.WithLabelValues("swap_in").Set(float64(stats.SwapIn))
+	balloonStatsMetrics.WithLabelValues("swap_out").Set(float64(stats.SwapOut))
+	balloonStatsMetrics.WithLabelValues("hugetlb_allocations").Set(float64(stats.HugetlbAllocations))
+	balloonStatsMetrics.WithLabelValues("hugetlb_failures").Set(float64(stats.HugetlbFailures))
 }
 
 // updateFirecrackerMetrics update all metrics to the latest values.
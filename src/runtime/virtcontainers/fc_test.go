@@ -9,15 +9,90 @@ package virtcontainers
 
 import (
 	"context"
+	"encoding/json"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 
+	ktu "github.com/kata-containers/kata-containers/src/runtime/pkg/katatestutils"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/fs"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/firecracker/client/models"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/rootless"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/moby/sys/mountinfo"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
+type fcTestRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// fcTestAPIServer is a stub of the firecracker API, accepting every request.
+// Requests with a canned response, indexed by "<method> <path>", get it back.
+type fcTestAPIServer struct {
+	sync.Mutex
+	requests  []fcTestRequest
+	responses map[string]string
+}
+
+func (s *fcTestAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := fcTestRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+	}
+	json.NewDecoder(r.Body).Decode(&req.Body)
+
+	s.Lock()
+	s.requests = append(s.requests, req)
+	response, ok := s.responses[r.Method+" "+r.URL.Path]
+	s.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(response))
+}
+
+func (s *fcTestAPIServer) Requests() []fcTestRequest {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]fcTestRequest{}, s.requests...)
+}
+
+// startFCTestAPIServer serves the stub API on the socket of fc.
+func startFCTestAPIServer(t *testing.T, fc *firecracker) *fcTestAPIServer {
+	// Keep the socket path short, unix socket paths are limited to 108 bytes.
+	dir, err := os.MkdirTemp("", "fc")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fc.socketPath = filepath.Join(dir, fcSocket)
+	l, err := net.Listen("unix", fc.socketPath)
+	assert.NoError(t, err)
+
+	s := &fcTestAPIServer{
+		responses: make(map[string]string),
+	}
+	srv := &http.Server{Handler: s}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	return s
+}
+
 func TestFCGenerateSocket(t *testing.T) {
 	assert := assert.New(t)
 
@@ -115,26 +190,113 @@ func TestFCPauseVM(t *testing.T) {
 	assert := assert.New(t)
 
 	fc := firecracker{}
+	api := startFCTestAPIServer(t, &fc)
+
 	ctx := context.Background()
 	err := fc.PauseVM(ctx)
 	assert.NoError(err)
+
+	assert.Equal([]fcTestRequest{
+		{"PATCH", "/vm", map[string]interface{}{"state": "Paused"}},
+	}, api.Requests())
 }
 
 func TestFCSaveVM(t *testing.T) {
 	assert := assert.New(t)
 
+	// Missing snapshot files
 	fc := firecracker{}
 	err := fc.SaveVM()
+	assert.Error(err)
+
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	dir := t.TempDir()
+	fc.jailerRoot = filepath.Join(dir, "root")
+	assert.NoError(os.MkdirAll(fc.jailerRoot, DirMode))
+	fc.config.MemoryPath = filepath.Join(dir, "memory")
+	fc.config.DevicesStatePath = filepath.Join(dir, "state")
+	fc.config.EnableDiffSnapshots = true
+
+	api := startFCTestAPIServer(t, &fc)
+
+	// A full snapshot first, then diff ones on top of it.
+	assert.NoError(fc.SaveVM())
+	assert.NoError(fc.SaveVM())
+
+	requests := api.Requests()
+	assert.Len(requests, 2)
+	for i, snapshotType := range []string{"Full", "Diff"} {
+		assert.Equal("PUT", requests[i].Method)
+		assert.Equal("/snapshot/create", requests[i].Path)
+		assert.Equal(map[string]interface{}{
+			"snapshot_path": filepath.Join(fc.jailerRoot, fcSnapshotState),
+			"mem_file_path": filepath.Join(fc.jailerRoot, fcSnapshotMem),
+			"snapshot_type": snapshotType,
+		}, requests[i].Body)
+	}
+
+	// The snapshot files are only mounted into the jail while in use
+	assert.FileExists(fc.config.MemoryPath)
+	assert.FileExists(fc.config.DevicesStatePath)
+	mounted, err := mountinfo.Mounted(filepath.Join(fc.jailerRoot, fcSnapshotMem))
 	assert.NoError(err)
+	assert.False(mounted)
+}
+
+func TestFCLoadSnapshot(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	fc := firecracker{
+		jailed:     true,
+		jailerRoot: filepath.Join(dir, "root"),
+		fcConfig:   &types.FcConfig{},
+	}
+	assert.NoError(os.MkdirAll(fc.jailerRoot, DirMode))
+
+	fc.config.MemoryPath = filepath.Join(dir, "memory")
+	fc.config.DevicesStatePath = filepath.Join(dir, "state")
+	for _, path := range []string{fc.config.MemoryPath, fc.config.DevicesStatePath} {
+		assert.NoError(os.WriteFile(path, nil, 0600))
+	}
+
+	api := startFCTestAPIServer(t, &fc)
+
+	assert.NoError(fc.fcLoadSnapshot(context.Background()))
+
+	requests := api.Requests()
+	assert.Len(requests, 3)
+	assert.Equal("/logger", requests[0].Path)
+	assert.Equal("/metrics", requests[1].Path)
+	assert.Equal(fcTestRequest{"PUT", "/snapshot/load", map[string]interface{}{
+		"snapshot_path": "/" + fcSnapshotState,
+		"mem_backend": map[string]interface{}{
+			"backend_path": "/" + fcSnapshotMem,
+			"backend_type": "File",
+		},
+	}}, requests[2])
 }
 
 func TestFCResumeVM(t *testing.T) {
 	assert := assert.New(t)
 
 	fc := firecracker{}
+	api := startFCTestAPIServer(t, &fc)
+
 	ctx := context.Background()
 	err := fc.ResumeVM(ctx)
 	assert.NoError(err)
+
+	assert.Equal([]fcTestRequest{
+		{"PATCH", "/vm", map[string]interface{}{"state": "Resumed"}},
+	}, api.Requests())
 }
 
 func TestFCGetVirtioFsPid(t *testing.T) {
@@ -181,9 +343,40 @@ func TestFCCleanup(t *testing.T) {
 func TestFCToGrpc(t *testing.T) {
 	assert := assert.New(t)
 
-	fc := firecracker{}
+	fc := firecracker{
+		id:     "foobar",
+		jailed: true,
+		uid:    "1000",
+		gid:    "1001",
+		info: FirecrackerInfo{
+			Version: "1.4.0",
+			PID:     1234,
+		},
+	}
 	ctx := context.Background()
-	_, err := fc.toGrpc(ctx)
+	j, err := fc.toGrpc(ctx)
+	assert.NoError(err)
+
+	config := HypervisorConfig{
+		HypervisorPath: "/usr/bin/firecracker",
+	}
+
+	var fc2 firecracker
+	err = fc2.fromGrpc(ctx, &config, j)
+	assert.NoError(err)
+
+	assert.Equal(fc.id, fc2.id)
+	assert.Equal(fc.info, fc2.info)
+	assert.True(fc2.jailed)
+	// The VMM keeps the user it was started as, not the one of the
+	// configuration of the runtime taking the VM over.
+	assert.Equal("1000", fc2.uid)
+	assert.Equal("1001", fc2.gid)
+	assert.Equal(config, fc2.config)
+	assert.Equal(vmReady, fc2.state.state)
+	assert.Equal(filepath.Join("/run", "vc", "firecracker", "foobar", "root", "run", fcSocket), fc2.socketPath)
+
+	err = fc2.fromGrpc(ctx, &config, []byte("invalid"))
 	assert.Error(err)
 }
 
@@ -206,6 +399,247 @@ func TestFCGetTotalMemoryMB(t *testing.T) {
 	fc.config.MemorySize = 1024
 	memSize := fc.GetTotalMemoryMB(ctx)
 	assert.Equal(memSize, initialMemSize)
+
+	// Without the memory balloon, the VM is not over-provisioned
+	fc.config.DefaultMaxMemorySize = 4096
+	assert.Equal(initialMemSize, fc.GetTotalMemoryMB(ctx))
+
+	fc.config.EnableMemoryBalloon = true
+	assert.Equal(uint32(4096), fc.GetTotalMemoryMB(ctx))
+
+	fc.balloonMemory = 3072
+	assert.Equal(initialMemSize, fc.GetTotalMemoryMB(ctx))
+}
+
+func TestFCSetBalloon(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		fcConfig: &types.FcConfig{},
+		config: HypervisorConfig{
+			MemorySize:              1024,
+			DefaultMaxMemorySize:    4096,
+			EnableMemoryBalloon:     true,
+			ReclaimGuestFreedMemory: true,
+		},
+	}
+	assert.True(fc.balloonEnabled())
+
+	fc.fcSetBalloon(context.Background())
+	assert.NotNil(fc.fcConfig.Balloon)
+	assert.Equal(int64(3072), *fc.fcConfig.Balloon.AmountMib)
+	assert.True(*fc.fcConfig.Balloon.DeflateOnOom)
+	assert.True(fc.fcConfig.Balloon.FreePageReporting)
+	assert.Equal(int64(fcBalloonStatsInterval), fc.fcConfig.Balloon.StatsPollingIntervals)
+	assert.Equal(uint32(3072), fc.balloonMemory)
+
+	// Only reclaiming free memory: an empty balloon
+	fc.config.EnableMemoryBalloon = false
+	assert.True(fc.balloonEnabled())
+	fc.fcSetBalloon(context.Background())
+	assert.Equal(int64(0), *fc.fcConfig.Balloon.AmountMib)
+
+	fc.config.ReclaimGuestFreedMemory = false
+	assert.False(fc.balloonEnabled())
+}
+
+func TestFCCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		config: HypervisorConfig{
+			ReclaimGuestFreedMemory: true,
+		},
+	}
+
+	// Reclaiming the freed memory alone does not shrink the memory
+	caps := fc.Capabilities(context.Background())
+	assert.True(caps.IsBlockDeviceHotplugSupported())
+	assert.False(caps.IsMemoryShrinkSupported())
+
+	fc.config.EnableMemoryBalloon = true
+	caps = fc.Capabilities(context.Background())
+	assert.True(caps.IsMemoryShrinkSupported())
+}
+
+func TestFCSetMmdsConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		fcConfig: &types.FcConfig{},
+		config: HypervisorConfig{
+			EnableMmds: true,
+		},
+	}
+
+	// MMDS needs a network interface
+	fc.fcSetMmdsConfig(context.Background())
+	assert.Nil(fc.fcConfig.MmdsConfig)
+
+	for _, name := range []string{"eth0", "eth1"} {
+		ifaceID := name
+		fc.fcConfig.NetworkInterfaces = append(fc.fcConfig.NetworkInterfaces, &models.NetworkInterface{IfaceID: &ifaceID})
+	}
+
+	fc.fcSetMmdsConfig(context.Background())
+	assert.NotNil(fc.fcConfig.MmdsConfig)
+	assert.Equal([]string{"eth0", "eth1"}, fc.fcConfig.MmdsConfig.NetworkInterfaces)
+	assert.Equal(fcMmdsVersion, *fc.fcConfig.MmdsConfig.Version)
+	assert.Nil(fc.fcConfig.MmdsConfig.IPV4Address)
+
+	fc.config.MmdsIPv4Address = "169.254.170.2"
+	fc.fcSetMmdsConfig(context.Background())
+	assert.Equal("169.254.170.2", *fc.fcConfig.MmdsConfig.IPV4Address)
+}
+
+func TestFCCheckMmdsVersion(t *testing.T) {
+	assert := assert.New(t)
+	fc := firecracker{}
+
+	assert.Error(fc.checkMmdsVersion("0.25.0"))
+	assert.Error(fc.checkMmdsVersion("v1.0"))
+	assert.NoError(fc.checkMmdsVersion("1.0.0"))
+	assert.NoError(fc.checkMmdsVersion("1.4.1"))
+}
+
+func TestFCUpdateGuestMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	fc := firecracker{
+		fcConfig: &types.FcConfig{},
+	}
+	api := startFCTestAPIServer(t, &fc)
+
+	metadata := map[string]interface{}{
+		"kata": map[string]interface{}{
+			"policy": "package agent_policy",
+		},
+	}
+
+	// MMDS is not configured
+	assert.NoError(fc.updateGuestMetadata(ctx, metadata))
+	assert.Empty(api.Requests())
+
+	fc.fcConfig.MmdsConfig = &models.MmdsConfig{NetworkInterfaces: []string{"eth0"}}
+	assert.NoError(fc.updateGuestMetadata(ctx, metadata))
+	assert.Equal([]fcTestRequest{{"PATCH", "/mmds", metadata}}, api.Requests())
+}
+
+func TestFCResizeMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	fc := firecracker{
+		config: HypervisorConfig{
+			MemorySize:           1024,
+			DefaultMaxMemorySize: 4096,
+		},
+	}
+	api := startFCTestAPIServer(t, &fc)
+
+	// Without the memory balloon the guest memory cannot change
+	mem, _, err := fc.ResizeMemory(ctx, 2048, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(1024), mem)
+	assert.Empty(api.Requests())
+
+	fc.config.EnableMemoryBalloon = true
+	fc.balloonMemory = 3072
+
+	for _, d := range []struct {
+		reqMemMB uint32
+		memMB    uint32
+		balloon  float64
+	}{
+		{2048, 2048, 2048},
+		// Capped to the boot memory
+		{8192, 4096, 0},
+		// Never below the default memory
+		{512, 1024, 3072},
+	} {
+		mem, _, err := fc.ResizeMemory(ctx, d.reqMemMB, 128, false)
+		assert.NoError(err)
+		assert.Equal(d.memMB, mem)
+		assert.Equal(d.memMB, fc.GetTotalMemoryMB(ctx))
+
+		requests := api.Requests()
+		assert.Equal(fcTestRequest{"PATCH", "/balloon", map[string]interface{}{"amount_mib": d.balloon}}, requests[len(requests)-1])
+	}
+
+	// Each balloon resize is reported
+	events := fc.HypervisorEvents()
+	assert.Len(events, 3)
+	event := <-events
+	assert.Equal(HypervisorEventBalloonChange, event.Type)
+	assert.Equal("2048", event.Details["balloon-mib"])
+
+	// Nothing to do when the size does not change
+	count := len(api.Requests())
+	_, _, err = fc.ResizeMemory(ctx, 1024, 128, false)
+	assert.NoError(err)
+	assert.Len(api.Requests(), count)
+
+	state := fc.Save()
+	assert.Equal(3072, state.BalloonMemory)
+
+	var fc2 firecracker
+	fc2.Load(state)
+	assert.Equal(uint32(3072), fc2.balloonMemory)
+}
+
+func TestFCHandleLog(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	fc.handleLog("2026-10-19T10:00:00.000000000 [anonymous-instance:main] Running Firecracker v1.10.1")
+	assert.Empty(fc.HypervisorEvents())
+
+	fc.handleLog("2026-10-19T10:00:01.000000000 [anonymous-instance:main] Firecracker exiting with error. exit_code=1")
+	events := fc.HypervisorEvents()
+	assert.Len(events, 1)
+	event := <-events
+	assert.Equal(HypervisorEventShutdown, event.Type)
+	assert.Equal("1", event.Details["exit-code"])
+}
+
+func TestFCUpdateMetricsEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	fc.updateMetrics(`{"i8042":{"reset_count":0},"block":{"execute_fails":0}}`)
+	assert.Empty(fc.HypervisorEvents())
+
+	fc.updateMetrics(`{"i8042":{"reset_count":1},"block":{"execute_fails":2}}`)
+	events := fc.HypervisorEvents()
+	assert.Len(events, 2)
+	event := <-events
+	assert.Equal(HypervisorEventReset, event.Type)
+	assert.Equal("guest", event.Reason)
+	event = <-events
+	assert.Equal(HypervisorEventBlockIOError, event.Type)
+	assert.Equal("2", event.Details["execute-fails"])
+}
+
+func TestFCUpdateBalloonMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	api := startFCTestAPIServer(t, &fc)
+	api.responses["GET /balloon/statistics"] = `{"target_pages":786432,"actual_pages":786432,"target_mib":3072,"actual_mib":3072,"free_memory":1048576,"total_memory":4294967296}`
+
+	fc.updateBalloonMetrics()
+
+	for item, value := range map[string]float64{
+		"target_mib":   3072,
+		"actual_mib":   3072,
+		"free_memory":  1048576,
+		"total_memory": 4294967296,
+	} {
+		var m dto.Metric
+		assert.NoError(balloonStatsMetrics.WithLabelValues(item).Write(&m))
+		assert.Equal(value, m.GetGauge().GetValue(), item)
+	}
 }
 
 func TestFCClient(t *testing.T) {
@@ -237,6 +671,129 @@ func TestFCCreateJailedDrive(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestFCRootless(t *testing.T) {
+	assert := assert.New(t)
+
+	savedIsRootless := rootless.IsRootless
+	defer func() {
+		rootless.IsRootless = savedIsRootless
+	}()
+	rootless.IsRootless = func() bool { return true }
+
+	config := HypervisorConfig{
+		HypervisorPath: "/some/where/firecracker",
+		Uid:            uint32(os.Getuid()),
+		Gid:            uint32(os.Getgid()),
+		Groups:         []uint32{1234},
+	}
+
+	network, err := NewNetwork()
+	assert.NoError(err)
+
+	fc := firecracker{}
+	err = fc.CreateVM(context.Background(), "rootless", network, &config)
+	assert.NoError(err)
+
+	// The jail lives in the directory of the VMM user
+	assert.True(strings.HasPrefix(fc.vmPath, rootless.GetRootlessDir()))
+	assert.Equal(strconv.Itoa(os.Getuid()), fc.uid)
+	assert.Equal(strconv.Itoa(os.Getgid()), fc.gid)
+
+	// Without jailer, firecracker is started as the VMM user
+	cmd := fc.vmmCommand([]string{"--config-file", "config"})
+	assert.Equal([]string{config.HypervisorPath, "--api-sock", fc.socketPath, "--config-file", "config"}, cmd.Args)
+	assert.NotNil(cmd.SysProcAttr)
+	assert.Equal(config.Uid, cmd.SysProcAttr.Credential.Uid)
+	assert.Equal(config.Gid, cmd.SysProcAttr.Credential.Gid)
+	assert.Equal(config.Groups, cmd.SysProcAttr.Credential.Groups)
+
+	// The jailer drops to the VMM user itself
+	fc.jailed = true
+	fc.config.JailerPath = "/some/where/jailer"
+	cmd = fc.vmmCommand(nil)
+	assert.Equal(fc.config.JailerPath, cmd.Path)
+	assert.Contains(strings.Join(cmd.Args, " "), "--uid "+fc.uid+" --gid "+fc.gid)
+	assert.Nil(cmd.SysProcAttr)
+
+	// The resources created for firecracker are given to the VMM user
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(os.WriteFile(path, nil, 0600))
+	assert.NoError(fc.chownToVMM(path))
+
+	fc.config.Uid = uint32(os.Getuid()) + 1
+	if os.Getuid() != 0 {
+		assert.Error(fc.chownToVMM(path))
+	}
+
+	// Nothing is given away when firecracker runs as root
+	rootless.IsRootless = func() bool { return false }
+	assert.NoError(fc.chownToVMM(path))
+	fc.jailed = false
+	assert.Nil(fc.vmmCommand(nil).SysProcAttr)
+}
+
+func TestFCRootlessInUserNS(t *testing.T) {
+	if !runInUserNS(t, 0) {
+		return
+	}
+
+	assert := assert.New(t)
+	assert.True(rootless.IsRootless())
+
+	// The VMM user is only mapped when the namespace holds a range of ids
+	vmmID := uint32(0)
+	if canSetGroups() {
+		vmmID = 1000
+	}
+
+	dir := t.TempDir()
+	assert.NoError(os.Chmod(dir, 0755))
+	assert.NoError(os.Chmod(filepath.Dir(dir), 0755))
+
+	// The VMM reports the ids it runs with
+	hypervisorPath := filepath.Join(dir, "firecracker")
+	assert.NoError(os.WriteFile(hypervisorPath, []byte("#!/bin/sh\necho $(id -u) $(id -g) $(id -G)\n"), 0755))
+
+	config := HypervisorConfig{
+		HypervisorPath: hypervisorPath,
+		Uid:            vmmID,
+		Gid:            vmmID,
+		Groups:         []uint32{vmmID + 1},
+	}
+
+	network, err := NewNetwork()
+	assert.NoError(err)
+
+	fc := firecracker{}
+	err = fc.CreateVM(context.Background(), "rootless", network, &config)
+	assert.NoError(err)
+
+	// The jail lives in the runtime directory of the user
+	assert.Equal(filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), fs.StoragePathSuffix), fc.chrootBaseDir)
+	assert.True(strings.HasPrefix(fc.vmPath, fc.chrootBaseDir))
+
+	// The resources created for firecracker are given to the VMM user
+	path := filepath.Join(dir, "config")
+	assert.NoError(os.WriteFile(path, nil, 0600))
+	assert.NoError(fc.chownToVMM(path))
+
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(vmmID, info.Sys().(*syscall.Stat_t).Uid)
+	assert.Equal(vmmID, info.Sys().(*syscall.Stat_t).Gid)
+
+	// Without jailer, firecracker is started as the VMM user
+	if canSetGroups() {
+		out, err := fc.vmmCommand(nil).Output()
+		assert.NoError(err)
+		assert.Equal("1000 1000 1000 1001", strings.TrimSpace(string(out)))
+	}
+
+	// Users outside of the namespace cannot be given anything
+	fc.config.Uid = 70000
+	assert.Error(fc.chownToVMM(path))
+}
+
 func TestFcSetConfig(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,232 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	pkgUtils "github.com/kata-containers/kata-containers/src/runtime/pkg/utils"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// guestDumpCoreFile is the name of the ELF core file holding the guest
+	// memory, which can be processed with crash or gdb.
+	guestDumpCoreFile = "vmcore.elf"
+
+	// guestDumpMetadataFile links a dump to the sandbox and the pod it
+	// was taken from.
+	guestDumpMetadataFile = "metadata.json"
+
+	guestDumpTimeFormat = "20060102150405.000"
+
+	guestDumpReasonPanic    = "guest-panic"
+	guestDumpReasonWatchdog = "watchdog"
+	guestDumpReasonRequest  = "request"
+)
+
+var errGuestDumpNotConfigured = errors.New("guest memory dump path is not configured")
+
+// guestDumpMetadata is saved next to each guest memory dump.
+type guestDumpMetadata struct {
+	Time              time.Time `json:"time"`
+	Reason            string    `json:"reason"`
+	SandboxID         string    `json:"sandbox_id"`
+	SandboxName       string    `json:"sandbox_name,omitempty"`
+	SandboxNamespace  string    `json:"sandbox_namespace,omitempty"`
+	HypervisorPath    string    `json:"hypervisor_path"`
+	HypervisorVersion string    `json:"hypervisor_version,omitempty"`
+	CoreFile          string    `json:"core_file"`
+	CoreSize          int64     `json:"core_size"`
+}
+
+// dumpGuest dumps the memory of the guest run by h, with the sandbox state
+// and the hypervisor configuration, to a new directory named after the
+// sandbox and the current time under GuestMemoryDumpPath. The oldest dumps of
+// all the sandboxes are then removed to honour GuestMemoryDumpMaxCount and
+// GuestMemoryDumpMaxSize.
+// It returns the directory of the dump.
+func dumpGuest(ctx context.Context, h Hypervisor, sandboxID, reason string) (string, error) {
+	dumper, ok := h.(guestDumper)
+	if !ok {
+		return "", fmt.Errorf("hypervisor does not support guest memory dumps")
+	}
+
+	conf := h.HypervisorConfig()
+	if conf.GuestMemoryDumpPath == "" {
+		return "", errGuestDumpNotConfigured
+	}
+
+	now := time.Now()
+	dumpDir := filepath.Join(conf.GuestMemoryDumpPath, sandboxID, now.Format(guestDumpTimeFormat))
+	logger := hvLogger.WithFields(logrus.Fields{
+		"sandbox": sandboxID,
+		"reason":  reason,
+		"dir":     dumpDir,
+	})
+	logger.Info("dumping guest memory")
+
+	guestMemory := uint64(h.GetTotalMemoryMB(ctx)) << utils.MibToBytesShift
+	maxSize := uint64(conf.GuestMemoryDumpMaxSize) << utils.MibToBytesShift
+	if maxSize > 0 && guestMemory > maxSize {
+		return "", fmt.Errorf("guest memory dump of %d bytes would exceed the %d bytes limit", guestMemory, maxSize)
+	}
+
+	if err := pkgUtils.EnsureDir(dumpDir, DirMode); err != nil {
+		return "", err
+	}
+
+	if err := checkGuestDumpSpace(dumpDir, guestMemory); err != nil {
+		os.RemoveAll(dumpDir)
+		return "", err
+	}
+
+	version := saveGuestDumpInfo(logger, &conf, sandboxID, dumpDir)
+
+	coreFile := filepath.Join(dumpDir, guestDumpCoreFile)
+	if err := dumper.dumpGuestMemory(ctx, coreFile); err != nil {
+		logger.WithError(err).Error("failed to dump guest memory")
+		os.RemoveAll(dumpDir)
+		return "", err
+	}
+
+	metadata := guestDumpMetadata{
+		Time:              now,
+		Reason:            reason,
+		SandboxID:         sandboxID,
+		SandboxName:       conf.SandboxName,
+		SandboxNamespace:  conf.SandboxNamespace,
+		HypervisorPath:    conf.HypervisorPath,
+		HypervisorVersion: version,
+		CoreFile:          guestDumpCoreFile,
+	}
+	if fi, err := os.Stat(coreFile); err == nil {
+		metadata.CoreSize = fi.Size()
+	}
+
+	data, err := json.MarshalIndent(metadata, "", " ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dumpDir, guestDumpMetadataFile), data, defaultFilePerms); err != nil {
+		return "", err
+	}
+
+	logger.WithField("size", metadata.CoreSize).Info("dump guest memory completed")
+
+	if err := rotateGuestDumps(conf.GuestMemoryDumpPath, conf.GuestMemoryDumpMaxCount, maxSize); err != nil {
+		logger.WithError(err).Warn("failed to rotate guest memory dumps")
+	}
+
+	return dumpDir, nil
+}
+
+// checkGuestDumpSpace checks there is enough free disk space to store a dump
+// of guestMemory bytes. It asks for twice the guest memory size, so that a
+// dump can complete while another sandbox is dumping its own guest.
+func checkGuestDumpSpace(dumpDir string, guestMemory uint64) error {
+	fs := unix.Statfs_t{}
+	if err := unix.Statfs(dumpDir, &fs); err != nil {
+		return fmt.Errorf("failed to get the free space of %s: %w", dumpDir, err)
+	}
+
+	available := fs.Bavail * uint64(fs.Bsize)
+	expected := guestMemory * 2
+	if available >= expected {
+		return nil
+	}
+	return fmt.Errorf("not enough free space to store the guest memory dump: expected %d bytes, but only %d bytes are available", expected, available)
+}
+
+// saveGuestDumpInfo saves the sandbox state, the hypervisor configuration and
+// version next to a dump for debug purpose. It returns the hypervisor version.
+func saveGuestDumpInfo(logger *logrus.Entry, conf *HypervisorConfig, sandboxID, dumpDir string) string {
+	// copy state from /run/vc/sbs to memory dump directory
+	statePath := filepath.Join(conf.RunStorePath, sandboxID)
+	command := []string{"/bin/cp", "-ar", statePath, filepath.Join(dumpDir, "state")}
+	if output, err := pkgUtils.RunCommandFull(command, true); err != nil {
+		logger.WithError(err).WithField("output", output).Error("failed to save state")
+	}
+
+	data, _ := json.MarshalIndent(conf, "", " ")
+	if err := os.WriteFile(filepath.Join(dumpDir, "hypervisor.conf"), data, defaultFilePerms); err != nil {
+		logger.WithError(err).Error("write to hypervisor.conf file failed")
+	}
+
+	version, err := pkgUtils.RunCommand([]string{conf.HypervisorPath, "--version"})
+	if err != nil {
+		logger.WithError(err).WithField("HypervisorPath", conf.HypervisorPath).Error("failed to get hypervisor version")
+	}
+	if err := os.WriteFile(filepath.Join(dumpDir, "hypervisor.version"), []byte(version), defaultFilePerms); err != nil {
+		logger.WithError(err).Error("write to hypervisor.version file failed")
+	}
+
+	return version
+}
+
+// rotateGuestDumps removes the oldest dumps found under dumpPath, from all
+// the sandboxes, until there are at most maxCount of them using at most
+// maxSize bytes. Zero limits are ignored, and the newest dump is always kept.
+func rotateGuestDumps(dumpPath string, maxCount uint32, maxSize uint64) error {
+	if maxCount == 0 && maxSize == 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dumpPath, "*", "*", guestDumpMetadataFile))
+	if err != nil {
+		return err
+	}
+
+	type dump struct {
+		dir string
+		guestDumpMetadata
+	}
+
+	var dumps []dump
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		d := dump{dir: filepath.Dir(f)}
+		if err := json.Unmarshal(data, &d.guestDumpMetadata); err != nil {
+			hvLogger.WithError(err).WithField("file", f).Warn("ignoring invalid guest memory dump metadata")
+			continue
+		}
+		dumps = append(dumps, d)
+	}
+
+	sort.Slice(dumps, func(i, j int) bool {
+		return dumps[i].Time.After(dumps[j].Time)
+	})
+
+	var size uint64
+	for i, d := range dumps {
+		size += uint64(d.CoreSize)
+		if i == 0 || ((maxCount == 0 || i < int(maxCount)) && (maxSize == 0 || size <= maxSize)) {
+			continue
+		}
+
+		hvLogger.WithField("dir", d.dir).Info("removing guest memory dump")
+		if err := os.RemoveAll(d.dir); err != nil {
+			return err
+		}
+		// Remove the sandbox directory once its last dump is gone.
+		os.Remove(filepath.Dir(d.dir))
+	}
+
+	return nil
+}
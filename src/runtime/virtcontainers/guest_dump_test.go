@@ -0,0 +1,138 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockGuestDumper struct {
+	mockHypervisor
+	err error
+}
+
+func (m *mockGuestDumper) dumpGuestMemory(ctx context.Context, coreFile string) error {
+	if m.err != nil {
+		return m.err
+	}
+	return os.WriteFile(coreFile, []byte("vmcore"), 0600)
+}
+
+func TestDumpGuest(t *testing.T) {
+	assert := assert.New(t)
+	dumpPath := t.TempDir()
+
+	h := &mockGuestDumper{}
+	h.config = HypervisorConfig{
+		HypervisorPath:   "/bin/echo",
+		RunStorePath:     t.TempDir(),
+		MemorySize:       1,
+		SandboxName:      "pod",
+		SandboxNamespace: "default",
+	}
+
+	_, err := dumpGuest(context.Background(), h, testSandboxID, guestDumpReasonRequest)
+	assert.Equal(errGuestDumpNotConfigured, err)
+
+	_, err = dumpGuest(context.Background(), &mockHypervisor{config: HypervisorConfig{GuestMemoryDumpPath: dumpPath}}, testSandboxID, guestDumpReasonRequest)
+	assert.Error(err)
+
+	h.config.GuestMemoryDumpPath = dumpPath
+	dir, err := dumpGuest(context.Background(), h, testSandboxID, guestDumpReasonPanic)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(dumpPath, testSandboxID), filepath.Dir(dir))
+	assert.FileExists(filepath.Join(dir, guestDumpCoreFile))
+	assert.FileExists(filepath.Join(dir, "hypervisor.conf"))
+
+	data, err := os.ReadFile(filepath.Join(dir, guestDumpMetadataFile))
+	assert.NoError(err)
+
+	var metadata guestDumpMetadata
+	assert.NoError(json.Unmarshal(data, &metadata))
+	assert.Equal(guestDumpReasonPanic, metadata.Reason)
+	assert.Equal(testSandboxID, metadata.SandboxID)
+	assert.Equal("pod", metadata.SandboxName)
+	assert.Equal("default", metadata.SandboxNamespace)
+	assert.Equal(guestDumpCoreFile, metadata.CoreFile)
+	assert.Equal(int64(len("vmcore")), metadata.CoreSize)
+
+	// A failed dump leaves nothing behind
+	h.err = fmt.Errorf("dump failed")
+	_, err = dumpGuest(context.Background(), h, testSandboxID, guestDumpReasonRequest)
+	assert.Error(err)
+	entries, err := os.ReadDir(filepath.Join(dumpPath, testSandboxID))
+	assert.NoError(err)
+	assert.Len(entries, 1)
+
+	// The guest memory does not fit in the size limit
+	h.err = nil
+	h.config.MemorySize = 2
+	h.config.GuestMemoryDumpMaxSize = 1
+	_, err = dumpGuest(context.Background(), h, testSandboxID, guestDumpReasonRequest)
+	assert.Error(err)
+}
+
+func TestCheckGuestDumpSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkGuestDumpSpace(t.TempDir(), 1))
+	assert.Error(checkGuestDumpSpace(t.TempDir(), math.MaxUint64/2))
+
+	// The free space cannot be checked
+	assert.Error(checkGuestDumpSpace(filepath.Join(t.TempDir(), "missing"), 1))
+}
+
+func TestRotateGuestDumps(t *testing.T) {
+	assert := assert.New(t)
+	dumpPath := t.TempDir()
+
+	now := time.Now()
+	addDump := func(sandboxID string, age time.Duration, size int64) string {
+		dir := filepath.Join(dumpPath, sandboxID, now.Add(-age).Format(guestDumpTimeFormat))
+		assert.NoError(os.MkdirAll(dir, DirMode))
+		data, err := json.Marshal(guestDumpMetadata{
+			Time:      now.Add(-age),
+			SandboxID: sandboxID,
+			CoreSize:  size,
+		})
+		assert.NoError(err)
+		assert.NoError(os.WriteFile(filepath.Join(dir, guestDumpMetadataFile), data, 0600))
+		return dir
+	}
+
+	newest := addDump("sandbox1", 0, 100)
+	second := addDump("sandbox2", time.Minute, 100)
+	third := addDump("sandbox2", 2*time.Minute, 100)
+	oldest := addDump("sandbox3", 3*time.Minute, 100)
+
+	// No limits
+	assert.NoError(rotateGuestDumps(dumpPath, 0, 0))
+	assert.DirExists(oldest)
+
+	assert.NoError(rotateGuestDumps(dumpPath, 3, 0))
+	assert.NoDirExists(oldest)
+	assert.NoDirExists(filepath.Dir(oldest))
+	assert.DirExists(third)
+
+	assert.NoError(rotateGuestDumps(dumpPath, 0, 250))
+	assert.NoDirExists(third)
+	assert.DirExists(second)
+	assert.DirExists(newest)
+
+	// The newest dump is kept even when over the limits
+	assert.NoError(rotateGuestDumps(dumpPath, 1, 50))
+	assert.NoDirExists(filepath.Dir(second))
+	assert.DirExists(newest)
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// Guest hook stages supported by the agent.
+const (
+	GuestHookPrestart  = "prestart"
+	GuestHookPoststart = "poststart"
+	GuestHookPoststop  = "poststop"
+)
+
+// guestHookVersion is the only supported version of the oci-hooks(5) JSON
+// format.
+const guestHookVersion = "1.0.0"
+
+// guestHooksDir is the directory, in the sandbox shared directory or in the
+// guest sandbox directory, the guest hooks are shipped to.
+const guestHooksDir = "guest-hooks"
+
+// guestHooksManifest is the file listing the shipped hooks with their
+// digest, read by the agent from the guest hook directory.
+const guestHooksManifest = "hooks.json"
+
+var guestHookStages = []string{GuestHookPrestart, GuestHookPoststart, GuestHookPoststop}
+
+// GuestHookWhen is the condition of a guest hook. Guest hooks apply to all
+// the containers of the sandbox, so only the "always" condition is
+// supported.
+type GuestHookWhen struct {
+	Always *bool `json:"always,omitempty"`
+}
+
+// GuestHook is a hook run by the agent in the guest, described with the
+// oci-hooks(5) JSON format. The path of the hook is the host path of its
+// binary, shipped to the guest when the sandbox starts.
+type GuestHook struct {
+	Version string         `json:"version"`
+	Hook    specs.Hook     `json:"hook"`
+	When    *GuestHookWhen `json:"when,omitempty"`
+	Stages  []string       `json:"stages"`
+
+	// Digest is the "<algorithm>:<hex>" digest the hook binary must
+	// match. The binary is verified against it on the host before being
+	// shipped, and by the agent before being run.
+	Digest string `json:"digest,omitempty"`
+}
+
+func (h GuestHook) validate() error {
+	if h.Version != guestHookVersion {
+		return fmt.Errorf("unsupported version %q, expected %q", h.Version, guestHookVersion)
+	}
+
+	if !filepath.IsAbs(h.Hook.Path) {
+		return fmt.Errorf("hook path %q is not absolute", h.Hook.Path)
+	}
+
+	if h.When != nil && (h.When.Always == nil || !*h.When.Always) {
+		return errors.New("only the always condition is supported")
+	}
+
+	if len(h.Stages) == 0 {
+		return errors.New("no stages")
+	}
+	for _, stage := range h.Stages {
+		if !slices.Contains(guestHookStages, stage) {
+			return fmt.Errorf("unsupported stage %q", stage)
+		}
+	}
+
+	if h.Digest != "" {
+		if _, _, err := types.ParseAssetDigest(h.Digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeGuestHooks(r io.Reader, hooks any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(hooks)
+}
+
+// ParseGuestHooks parses a JSON list of guest hooks.
+func ParseGuestHooks(data []byte) ([]GuestHook, error) {
+	var hooks []GuestHook
+	if err := decodeGuestHooks(bytes.NewReader(data), &hooks); err != nil {
+		return nil, err
+	}
+
+	for i, hook := range hooks {
+		if err := hook.validate(); err != nil {
+			return nil, fmt.Errorf("guest hook %d: %w", i, err)
+		}
+	}
+
+	return hooks, nil
+}
+
+// LoadGuestHooks loads the guest hooks described by the JSON files of dir,
+// in lexicographical order of the file names.
+func LoadGuestHooks(dir string) ([]GuestHook, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var hooks []GuestHook
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var hook GuestHook
+		if err := decodeGuestHooks(bytes.NewReader(data), &hook); err != nil {
+			return nil, fmt.Errorf("guest hook %s: %w", file, err)
+		}
+		if err := hook.validate(); err != nil {
+			return nil, fmt.Errorf("guest hook %s: %w", file, err)
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// guestHookEntry is a hook of the guest hooks manifest.
+type guestHookEntry struct {
+	specs.Hook
+	Digest string `json:"digest"`
+}
+
+// guestHookManifest is the format of the guest hooks manifest.
+type guestHookManifest struct {
+	Prestart  []guestHookEntry `json:"prestart,omitempty"`
+	Poststart []guestHookEntry `json:"poststart,omitempty"`
+	Poststop  []guestHookEntry `json:"poststop,omitempty"`
+}
+
+func (m *guestHookManifest) add(stage string, entry guestHookEntry) {
+	switch stage {
+	case GuestHookPrestart:
+		m.Prestart = append(m.Prestart, entry)
+	case GuestHookPoststart:
+		m.Poststart = append(m.Poststart, entry)
+	case GuestHookPoststop:
+		m.Poststop = append(m.Poststop, entry)
+	}
+}
+
+// guestHookFile is a file to ship to the guest hook directory.
+type guestHookFile struct {
+	// path is relative to the guest hook directory.
+	path string
+	data []byte
+	mode os.FileMode
+}
+
+// prepareGuestHooks reads the binaries of the hooks, verifies them against
+// their digest, and returns the files to ship to the guest hook directory
+// guestDir, manifest included. Each binary is read once, so that what is
+// shipped is what was verified.
+func prepareGuestHooks(hooks []GuestHook, guestDir string) ([]guestHookFile, error) {
+	var files []guestHookFile
+	var manifest guestHookManifest
+
+	for i, hook := range hooks {
+		info, err := os.Stat(hook.Hook.Path)
+		if err != nil {
+			return nil, fmt.Errorf("guest hook %s: %w", hook.Hook.Path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("guest hook %s is not a regular file", hook.Hook.Path)
+		}
+
+		data, err := os.ReadFile(hook.Hook.Path)
+		if err != nil {
+			return nil, fmt.Errorf("guest hook %s: %w", hook.Hook.Path, err)
+		}
+
+		algorithm := types.SHA256
+		expected := ""
+		if hook.Digest != "" {
+			var value string
+			if algorithm, value, err = types.ParseAssetDigest(hook.Digest); err != nil {
+				return nil, err
+			}
+			expected = algorithm + ":" + value
+		}
+
+		digest, err := types.ComputeDigest(data, algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if expected != "" && digest != expected {
+			return nil, fmt.Errorf("guest hook %s: digest mismatch: expected %s, got %s", hook.Hook.Path, expected, digest)
+		}
+
+		// The same binary can be used by several hooks, each one gets
+		// its own copy.
+		path := filepath.Join("bin", fmt.Sprintf("%02d-%s", i, filepath.Base(hook.Hook.Path)))
+		files = append(files, guestHookFile{
+			path: path,
+			data: data,
+			mode: 0755,
+		})
+
+		entry := guestHookEntry{
+			Hook: specs.Hook{
+				Path:    filepath.Join(guestDir, path),
+				Args:    hook.Hook.Args,
+				Env:     hook.Hook.Env,
+				Timeout: hook.Hook.Timeout,
+			},
+			Digest: digest,
+		}
+		if len(entry.Args) == 0 {
+			entry.Args = []string{entry.Path}
+		}
+
+		for _, stage := range hook.Stages {
+			manifest.add(stage, entry)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(files, guestHookFile{
+		path: guestHooksManifest,
+		data: data,
+		mode: 0644,
+	}), nil
+}
+
+// setupGuestHooks ships the guest hooks of the sandbox to the guest, over
+// the shared filesystem when there is one or with the agent otherwise, and
+// returns the guest directory the agent must look the hooks up in.
+func (k *kataAgent) setupGuestHooks(ctx context.Context, sandbox *Sandbox) (string, error) {
+	hConfig := sandbox.config.HypervisorConfig
+	if len(hConfig.GuestHooks) == 0 {
+		return hConfig.GuestHookPath, nil
+	}
+
+	if hConfig.GuestHookPath != "" {
+		return "", errors.New("guest hooks cannot be shipped to the guest along guest_hook_path")
+	}
+
+	if hConfig.SharedFS != config.NoSharedFS {
+		guestDir := filepath.Join(kataGuestSharedDir(), guestHooksDir)
+		files, err := prepareGuestHooks(hConfig.GuestHooks, guestDir)
+		if err != nil {
+			return "", err
+		}
+
+		hostDir := filepath.Join(getMountPath(sandbox.id), guestHooksDir)
+		for _, f := range files {
+			path := filepath.Join(hostDir, f.path)
+			if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+				return "", err
+			}
+			if err := os.WriteFile(path, f.data, f.mode); err != nil {
+				return "", err
+			}
+			// WriteFile honours the umask.
+			if err := os.Chmod(path, f.mode); err != nil {
+				return "", err
+			}
+		}
+
+		return guestDir, nil
+	}
+
+	guestDir := filepath.Join(kataGuestSandboxDir(), guestHooksDir)
+	files, err := prepareGuestHooks(hConfig.GuestHooks, guestDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		req := &grpc.CopyFileRequest{
+			Path:     filepath.Join(guestDir, f.path),
+			DirMode:  uint32(DirMode),
+			FileMode: unix.S_IFREG | uint32(f.mode),
+		}
+		if err := k.copyFileData(ctx, req, f.data); err != nil {
+			return "", err
+		}
+	}
+
+	return guestDir, nil
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/mock"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGuestHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	hooks, err := ParseGuestHooks([]byte(`[
+		{"version": "1.0.0", "hook": {"path": "/usr/bin/hook", "args": ["hook", "start"]}, "stages": ["prestart", "poststop"]},
+		{"version": "1.0.0", "hook": {"path": "/usr/bin/other"}, "when": {"always": true}, "stages": ["poststart"], "digest": "sha256:` + strings.Repeat("ab", 32) + `"}
+	]`))
+	assert.NoError(err)
+	assert.Len(hooks, 2)
+	assert.Equal([]string{"hook", "start"}, hooks[0].Hook.Args)
+	assert.Equal([]string{GuestHookPrestart, GuestHookPoststop}, hooks[0].Stages)
+	assert.Equal("sha256:"+strings.Repeat("ab", 32), hooks[1].Digest)
+
+	for _, invalid := range []string{
+		`{"version": "1.0.0"}`,
+		`[{"version": "2.0.0", "hook": {"path": "/usr/bin/hook"}, "stages": ["prestart"]}]`,
+		`[{"version": "1.0.0", "hook": {"path": "hook"}, "stages": ["prestart"]}]`,
+		`[{"version": "1.0.0", "hook": {"path": "/usr/bin/hook"}, "stages": []}]`,
+		`[{"version": "1.0.0", "hook": {"path": "/usr/bin/hook"}, "stages": ["createRuntime"]}]`,
+		`[{"version": "1.0.0", "hook": {"path": "/usr/bin/hook"}, "when": {"always": false}, "stages": ["prestart"]}]`,
+		`[{"version": "1.0.0", "hook": {"path": "/usr/bin/hook"}, "when": {"commands": ["sh"]}, "stages": ["prestart"]}]`,
+		`[{"version": "1.0.0", "hook": {"path": "/usr/bin/hook"}, "stages": ["prestart"], "digest": "sha256:1234"}]`,
+	} {
+		_, err := ParseGuestHooks([]byte(invalid))
+		assert.Error(err, "hooks %s", invalid)
+	}
+}
+
+func TestLoadGuestHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	hooks, err := LoadGuestHooks(dir)
+	assert.NoError(err)
+	assert.Empty(hooks)
+
+	for name, path := range map[string]string{
+		"10-second.json": "/usr/bin/second",
+		"00-first.json":  "/usr/bin/first",
+	} {
+		hook := `{"version": "1.0.0", "hook": {"path": "` + path + `"}, "stages": ["prestart"]}`
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), []byte(hook), 0644))
+	}
+	// Only the JSON files are loaded
+	assert.NoError(os.WriteFile(filepath.Join(dir, "README"), []byte("guest hooks"), 0644))
+
+	hooks, err = LoadGuestHooks(dir)
+	assert.NoError(err)
+	assert.Len(hooks, 2)
+	assert.Equal("/usr/bin/first", hooks[0].Hook.Path)
+	assert.Equal("/usr/bin/second", hooks[1].Hook.Path)
+
+	assert.NoError(os.WriteFile(filepath.Join(dir, "20-invalid.json"), []byte(`{"version": "1.0.0", "unknown": true}`), 0644))
+	_, err = LoadGuestHooks(dir)
+	assert.Error(err)
+}
+
+func TestPrepareGuestHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	content := []byte("#!/bin/sh\n")
+	path := filepath.Join(dir, "hook")
+	assert.NoError(os.WriteFile(path, content, 0700))
+
+	digest, err := types.ComputeDigest(content, types.SHA256)
+	assert.NoError(err)
+
+	timeout := 5
+	hooks := []GuestHook{
+		{
+			Version: guestHookVersion,
+			Hook:    specs.Hook{Path: path},
+			Stages:  []string{GuestHookPrestart, GuestHookPoststop},
+		},
+		{
+			Version: guestHookVersion,
+			Hook: specs.Hook{
+				Path:    path,
+				Args:    []string{"hook", "start"},
+				Env:     []string{"DEBUG=1"},
+				Timeout: &timeout,
+			},
+			Stages: []string{GuestHookPoststart},
+			Digest: types.SHA256 + ":" + strings.ToUpper(strings.TrimPrefix(digest, types.SHA256+":")),
+		},
+	}
+
+	guestDir := "/run/kata-containers/sandbox/guest-hooks"
+	files, err := prepareGuestHooks(hooks, guestDir)
+	assert.NoError(err)
+	assert.Len(files, 3)
+
+	assert.Equal("bin/00-hook", files[0].path)
+	assert.Equal(content, files[0].data)
+	assert.Equal(os.FileMode(0755), files[0].mode)
+	assert.Equal("bin/01-hook", files[1].path)
+	assert.Equal(guestHooksManifest, files[2].path)
+
+	var manifest guestHookManifest
+	assert.NoError(json.Unmarshal(files[2].data, &manifest))
+	assert.Equal([]guestHookEntry{
+		{
+			Hook: specs.Hook{
+				Path: guestDir + "/bin/00-hook",
+				Args: []string{guestDir + "/bin/00-hook"},
+			},
+			Digest: digest,
+		},
+	}, manifest.Prestart)
+	assert.Equal(manifest.Prestart, manifest.Poststop)
+	assert.Equal([]guestHookEntry{
+		{
+			Hook: specs.Hook{
+				Path:    guestDir + "/bin/01-hook",
+				Args:    []string{"hook", "start"},
+				Env:     []string{"DEBUG=1"},
+				Timeout: &timeout,
+			},
+			Digest: digest,
+		},
+	}, manifest.Poststart)
+
+	// The binary does not match its digest
+	hooks[1].Digest = "sha256:" + strings.Repeat("ab", 32)
+	_, err = prepareGuestHooks(hooks, guestDir)
+	assert.Error(err)
+
+	// The binary is not a regular file
+	hooks = []GuestHook{{Version: guestHookVersion, Hook: specs.Hook{Path: dir}, Stages: []string{GuestHookPrestart}}}
+	_, err = prepareGuestHooks(hooks, guestDir)
+	assert.Error(err)
+}
+
+func TestKataAgentSetupGuestHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	kataHostSharedDirSaved := kataHostSharedDir
+	hostSharedDir := t.TempDir()
+	kataHostSharedDir = func() string {
+		return hostSharedDir
+	}
+	defer func() {
+		kataHostSharedDir = kataHostSharedDirSaved
+	}()
+
+	url, err := mock.GenerateKataMockHybridVSock()
+	assert.NoError(err)
+	defer mock.RemoveKataMockHybridVSock(url)
+
+	hybridVSockTTRPCMock := mock.HybridVSockTTRPCMock{}
+	assert.NoError(hybridVSockTTRPCMock.Start(url))
+	defer hybridVSockTTRPCMock.Stop()
+
+	k := &kataAgent{
+		ctx: context.Background(),
+		state: KataAgentState{
+			URL: url,
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "hook")
+	assert.NoError(os.WriteFile(path, []byte("#!/bin/sh\n"), 0700))
+
+	sandbox := &Sandbox{
+		id: "sandbox",
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				GuestHookPath: "/usr/share/oci/hooks",
+			},
+		},
+	}
+
+	// Hooks in the guest image only
+	dir, err := k.setupGuestHooks(context.Background(), sandbox)
+	assert.NoError(err)
+	assert.Equal("/usr/share/oci/hooks", dir)
+
+	// Hooks in the guest image cannot be used along shipped hooks
+	sandbox.config.HypervisorConfig.GuestHooks = []GuestHook{
+		{
+			Version: guestHookVersion,
+			Hook:    specs.Hook{Path: path},
+			Stages:  []string{GuestHookPrestart},
+		},
+	}
+	_, err = k.setupGuestHooks(context.Background(), sandbox)
+	assert.Error(err)
+
+	// Shipped over the shared filesystem
+	sandbox.config.HypervisorConfig.GuestHookPath = ""
+	sandbox.config.HypervisorConfig.SharedFS = config.VirtioFS
+	dir, err = k.setupGuestHooks(context.Background(), sandbox)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(kataGuestSharedDir(), guestHooksDir), dir)
+
+	hostDir := filepath.Join(getMountPath(sandbox.id), guestHooksDir)
+	info, err := os.Stat(filepath.Join(hostDir, "bin", "00-hook"))
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0755), info.Mode().Perm())
+	assert.FileExists(filepath.Join(hostDir, guestHooksManifest))
+
+	// Copied by the agent
+	sandbox.config.HypervisorConfig.SharedFS = config.NoSharedFS
+	dir, err = k.setupGuestHooks(context.Background(), sandbox)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(kataGuestSandboxDir(), guestHooksDir), dir)
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The diagnostic data of the agent listing the processes of a container.
+const diagnosticProcessList = "process_list"
+
+// GuestProcess describes a process of a container running in the guest.
+type GuestProcess struct {
+	// The exec ID of the process when it was started by the runtime,
+	// empty for the processes they forked.
+	ExecID string   `json:"exec_id,omitempty"`
+	State  string   `json:"state"`
+	Cmd    []string `json:"cmd"`
+	// Time spent in user and kernel mode, in nanoseconds.
+	CPUTime uint64 `json:"cpu_time_ns"`
+	// Resident set size, in bytes.
+	RSS uint64 `json:"rss_bytes"`
+	// Time the process started after the guest booted, in nanoseconds.
+	StartTime uint64 `json:"start_time_ns"`
+	Threads   int64  `json:"threads"`
+	Pid       int    `json:"pid"`
+	PPid      int    `json:"ppid"`
+	UID       uint32 `json:"uid"`
+}
+
+// parseGuestProcesses decodes the process list reported by the agent.
+func parseGuestProcesses(data string) ([]GuestProcess, error) {
+	processes := []GuestProcess{}
+	if data == "" {
+		return processes, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &processes); err != nil {
+		return nil, fmt.Errorf("failed to decode the guest process list: %w", err)
+	}
+
+	return processes, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGuestProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	processes, err := parseGuestProcesses(`[
+		{"exec_id":"ctr","state":"S","cmd":["sh","-c","sleep 100"],"cpu_time_ns":20000000,"rss_bytes":1048576,"start_time_ns":5000000000,"threads":1,"pid":42,"ppid":1,"uid":0},
+		{"state":"S","cmd":["sleep","100"],"threads":1,"pid":43,"ppid":42,"uid":1000}
+	]`)
+	assert.NoError(err)
+	assert.Equal([]GuestProcess{
+		{
+			ExecID:    "ctr",
+			State:     "S",
+			Cmd:       []string{"sh", "-c", "sleep 100"},
+			CPUTime:   20000000,
+			RSS:       1048576,
+			StartTime: 5000000000,
+			Threads:   1,
+			Pid:       42,
+			PPid:      1,
+		},
+		{
+			State:   "S",
+			Cmd:     []string{"sleep", "100"},
+			Threads: 1,
+			Pid:     43,
+			PPid:    42,
+			UID:     1000,
+		},
+	}, processes)
+
+	processes, err = parseGuestProcesses("")
+	assert.NoError(err)
+	assert.Empty(processes)
+
+	_, err = parseGuestProcesses("termination message")
+	assert.Error(err)
+}
+
+func TestSandboxListProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		agent:      &mockAgent{},
+		containers: map[string]*Container{"ctr": {id: "ctr"}},
+	}
+
+	processes, err := s.ListProcesses(context.Background(), "ctr")
+	assert.NoError(err)
+	assert.Empty(processes)
+
+	_, err = s.ListProcesses(context.Background(), "unknown")
+	assert.Error(err)
+}
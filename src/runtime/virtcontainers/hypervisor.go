@@ -358,6 +358,10 @@ const (
 	// HybridVirtioVsockDev is a hybrid virtio-vsock device supported
 	// only on certain hypervisors, like firecracker.
 	HybridVirtioVsockDev
+
+	// VhostVDPADev is a vDPA device given through its vhost-vdpa
+	// character device.
+	VhostVDPADev
 )
 
 type MemoryDevice struct {
@@ -506,6 +510,18 @@ type HypervisorConfig struct {
 	// JailerPath is the jailer executable host path.
 	JailerPath string
 
+	// AssetDigests maps an asset type to the "<algorithm>:<hex>" digest
+	// its configured path must match before the VM is created.
+	AssetDigests map[types.AssetType]string
+
+	// AssetManifest is the host path of a signed manifest listing the
+	// digest of every asset the sandbox may use.
+	AssetManifest string
+
+	// AssetManifestPublicKey is the host path of the PEM encoded ed25519
+	// public key used to verify the signature of AssetManifest.
+	AssetManifestPublicKey string
+
 	// BlockDeviceDriver specifies the driver to be used for block device
 	// either VirtioSCSI or VirtioBlock with the default driver being defaultBlockDriver
 	BlockDeviceDriver string
@@ -551,9 +567,26 @@ type HypervisorConfig struct {
 	// GuestCoredumpPath is the path in host for saving guest memory dump
 	GuestMemoryDumpPath string
 
+	// ConsoleLogPath is the host directory the guest console of the
+	// sandboxes is captured to, in a directory per sandbox. The guest
+	// console is not captured when it is empty.
+	ConsoleLogPath string
+
+	// VMSnapshotPath is the host directory VM snapshots are saved to and
+	// restored from. Snapshots cannot be taken when it is empty.
+	VMSnapshotPath string
+
 	// GuestHookPath is the path within the VM that will be used for 'drop-in' hooks
 	GuestHookPath string
 
+	// GuestHooks are the hooks shipped from the host to the guest when
+	// the sandbox starts. They cannot be used along GuestHookPath.
+	GuestHooks []GuestHook
+
+	// GuestHooksPathList is the list of host paths the binaries of the
+	// guest hooks required from annotations may be taken from.
+	GuestHooksPathList []string
+
 	// VMid is the id of the VM that create the hypervisor if the VM is created by the factory.
 	// VMid is "" if the hypervisor is not created by the factory.
 	VMid string
@@ -768,6 +801,24 @@ type HypervisorConfig struct {
 	// Independent IOThreads enables IO to be processed in a separate thread.
 	IndepIOThreads uint32
 
+	// GuestMemoryDumpMaxCount is the number of guest memory dumps kept
+	// under GuestMemoryDumpPath, from all the sandboxes rather than per
+	// sandbox. Zero means no limit.
+	GuestMemoryDumpMaxCount uint32
+
+	// GuestMemoryDumpMaxSize is the size in MiB of the guest memory dumps
+	// kept under GuestMemoryDumpPath, from all the sandboxes rather than
+	// per sandbox. Zero means no limit.
+	GuestMemoryDumpMaxSize uint32
+
+	// ConsoleLogMaxSize is the size in MiB the sandbox console log is
+	// rotated at. Zero means DefaultConsoleLogMaxSize.
+	ConsoleLogMaxSize uint32
+
+	// ConsoleLogMaxFiles is the number of rotated console log files kept
+	// along the current one. Zero means DefaultConsoleLogMaxFiles.
+	ConsoleLogMaxFiles uint32
+
 	// Debug changes the default hypervisor and kernel parameters to
 	// enable debug output where available.
 	Debug bool
@@ -782,6 +833,11 @@ type HypervisorConfig struct {
 	// ReclaimGuestFreedMemory is a sandbox annotation that specifies whether the memory freed by the guest will be reclaimed by the hypervisor or not.
 	ReclaimGuestFreedMemory bool
 
+	// BalloonShrink specifies whether shrinking the memory of the sandbox
+	// inflates the balloon to return the memory to the host. It is set when
+	// the sandbox rightsizing is enabled.
+	BalloonShrink bool
+
 	// HugePages specifies if the memory should be pre-allocated from huge pages
 	HugePages bool
 
@@ -831,6 +887,30 @@ type HypervisorConfig struct {
 	// BootFromTemplate used to indicate if the VM should be created from a template VM
 	BootFromTemplate bool
 
+	// EnableDiffSnapshots makes the hypervisor track dirty guest pages so that
+	// a VM snapshot taken after a first full one only writes the pages that
+	// changed since. Only supported by Firecracker.
+	EnableDiffSnapshots bool
+
+	// EnableMemoryBalloon boots the VM with DefaultMaxMemorySize and a balloon
+	// holding back the memory above MemorySize. The guest memory is then
+	// resized by inflating or deflating the balloon. Only supported by
+	// Firecracker and StratoVirt, which cannot hotplug memory.
+	EnableMemoryBalloon bool
+
+	// EnableMmds publishes the sandbox metadata to the guest through the
+	// Firecracker microVM metadata service (MMDS v2), on the VM network
+	// interfaces. Only supported by Firecracker.
+	EnableMmds bool
+
+	// MmdsIPv4Address is the link-local address the guest reaches the MMDS
+	// at. Firecracker defaults to 169.254.169.254 when empty.
+	MmdsIPv4Address string
+
+	// MmdsMetadata is the list of the sandbox metadata fields published
+	// through the MMDS, among GuestMetadataFields.
+	MmdsMetadata []string
+
 	// DisableVhostNet is used to indicate if host supports vhost_net
 	DisableVhostNet bool
 
@@ -840,6 +920,10 @@ type HypervisorConfig struct {
 	// GuestSwap Used to enable/disable swap in the guest
 	GuestSwap bool
 
+	// EnableVMMigration enables moving the VM to another hypervisor process,
+	// and saving it to or restoring it from a snapshot.
+	EnableVMMigration bool
+
 	// Rootless is used to enable rootless VMM process
 	Rootless bool
 
@@ -1058,6 +1142,20 @@ func (conf *HypervisorConfig) IfPVPanicEnabled() bool {
 	return conf.GuestMemoryDumpPath != ""
 }
 
+// ConsoleLogEnabled returns true if the guest console is captured to the
+// sandbox console log. The console of confidential guests is only exposed
+// to the host in debug mode.
+func (conf *HypervisorConfig) ConsoleLogEnabled() bool {
+	return conf.ConsoleLogPath != "" && (conf.Debug || !conf.ConfidentialGuest)
+}
+
+// GuestConsoleEnabled returns true if the hypervisor must provide the guest
+// with a console the host reads, either to capture it to the sandbox
+// console log or to relay it to the runtime logs in debug mode.
+func (conf *HypervisorConfig) GuestConsoleEnabled() bool {
+	return conf.Debug || conf.ConsoleLogEnabled()
+}
+
 // CustomHypervisorAsset returns true if the hypervisor asset is a custom one, false otherwise.
 func (conf *HypervisorConfig) CustomHypervisorAsset() bool {
 	return conf.isCustomAsset(types.HypervisorAsset)
@@ -1295,6 +1393,55 @@ func AvailableGuestProtections() (protections []string) {
 	return []string{gp.String()}
 }
 
+// guestMetadataPublisher is implemented by the hypervisors able to expose the
+// sandbox metadata to the guest without an extra device, like the Firecracker
+// microVM metadata service.
+type guestMetadataPublisher interface {
+	// setGuestMetadata sets the metadata the VM boots with.
+	setGuestMetadata(metadata map[string]interface{})
+
+	// updateGuestMetadata merges metadata into the one of the running VM.
+	updateGuestMetadata(ctx context.Context, metadata map[string]interface{}) error
+}
+
+// hypervisorMetricsUpdater is implemented by the hypervisors publishing
+// metrics which are polled when the sandbox metrics are requested.
+type hypervisorMetricsUpdater interface {
+	updateHypervisorMetrics(ctx context.Context) error
+}
+
+// vmMigrator is implemented by the hypervisors able to move the VM to another
+// VMM process, and to snapshot it to a directory to restore it later. The
+// hypervisor state returned by Save() follows the VMM running the VM.
+type vmMigrator interface {
+	// migrateVM moves the VM to a new VMM process of the same host.
+	migrateVM(ctx context.Context) error
+
+	// snapshotVM saves the VM to dir.
+	snapshotVM(ctx context.Context, dir string) error
+
+	// restoreVMSnapshot replaces the VM with the one saved to dir.
+	restoreVMSnapshot(ctx context.Context, dir string) error
+}
+
+// guestDumper is implemented by the hypervisors able to write the guest
+// memory to an ELF core file on the host, see dumpGuest().
+type guestDumper interface {
+	dumpGuestMemory(ctx context.Context, coreFile string) error
+}
+
+// diskResizer is implemented by the hypervisors able to resize the block
+// device of a running VM, so that the guest sees the new capacity of the disk.
+type diskResizer interface {
+	resizeDisk(ctx context.Context, drive *config.BlockDrive, size uint64) error
+}
+
+// hypervisorEventsPublisher is implemented by the hypervisors reporting the
+// lifecycle events of the VM, like a guest panic or a shutdown.
+type hypervisorEventsPublisher interface {
+	HypervisorEvents() <-chan HypervisorEvent
+}
+
 // hypervisor is the virtcontainers hypervisor interface.
 // The default hypervisor implementation is Qemu.
 type Hypervisor interface {
@@ -0,0 +1,83 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HypervisorEventType is the type of a lifecycle event of the VM reported by
+// the hypervisor.
+type HypervisorEventType string
+
+const (
+	// The guest kernel panicked.
+	HypervisorEventGuestPanic HypervisorEventType = "guest-panic"
+	// The guest watchdog expired.
+	HypervisorEventWatchdog HypervisorEventType = "watchdog"
+	// The VM was reset.
+	HypervisorEventReset HypervisorEventType = "reset"
+	// The VM was shut down, the reason tells by whom.
+	HypervisorEventShutdown HypervisorEventType = "shutdown"
+	// The memory balloon changed the guest memory size.
+	HypervisorEventBalloonChange HypervisorEventType = "balloon-change"
+	// The removal of a device was completed by the guest.
+	HypervisorEventDeviceDeleted HypervisorEventType = "device-deleted"
+	// An I/O error happened on a block device.
+	HypervisorEventBlockIOError HypervisorEventType = "block-io-error"
+)
+
+// Number of events buffered for the consumer of HypervisorEvents.
+const hypervisorEventsBufferSize = 32
+
+// HypervisorEvent is a lifecycle event of the VM reported by the hypervisor.
+type HypervisorEvent struct {
+	Timestamp time.Time
+	Details   map[string]string
+	Type      HypervisorEventType
+	Reason    string
+}
+
+// hypervisorEventStream buffers the events reported by a hypervisor until
+// they are consumed. The events are dropped when nobody consumes them.
+type hypervisorEventStream struct {
+	once   sync.Once
+	events chan HypervisorEvent
+}
+
+// channel returns the events buffered for their consumer.
+func (h *hypervisorEventStream) channel() chan HypervisorEvent {
+	h.once.Do(func() {
+		h.events = make(chan HypervisorEvent, hypervisorEventsBufferSize)
+	})
+
+	return h.events
+}
+
+// publish logs the event and queues it for the consumer of the events.
+func (h *hypervisorEventStream) publish(logger *logrus.Entry, event HypervisorEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	fields := logrus.Fields{
+		"event-type": event.Type,
+		"reason":     event.Reason,
+	}
+	for k, v := range event.Details {
+		fields[k] = v
+	}
+	logger.WithFields(fields).Info("hypervisor event")
+
+	select {
+	case h.channel() <- event:
+	default:
+		logger.WithField("event-type", event.Type).Warn("hypervisor events buffer full, dropping event")
+	}
+}
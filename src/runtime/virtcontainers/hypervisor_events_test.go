@@ -0,0 +1,63 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHypervisorEventStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var stream hypervisorEventStream
+	logger := virtLog.WithField("subsystem", "test")
+
+	stream.publish(logger, HypervisorEvent{Type: HypervisorEventReset, Reason: "guest"})
+	timestamp := time.Now().Add(-time.Minute)
+	stream.publish(logger, HypervisorEvent{Type: HypervisorEventShutdown, Timestamp: timestamp})
+
+	events := stream.channel()
+	assert.Len(events, 2)
+
+	event := <-events
+	assert.Equal(HypervisorEventReset, event.Type)
+	assert.Equal("guest", event.Reason)
+	assert.False(event.Timestamp.IsZero())
+
+	event = <-events
+	assert.Equal(HypervisorEventShutdown, event.Type)
+	assert.Equal(timestamp, event.Timestamp)
+
+	// The events are dropped when the buffer is full
+	for i := 0; i < hypervisorEventsBufferSize+1; i++ {
+		stream.publish(logger, HypervisorEvent{Type: HypervisorEventWatchdog})
+	}
+	assert.Len(events, hypervisorEventsBufferSize)
+}
+
+type mockHypervisorEventsPublisher struct {
+	mockHypervisor
+	events hypervisorEventStream
+}
+
+func (m *mockHypervisorEventsPublisher) HypervisorEvents() <-chan HypervisorEvent {
+	return m.events.channel()
+}
+
+func TestSandboxHypervisorEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{hypervisor: &mockHypervisor{}}
+	assert.Nil(s.HypervisorEvents())
+
+	h := &mockHypervisorEventsPublisher{}
+	s.hypervisor = h
+	h.events.publish(virtLog, HypervisorEvent{Type: HypervisorEventGuestPanic})
+	assert.Len(s.HypervisorEvents(), 1)
+}
@@ -700,3 +700,29 @@ func TestKernelParamFields(t *testing.T) {
 		assert.Equal(params, t.expectedKernelParamFieldsResult, "Unexpected KernelParamFields behavior")
 	}
 }
+
+func TestHypervisorConfigConsoleLogEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	// The console log is disabled by default
+	conf := HypervisorConfig{}
+	assert.False(conf.ConsoleLogEnabled())
+	assert.False(conf.GuestConsoleEnabled())
+
+	conf.Debug = true
+	assert.False(conf.ConsoleLogEnabled())
+	assert.True(conf.GuestConsoleEnabled())
+
+	conf = HypervisorConfig{ConsoleLogPath: "/var/log/kata/console"}
+	assert.True(conf.ConsoleLogEnabled())
+	assert.True(conf.GuestConsoleEnabled())
+
+	// The console of confidential guests is only exposed in debug mode
+	conf.ConfidentialGuest = true
+	assert.False(conf.ConsoleLogEnabled())
+	assert.False(conf.GuestConsoleEnabled())
+
+	conf.Debug = true
+	assert.True(conf.ConsoleLogEnabled())
+	assert.True(conf.GuestConsoleEnabled())
+}
@@ -71,6 +71,8 @@ type VCSandbox interface {
 	ListRoutes(ctx context.Context) ([]*pbTypes.Route, error)
 
 	GetOOMEvent(ctx context.Context) (string, error)
+	RightsizingEvents() <-chan RightsizingDecision
+	HypervisorEvents() <-chan HypervisorEvent
 	GetHypervisorPid() (int, error)
 	// RescanNetwork re-scans the network namespace for late-discovered endpoints.
 	RescanNetwork(ctx context.Context) error
@@ -80,11 +82,18 @@ type VCSandbox interface {
 	GetAgentURL() (string, error)
 
 	GuestVolumeStats(ctx context.Context, volumePath string) ([]byte, error)
+	ListProcesses(ctx context.Context, containerID string) ([]GuestProcess, error)
+	ConsoleLog(ctx context.Context) ([]byte, error)
 	ResizeGuestVolume(ctx context.Context, volumePath string, size uint64) error
 
 	GetIPTables(ctx context.Context, isIPv6 bool) ([]byte, error)
 	SetIPTables(ctx context.Context, isIPv6 bool, data []byte) error
 	SetPolicy(ctx context.Context, policy string) error
+
+	MigrateVM(ctx context.Context) error
+	SnapshotVM(ctx context.Context, dir string) error
+	RestoreVM(ctx context.Context, dir string) error
+	DumpGuest(ctx context.Context) (string, error)
 }
 
 // VCContainer is the Container interface
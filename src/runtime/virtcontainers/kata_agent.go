@@ -870,13 +870,18 @@ func (k *kataAgent) startSandbox(ctx context.Context, sandbox *Sandbox) error {
 
 	storages := setupStorages(ctx, sandbox)
 
+	guestHookPath, err := k.setupGuestHooks(ctx, sandbox)
+	if err != nil {
+		return err
+	}
+
 	req := &grpc.CreateSandboxRequest{
 		Hostname:      hostname,
 		Dns:           dns,
 		Storages:      storages,
 		SandboxPidns:  sandbox.sharePidNs,
 		SandboxId:     sandbox.id,
-		GuestHookPath: sandbox.config.HypervisorConfig.GuestHookPath,
+		GuestHookPath: guestHookPath,
 		KernelModules: kmodules,
 	}
 
@@ -1242,6 +1247,29 @@ func (k *kataAgent) appendVhostUserBlkDevice(dev ContainerDevice, device api.Dev
 	return kataDevice
 }
 
+func (k *kataAgent) appendVhostVDPADevice(dev ContainerDevice, device api.Device, c *Container) *grpc.Device {
+	d, ok := device.GetDeviceInfo().(*config.VhostVDPADeviceAttrs)
+	if !ok || d == nil {
+		k.Logger().WithField("device", device).Error("malformed vhost-vdpa device")
+		return nil
+	}
+
+	// Only vDPA block devices show up as a device node in the guest,
+	// vDPA network devices are given to the sandbox through an endpoint.
+	if d.VirtioID != config.VirtioBlockID {
+		k.Logger().WithField("device", device).WithField("virtio-id", d.VirtioID).Warn("ignoring non block vhost-vdpa container device")
+		return nil
+	}
+
+	kataDevice := &grpc.Device{
+		ContainerPath: dev.ContainerPath,
+		Type:          kataBlkDevType,
+		Id:            d.PCIPath.String(),
+	}
+
+	return kataDevice
+}
+
 func (k *kataAgent) appendVfioDevice(dev ContainerDevice, device api.Device, c *Container) *grpc.Device {
 	devList, ok := device.GetDeviceInfo().([]*config.VFIODev)
 	if !ok || devList == nil {
@@ -1340,6 +1368,8 @@ func (k *kataAgent) appendDevices(deviceList []*grpc.Device, c *Container) []*gr
 			kataDevice = k.appendBlockDevice(dev, device, c)
 		case config.VhostUserBlk:
 			kataDevice = k.appendVhostUserBlkDevice(dev, device, c)
+		case config.DeviceVhostVDPA:
+			kataDevice = k.appendVhostVDPADevice(dev, device, c)
 		case config.DeviceVFIO:
 			kataDevice = k.appendVfioDevice(dev, device, c)
 		}
@@ -2805,7 +2835,6 @@ func (k *kataAgent) copyFile(ctx context.Context, src, dst string) error {
 		if err != nil {
 			return fmt.Errorf("Could not read file %s: %v", src, err)
 		}
-		cpReq.FileSize = int64(len(b))
 
 	case unix.S_IFDIR:
 
@@ -2825,6 +2854,14 @@ func (k *kataAgent) copyFile(ctx context.Context, src, dst string) error {
 		"dest":   dst,
 	}).Debugf("Copying file from host to guest")
 
+	return k.copyFileData(ctx, cpReq, b)
+}
+
+// copyFileData copies the regular file content b, or the file described by
+// cpReq when b is empty, to the guest.
+func (k *kataAgent) copyFileData(ctx context.Context, cpReq *grpc.CopyFileRequest, b []byte) error {
+	cpReq.FileSize = int64(len(b))
+
 	// Handle the special case where the file is empty
 	if cpReq.FileSize == 0 {
 		_, err := k.sendReq(ctx, cpReq)
@@ -2846,7 +2883,7 @@ func (k *kataAgent) copyFile(ctx context.Context, src, dst string) error {
 		cpReq.Data = b[:bytesToCopy]
 		cpReq.Offset = offset
 
-		if _, err = k.sendReq(ctx, cpReq); err != nil {
+		if _, err := k.sendReq(ctx, cpReq); err != nil {
 			if err.Error() == context.DeadlineExceeded.Error() {
 				return grpcStatus.Errorf(codes.DeadlineExceeded, "CopyFileRequest timed out")
 			}
@@ -32,6 +32,7 @@ import (
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/katautils/katatrace"
 	persistapi "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/api"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/rootless"
 	vctypes "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
 )
@@ -110,6 +111,8 @@ func LoadNetwork(netInfo persistapi.NetworkInfo) Network {
 			ep = &TapEndpoint{}
 		case IPVlanEndpointType:
 			ep = &IPVlanEndpoint{}
+		case VdpaEndpointType:
+			ep = &VdpaEndpoint{}
 		default:
 			networkLogger().WithField("endpoint-type", e.Type).Error("unknown endpoint type")
 			continue
@@ -139,7 +142,19 @@ func (n *LinuxNetwork) addSingleEndpoint(ctx context.Context, s *Sandbox, netInf
 		return nil, err
 	}
 
+	var vdpaDevPath string
 	if isPhysical {
+		// A vDPA device created on top of the interface PCI function is
+		// given to the guest instead of the whole function.
+		if vdpaDevPath, err = findVdpaIfaceDevice(netInfo.Iface.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if vdpaDevPath != "" {
+		networkLogger().WithField("interface", netInfo.Iface.Name).WithField("device", vdpaDevPath).Info("vDPA network interface found")
+		endpoint, err = createVdpaEndpoint(netInfo.Iface.Name, netInfo.Iface.HardwareAddr.String(), vdpaDevPath)
+	} else if isPhysical {
 		if s.config.HypervisorConfig.ColdPlugVFIO == config.NoPort {
 			// When `cold_plug_vfio` is set to "no-port", the PhysicalEndpoint's VFIO device cannot be attached to the guest VM.
 			// Fail early to prevent the VF interface from being unbound and rebound to the VFIO driver.
@@ -565,7 +580,7 @@ func convertDanDeviceToNetworkInfo(device *vctypes.DanDevice) (*NetworkInfo, err
 
 // Load network config in DAN config
 // Create the endpoints for the interfaces in Dan.
-func (n *LinuxNetwork) addDanEndpoints() error {
+func (n *LinuxNetwork) addDanEndpoints(ctx context.Context, s *Sandbox, hotplug bool) error {
 	if len(n.eps) > 0 {
 		// only load DAN config once
 		return nil
@@ -597,6 +612,11 @@ func (n *LinuxNetwork) addDanEndpoints() error {
 			if err != nil {
 				return err
 			}
+		case vctypes.VdpaDanDeviceType:
+			endpoint, err = n.addDanVdpaEndpoint(ctx, s, &device, netInfo, hotplug)
+			if err != nil {
+				return err
+			}
 		default:
 			return fmt.Errorf("unknown DAN device type: '%s'", device.Device.Type)
 		}
@@ -611,6 +631,29 @@ func (n *LinuxNetwork) addDanEndpoints() error {
 	return nil
 }
 
+// addDanVdpaEndpoint creates and attaches the vDPA endpoint of a DAN device.
+// Unlike VFIO endpoints, vDPA endpoints are plugged along with the sandbox.
+func (n *LinuxNetwork) addDanVdpaEndpoint(ctx context.Context, s *Sandbox, device *vctypes.DanDevice, netInfo *NetworkInfo, hotplug bool) (Endpoint, error) {
+	endpoint, err := createVdpaEndpoint(device.Name, device.GuestMac, device.Device.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint.SetProperties(*netInfo)
+
+	networkLogger().WithField("endpoint-type", endpoint.Type()).WithField("hotplug", hotplug).Info("Attaching endpoint")
+	if hotplug {
+		err = endpoint.HotAttach(ctx, s)
+	} else {
+		err = endpoint.Attach(ctx, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
 // Run runs a callback in the specified network namespace.
 func (n *LinuxNetwork) Run(ctx context.Context, cb func() error) error {
 	span, _ := n.trace(ctx, "Run")
@@ -630,7 +673,7 @@ func (n *LinuxNetwork) AddEndpoints(ctx context.Context, s *Sandbox, endpointsIn
 	if endpointsInfo == nil {
 		// If a sandbox has a DAN configuration, it takes priority and will be used exclusively.
 		if n.danConfigPath != "" {
-			if err := n.addDanEndpoints(); err != nil {
+			if err := n.addDanEndpoints(ctx, s, hotplug); err != nil {
 				return nil, err
 			}
 		} else {
@@ -746,12 +789,17 @@ func createLink(netHandle *netlink.Handle, name string, expectedLink netlink.Lin
 			// https://github.com/kata-containers/kata-containers/blob/e6e5d2593ac319329269d7b58c30f99ba7b2bf5a/src/runtime/vendor/github.com/vishvananda/netlink/link_linux.go#L1164-L1316
 			queues = 1
 		}
-		newLink = &netlink.Tuntap{
+		tap := &netlink.Tuntap{
 			LinkAttrs: netlink.LinkAttrs{Name: name},
 			Mode:      netlink.TUNTAP_MODE_TAP,
 			Queues:    queues,
 			Flags:     flags,
 		}
+		if expected, ok := expectedLink.(*netlink.Tuntap); ok {
+			tap.Owner = expected.Owner
+			tap.Group = expected.Group
+		}
+		newLink = tap
 	case (&netlink.Macvtap{}).Type():
 		qlen := expectedLink.Attrs().TxQLen
 		if qlen <= 0 {
@@ -863,6 +911,7 @@ func xConnectVMNetwork(ctx context.Context, endpoint Endpoint, h Hypervisor) err
 	}
 
 	disableVhostNet := h.HypervisorConfig().DisableVhostNet
+	tap := vmmTuntap(h)
 
 	if netPair.NetInterworkingModel == NetXConnectDefaultModel {
 		netPair.NetInterworkingModel = DefaultNetInterworkingModel
@@ -874,13 +923,26 @@ func xConnectVMNetwork(ctx context.Context, endpoint Endpoint, h Hypervisor) err
 		err = tapNetworkPair(ctx, endpoint, queues, disableVhostNet)
 	case NetXConnectTCFilterModel:
 		networkLogger().Info("connect TCFilter to VM network")
-		err = setupTCFiltering(ctx, endpoint, queues, disableVhostNet)
+		err = setupTCFiltering(ctx, endpoint, tap, queues, disableVhostNet)
 	default:
 		err = fmt.Errorf("Invalid internetworking model")
 	}
 	return err
 }
 
+// vmmTuntap returns the tap device to create for the VMM of h. An unprivileged
+// VMM owns its tap devices, so that it can attach to them by name, as
+// firecracker does, rather than only through the fds opened by the runtime.
+func vmmTuntap(h Hypervisor) *netlink.Tuntap {
+	tap := &netlink.Tuntap{}
+	if rootless.IsRootless() {
+		config := h.HypervisorConfig()
+		tap.Owner = config.Uid
+		tap.Group = config.Gid
+	}
+	return tap
+}
+
 // The endpoint type should dictate how the disconnection needs to happen.
 func xDisconnectVMNetwork(ctx context.Context, endpoint Endpoint) error {
 	var err error
@@ -1081,7 +1143,7 @@ func tapNetworkPair(ctx context.Context, endpoint Endpoint, queues int, disableV
 	return nil
 }
 
-func setupTCFiltering(ctx context.Context, endpoint Endpoint, queues int, disableVhostNet bool) error {
+func setupTCFiltering(ctx context.Context, endpoint Endpoint, tap *netlink.Tuntap, queues int, disableVhostNet bool) error {
 	span, _ := networkTrace(ctx, "setupTCFiltering", endpoint)
 	defer span.End()
 
@@ -1093,7 +1155,7 @@ func setupTCFiltering(ctx context.Context, endpoint Endpoint, queues int, disabl
 
 	netPair := endpoint.NetworkPair()
 
-	tapLink, fds, err := createLink(netHandle, netPair.TAPIface.Name, &netlink.Tuntap{}, queues)
+	tapLink, fds, err := createLink(netHandle, netPair.TAPIface.Name, tap, queues)
 	if err != nil {
 		return fmt.Errorf("Could not create TAP interface: %s", err)
 	}
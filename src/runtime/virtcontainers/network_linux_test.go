@@ -11,13 +11,18 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"strings"
+	"syscall"
 	"testing"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	ktu "github.com/kata-containers/kata-containers/src/runtime/pkg/katatestutils"
+	persistapi "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/api"
 	pbTypes "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/rootless"
 	vctypes "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
 	"github.com/stretchr/testify/assert"
@@ -147,6 +152,75 @@ func TestCreateGetTunTapLink(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestVMMTuntap(t *testing.T) {
+	assert := assert.New(t)
+
+	savedIsRootless := rootless.IsRootless
+	defer func() {
+		rootless.IsRootless = savedIsRootless
+	}()
+
+	h := &mockHypervisor{
+		config: HypervisorConfig{
+			Uid: 1000,
+			Gid: 1001,
+		},
+	}
+
+	rootless.IsRootless = func() bool { return false }
+	assert.Equal(&netlink.Tuntap{}, vmmTuntap(h))
+
+	// An unprivileged VMM owns its tap devices
+	rootless.IsRootless = func() bool { return true }
+	tap := vmmTuntap(h)
+	assert.Equal(uint32(1000), tap.Owner)
+	assert.Equal(uint32(1001), tap.Group)
+}
+
+func TestVMMTuntapInUserNS(t *testing.T) {
+	if !runInUserNS(t, syscall.CLONE_NEWNET) {
+		return
+	}
+
+	assert := assert.New(t)
+	assert.True(rootless.IsRootless())
+
+	// The VMM user is only mapped when the namespace holds a range of ids
+	vmmID := uint32(0)
+	if canSetGroups() {
+		vmmID = 1000
+	}
+
+	h := &mockHypervisor{
+		config: HypervisorConfig{
+			Uid: vmmID,
+			Gid: vmmID + 1,
+		},
+	}
+
+	netHandle, err := netlink.NewHandle()
+	assert.NoError(err)
+	defer netHandle.Close()
+
+	tapName := "testtap0"
+	_, fds, err := createLink(netHandle, tapName, vmmTuntap(h), 0)
+	if err != nil && strings.Contains(err.Error(), "permission denied") {
+		t.Skipf("tap devices cannot be created: %v", err)
+	}
+	assert.NoError(err)
+	for _, f := range fds {
+		f.Close()
+	}
+
+	// The tap device is owned by the VMM user
+	link, err := netHandle.LinkByName(tapName)
+	assert.NoError(err)
+	tap, ok := link.(*netlink.Tuntap)
+	assert.True(ok)
+	assert.Equal(vmmID, tap.Owner)
+	assert.Equal(vmmID+1, tap.Group)
+}
+
 func TestCreateMacVtap(t *testing.T) {
 	if tc.NotValid(ktu.NeedRoot()) {
 		t.Skip(testDisabledAsNonRoot)
@@ -219,7 +293,7 @@ func TestTcRedirectNetwork(t *testing.T) {
 	err = netHandle.LinkSetUp(link)
 	assert.NoError(err)
 
-	err = setupTCFiltering(context.Background(), endpoint, 1, true)
+	err = setupTCFiltering(context.Background(), endpoint, &netlink.Tuntap{}, 1, true)
 	assert.NoError(err)
 
 	err = removeTCFiltering(context.Background(), endpoint)
@@ -257,7 +331,7 @@ func TestRxRateLimiter(t *testing.T) {
 	err = netHandle.LinkSetUp(link)
 	assert.NoError(err)
 
-	err = setupTCFiltering(context.Background(), endpoint, 1, true)
+	err = setupTCFiltering(context.Background(), endpoint, &netlink.Tuntap{}, 1, true)
 	assert.NoError(err)
 
 	// 10Mb
@@ -306,7 +380,7 @@ func TestTxRateLimiter(t *testing.T) {
 	err = netHandle.LinkSetUp(link)
 	assert.NoError(err)
 
-	err = setupTCFiltering(context.Background(), endpoint, 1, true)
+	err = setupTCFiltering(context.Background(), endpoint, &netlink.Tuntap{}, 1, true)
 	assert.NoError(err)
 
 	// 10Mb
@@ -382,6 +456,72 @@ func TestAddEndpoints_Dan(t *testing.T) {
 	assert.Equal(t, ep.PciPath().String(), "")
 }
 
+func TestAddEndpoints_DanVdpa(t *testing.T) {
+	assert := assert.New(t)
+
+	savedFunc := config.GetVhostVDPADeviceInfoFunc
+	defer func() {
+		config.GetVhostVDPADeviceInfoFunc = savedFunc
+	}()
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return config.VirtioNetID, 2, nil
+	}
+
+	network := &LinuxNetwork{
+		netNSPath:         "net-123",
+		eps:               []Endpoint{},
+		interworkingModel: NetXConnectDefaultModel,
+		netNSCreated:      true,
+		danConfigPath:     "testdata/dan-config-vdpa.json",
+	}
+
+	s := &Sandbox{
+		hypervisor: &mockHypervisor{},
+	}
+
+	eps, err := network.AddEndpoints(context.Background(), s, nil, false)
+	assert.NoError(err)
+	assert.Len(eps, 1)
+
+	ep, ok := eps[0].(*VdpaEndpoint)
+	assert.True(ok)
+	assert.Equal("eth0", ep.Name())
+	assert.Equal("0a:58:0a:0a:00:06", ep.HardwareAddr())
+	assert.Equal("/dev/vhost-vdpa-0", ep.VhostDevPath)
+	assert.Equal(uint32(2), ep.NumQueues)
+	assert.NotEmpty(ep.DevID)
+	assert.Len(ep.Properties().Addrs, 1)
+
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return config.VirtioBlockID, 1, nil
+	}
+	network.eps = nil
+	_, err = network.AddEndpoints(context.Background(), s, nil, false)
+	assert.Error(err)
+}
+
+func TestLoadNetworkVdpa(t *testing.T) {
+	assert := assert.New(t)
+
+	ep := &VdpaEndpoint{
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		HardAddr:     "0a:58:0a:0a:00:06",
+		IfaceName:    "eth0",
+		DevID:        "vdpa0",
+		EndpointType: VdpaEndpointType,
+	}
+	ep.PCIPath, _ = vctypes.PciPathFromString("02/01")
+
+	network := LoadNetwork(persistapi.NetworkInfo{
+		NetworkID: "net-123",
+		Endpoints: []persistapi.NetworkEndpoint{ep.save()},
+	})
+
+	eps := network.Endpoints()
+	assert.Len(eps, 1)
+	assert.Equal(ep, eps[0])
+}
+
 func TestValidGuestNeighbor(t *testing.T) {
 	assert := assert.New(t)
 
@@ -83,6 +83,14 @@ type VfioEndpoint struct {
 	IfaceName string
 }
 
+type VdpaEndpoint struct {
+	IfaceName    string
+	HardAddr     string
+	VhostDevPath string
+	DevID        string
+	PCIPath      vcTypes.PciPath
+}
+
 // NetworkEndpoint contains network interface information
 type NetworkEndpoint struct {
 	// One and only one of these below are not nil according to Type.
@@ -95,6 +103,7 @@ type NetworkEndpoint struct {
 	IPVlan    *IPVlanEndpoint    `json:",omitempty"`
 	Tuntap    *TuntapEndpoint    `json:",omitempty"`
 	Vfio      *VfioEndpoint      `json:",omitempty"`
+	Vdpa      *VdpaEndpoint      `json:",omitempty"`
 
 	Type string
 }
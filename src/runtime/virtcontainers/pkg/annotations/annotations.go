@@ -22,6 +22,10 @@ const (
 	ContainerTypeKey = kataAnnotationsPrefix + "pkg.oci.container_type"
 
 	SandboxConfigPathKey = kataAnnotationsPrefix + "config_path"
+
+	// SandboxConfigProfileKey is the annotation key selecting one of the
+	// configuration profiles defined in the configuration file.
+	SandboxConfigProfileKey = kataAnnotationsPrefix + "config_profile"
 )
 
 // Annotations related to Hypervisor configuration
@@ -113,6 +117,10 @@ const (
 	// GuestHookPath is a sandbox annotation to specify the path within the VM that will be used for 'drop-in' hooks.
 	GuestHookPath = kataAnnotHypervisorPrefix + "guest_hook_path"
 
+	// GuestHooks is a sandbox annotation to specify, as a JSON list in the oci-hooks(5) format, hooks
+	// shipped from the host to the guest. The hook binaries must have a digest and a valid path.
+	GuestHooks = kataAnnotHypervisorPrefix + "guest_hooks"
+
 	// DisableImageNvdimm is a sandbox annotation to specify use of nvdimm device for guest rootfs image.
 	DisableImageNvdimm = kataAnnotHypervisorPrefix + "disable_image_nvdimm"
 
@@ -263,7 +271,7 @@ const (
 	// EnableGuestSwap is a sandbox annotation to enable swap in the guest.
 	EnableGuestSwap = kataAnnotHypervisorPrefix + "enable_guest_swap"
 
-	// EnableRootlessHypervisor is a sandbox annotation to enable rootless hypervisor (only supported in QEMU currently).
+	// EnableRootlessHypervisor is a sandbox annotation to enable rootless hypervisor (supported by QEMU, cloud-hypervisor and firecracker).
 	EnableRootlessHypervisor = kataAnnotHypervisorPrefix + "rootless"
 
 	// Initdata is the initdata passed in when CreateVM
@@ -283,6 +291,14 @@ const (
 	// SandboxCgroupOnly is a sandbox annotation that determines if kata processes are managed only in sandbox cgroup.
 	SandboxCgroupOnly = kataAnnotRuntimePrefix + "sandbox_cgroup_only"
 
+	// PodOverheadCPU is a sandbox annotation that sets the number of CPUs the kata overhead processes can use
+	// on the host, usually the CPU of the pod overhead. It applies to the overhead sub-cgroup of the sandbox cgroup.
+	PodOverheadCPU = kataAnnotRuntimePrefix + "pod_overhead_cpu"
+
+	// PodOverheadMemory is a sandbox annotation that sets the memory, in MiB, the kata overhead processes can use
+	// on the host, usually the memory of the pod overhead. It applies to the overhead sub-cgroup of the sandbox cgroup.
+	PodOverheadMemory = kataAnnotRuntimePrefix + "pod_overhead_memory"
+
 	// EnableVCPUsPinning is a sandbox annotation that controls bundling between vCPU threads and CPUs
 	EnableVCPUsPinning = kataAnnotationsPrefix + "enable_vcpus_pinning"
 
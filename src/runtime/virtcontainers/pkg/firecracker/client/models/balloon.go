@@ -27,6 +27,9 @@ type Balloon struct {
 	// Required: true
 	DeflateOnOom *bool `json:"deflate_on_oom"`
 
+	// Whether the guest reports free pages to the host, which reclaims them.
+	FreePageReporting bool `json:"free_page_reporting,omitempty"`
+
 	// Interval in seconds between refreshing statistics. A non-zero value will enable the statistics. Defaults to 0.
 	StatsPollingIntervals int64 `json:"stats_polling_interval_s,omitempty"`
 }
@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package rootless
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/moby/sys/userns"
+	"github.com/stretchr/testify/assert"
+)
+
+// userNSTestEnv is set when the test binary runs in a user namespace.
+const userNSTestEnv = "KATA_ROOTLESS_TEST_USERNS"
+
+// TestIsRootlessInUserNS runs the detection as the root user of a user
+// namespace, so that the rootless mode is tested without being root.
+func TestIsRootlessInUserNS(t *testing.T) {
+	assert := assert.New(t)
+
+	if os.Getenv(userNSTestEnv) != "" {
+		isRootless = nil
+		defer func() {
+			isRootless = nil
+		}()
+
+		assert.Equal(0, os.Geteuid())
+		assert.True(userns.RunningInUserNS())
+		assert.True(isRootlessFunc())
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestIsRootlessInUserNS$", "-test.count=1")
+	cmd.Env = append(os.Environ(), userNSTestEnv+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+	}
+
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Skipf("user namespaces are not available: %v", err)
+	}
+	assert.NoError(err, string(out))
+}
@@ -220,6 +220,16 @@ func (s *Sandbox) GetOOMEvent(ctx context.Context) (string, error) {
 	return "", nil
 }
 
+// RightsizingEvents implements the VCSandbox function of the same name.
+func (s *Sandbox) RightsizingEvents() <-chan vc.RightsizingDecision {
+	return nil
+}
+
+// HypervisorEvents implements the VCSandbox function of the same name.
+func (s *Sandbox) HypervisorEvents() <-chan vc.HypervisorEvent {
+	return nil
+}
+
 // UpdateRuntimeMetrics implements the VCSandbox function of the same name.
 func (s *Sandbox) UpdateRuntimeMetrics() error {
 	if s.UpdateRuntimeMetricsFunc != nil {
@@ -262,6 +272,15 @@ func (s *Sandbox) RescanNetwork(ctx context.Context) error {
 func (s *Sandbox) GuestVolumeStats(ctx context.Context, path string) ([]byte, error) {
 	return nil, nil
 }
+
+// ListProcesses implements the VCSandbox function of the same name.
+func (s *Sandbox) ListProcesses(ctx context.Context, contID string) ([]vc.GuestProcess, error) {
+	if s.ListProcessesFunc != nil {
+		return s.ListProcessesFunc(contID)
+	}
+	return nil, nil
+}
+
 func (s *Sandbox) ResizeGuestVolume(ctx context.Context, path string, size uint64) error {
 	return nil
 }
@@ -277,3 +296,43 @@ func (s *Sandbox) SetIPTables(ctx context.Context, isIPv6 bool, data []byte) err
 func (s *Sandbox) SetPolicy(ctx context.Context, policy string) error {
 	return nil
 }
+
+// MigrateVM implements the VCSandbox function of the same name.
+func (s *Sandbox) MigrateVM(ctx context.Context) error {
+	if s.MigrateVMFunc != nil {
+		return s.MigrateVMFunc()
+	}
+	return nil
+}
+
+// SnapshotVM implements the VCSandbox function of the same name.
+func (s *Sandbox) SnapshotVM(ctx context.Context, dir string) error {
+	if s.SnapshotVMFunc != nil {
+		return s.SnapshotVMFunc(dir)
+	}
+	return nil
+}
+
+// RestoreVM implements the VCSandbox function of the same name.
+func (s *Sandbox) RestoreVM(ctx context.Context, dir string) error {
+	if s.RestoreVMFunc != nil {
+		return s.RestoreVMFunc(dir)
+	}
+	return nil
+}
+
+// DumpGuest implements the VCSandbox function of the same name.
+func (s *Sandbox) DumpGuest(ctx context.Context) (string, error) {
+	if s.DumpGuestFunc != nil {
+		return s.DumpGuestFunc()
+	}
+	return "", nil
+}
+
+// ConsoleLog implements the VCSandbox function of the same name.
+func (s *Sandbox) ConsoleLog(ctx context.Context) ([]byte, error) {
+	if s.ConsoleLogFunc != nil {
+		return s.ConsoleLogFunc()
+	}
+	return nil, nil
+}
@@ -67,7 +67,13 @@ type Sandbox struct {
 	UpdateRuntimeMetricsFunc func() error
 	GetAgentMetricsFunc      func() (string, error)
 	StatsFunc                func() (vc.SandboxStats, error)
+	ListProcessesFunc        func(contID string) ([]vc.GuestProcess, error)
 	GetAgentURLFunc          func() (string, error)
+	MigrateVMFunc            func() error
+	SnapshotVMFunc           func(dir string) error
+	RestoreVMFunc            func(dir string) error
+	DumpGuestFunc            func() (string, error)
+	ConsoleLogFunc           func() ([]byte, error)
 }
 
 // Container is a fake Container type used for testing
@@ -83,6 +83,7 @@ type QemuState struct {
 	Bridges           []types.Bridge
 	HotpluggedVCPUs   []hv.CPUDevice
 	HotpluggedMemory  int
+	BalloonMemory     int
 	VirtiofsDaemonPid int
 	HotplugVFIO       config.PCIePort
 	ColdPlugVFIO      config.PCIePort
@@ -121,6 +122,8 @@ type qemu struct {
 	stopped int32
 
 	mu sync.Mutex
+
+	events hypervisorEventStream
 }
 
 const (
@@ -224,7 +227,15 @@ func (q *qemu) Capabilities(ctx context.Context) types.Capabilities {
 	span, _ := katatrace.Trace(ctx, q.Logger(), "Capabilities", qemuTracingTags, map[string]string{"sandbox_id": q.id})
 	defer span.End()
 
-	return q.arch.capabilities(q.config)
+	caps := q.arch.capabilities(q.config)
+
+	// Hot unplugging memory is not supported, guest memory is returned
+	// to the host with virtio-mem or the balloon.
+	if q.config.VirtioMem || (q.balloonEnabled() && q.config.BalloonShrink) {
+		caps.SetMemoryShrinkSupport()
+	}
+
+	return caps
 }
 
 func (q *qemu) HypervisorConfig() HypervisorConfig {
@@ -640,27 +651,9 @@ func (q *qemu) buildNUMATopology() ([]govmmQemu.NUMANode, []govmmQemu.NUMADist,
 	}
 
 	// Distribute memory proportionally to vCPU counts, aligned to memAlign.
-	memPerNode := make([]uint64, numNodes)
-	var memAssigned uint64
-	for i := uint32(0); i < numNodes; i++ {
-		raw := memMb * uint64(vcpusPerNode[i]) / uint64(maxVCPUs)
-		memPerNode[i] = (raw / memAlign) * memAlign
-		if memPerNode[i] == 0 {
-			memPerNode[i] = memAlign
-		}
-		memAssigned += memPerNode[i]
-	}
-	// Give the remainder to the last node (must also be aligned).
-	if memAssigned < memMb {
-		remainder := memMb - memAssigned
-		if remainder%memAlign != 0 {
-			return nil, nil, fmt.Errorf("MemorySize (%d MiB) cannot be evenly distributed across %d NUMA nodes with %d MiB alignment",
-				memMb, numNodes, memAlign)
-		}
-		memPerNode[numNodes-1] += remainder
-	} else if memAssigned > memMb {
-		return nil, nil, fmt.Errorf("MemorySize (%d MiB) cannot be evenly distributed across %d NUMA nodes with %d MiB alignment",
-			memMb, numNodes, memAlign)
+	memPerNode, err := utils.DistributeMemoryProportionally(vcpusPerNode, memMb, memAlign)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var nodes []govmmQemu.NUMANode
@@ -1639,10 +1632,13 @@ func (q *qemu) StartVM(ctx context.Context, timeout int) error {
 	}
 
 	if q.config.VirtioMem {
-		err = q.setupVirtioMem(ctx)
+		if err = q.setupVirtioMem(ctx); err != nil {
+			return err
+		}
 	}
 
-	return err
+	// Keep a QMP connection open to observe the lifecycle events of the VM.
+	return q.qmpSetup()
 }
 
 func (q *qemu) bootFromTemplate() error {
@@ -1931,124 +1927,98 @@ func (q *qemu) qmpSetup() error {
 func (q *qemu) loopQMPEvent(event chan govmmQemu.QMPEvent) {
 	for e := range event {
 		q.Logger().WithField("event", e).Debug("got QMP event")
-		if e.Name == "GUEST_PANICKED" {
-			go q.handleGuestPanic()
+		if hypervisorEvent, ok := qmpHypervisorEvent(e); ok {
+			q.events.publish(q.Logger(), hypervisorEvent)
+		}
+		switch e.Name {
+		case "GUEST_PANICKED":
+			go q.handleGuestCrash(guestDumpReasonPanic)
+		case "WATCHDOG":
+			go q.handleGuestCrash(guestDumpReasonWatchdog)
 		}
 	}
 	q.Logger().Infof("QMP event channel closed")
 }
 
-func (q *qemu) handleGuestPanic() {
-	if err := q.dumpGuestMemory(q.config.GuestMemoryDumpPath); err != nil {
-		q.Logger().WithError(err).Error("failed to dump guest memory")
-	}
-
-	// TODO: how to notify the upper level sandbox to handle the error
-	// to do a fast fail(shutdown or others).
-	// tracked by https://github.com/kata-containers/kata-containers/issues/1026
+// HypervisorEvents returns the lifecycle events of the VM reported by QMP.
+func (q *qemu) HypervisorEvents() <-chan HypervisorEvent {
+	return q.events.channel()
 }
 
-// canDumpGuestMemory check if can do a guest memory dump operation.
-// for now it only ensure there must be double of VM size for free disk spaces
-func (q *qemu) canDumpGuestMemory(dumpSavePath string) error {
-	fs := unix.Statfs_t{}
-	if err := unix.Statfs(dumpSavePath, &fs); err != nil {
-		q.Logger().WithError(err).WithField("dumpSavePath", dumpSavePath).Error("failed to call Statfs")
-		return nil
-	}
-	availSpaceInBytes := fs.Bavail * uint64(fs.Bsize)
-	q.Logger().WithFields(
-		logrus.Fields{
-			"dumpSavePath":      dumpSavePath,
-			"availSpaceInBytes": availSpaceInBytes,
-		}).Info("get avail space")
-
-	// get guest memory size
-	guestMemorySizeInBytes := (uint64(q.config.MemorySize) + uint64(q.state.HotpluggedMemory)) << utils.MibToBytesShift
-	q.Logger().WithField("guestMemorySizeInBytes", guestMemorySizeInBytes).Info("get guest memory size")
-
-	// default we want ensure there are at least double of VM memory size free spaces available,
-	// this may complete one dump operation for one sandbox
-	exceptMemorySize := guestMemorySizeInBytes * 2
-	if availSpaceInBytes >= exceptMemorySize {
-		return nil
-	}
-	return fmt.Errorf("there are not enough free space to store memory dump file. Except %d bytes, but only %d bytes available", exceptMemorySize, availSpaceInBytes)
+// qmpEventTypes maps the QMP events to the lifecycle events of the VM.
+var qmpEventTypes = map[string]HypervisorEventType{
+	"GUEST_PANICKED": HypervisorEventGuestPanic,
+	"WATCHDOG":       HypervisorEventWatchdog,
+	"RESET":          HypervisorEventReset,
+	"SHUTDOWN":       HypervisorEventShutdown,
+	"BALLOON_CHANGE": HypervisorEventBalloonChange,
+	"DEVICE_DELETED": HypervisorEventDeviceDeleted,
+	"BLOCK_IO_ERROR": HypervisorEventBlockIOError,
 }
 
-// dumpSandboxMetaInfo save meta information for debug purpose, includes:
-// hypervisor version, sandbox/container state, hypervisor config
-func (q *qemu) dumpSandboxMetaInfo(dumpSavePath string) {
-	dumpStatePath := filepath.Join(dumpSavePath, "state")
+// qmpHypervisorEvent converts a QMP event to a lifecycle event of the VM. The
+// QMP event data are kept as the event details.
+func qmpHypervisorEvent(e govmmQemu.QMPEvent) (HypervisorEvent, bool) {
+	eventType, ok := qmpEventTypes[e.Name]
+	if !ok {
+		return HypervisorEvent{}, false
+	}
 
-	// copy state from /run/vc/sbs to memory dump directory
-	statePath := filepath.Join(q.config.RunStorePath, q.id)
-	command := []string{"/bin/cp", "-ar", statePath, dumpStatePath}
-	q.Logger().WithField("command", command).Info("try to Save sandbox state")
-	if output, err := pkgUtils.RunCommandFull(command, true); err != nil {
-		q.Logger().WithError(err).WithField("output", output).Error("failed to Save state")
+	event := HypervisorEvent{
+		Timestamp: e.Timestamp,
+		Type:      eventType,
+		Details:   make(map[string]string),
 	}
-	// Save hypervisor meta information
-	fileName := filepath.Join(dumpSavePath, "hypervisor.conf")
-	data, _ := json.MarshalIndent(q.config, "", " ")
-	if err := os.WriteFile(fileName, data, defaultFilePerms); err != nil {
-		q.Logger().WithError(err).WithField("hypervisor.conf", data).Error("write to hypervisor.conf file failed")
+	for k, v := range e.Data {
+		switch v := v.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		case float64:
+			// JSON numbers, like the balloon size in bytes.
+			event.Details[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			event.Details[k] = fmt.Sprint(v)
+		}
 	}
 
-	// Save hypervisor version
-	hyperVisorVersion, err := pkgUtils.RunCommand([]string{q.config.HypervisorPath, "--version"})
-	if err != nil {
-		q.Logger().WithError(err).WithField("HypervisorPath", data).Error("failed to get hypervisor version")
+	// The reason of the event, or the action taken by QEMU.
+	switch e.Name {
+	case "SHUTDOWN", "RESET", "BLOCK_IO_ERROR":
+		event.Reason = event.Details["reason"]
+	case "WATCHDOG", "GUEST_PANICKED":
+		event.Reason = event.Details["action"]
 	}
 
-	fileName = filepath.Join(dumpSavePath, "hypervisor.version")
-	if err := os.WriteFile(fileName, []byte(hyperVisorVersion), defaultFilePerms); err != nil {
-		q.Logger().WithError(err).WithField("hypervisor.version", data).Error("write to hypervisor.version file failed")
-	}
+	return event, true
 }
 
-func (q *qemu) dumpGuestMemory(dumpSavePath string) error {
-	if dumpSavePath == "" {
-		return nil
+func (q *qemu) handleGuestCrash(reason string) {
+	if q.config.GuestMemoryDumpPath == "" {
+		return
 	}
 
-	q.memoryDumpFlag.Lock()
-	defer q.memoryDumpFlag.Unlock()
-
-	q.Logger().WithField("dumpSavePath", dumpSavePath).Info("try to dump guest memory")
-
-	dumpSavePath = filepath.Join(dumpSavePath, q.id)
-	dumpStatePath := filepath.Join(dumpSavePath, "state")
-	if err := pkgUtils.EnsureDir(dumpStatePath, DirMode); err != nil {
-		return err
+	if _, err := dumpGuest(q.qmpMonitorCh.ctx, q, q.id, reason); err != nil {
+		q.Logger().WithError(err).Error("failed to dump guest memory")
 	}
 
-	// Save meta information for sandbox
-	q.dumpSandboxMetaInfo(dumpSavePath)
-	q.Logger().Info("dump sandbox meta information completed")
-
-	// Check device free space and estimated dump size
-	if err := q.canDumpGuestMemory(dumpSavePath); err != nil {
-		q.Logger().Warnf("can't dump guest memory: %s", err.Error())
-		return err
-	}
+	// TODO: how to notify the upper level sandbox to handle the error
+	// to do a fast fail(shutdown or others).
+	// tracked by https://github.com/kata-containers/kata-containers/issues/1026
+}
 
-	// dump guest memory
-	protocol := fmt.Sprintf("file:%s/vmcore-%s.%s", dumpSavePath, time.Now().Format("20060102150405.999"), memoryDumpFormat)
-	q.Logger().Infof("try to dump guest memory to %s", protocol)
+func (q *qemu) dumpGuestMemory(ctx context.Context, coreFile string) error {
+	q.memoryDumpFlag.Lock()
+	defer q.memoryDumpFlag.Unlock()
 
 	if err := q.qmpSetup(); err != nil {
 		q.Logger().WithError(err).Error("setup manage QMP failed")
 		return err
 	}
 
-	if err := q.qmpMonitorCh.qmp.ExecuteDumpGuestMemory(q.qmpMonitorCh.ctx, protocol, q.config.GuestMemoryDumpPaging, memoryDumpFormat); err != nil {
-		q.Logger().WithError(err).Error("dump guest memory failed")
-		return err
-	}
+	protocol := "file:" + coreFile
+	q.Logger().Infof("try to dump guest memory to %s", protocol)
 
-	q.Logger().Info("dump guest memory completed")
-	return nil
+	return q.qmpMonitorCh.qmp.ExecuteDumpGuestMemory(ctx, protocol, q.config.GuestMemoryDumpPaging, memoryDumpFormat)
 }
 
 func (q *qemu) qmpShutdown() {
@@ -2333,6 +2303,76 @@ func (q *qemu) hotplugVhostUserDevice(ctx context.Context, vAttr *config.VhostUs
 	}
 }
 
+func (q *qemu) hotplugVhostVDPADevice(ctx context.Context, vAttr *config.VhostVDPADeviceAttrs, op Operation) (err error) {
+	if err = q.qmpSetup(); err != nil {
+		return err
+	}
+
+	devID := utils.MakeNameID("vdpa", vAttr.DevID, maxDevIDSize)
+	machineType := q.HypervisorConfig().HypervisorMachineType
+
+	if op == RemoveDevice {
+		if machineType != QemuVirt {
+			if err := q.arch.removeDeviceFromBridge(vAttr.DevID); err != nil {
+				return err
+			}
+		}
+
+		return q.qmpMonitorCh.qmp.ExecuteDeviceDel(q.qmpMonitorCh.ctx, devID)
+	}
+
+	// Hotplug the vhost-vdpa device to a pcie root port for QemuVirt
+	if machineType == QemuVirt {
+		addr := "00"
+		bridgeID := fmt.Sprintf("%s%d", config.PCIeRootPortPrefix, len(config.PCIeDevicesPerPort[config.RootPort]))
+		dev := config.VFIODev{ID: devID}
+		config.PCIeDevicesPerPort[config.RootPort] = append(config.PCIeDevicesPerPort[config.RootPort], dev)
+
+		bridgeSlot, err := q.arch.qomGetSlot(fmt.Sprintf("%s%s", qomPathPrefix, bridgeID), &q.qmpMonitorCh)
+		if err != nil {
+			return err
+		}
+
+		devSlot, err := types.PciSlotFromString(addr)
+		if err != nil {
+			return err
+		}
+
+		if vAttr.PCIPath, err = types.PciPathFromSlots(bridgeSlot, devSlot); err != nil {
+			return err
+		}
+
+		return q.qmpMonitorCh.qmp.ExecuteVhostVDPADeviceAdd(q.qmpMonitorCh.ctx, devID, vAttr.VhostDevPath, addr, bridgeID, romFile)
+	}
+
+	addr, bridge, err := q.arch.addDeviceToBridge(ctx, vAttr.DevID, types.PCI)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			q.arch.removeDeviceFromBridge(vAttr.DevID)
+		}
+	}()
+
+	bridgeSlot, err := types.PciSlotFromInt(bridge.Addr)
+	if err != nil {
+		return err
+	}
+
+	devSlot, err := types.PciSlotFromString(addr)
+	if err != nil {
+		return err
+	}
+
+	if vAttr.PCIPath, err = types.PciPathFromSlots(bridgeSlot, devSlot); err != nil {
+		return err
+	}
+
+	return q.qmpMonitorCh.qmp.ExecuteVhostVDPADeviceAdd(q.qmpMonitorCh.ctx, devID, vAttr.VhostDevPath, addr, bridge.ID, romFile)
+}
+
 func (q *qemu) hotplugVFIODeviceRootPort(ctx context.Context, device *config.VFIODev) (err error) {
 	return q.executeVFIODeviceAdd(device)
 }
@@ -2551,6 +2591,9 @@ func (q *qemu) hotplugDevice(ctx context.Context, devInfo interface{}, devType D
 	case VhostuserDev:
 		vAttr := devInfo.(*config.VhostUserDeviceAttrs)
 		return nil, q.hotplugVhostUserDevice(ctx, vAttr, op)
+	case VhostVDPADev:
+		vAttr := devInfo.(*config.VhostVDPADeviceAttrs)
+		return nil, q.hotplugVhostVDPADevice(ctx, vAttr, op)
 	default:
 		return nil, fmt.Errorf("cannot hotplug device: unsupported device type '%v'", devType)
 	}
@@ -2865,6 +2908,8 @@ func (q *qemu) AddDevice(ctx context.Context, devInfo interface{}, devType Devic
 		q.qemuConfig.Devices, err = q.arch.appendVhostUserDevice(ctx, q.qemuConfig.Devices, v)
 	case config.VFIODev:
 		q.qemuConfig.Devices = q.arch.appendVFIODevice(q.qemuConfig.Devices, v)
+	case config.VhostVDPADeviceAttrs:
+		q.qemuConfig.Devices, err = q.arch.appendVhostVDPADevice(ctx, q.qemuConfig.Devices, v)
 	default:
 		q.Logger().WithField("dev-type", v).Warn("Could not append device: unsupported device type")
 	}
@@ -2946,8 +2991,29 @@ func (q *qemu) Disconnect(ctx context.Context) {
 	q.qmpShutdown()
 }
 
+// GetTotalMemoryMB returns the memory available to the guest, that is the
+// plugged memory minus the memory reclaimed by the balloon.
 func (q *qemu) GetTotalMemoryMB(ctx context.Context) uint32 {
-	return q.config.MemorySize + uint32(q.state.HotpluggedMemory)
+	return q.config.MemorySize + uint32(q.state.HotpluggedMemory) - uint32(q.state.BalloonMemory)
+}
+
+// balloonEnabled returns true if the VM has a balloon device which can be
+// used to return memory to the host.
+func (q *qemu) balloonEnabled() bool {
+	return q.config.ReclaimGuestFreedMemory && !q.config.ConfidentialGuest
+}
+
+// resizeBalloon sets the memory available to the guest to targetMB, out of
+// pluggedMB, by inflating or deflating the balloon.
+func (q *qemu) resizeBalloon(targetMB, pluggedMB uint32) error {
+	if err := q.qmpMonitorCh.qmp.ExecuteBalloon(q.qmpMonitorCh.ctx, uint64(targetMB)<<utils.MibToBytesShift); err != nil {
+		q.Logger().WithError(err).Error("failed to resize balloon")
+		return err
+	}
+
+	q.state.BalloonMemory = int(pluggedMB - targetMB)
+
+	return nil
 }
 
 // ResizeMemory gets a request to update the VM memory to reqMemMB
@@ -2977,6 +3043,30 @@ func (q *qemu) ResizeMemory(ctx context.Context, reqMemMB uint32, memoryBlockSiz
 		return reqMemMB, MemoryDevice{}, nil
 	}
 
+	// Memory cannot be hot unplugged, so with the sandbox rightsizing it is
+	// returned to the host by inflating the balloon. When growing, the
+	// balloon is deflated first, and memory only gets hotplugged for what
+	// the deflated balloon can't give back.
+	ballooned := false
+	if q.balloonEnabled() && (q.state.BalloonMemory > 0 || (q.config.BalloonShrink && reqMemMB < currentMemory)) {
+		pluggedMemory := currentMemory + uint32(q.state.BalloonMemory)
+		targetMemory := reqMemMB
+		if targetMemory > pluggedMemory {
+			targetMemory = pluggedMemory
+		}
+
+		q.Logger().WithField("hotplug", "memory").Debugf("resize balloon to leave %dMB of %dMB to the guest", targetMemory, pluggedMemory)
+		if err := q.resizeBalloon(targetMemory, pluggedMemory); err != nil {
+			return currentMemory, MemoryDevice{}, err
+		}
+
+		currentMemory = targetMemory
+		ballooned = true
+		if reqMemMB <= pluggedMemory {
+			return currentMemory, MemoryDevice{}, nil
+		}
+	}
+
 	switch {
 	case currentMemory < reqMemMB:
 		//hotplug
@@ -3003,6 +3093,17 @@ func (q *qemu) ResizeMemory(ctx context.Context, reqMemMB uint32, memoryBlockSiz
 			return currentMemory, addMemDevice, fmt.Errorf("Could not get the memory added, got %+v", data)
 		}
 		currentMemory += uint32(memoryAdded)
+
+		// The balloon target is the memory left to the guest, not the
+		// memory taken from it, and the hotplug leaves it unchanged. Once
+		// the memory is plugged, raise the target that was set to the new
+		// total, otherwise the balloon inflates to take the hotplugged
+		// memory back.
+		if q.balloonEnabled() && (q.config.BalloonShrink || ballooned) {
+			if err := q.resizeBalloon(currentMemory, currentMemory); err != nil {
+				return currentMemory, addMemDevice, err
+			}
+		}
 	case currentMemory > reqMemMB:
 		//hotunplug
 		addMemMB := currentMemory - reqMemMB
@@ -3509,6 +3610,7 @@ func (q *qemu) Save() (s hv.HypervisorState) {
 	s.Type = string(QemuHypervisor)
 	s.UUID = q.state.UUID
 	s.HotpluggedMemory = q.state.HotpluggedMemory
+	s.BalloonMemory = q.state.BalloonMemory
 
 	for _, bridge := range q.arch.getBridges() {
 		s.Bridges = append(s.Bridges, hv.Bridge{
@@ -3530,6 +3632,7 @@ func (q *qemu) Save() (s hv.HypervisorState) {
 func (q *qemu) Load(s hv.HypervisorState) {
 	q.state.UUID = s.UUID
 	q.state.HotpluggedMemory = s.HotpluggedMemory
+	q.state.BalloonMemory = s.BalloonMemory
 	q.state.VirtiofsDaemonPid = s.VirtiofsDaemonPid
 
 	for _, bridge := range s.Bridges {
@@ -114,6 +114,9 @@ type qemuArch interface {
 	// appendVFIODevice appends a VFIO device to devices
 	appendVFIODevice(devices []govmmQemu.Device, vfioDevice config.VFIODev) []govmmQemu.Device
 
+	// appendVhostVDPADevice appends a vhost-vdpa device to devices
+	appendVhostVDPADevice(ctx context.Context, devices []govmmQemu.Device, attr config.VhostVDPADeviceAttrs) ([]govmmQemu.Device, error)
+
 	// appendRNGDevice appends a RNG device to devices
 	appendRNGDevice(ctx context.Context, devices []govmmQemu.Device, rngDevice config.RNGDev) ([]govmmQemu.Device, error)
 
@@ -755,6 +758,17 @@ func (q *qemuArchBase) appendVFIODevice(devices []govmmQemu.Device, vfioDev conf
 	return devices
 }
 
+func (q *qemuArchBase) appendVhostVDPADevice(_ context.Context, devices []govmmQemu.Device, attr config.VhostVDPADeviceAttrs) ([]govmmQemu.Device, error) {
+	devices = append(devices,
+		govmmQemu.VhostVDPADevice{
+			ID:       utils.MakeNameID("vdpa", attr.DevID, maxDevIDSize),
+			VhostDev: attr.VhostDevPath,
+		},
+	)
+
+	return devices, nil
+}
+
 func (q *qemuArchBase) appendRNGDevice(_ context.Context, devices []govmmQemu.Device, rngDev config.RNGDev) ([]govmmQemu.Device, error) {
 	devices = append(devices,
 		govmmQemu.RngDevice{
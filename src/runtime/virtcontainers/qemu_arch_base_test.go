@@ -291,6 +291,8 @@ func testQemuArchBaseAppend(t *testing.T, structure interface{}, expected []govm
 		devices = qemuArchBase.appendVFIODevice(devices, s)
 	case config.VhostUserDeviceAttrs:
 		devices, err = qemuArchBase.appendVhostUserDevice(context.Background(), devices, s)
+	case config.VhostVDPADeviceAttrs:
+		devices, err = qemuArchBase.appendVhostVDPADevice(context.Background(), devices, s)
 	}
 
 	assert.NoError(err)
@@ -566,6 +568,24 @@ func TestQemuArchBaseAppendVhostUserDevice(t *testing.T) {
 	testQemuArchBaseAppend(t, vhostUserDevice, expectedOut)
 }
 
+func TestQemuArchBaseAppendVhostVDPADevice(t *testing.T) {
+	id := "deadbeef"
+
+	expectedOut := []govmmQemu.Device{
+		govmmQemu.VhostVDPADevice{
+			ID:       fmt.Sprintf("vdpa-%s", id),
+			VhostDev: "/dev/vhost-vdpa-0",
+		},
+	}
+
+	vdpaDevice := config.VhostVDPADeviceAttrs{
+		DevID:        id,
+		VhostDevPath: "/dev/vhost-vdpa-0",
+	}
+
+	testQemuArchBaseAppend(t, vdpaDevice, expectedOut)
+}
+
 func TestQemuArchBaseAppendVFIODevice(t *testing.T) {
 	bdf := "02:10.1"
 
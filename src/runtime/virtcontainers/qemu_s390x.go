@@ -170,6 +170,10 @@ func (q *qemuS390x) appendCCWBlockDevice(ctx context.Context, devices []govmmQem
 	return devices, nil
 }
 
+func (q *qemuS390x) appendVhostVDPADevice(ctx context.Context, devices []govmmQemu.Device, attr config.VhostVDPADeviceAttrs) ([]govmmQemu.Device, error) {
+	return devices, fmt.Errorf("vhost-vdpa devices are not supported on s390x")
+}
+
 func (q *qemuS390x) appendVhostUserDevice(ctx context.Context, devices []govmmQemu.Device, attr config.VhostUserDeviceAttrs) ([]govmmQemu.Device, error) {
 	if attr.Type != config.VhostUserFS {
 		return devices, fmt.Errorf("vhost-user device of type %s not supported on s390x, only vhost-user-fs-ccw is supported", attr.Type)
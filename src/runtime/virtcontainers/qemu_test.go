@@ -13,6 +13,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -20,6 +21,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
@@ -213,16 +215,11 @@ func TestQemuCreateVM(t *testing.T) {
 		mem := q.GetTotalMemoryMB(context.Background())
 		assert.True(mem > 0)
 
-		err = q.canDumpGuestMemory("/tmp")
+		err = checkGuestDumpSpace("/tmp", uint64(mem)<<utils.MibToBytesShift)
 		assert.NoError(err)
 
-		err = q.dumpGuestMemory("")
-		assert.NoError(err)
-
-		q.dumpSandboxMetaInfo("/tmp/")
-
 		// now we exercise code that should fail since the VM isn't running
-		err = q.dumpGuestMemory("/tmp")
+		err = q.dumpGuestMemory(context.Background(), filepath.Join(t.TempDir(), guestDumpCoreFile))
 		assert.Error(err)
 
 		err = q.setupVirtioMem(context.Background())
@@ -493,6 +490,21 @@ func TestQemuCapabilities(t *testing.T) {
 	caps := q.Capabilities(q.ctx)
 	assert.True(caps.IsBlockDeviceHotplugSupported())
 	assert.True(caps.IsNetworkDeviceHotplugSupported())
+	assert.False(caps.IsMemoryShrinkSupported())
+
+	// Reclaiming the freed memory alone does not shrink the memory
+	q.config.ReclaimGuestFreedMemory = true
+	caps = q.Capabilities(q.ctx)
+	assert.False(caps.IsMemoryShrinkSupported())
+
+	q.config.BalloonShrink = true
+	caps = q.Capabilities(q.ctx)
+	assert.True(caps.IsMemoryShrinkSupported())
+
+	q.config.ReclaimGuestFreedMemory = false
+	q.config.VirtioMem = true
+	caps = q.Capabilities(q.ctx)
+	assert.True(caps.IsMemoryShrinkSupported())
 }
 
 func TestQemuQemuPath(t *testing.T) {
@@ -891,6 +903,221 @@ func TestHotplugAddMemoryVirtioMem(t *testing.T) {
 	assert.Equal(228, q.state.HotpluggedMemory)
 }
 
+// TestQemuResizeBalloon verifies that inflating and deflating the balloon
+// is reflected in the memory reported to the guest.
+func TestQemuResizeBalloon(t *testing.T) {
+	assert := assert.New(t)
+
+	serverConn, clientConn := net.Pipe()
+	startTestQMPServer(t, serverConn, []string{`{"return":{}}`, `{"return":{}}`})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disconnectedCh := make(chan struct{})
+	cfg := govmmQemu.QMPConfig{Logger: newQMPLogger()}
+	qmp, _, err := govmmQemu.QMPStartWithConn(ctx, clientConn, cfg, disconnectedCh)
+	assert.NoError(err)
+
+	defer func() {
+		qmp.Shutdown()
+		<-disconnectedCh
+	}()
+
+	q := &qemu{
+		arch: &qemuArchBase{},
+		config: HypervisorConfig{
+			MemorySize:              2048,
+			ReclaimGuestFreedMemory: true,
+		},
+		state: QemuState{
+			HotpluggedMemory: 1024,
+		},
+		qmpMonitorCh: qmpChannel{
+			qmp: qmp,
+			ctx: ctx,
+		},
+	}
+
+	assert.True(q.balloonEnabled())
+	assert.Equal(uint32(3072), q.GetTotalMemoryMB(ctx))
+
+	// Inflate: leave 2560MB of the 3072MB plugged to the guest.
+	assert.NoError(q.resizeBalloon(2560, 3072))
+	assert.Equal(512, q.state.BalloonMemory)
+	assert.Equal(uint32(2560), q.GetTotalMemoryMB(ctx))
+
+	state := q.Save()
+	assert.Equal(512, state.BalloonMemory)
+
+	// Deflate completely.
+	assert.NoError(q.resizeBalloon(3072, 3072))
+	assert.Equal(0, q.state.BalloonMemory)
+	assert.Equal(uint32(3072), q.GetTotalMemoryMB(ctx))
+
+	q.Load(state)
+	assert.Equal(512, q.state.BalloonMemory)
+
+	q.config.ConfidentialGuest = true
+	assert.False(q.balloonEnabled())
+}
+
+// testQMPCommand is a QMP command received by a recording QMP server.
+type testQMPCommand struct {
+	Execute   string                 `json:"execute"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// startRecordingQMPServer serves QMP on serverConn, answering every command
+// with an empty result, and returns a function listing the commands
+// received so far.
+func startRecordingQMPServer(t *testing.T, serverConn net.Conn) func() []testQMPCommand {
+	t.Helper()
+
+	var lock sync.Mutex
+	var commands []testQMPCommand
+
+	go func() {
+		defer serverConn.Close()
+		hello := `{"QMP":{"version":{"qemu":{"micro":0,"minor":0,"major":5},"package":""},"capabilities":[]}}` + "\n"
+		if _, err := serverConn.Write([]byte(hello)); err != nil {
+			return
+		}
+		scanner := bufio.NewScanner(serverConn)
+		for scanner.Scan() {
+			var cmd testQMPCommand
+			if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+				return
+			}
+
+			lock.Lock()
+			commands = append(commands, cmd)
+			lock.Unlock()
+
+			resp := `{"return":{}}`
+			if cmd.Execute == "query-memory-devices" {
+				resp = `{"return":[]}`
+			}
+			if _, err := serverConn.Write([]byte(resp + "\n")); err != nil {
+				return
+			}
+		}
+	}()
+
+	return func() []testQMPCommand {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]testQMPCommand{}, commands...)
+	}
+}
+
+// TestQemuResizeMemoryGrowPastBalloon verifies that growing the memory of
+// a VM whose balloon was inflated deflates the balloon, hotplugs the
+// missing memory and raises the balloon target to the new total, so that
+// the balloon does not take the hotplugged memory back.
+func TestQemuResizeMemoryGrowPastBalloon(t *testing.T) {
+	assert := assert.New(t)
+
+	serverConn, clientConn := net.Pipe()
+	commands := startRecordingQMPServer(t, serverConn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disconnectedCh := make(chan struct{})
+	cfg := govmmQemu.QMPConfig{Logger: newQMPLogger()}
+	qmp, _, err := govmmQemu.QMPStartWithConn(ctx, clientConn, cfg, disconnectedCh)
+	assert.NoError(err)
+
+	defer func() {
+		qmp.Shutdown()
+		<-disconnectedCh
+	}()
+
+	q := &qemu{
+		arch: &qemuArchBase{},
+		config: HypervisorConfig{
+			MemorySize:              2048,
+			DefaultMaxMemorySize:    8192,
+			ReclaimGuestFreedMemory: true,
+			BalloonShrink:           true,
+		},
+		qmpMonitorCh: qmpChannel{
+			qmp: qmp,
+			ctx: ctx,
+		},
+	}
+
+	balloonTargets := func() []uint64 {
+		var targets []uint64
+		for _, cmd := range commands() {
+			if cmd.Execute == "balloon" {
+				targets = append(targets, uint64(cmd.Arguments["value"].(float64))>>utils.MibToBytesShift)
+			}
+		}
+		return targets
+	}
+
+	// Shrink: the balloon takes 512MB from the guest.
+	mem, _, err := q.ResizeMemory(ctx, 1536, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(1536), mem)
+	assert.Equal(512, q.state.BalloonMemory)
+	assert.Equal([]uint64{1536}, balloonTargets())
+
+	// Grow past the plugged memory: the balloon is deflated, 1024MB are
+	// hotplugged and the balloon target follows the new total.
+	mem, _, err = q.ResizeMemory(ctx, 3072, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(3072), mem)
+	assert.Equal(1024, q.state.HotpluggedMemory)
+	assert.Equal(0, q.state.BalloonMemory)
+	assert.Equal([]uint64{1536, 2048, 3072}, balloonTargets())
+	assert.Equal(uint32(3072), q.GetTotalMemoryMB(ctx))
+}
+
+// TestQemuResizeMemoryReclaimOnly verifies that, when the guest freed
+// memory is reclaimed without the sandbox rightsizing, shrinking the
+// memory leaves the balloon alone.
+func TestQemuResizeMemoryReclaimOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	serverConn, clientConn := net.Pipe()
+	commands := startRecordingQMPServer(t, serverConn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disconnectedCh := make(chan struct{})
+	cfg := govmmQemu.QMPConfig{Logger: newQMPLogger()}
+	qmp, _, err := govmmQemu.QMPStartWithConn(ctx, clientConn, cfg, disconnectedCh)
+	assert.NoError(err)
+
+	defer func() {
+		qmp.Shutdown()
+		<-disconnectedCh
+	}()
+
+	q := &qemu{
+		arch: &qemuArchBase{},
+		config: HypervisorConfig{
+			MemorySize:              2048,
+			DefaultMaxMemorySize:    8192,
+			ReclaimGuestFreedMemory: true,
+		},
+		qmpMonitorCh: qmpChannel{
+			qmp: qmp,
+			ctx: ctx,
+		},
+	}
+
+	q.ResizeMemory(ctx, 1536, 128, false)
+	assert.Equal(0, q.state.BalloonMemory)
+	for _, cmd := range commands() {
+		assert.NotEqual("balloon", cmd.Execute)
+	}
+}
+
 // TestHotplugAddMemoryVirtioMemMultipleOperations verifies that
 // multiple virtio-mem resize operations accumulate correctly.
 func TestHotplugAddMemoryVirtioMemMultipleOperations(t *testing.T) {
@@ -1867,3 +2094,54 @@ func TestMaybeRightSizeAutoNUMANoOpForFlatTopology(t *testing.T) {
 		})
 	}
 }
+
+func TestQMPHypervisorEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	event, ok := qmpHypervisorEvent(govmmQemu.QMPEvent{
+		Name: "SHUTDOWN",
+		Data: map[string]interface{}{"guest": true, "reason": "guest-shutdown"},
+	})
+	assert.True(ok)
+	assert.Equal(HypervisorEventShutdown, event.Type)
+	assert.Equal("guest-shutdown", event.Reason)
+	assert.Equal("true", event.Details["guest"])
+
+	event, ok = qmpHypervisorEvent(govmmQemu.QMPEvent{
+		Name: "GUEST_PANICKED",
+		Data: map[string]interface{}{"action": "pause", "info": map[string]interface{}{"type": "hyper-v"}},
+	})
+	assert.True(ok)
+	assert.Equal(HypervisorEventGuestPanic, event.Type)
+	assert.Equal("pause", event.Reason)
+	assert.NotContains(event.Details, "info")
+
+	event, ok = qmpHypervisorEvent(govmmQemu.QMPEvent{
+		Name: "BALLOON_CHANGE",
+		Data: map[string]interface{}{"actual": float64(1 << 30)},
+	})
+	assert.True(ok)
+	assert.Equal(HypervisorEventBalloonChange, event.Type)
+	assert.Equal("1073741824", event.Details["actual"])
+
+	_, ok = qmpHypervisorEvent(govmmQemu.QMPEvent{Name: "RESUME"})
+	assert.False(ok)
+}
+
+func TestQemuLoopQMPEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	q := &qemu{}
+	events := make(chan govmmQemu.QMPEvent, 2)
+	events <- govmmQemu.QMPEvent{Name: "RESUME"}
+	events <- govmmQemu.QMPEvent{Name: "DEVICE_DELETED", Data: map[string]interface{}{"device": "virtio-drive-1"}}
+	close(events)
+
+	q.loopQMPEvent(events)
+
+	hypervisorEvents := q.HypervisorEvents()
+	assert.Len(hypervisorEvents, 1)
+	event := <-hypervisorEvents
+	assert.Equal(HypervisorEventDeviceDeleted, event.Type)
+	assert.Equal("virtio-drive-1", event.Details["device"])
+}
@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
@@ -17,13 +18,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	v1 "github.com/containerd/cgroups/stats/v1"
-	v2 "github.com/containerd/cgroups/v2/stats"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -115,8 +116,42 @@ type SandboxStatus struct {
 
 // SandboxStats describes a sandbox's stats
 type SandboxStats struct {
-	CgroupStats CgroupStats
-	Cpus        int
+	CgroupStats  CgroupStats
+	MemoryEvents SandboxMemoryEvents
+	// The usage of the sandbox processes and threads running the workload.
+	// The split with Overhead is approximate: with sandbox_cgroup_only, the
+	// VMM threads other than the vCPU ones, and what the file system daemon
+	// charges while the VMM starts, are accounted as workload.
+	Workload SandboxResourceUsage
+	// The usage of the Kata overhead processes and threads, nil when they
+	// are not accounted in an overhead controller
+	Overhead *SandboxResourceUsage
+	Cpus     int
+}
+
+// SandboxResourceUsage describes the host resource usage of a part of the
+// sandbox.
+type SandboxResourceUsage struct {
+	// CPU time, in nanoseconds
+	CPUTime uint64
+	// Memory usage, in bytes
+	Memory uint64
+}
+
+// SandboxMemoryEvents describes the memory events of the sandbox cgroup on
+// the host. They are counters since the creation of the cgroup, cgroup v1
+// only reports OOMKill.
+type SandboxMemoryEvents struct {
+	// Times the memory usage was over the low boundary
+	Low uint64
+	// Times the memory usage was throttled over the high boundary
+	High uint64
+	// Times the memory usage was about to go over the max boundary
+	Max uint64
+	// Times the memory usage reached the limit and the OOM killer was invoked
+	OOM uint64
+	// Processes of the sandbox killed by the OOM killer
+	OOMKill uint64
 }
 
 type SandboxResourceSizing struct {
@@ -128,6 +163,12 @@ type SandboxResourceSizing struct {
 	WorkloadMemMB uint32
 	// The base amount of memory required for that VM that is assigned as overhead
 	BaseMemMB uint32
+	// The number of CPUs the Kata overhead processes can use on the host,
+	// from the pod overhead. Zero means no limit.
+	OverheadCPUs float32
+	// The amount of memory the Kata overhead processes can use on the host,
+	// from the pod overhead. Zero means no limit.
+	OverheadMemMB uint32
 }
 
 // SandboxConfig is a Sandbox configuration.
@@ -173,6 +214,15 @@ type SandboxConfig struct {
 	// StaticResourceMgmt indicates if the shim should rely on statically sizing the sandbox (VM)
 	StaticResourceMgmt bool
 
+	// Rightsizing configures the controller resizing the sandbox (VM)
+	// according to the guest usage. It is ignored when StaticResourceMgmt
+	// is set.
+	Rightsizing RightsizingConfig
+
+	// GuestTimeSync configures the periodic synchronization of the guest
+	// clock with the host one.
+	GuestTimeSync GuestTimeSyncConfig
+
 	// SharePidNs sets all containers to share the same sandbox level pid namespace.
 	SharePidNs bool
 	// SystemdCgroup enables systemd cgroup support
@@ -246,6 +296,13 @@ type Sandbox struct {
 	sandboxController  resCtrl.ResourceController
 	overheadController resCtrl.ResourceController
 
+	rightsizer *rightsizer
+	timeSyncer *timeSyncer
+
+	// resizeLock serializes the resizes of the VM performed for the
+	// containers and by the rightsizing controller.
+	resizeLock sync.Mutex
+
 	containers map[string]*Container
 
 	id string
@@ -695,6 +752,14 @@ func newSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factor
 		swapDevices:     []*config.BlockDrive{},
 	}
 
+	if sandboxConfig.Rightsizing.Enabled && !sandboxConfig.StaticResourceMgmt {
+		s.rightsizer = newRightsizer(s, sandboxConfig.Rightsizing)
+	}
+
+	if sandboxConfig.GuestTimeSync.Enabled {
+		s.timeSyncer = newTimeSyncer(s, sandboxConfig.GuestTimeSync)
+	}
+
 	fsShare, err := NewFilesystemShare(s)
 	if err != nil {
 		return nil, err
@@ -713,6 +778,7 @@ func newSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factor
 
 	sandboxConfig.HypervisorConfig.VMStorePath = s.store.RunVMStoragePath()
 	sandboxConfig.HypervisorConfig.RunStorePath = s.store.RunStoragePath()
+	sandboxConfig.HypervisorConfig.BalloonShrink = s.rightsizer != nil
 
 	spec := s.GetPatchedOCISpec()
 	if spec != nil && spec.Process.SelinuxLabel != "" {
@@ -765,6 +831,10 @@ func newSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factor
 		return nil, err
 	}
 
+	if err = verifySandboxAssets(ctx, &sandboxConfig); err != nil {
+		return nil, err
+	}
+
 	// store doesn't require hypervisor to be stored immediately
 	if err = s.hypervisor.CreateVM(ctx, s.id, s.network, &sandboxConfig.HypervisorConfig); err != nil {
 		return nil, err
@@ -1007,7 +1077,17 @@ func (s *Sandbox) createResourceController() error {
 	s.state.OverheadCgroupPath = ""
 
 	if s.config.SandboxCgroupOnly {
+		// All the Kata processes live in the sandbox controller, the ones
+		// that are not running the workload are accounted and limited in
+		// its overhead sub-controller, when supported.
 		s.overheadController = nil
+		overheadController, err := resCtrl.NewOverheadResourceController(s.sandboxController, s.overheadResources())
+		if err != nil {
+			s.Logger().WithError(err).Info("Could not create the sandbox overhead resource controller")
+		} else {
+			s.overheadController = overheadController
+			s.state.OverheadCgroupPath = s.overheadController.ID()
+		}
 	} else {
 		// The shim configuration is requesting that we do not put all threads
 		// into the sandbox resource controller.
@@ -1026,6 +1106,30 @@ func (s *Sandbox) createResourceController() error {
 	return nil
 }
 
+// overheadResources returns the resources of the overhead controller, from
+// the pod overhead.
+func (s *Sandbox) overheadResources() *specs.LinuxResources {
+	resources := &specs.LinuxResources{}
+
+	if cpus := s.config.SandboxResources.OverheadCPUs; cpus > 0 {
+		period := uint64(100000)
+		quota := int64(float64(cpus) * float64(period))
+		resources.CPU = &specs.LinuxCPU{
+			Period: &period,
+			Quota:  &quota,
+		}
+	}
+
+	if memMB := s.config.SandboxResources.OverheadMemMB; memMB > 0 {
+		limit := int64(memMB) << utils.MibToBytesShift
+		resources.Memory = &specs.LinuxMemory{
+			Limit: &limit,
+		}
+	}
+
+	return resources
+}
+
 // storeSandbox stores a sandbox config.
 func (s *Sandbox) storeSandbox(ctx context.Context) error {
 	span, _ := katatrace.Trace(ctx, s.Logger(), "storeSandbox", sandboxTracingTags, map[string]string{"sandbox_id": s.id})
@@ -1124,6 +1228,13 @@ func (s *Sandbox) Delete(ctx context.Context) error {
 		s.Logger().WithError(err).Error("failed to cleanup ephemeral disks")
 	}
 
+	// The console log is kept for the lifetime of the sandbox.
+	if s.config.HypervisorConfig.ConsoleLogPath != "" {
+		if err := os.RemoveAll(s.consoleLogDir()); err != nil {
+			s.Logger().WithError(err).Error("failed to remove the console log")
+		}
+	}
+
 	return s.store.Destroy(s.id)
 }
 
@@ -1329,6 +1440,7 @@ const (
 type consoleWatcher struct {
 	conn       net.Conn
 	ptyConsole *os.File
+	log        *consoleLog
 	proto      string
 	consoleURL string
 }
@@ -1370,10 +1482,35 @@ func (cw *consoleWatcher) start(s *Sandbox) (err error) {
 		return fmt.Errorf("unknown console proto %s", cw.proto)
 	}
 
+	if s.config.HypervisorConfig.ConsoleLogEnabled() {
+		// The console must still be drained when it cannot be logged,
+		// not to block the hypervisor writing to it.
+		if cw.log, err = newConsoleLog(s.consoleLogDir(), s.config.HypervisorConfig.ConsoleLogMaxSize, s.config.HypervisorConfig.ConsoleLogMaxFiles); err != nil {
+			s.Logger().WithError(err).Warn("Failed to open guest console log")
+		}
+	}
+	clog := cw.log
+	debug := s.config.HypervisorConfig.Debug
+
 	go func() {
+		logErr := false
 		for scanner.Scan() {
 			text := scanner.Text()
-			if text != "" {
+			if text == "" {
+				continue
+			}
+
+			if clog != nil {
+				err := clog.write(ConsoleLogEntry{Time: time.Now().UTC(), Line: text})
+				// Only report the first failure, rather than
+				// one per console line.
+				if err != nil && !logErr {
+					logErr = true
+					s.Logger().WithError(err).Warn("Failed to write guest console log")
+				}
+			}
+
+			if debug {
 				s.Logger().WithFields(logrus.Fields{
 					"console-protocol": cw.proto,
 					"console-url":      cw.consoleURL,
@@ -1413,6 +1550,52 @@ func (cw *consoleWatcher) stop() {
 		cw.ptyConsole.Close()
 		cw.ptyConsole = nil
 	}
+
+	if cw.log != nil {
+		cw.log.close()
+		cw.log = nil
+	}
+}
+
+// consoleLogDir returns the directory of the sandbox console log.
+func (s *Sandbox) consoleLogDir() string {
+	return consoleLogDir(&s.config.HypervisorConfig, s.id)
+}
+
+// ConsoleLog returns the guest console log of the sandbox, made of JSON
+// encoded ConsoleLogEntry lines.
+func (s *Sandbox) ConsoleLog(ctx context.Context) ([]byte, error) {
+	if !s.config.HypervisorConfig.ConsoleLogEnabled() {
+		return nil, errors.New("the console log of the sandbox is disabled")
+	}
+
+	return readConsoleLog(s.consoleLogDir())
+}
+
+// logConsoleTail logs the last lines of the sandbox console log, so that the
+// early boot logs of a VM failing to start are not lost with the sandbox.
+func (s *Sandbox) logConsoleTail() {
+	data, err := readConsoleLog(s.consoleLogDir())
+	if err != nil {
+		return
+	}
+
+	entries, err := ParseConsoleLog(data)
+	if err != nil {
+		s.Logger().WithError(err).Warn("Failed to parse guest console log")
+		return
+	}
+
+	if len(entries) > consoleLogTailLines {
+		entries = entries[len(entries)-consoleLogTailLines:]
+	}
+
+	for _, entry := range entries {
+		s.Logger().WithFields(logrus.Fields{
+			"console-time": entry.Time,
+			"vmconsole":    entry.Line,
+		}).Error("guest console before VM start failure")
+	}
 }
 
 func (s *Sandbox) addSwap(ctx context.Context, swapID string, size int64) (*config.BlockDrive, error) {
@@ -1547,13 +1730,16 @@ func (s *Sandbox) startVM(ctx context.Context, prestartHookFunc func(context.Con
 
 	s.Logger().Info("Starting VM")
 
-	if s.config.HypervisorConfig.Debug {
+	if s.config.HypervisorConfig.GuestConsoleEnabled() {
 		// create console watcher
 		consoleWatcher, err := newConsoleWatcher(ctx, s)
-		if err != nil {
+		if err == nil {
+			s.cw = consoleWatcher
+		} else if s.config.HypervisorConfig.Debug {
 			return err
+		} else {
+			s.Logger().WithError(err).Warn("Cannot capture the guest console")
 		}
-		s.cw = consoleWatcher
 	}
 
 	defer func() {
@@ -1561,6 +1747,9 @@ func (s *Sandbox) startVM(ctx context.Context, prestartHookFunc func(context.Con
 			// Log error, otherwise nobody might see it - StopVM could kill this process.
 			s.Logger().WithError(err).Error("Cannot start VM")
 			s.hypervisor.StopVM(ctx, false)
+			if s.cw != nil {
+				s.logConsoleTail()
+			}
 		}
 	}()
 
@@ -1582,6 +1771,10 @@ func (s *Sandbox) startVM(ctx context.Context, prestartHookFunc func(context.Con
 		}
 	}
 
+	if p, ok := s.hypervisor.(guestMetadataPublisher); ok {
+		p.setGuestMetadata(s.guestMetadata())
+	}
+
 	if err := s.network.Run(ctx, func() error {
 		if s.factory != nil {
 			vm, err := s.factory.GetVM(ctx, VMConfig{
@@ -1596,7 +1789,9 @@ func (s *Sandbox) startVM(ctx context.Context, prestartHookFunc func(context.Con
 			return vm.assignSandbox(s)
 		}
 
-		return s.hypervisor.StartVM(ctx, VmStartTimeout)
+		return s.runInSandboxController(func() error {
+			return s.hypervisor.StartVM(ctx, VmStartTimeout)
+		})
 	}); err != nil {
 		return err
 	}
@@ -1628,7 +1823,13 @@ func (s *Sandbox) startVM(ctx context.Context, prestartHookFunc func(context.Con
 		s.Logger().Debug("console watcher starts")
 		if err := s.cw.start(s); err != nil {
 			s.cw.stop()
-			return err
+			if s.config.HypervisorConfig.Debug {
+				return err
+			}
+			// The guest console is only captured to the
+			// sandbox console log, which is best effort.
+			s.Logger().WithError(err).Warn("Cannot capture the guest console")
+			s.cw = nil
 		}
 	}
 
@@ -1952,18 +2153,50 @@ func (s *Sandbox) Stats(ctx context.Context) (SandboxStats, error) {
 
 	stats := SandboxStats{}
 
-	// TODO Do we want to aggregate the overhead cgroup stats to the sandbox ones?
 	switch mt := metrics.(type) {
 	case *v1.Metrics:
 		stats.CgroupStats.CPUStats.CPUUsage.TotalUsage = mt.CPU.Usage.Total
 		stats.CgroupStats.MemoryStats.Usage.Usage = mt.Memory.Usage.Usage
-	case *v2.Metrics:
+		if mt.MemoryOomControl != nil {
+			stats.MemoryEvents.OOMKill = mt.MemoryOomControl.OomKill
+		}
+	case *resCtrl.CgroupV2Stats:
 		stats.CgroupStats.CPUStats.CPUUsage.TotalUsage = mt.CPU.UsageUsec
-		stats.CgroupStats.MemoryStats.Usage.Usage = mt.Memory.Usage
+		stats.CgroupStats.MemoryStats.Usage.Usage = mt.Memory.Current
+		stats.MemoryEvents = SandboxMemoryEvents{
+			Low:     mt.Memory.Events.Low,
+			High:    mt.Memory.Events.High,
+			Max:     mt.Memory.Events.Max,
+			OOM:     mt.Memory.Events.OOM,
+			OOMKill: mt.Memory.Events.OOMKill,
+		}
 	default:
 		return SandboxStats{}, fmt.Errorf("unknown metrics type %T", mt)
 	}
 
+	if stats.Workload, err = resourceUsage(metrics); err != nil {
+		return SandboxStats{}, err
+	}
+
+	if s.overheadController != nil {
+		overheadMetrics, err := s.overheadController.Stat()
+		if err != nil {
+			return SandboxStats{}, err
+		}
+		overhead, err := resourceUsage(overheadMetrics)
+		if err != nil {
+			return SandboxStats{}, err
+		}
+		stats.Overhead = &overhead
+
+		// With sandbox_cgroup_only, the overhead controller is a
+		// sub-controller of the sandbox one.
+		if s.config.SandboxCgroupOnly {
+			stats.Workload.CPUTime -= min(stats.Workload.CPUTime, overhead.CPUTime)
+			stats.Workload.Memory -= min(stats.Workload.Memory, overhead.Memory)
+		}
+	}
+
 	tids, err := s.hypervisor.GetThreadIDs(ctx)
 	if err != nil {
 		return stats, err
@@ -1973,6 +2206,25 @@ func (s *Sandbox) Stats(ctx context.Context) (SandboxStats, error) {
 	return stats, nil
 }
 
+// resourceUsage returns the resource usage from the statistics of a resource
+// controller.
+func resourceUsage(metrics interface{}) (SandboxResourceUsage, error) {
+	switch mt := metrics.(type) {
+	case *v1.Metrics:
+		return SandboxResourceUsage{
+			CPUTime: mt.CPU.Usage.Total,
+			Memory:  mt.Memory.Usage.Usage,
+		}, nil
+	case *resCtrl.CgroupV2Stats:
+		return SandboxResourceUsage{
+			CPUTime: mt.CPU.UsageUsec * uint64(time.Microsecond),
+			Memory:  mt.Memory.Current,
+		}, nil
+	default:
+		return SandboxResourceUsage{}, fmt.Errorf("unknown metrics type %T", mt)
+	}
+}
+
 // PauseContainer pauses a running container.
 func (s *Sandbox) PauseContainer(ctx context.Context, containerID string) error {
 	// Fetch the container.
@@ -2079,6 +2331,14 @@ func (s *Sandbox) Start(ctx context.Context) error {
 		return err
 	}
 
+	if s.rightsizer != nil {
+		s.rightsizer.start()
+	}
+
+	if s.timeSyncer != nil {
+		s.timeSyncer.start()
+	}
+
 	s.Logger().Info("Sandbox is started")
 
 	return nil
@@ -2100,6 +2360,14 @@ func (s *Sandbox) Stop(ctx context.Context, force bool) error {
 		return err
 	}
 
+	if s.rightsizer != nil {
+		s.rightsizer.stop()
+	}
+
+	if s.timeSyncer != nil {
+		s.timeSyncer.stop()
+	}
+
 	for _, c := range s.containers {
 		if err := c.stop(ctx, force); err != nil {
 			return err
@@ -2235,6 +2503,13 @@ func (s *Sandbox) HotplugAddDevice(ctx context.Context, device api.Device, devTy
 		}
 		_, err := s.hypervisor.HotplugAddDevice(ctx, vhostUserBlkDevice.VhostUserDeviceAttrs, VhostuserDev)
 		return err
+	case config.DeviceVhostVDPA:
+		vhostVDPADevice, ok := device.(*drivers.VhostVDPADevice)
+		if !ok {
+			return fmt.Errorf("device type mismatch, expect device type to be %s", devType)
+		}
+		_, err := s.hypervisor.HotplugAddDevice(ctx, vhostVDPADevice.VhostVDPADeviceAttrs, VhostVDPADev)
+		return err
 	case config.DeviceGeneric:
 		// TODO: what?
 		return nil
@@ -2293,6 +2568,13 @@ func (s *Sandbox) HotplugRemoveDevice(ctx context.Context, device api.Device, de
 		}
 		_, err := s.hypervisor.HotplugRemoveDevice(ctx, vhostUserDeviceAttrs, VhostuserDev)
 		return err
+	case config.DeviceVhostVDPA:
+		vhostVDPADeviceAttrs, ok := device.GetDeviceInfo().(*config.VhostVDPADeviceAttrs)
+		if !ok {
+			return fmt.Errorf("device type mismatch, expect device type to be %s", devType)
+		}
+		_, err := s.hypervisor.HotplugRemoveDevice(ctx, vhostVDPADeviceAttrs, VhostVDPADev)
+		return err
 	case config.DeviceGeneric:
 		// TODO: what?
 		return nil
@@ -2404,14 +2686,18 @@ func (s *Sandbox) updateResources(ctx context.Context) error {
 		return nil
 	}
 
-	sandboxVCPUs, err := s.calculateSandboxCPUs()
+	s.resizeLock.Lock()
+	defer s.resizeLock.Unlock()
+
+	workloadVCPUs, err := s.calculateSandboxCPUs()
 	if err != nil {
 		return err
 	}
 	// Add default vcpus for sandbox
-	sandboxVCPUs += s.hypervisor.HypervisorConfig().NumVCPUsF
+	sandboxVCPUs := workloadVCPUs + s.hypervisor.HypervisorConfig().NumVCPUsF
 
 	sandboxMemoryByte, sandboxneedPodSwap, sandboxSwapByte := s.calculateSandboxMemory()
+	workloadMemoryMB := uint32(sandboxMemoryByte >> utils.MibToBytesShift)
 
 	// Add default / rsvd memory for sandbox.
 	hypervisorMemoryByteI64 := int64(s.hypervisor.HypervisorConfig().MemorySize) << utils.MibToBytesShift
@@ -2493,6 +2779,8 @@ func (s *Sandbox) updateResources(ctx context.Context) error {
 		}
 	}
 
+	s.resetRightsizing(workloadVCPUs, workloadMemoryMB, newCPUs, finalMemoryMB)
+
 	tmpfsMounts, err := s.prepareEphemeralMounts(finalMemoryMB)
 	if err != nil {
 		return err
@@ -2705,6 +2993,24 @@ func (s *Sandbox) resourceControllerDelete() error {
 		return nil
 	}
 
+	// The overhead controller is deleted first, it is a sub-controller of
+	// the sandbox one with sandbox_cgroup_only.
+	if s.state.OverheadCgroupPath != "" {
+		overheadController, err := resCtrl.LoadResourceController(s.state.OverheadCgroupPath, s.config.SandboxCgroupOnly)
+		if err != nil {
+			return err
+		}
+
+		resCtrlParent := overheadController.Parent()
+		if err := overheadController.MoveTo(resCtrlParent); err != nil {
+			return err
+		}
+
+		if err := overheadController.Delete(); err != nil {
+			return err
+		}
+	}
+
 	sandboxController, err := resCtrl.LoadResourceController(s.state.SandboxCgroupPath, s.config.SandboxCgroupOnly)
 	if err != nil {
 		return err
@@ -2728,25 +3034,6 @@ func (s *Sandbox) resourceControllerDelete() error {
 		return err
 	}
 
-	if s.state.OverheadCgroupPath != "" {
-		overheadController, err := resCtrl.LoadResourceController(s.state.OverheadCgroupPath, s.config.SandboxCgroupOnly)
-		if err != nil {
-			return err
-		}
-
-		// See comment at above MoveTo: Avoid this action as systemd moves tasks on unit deletion.
-		if !resCtrl.IsSystemdCgroup(s.state.OverheadCgroupPath) || !s.config.SandboxCgroupOnly {
-			resCtrlParent := overheadController.Parent()
-			if err := s.overheadController.MoveTo(resCtrlParent); err != nil {
-				return err
-			}
-		}
-
-		if err := overheadController.Delete(); err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
 
@@ -2764,9 +3051,48 @@ func (s *Sandbox) constrainHypervisor(ctx context.Context) error {
 		}
 	}
 
+	if s.overheadController == nil {
+		return nil
+	}
+
+	// The file system daemon is started along with the VMM, and moves to
+	// the overhead controller once the VM runs. The VMM threads can't be
+	// split between cgroup v2 domains, with sandbox_cgroup_only they all
+	// stay in the sandbox controller.
+	if pid := s.hypervisor.GetVirtioFsPid(); pid != nil && *pid > 0 {
+		if err := s.overheadController.AddProcess(*pid); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// runInSandboxController runs start, which launches the VMM, with the runtime
+// in the sandbox controller rather than in its overhead sub-controller, so
+// that the VMM and the guest memory are charged to the sandbox controller
+// from the start: the memory charged to a cgroup v2 stays there when its
+// process moves. The runtime moves back to the overhead controller once the
+// VMM is launched.
+func (s *Sandbox) runInSandboxController(start func() error) error {
+	if !s.config.SandboxCgroupOnly || s.overheadController == nil {
+		return start()
+	}
+
+	runtimePid := os.Getpid()
+	if err := s.sandboxController.AddProcess(runtimePid); err != nil {
+		return fmt.Errorf("Could not add runtime PID %d to the sandbox %s resource controller: %v", runtimePid, s.sandboxController, err)
+	}
+
+	startErr := start()
+
+	if err := s.overheadController.AddProcess(runtimePid); err != nil && startErr == nil {
+		return fmt.Errorf("Could not add runtime PID %d to the overhead %s resource controller: %v", runtimePid, s.overheadController, err)
+	}
+
+	return startErr
+}
+
 // setupResourceController adds the runtime process to either the sandbox resource controller or the
 // overhead one, depending on the sandbox_cgroup_only configuration setting.
 func (s *Sandbox) setupResourceController() error {
@@ -2778,7 +3104,9 @@ func (s *Sandbox) setupResourceController() error {
 	// By adding the runtime process to either the sandbox or overhead controller, we are making
 	// sure that any child process of the runtime (i.e. *all* processes serving a Kata pod)
 	// will initially live in this controller. Depending on the sandbox_cgroup settings, we will
-	// then move the vCPU threads between resource controllers.
+	// then move the vCPU threads between resource controllers. With sandbox_cgroup_only, the
+	// overhead controller is a sub-controller of the sandbox one, which the VMM is started in
+	// by runInSandboxController.
 	runtimePid := os.Getpid()
 	// Add the runtime to the VMM sandbox resource controller
 	if err := vmmController.AddProcess(runtimePid); err != nil {
@@ -2819,6 +3147,16 @@ func (s *Sandbox) GetOOMEvent(ctx context.Context) (string, error) {
 	return s.agent.getOOMEvent(ctx)
 }
 
+// HypervisorEvents returns the lifecycle events of the VM reported by the
+// hypervisor, or nil if the hypervisor does not report them.
+func (s *Sandbox) HypervisorEvents() <-chan HypervisorEvent {
+	if h, ok := s.hypervisor.(hypervisorEventsPublisher); ok {
+		return h.HypervisorEvents()
+	}
+
+	return nil
+}
+
 func (s *Sandbox) GetAgentURL() (string, error) {
 	return s.agent.getAgentURL()
 }
@@ -2835,7 +3173,293 @@ func (s *Sandbox) SetIPTables(ctx context.Context, isIPv6 bool, data []byte) err
 
 // SetPolicy will set the policy in the guest
 func (s *Sandbox) SetPolicy(ctx context.Context, policy string) error {
-	return s.agent.setPolicy(ctx, policy)
+	if err := s.agent.setPolicy(ctx, policy); err != nil {
+		return err
+	}
+
+	if p, ok := s.hypervisor.(guestMetadataPublisher); ok && s.guestMetadataPublished(GuestMetadataPolicy) {
+		return p.updateGuestMetadata(ctx, map[string]interface{}{
+			guestMetadataKey: map[string]interface{}{
+				GuestMetadataPolicy: policy,
+			},
+		})
+	}
+
+	return nil
+}
+
+// reconnectAgent drops the connection to the agent, which went through the
+// hypervisor process the VM was moved from, and checks the agent answers
+// through the new one.
+func (s *Sandbox) reconnectAgent(ctx context.Context) error {
+	if err := s.agent.disconnect(ctx); err != nil {
+		s.Logger().WithError(err).Warn("Failed to close the agent connection")
+	}
+
+	if err := s.agent.check(ctx); err != nil {
+		return fmt.Errorf("agent is not reachable after moving the VM: %w", err)
+	}
+
+	return nil
+}
+
+// vmSnapshotDir returns the directory of the VM snapshot dir, which is
+// relative to the configured snapshot path and cannot resolve outside of it.
+func (s *Sandbox) vmSnapshotDir(dir string) (string, error) {
+	root := s.config.HypervisorConfig.VMSnapshotPath
+	if root == "" {
+		return "", errors.New("no VM snapshot path is configured")
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("VM snapshot path %q is not absolute", root)
+	}
+
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(root, dir)
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("VM snapshot directory %q is not under %q", dir, root)
+	}
+
+	// The snapshot directory may not exist yet, but none of its existing
+	// parents may link outside of the root.
+	existing := path
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+				return "", fmt.Errorf("VM snapshot directory %q is not under %q", dir, root)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		existing = filepath.Dir(existing)
+	}
+
+	return path, nil
+}
+
+func (s *Sandbox) vmMigrator() (vmMigrator, error) {
+	m, ok := s.hypervisor.(vmMigrator)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support VM migration", s.config.HypervisorType)
+	}
+
+	return m, nil
+}
+
+// suspendVMLoops stops the rightsizing and time sync loops while the VMM is
+// paused or replaced, and returns a function restarting the ones that ran.
+func (s *Sandbox) suspendVMLoops() func() {
+	rightsizing := s.rightsizer != nil && s.rightsizer.stopCh != nil
+	if rightsizing {
+		s.rightsizer.stop()
+	}
+
+	timeSync := s.timeSyncer != nil && s.timeSyncer.stopCh != nil
+	if timeSync {
+		s.timeSyncer.stop()
+	}
+
+	return func() {
+		if rightsizing {
+			s.rightsizer.start()
+		}
+		if timeSync {
+			s.timeSyncer.start()
+		}
+	}
+}
+
+// MigrateVM moves the sandbox VM to a new hypervisor process, and stores the
+// sandbox so that it is found there when the shim restarts.
+func (s *Sandbox) MigrateVM(ctx context.Context) error {
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "MigrateVM", sandboxTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	// No resize or time sync may reach the VMM while it is replaced. The
+	// loops are stopped first, as their steps take the resize lock.
+	defer s.suspendVMLoops()()
+	s.resizeLock.Lock()
+	defer s.resizeLock.Unlock()
+
+	m, err := s.vmMigrator()
+	if err != nil {
+		return err
+	}
+
+	if err := m.migrateVM(ctx); err != nil {
+		return err
+	}
+
+	if err := s.reconnectAgent(ctx); err != nil {
+		return err
+	}
+
+	s.vmResumed(ctx)
+
+	return s.storeSandbox(ctx)
+}
+
+// SnapshotVM saves the sandbox VM to dir, under the configured VM snapshot
+// path.
+func (s *Sandbox) SnapshotVM(ctx context.Context, dir string) error {
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "SnapshotVM", sandboxTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	// The VMM is paused while the snapshot is taken.
+	defer s.suspendVMLoops()()
+	s.resizeLock.Lock()
+	defer s.resizeLock.Unlock()
+
+	m, err := s.vmMigrator()
+	if err != nil {
+		return err
+	}
+
+	dir, err = s.vmSnapshotDir(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := m.snapshotVM(ctx, dir); err != nil {
+		return err
+	}
+
+	s.vmResumed(ctx)
+
+	return nil
+}
+
+// RestoreVM replaces the sandbox VM with the one saved to dir by SnapshotVM,
+// under the configured VM snapshot path, and stores the sandbox so that it is
+// found there when the shim restarts.
+func (s *Sandbox) RestoreVM(ctx context.Context, dir string) error {
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "RestoreVM", sandboxTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	// No resize or time sync may reach the VMM while it is replaced.
+	defer s.suspendVMLoops()()
+	s.resizeLock.Lock()
+	defer s.resizeLock.Unlock()
+
+	m, err := s.vmMigrator()
+	if err != nil {
+		return err
+	}
+
+	dir, err = s.vmSnapshotDir(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := m.restoreVMSnapshot(ctx, dir); err != nil {
+		return err
+	}
+
+	if err := s.reconnectAgent(ctx); err != nil {
+		return err
+	}
+
+	s.vmResumed(ctx)
+
+	return s.storeSandbox(ctx)
+}
+
+// DumpGuest dumps the guest memory of the sandbox VM under the configured
+// guest memory dump path, and returns the directory of the dump.
+func (s *Sandbox) DumpGuest(ctx context.Context) (string, error) {
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "DumpGuest", sandboxTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	if _, ok := s.hypervisor.(guestDumper); !ok {
+		return "", fmt.Errorf("%s does not support guest memory dumps", s.config.HypervisorType)
+	}
+
+	dir, err := dumpGuest(ctx, s.hypervisor, s.id, guestDumpReasonRequest)
+	if err != nil {
+		return "", err
+	}
+
+	s.vmResumed(ctx)
+
+	return dir, nil
+}
+
+// guestMetadataKey is the key all of the sandbox metadata published to the
+// guest is found under.
+const guestMetadataKey = "kata"
+
+// Fields of the sandbox metadata that can be published to the guest.
+const (
+	GuestMetadataSandboxID        = "sandbox_id"
+	GuestMetadataSandboxName      = "sandbox_name"
+	GuestMetadataSandboxNamespace = "sandbox_namespace"
+	GuestMetadataPolicy           = "policy"
+	GuestMetadataInitdata         = "initdata"
+	GuestMetadataInitdataDigest   = "initdata_digest"
+)
+
+// GuestMetadataFields lists the fields of the sandbox metadata that can be
+// published to the guest.
+var GuestMetadataFields = []string{
+	GuestMetadataSandboxID,
+	GuestMetadataSandboxName,
+	GuestMetadataSandboxNamespace,
+	GuestMetadataPolicy,
+	GuestMetadataInitdata,
+	GuestMetadataInitdataDigest,
+}
+
+// DefaultGuestMetadataFields lists the fields of the sandbox metadata
+// published to the guest unless configured otherwise. Any process of the
+// guest able to reach the metadata service can read them, so the policy and
+// the initdata, which may hold secrets, are left out.
+var DefaultGuestMetadataFields = []string{
+	GuestMetadataSandboxID,
+	GuestMetadataSandboxName,
+	GuestMetadataSandboxNamespace,
+	GuestMetadataInitdataDigest,
+}
+
+// guestMetadataPublished tells if a field of the sandbox metadata is
+// published to the guest.
+func (s *Sandbox) guestMetadataPublished(field string) bool {
+	return slices.Contains(s.config.HypervisorConfig.MmdsMetadata, field)
+}
+
+// guestMetadata returns the sandbox metadata published to the guest by the
+// hypervisors supporting it.
+func (s *Sandbox) guestMetadata() map[string]interface{} {
+	hConfig := s.config.HypervisorConfig
+	fields := map[string]interface{}{
+		GuestMetadataSandboxID:        s.id,
+		GuestMetadataSandboxName:      hConfig.SandboxName,
+		GuestMetadataSandboxNamespace: hConfig.SandboxNamespace,
+		GuestMetadataPolicy:           s.config.AgentConfig.Policy,
+		GuestMetadataInitdata:         hConfig.Initdata,
+	}
+
+	if len(hConfig.InitdataDigest) > 0 {
+		fields[GuestMetadataInitdataDigest] = hex.EncodeToString(hConfig.InitdataDigest)
+	}
+
+	metadata := make(map[string]interface{})
+	for field, value := range fields {
+		if s.guestMetadataPublished(field) {
+			metadata[field] = value
+		}
+	}
+
+	return map[string]interface{}{
+		guestMetadataKey: metadata,
+	}
 }
 
 // GuestVolumeStats return the filesystem stat of a given volume in the guest.
@@ -2847,32 +3471,86 @@ func (s *Sandbox) GuestVolumeStats(ctx context.Context, volumePath string) ([]by
 	return s.agent.getGuestVolumeStats(ctx, guestMountPath)
 }
 
-// ResizeGuestVolume resizes a volume in the guest.
+// ListProcesses returns the processes of a container running in the guest.
+func (s *Sandbox) ListProcesses(ctx context.Context, containerID string) ([]GuestProcess, error) {
+	if _, err := s.findContainer(containerID); err != nil {
+		return nil, err
+	}
+
+	data, err := s.agent.getDiagnosticData(ctx, diagnosticProcessList, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseGuestProcesses(data)
+}
+
+// ResizeGuestVolume resizes a volume in the guest. When the volume is a block
+// device and the hypervisor can resize it online, the device is grown first so
+// that the guest filesystem can be resized to the new capacity of the disk.
 func (s *Sandbox) ResizeGuestVolume(ctx context.Context, volumePath string, size uint64) error {
-	// TODO: https://github.com/kata-containers/kata-containers/issues/3694.
-	guestMountPath, err := s.guestMountPath(volumePath)
+	m, err := s.guestMount(volumePath)
 	if err != nil {
 		return err
 	}
-	return s.agent.resizeGuestVolume(ctx, guestMountPath, size)
+
+	if err := s.resizeVolumeDevice(ctx, m, size); err != nil {
+		return err
+	}
+
+	return s.agent.resizeGuestVolume(ctx, m.GuestDeviceMount, size)
+}
+
+// resizeVolumeDevice resizes the block device backing the mount m, if any.
+func (s *Sandbox) resizeVolumeDevice(ctx context.Context, m *Mount, size uint64) error {
+	if m.BlockDeviceID == "" {
+		return nil
+	}
+
+	resizer, ok := s.hypervisor.(diskResizer)
+	if !ok {
+		s.Logger().WithField("volume", m.Source).Debugf("%s cannot resize block devices, only the guest filesystem is resized", s.config.HypervisorType)
+		return nil
+	}
+
+	device := s.devManager.GetDeviceByID(m.BlockDeviceID)
+	if device == nil {
+		return fmt.Errorf("device %s of volume %s not found", m.BlockDeviceID, m.Source)
+	}
+
+	drive, ok := device.GetDeviceInfo().(*config.BlockDrive)
+	if !ok || drive == nil || drive.Pmem {
+		return nil
+	}
+
+	return resizer.resizeDisk(ctx, drive, size)
 }
 
 func (s *Sandbox) guestMountPath(volumePath string) (string, error) {
+	m, err := s.guestMount(volumePath)
+	if err != nil {
+		return "", err
+	}
+
+	return m.GuestDeviceMount, nil
+}
+
+func (s *Sandbox) guestMount(volumePath string) (*Mount, error) {
 	// verify the device even exists
 	if _, err := os.Stat(volumePath); err != nil {
 		s.Logger().WithError(err).WithField("volume", volumePath).Error("Cannot get stats for volume that doesn't exist")
-		return "", err
+		return nil, err
 	}
 
 	// verify that we have a mount in this sandbox who's source maps to this
 	for _, c := range s.containers {
-		for _, m := range c.mounts {
+		for i, m := range c.mounts {
 			if volumePath == m.Source {
-				return m.GuestDeviceMount, nil
+				return &c.mounts[i], nil
 			}
 		}
 	}
-	return "", fmt.Errorf("mount %s not found in sandbox", volumePath)
+	return nil, fmt.Errorf("mount %s not found in sandbox", volumePath)
 }
 
 // getSandboxCPUSet returns the union of each of the sandbox's containers' CPU sets'
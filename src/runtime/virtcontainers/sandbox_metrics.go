@@ -128,6 +128,15 @@ func RegisterMetrics() {
 	prometheus.MustRegister(virtiofsdProcStat)
 	prometheus.MustRegister(virtiofsdIOStat)
 	prometheus.MustRegister(virtiofsdOpenFDs)
+	// rightsizing controller
+	prometheus.MustRegister(rightsizingVCPUs)
+	prometheus.MustRegister(rightsizingMemory)
+	prometheus.MustRegister(rightsizingDecisions)
+	prometheus.MustRegister(rightsizingErrors)
+	// guest time synchronization
+	prometheus.MustRegister(guestClockDrift)
+	prometheus.MustRegister(guestTimeResyncs)
+	prometheus.MustRegister(guestTimeSyncErrors)
 }
 
 // UpdateRuntimeMetrics update shim/hypervisor's metrics
@@ -173,6 +182,14 @@ func (s *Sandbox) UpdateRuntimeMetrics() error {
 		mutils.SetGaugeVecProcIO(hypervisorIOStat, ioStat)
 	}
 
+	// metrics the hypervisor collects itself, which an older hypervisor
+	// may not provide: the other metrics are updated anyway
+	if u, ok := s.hypervisor.(hypervisorMetricsUpdater); ok {
+		if err := u.updateHypervisorMetrics(context.Background()); err != nil {
+			s.Logger().WithError(err).Warn("failed to update the hypervisor metrics")
+		}
+	}
+
 	// virtiofs metrics
 	err = s.UpdateVirtiofsdMetrics()
 	if err != nil {
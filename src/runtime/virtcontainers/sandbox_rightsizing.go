@@ -0,0 +1,563 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+)
+
+// Default values of the sandbox rightsizing controller settings.
+const (
+	DefaultRightsizingInterval        = 10 * time.Second
+	DefaultRightsizingHighWatermark   = 80
+	DefaultRightsizingLowWatermark    = 30
+	DefaultRightsizingStableIntervals = 6
+)
+
+// Resources resized by the rightsizing controller.
+const (
+	RightsizingResourceCPU    = "cpu"
+	RightsizingResourceMemory = "memory"
+)
+
+// Number of decisions buffered for the consumer of RightsizingEvents.
+// Decisions are dropped rather than blocking the controller.
+const rightsizingEventsBufferSize = 16
+
+// Default granularity of memory resizes when the guest memory block size
+// is not known.
+const defaultRightsizingMemoryStepMB = 128
+
+var (
+	rightsizingVCPUs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "rightsizing_vcpus",
+		Help:      "Number of vCPUs of the sandbox set by the rightsizing controller.",
+	})
+
+	rightsizingMemory = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "rightsizing_memory_bytes",
+		Help:      "Guest memory of the sandbox set by the rightsizing controller.",
+	})
+
+	rightsizingDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespaceKatashim,
+		Name:      "rightsizing_decisions_total",
+		Help:      "Resizes performed by the rightsizing controller.",
+	},
+		[]string{"resource", "direction"},
+	)
+
+	rightsizingErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespaceKatashim,
+		Name:      "rightsizing_errors_total",
+		Help:      "Rightsizing controller iterations which failed.",
+	})
+)
+
+// RightsizingConfig configures the controller which resizes the sandbox VM
+// according to the guest usage. Watermarks are percentages of the vCPUs, or
+// of the guest memory, in use.
+type RightsizingConfig struct {
+	// Interval between two samples of the guest usage.
+	Interval time.Duration
+
+	// The sandbox grows as soon as the usage exceeds HighWatermark.
+	HighWatermark uint32
+
+	// The sandbox shrinks once the usage stayed below LowWatermark for
+	// StableIntervals consecutive samples.
+	LowWatermark    uint32
+	StableIntervals uint32
+
+	Enabled bool
+}
+
+// RightsizingDecision describes a resize performed by the rightsizing
+// controller. Sizes are in vCPUs for the cpu resource and in MiB for the
+// memory resource.
+type RightsizingDecision struct {
+	Timestamp time.Time
+	Resource  string
+	Reason    string
+	From      uint32
+	To        uint32
+}
+
+// guestUsage is a sample of the guest metrics exported by the agent.
+type guestUsage struct {
+	// cumulative CPU times of all the guest CPUs, in milliseconds
+	cpuBusyMs  float64
+	cpuTotalMs float64
+
+	memTotalMB     uint32
+	memAvailableMB uint32
+}
+
+// rightsizingBounds are the sizes the controller resizes the sandbox
+// between.
+type rightsizingBounds struct {
+	minVCPUs    uint32
+	maxVCPUs    uint32
+	minMemoryMB uint32
+	maxMemoryMB uint32
+}
+
+// rightsizer is the sandbox rightsizing controller. It only resizes the
+// sandbox while holding the sandbox resize lock, so that it never races with
+// updateResources, which resets its view of the sandbox size.
+type rightsizer struct {
+	s      *Sandbox
+	config RightsizingConfig
+
+	events chan RightsizingDecision
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	// protected by the sandbox resize lock
+	bounds   rightsizingBounds
+	vcpus    uint32
+	memoryMB uint32
+	previous *guestUsage
+	cpuLow   uint32
+	memLow   uint32
+
+	// whether the hypervisor can return guest memory to the host
+	shrinkMemory bool
+}
+
+func newRightsizer(s *Sandbox, config RightsizingConfig) *rightsizer {
+	if config.Interval == 0 {
+		config.Interval = DefaultRightsizingInterval
+	}
+
+	return &rightsizer{
+		s:      s,
+		config: config,
+		events: make(chan RightsizingDecision, rightsizingEventsBufferSize),
+	}
+}
+
+func (r *rightsizer) logger() *logrus.Entry {
+	return r.s.Logger().WithField("subsystem", "rightsizing")
+}
+
+// reset records the size set by updateResources along with the new bounds,
+// and restarts the hysteresis.
+func (r *rightsizer) reset(bounds rightsizingBounds, vcpus, memoryMB uint32, shrinkMemory bool) {
+	r.bounds = bounds
+	r.shrinkMemory = shrinkMemory
+	r.vcpus = vcpus
+	r.memoryMB = memoryMB
+	r.previous = nil
+	r.cpuLow = 0
+	r.memLow = 0
+
+	rightsizingVCPUs.Set(float64(vcpus))
+	rightsizingMemory.Set(float64(uint64(memoryMB) << utils.MibToBytesShift))
+}
+
+func (r *rightsizer) start() {
+	if r.stopCh != nil {
+		return
+	}
+
+	r.stopCh = make(chan struct{})
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				if err := r.step(r.s.ctx); err != nil {
+					rightsizingErrors.Inc()
+					r.logger().WithError(err).Warn("failed to rightsize sandbox")
+				}
+			}
+		}
+	}()
+
+	r.logger().WithFields(logrus.Fields{
+		"interval":         r.config.Interval,
+		"high-watermark":   r.config.HighWatermark,
+		"low-watermark":    r.config.LowWatermark,
+		"stable-intervals": r.config.StableIntervals,
+	}).Info("rightsizing controller started")
+}
+
+func (r *rightsizer) stop() {
+	if r.stopCh == nil {
+		return
+	}
+
+	close(r.stopCh)
+	r.wg.Wait()
+	r.stopCh = nil
+}
+
+// step samples the guest usage and resizes the sandbox if needed.
+func (r *rightsizer) step(ctx context.Context) error {
+	metrics, err := r.s.GetAgentMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	usage, err := parseGuestUsage(metrics)
+	if err != nil {
+		return err
+	}
+
+	r.s.resizeLock.Lock()
+	defer r.s.resizeLock.Unlock()
+
+	previous := r.previous
+	r.previous = &usage
+	if previous == nil {
+		return nil
+	}
+
+	vcpus, cpuReason := r.decideVCPUs(previous, &usage)
+	if vcpus != r.vcpus {
+		if err := r.resizeVCPUs(ctx, vcpus, cpuReason); err != nil {
+			return err
+		}
+	}
+
+	memoryMB, memReason := r.decideMemory(&usage)
+	if memoryMB != r.memoryMB {
+		if err := r.resizeMemory(ctx, memoryMB, memReason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// targetSize returns the size for which used would sit in the middle of the
+// watermarks.
+func (r *rightsizer) targetSize(used float64) uint32 {
+	middle := float64(r.config.HighWatermark+r.config.LowWatermark) / 2
+	return uint32(math.Ceil(used * 100 / middle))
+}
+
+func clampSize(size, min, max uint32) uint32 {
+	if size > max {
+		size = max
+	}
+	if size < min {
+		size = min
+	}
+	return size
+}
+
+// decideVCPUs returns the number of vCPUs the sandbox should have according
+// to the CPU time used between two samples.
+func (r *rightsizer) decideVCPUs(previous, current *guestUsage) (uint32, string) {
+	total := current.cpuTotalMs - previous.cpuTotalMs
+	busy := current.cpuBusyMs - previous.cpuBusyMs
+	if total <= 0 || busy < 0 || r.vcpus == 0 {
+		return r.vcpus, ""
+	}
+
+	percent := busy * 100 / total
+	busyVCPUs := busy / total * float64(r.vcpus)
+
+	switch {
+	case percent >= float64(r.config.HighWatermark):
+		r.cpuLow = 0
+
+		target := r.targetSize(busyVCPUs)
+		if target <= r.vcpus {
+			target = r.vcpus + 1
+		}
+
+		return clampSize(target, r.bounds.minVCPUs, r.bounds.maxVCPUs),
+			fmt.Sprintf("cpu usage %.0f%% above high watermark", percent)
+
+	case percent < float64(r.config.LowWatermark):
+		r.cpuLow++
+		if r.cpuLow < r.config.StableIntervals {
+			return r.vcpus, ""
+		}
+		r.cpuLow = 0
+
+		target := r.targetSize(busyVCPUs)
+		if target >= r.vcpus {
+			target = r.vcpus - 1
+		}
+
+		return clampSize(target, r.bounds.minVCPUs, r.bounds.maxVCPUs),
+			fmt.Sprintf("cpu usage %.0f%% below low watermark for %d intervals", percent, r.config.StableIntervals)
+	}
+
+	r.cpuLow = 0
+	return r.vcpus, ""
+}
+
+// decideMemory returns the guest memory size the sandbox should have
+// according to the memory used by the guest.
+func (r *rightsizer) decideMemory(usage *guestUsage) (uint32, string) {
+	if r.memoryMB == 0 || usage.memTotalMB == 0 || usage.memAvailableMB > usage.memTotalMB {
+		return r.memoryMB, ""
+	}
+
+	used := usage.memTotalMB - usage.memAvailableMB
+
+	// Memory held by the balloon may still be accounted as used by the
+	// guest kernel.
+	if usage.memTotalMB > r.memoryMB {
+		reclaimed := usage.memTotalMB - r.memoryMB
+		if reclaimed > used {
+			reclaimed = used
+		}
+		used -= reclaimed
+	}
+
+	percent := float64(used) * 100 / float64(r.memoryMB)
+
+	step := r.s.state.GuestMemoryBlockSizeMB
+	if step == 0 {
+		step = defaultRightsizingMemoryStepMB
+	}
+
+	align := func(size uint32) uint32 {
+		return (size + step - 1) / step * step
+	}
+
+	switch {
+	case percent >= float64(r.config.HighWatermark):
+		r.memLow = 0
+
+		target := align(r.targetSize(float64(used)))
+		if target <= r.memoryMB {
+			target = align(r.memoryMB + 1)
+		}
+
+		return clampSize(target, r.bounds.minMemoryMB, r.bounds.maxMemoryMB),
+			fmt.Sprintf("memory usage %.0f%% above high watermark", percent)
+
+	case percent < float64(r.config.LowWatermark) && r.shrinkMemory:
+		r.memLow++
+		if r.memLow < r.config.StableIntervals {
+			return r.memoryMB, ""
+		}
+		r.memLow = 0
+
+		target := align(r.targetSize(float64(used)))
+		if target >= r.memoryMB {
+			return r.memoryMB, ""
+		}
+
+		return clampSize(target, r.bounds.minMemoryMB, r.bounds.maxMemoryMB),
+			fmt.Sprintf("memory usage %.0f%% below low watermark for %d intervals", percent, r.config.StableIntervals)
+	}
+
+	r.memLow = 0
+	return r.memoryMB, ""
+}
+
+func (r *rightsizer) resizeVCPUs(ctx context.Context, vcpus uint32, reason string) error {
+	oldCPUs, newCPUs, err := r.s.hypervisor.ResizeVCPUs(ctx, vcpus)
+	if err != nil {
+		return err
+	}
+
+	if oldCPUs < newCPUs {
+		if err := r.s.agent.onlineCPUMem(ctx, newCPUs, true); err != nil {
+			return err
+		}
+	}
+
+	r.record(RightsizingResourceCPU, r.vcpus, newCPUs, reason)
+	r.vcpus = newCPUs
+	rightsizingVCPUs.Set(float64(newCPUs))
+
+	return nil
+}
+
+func (r *rightsizer) resizeMemory(ctx context.Context, memoryMB uint32, reason string) error {
+	if err := r.s.updateMemory(ctx, memoryMB); err != nil {
+		return err
+	}
+
+	newMemoryMB := r.s.hypervisor.GetTotalMemoryMB(ctx)
+	if newMemoryMB == r.memoryMB {
+		r.logger().WithField("memory-mb", memoryMB).Debug("hypervisor cannot resize guest memory")
+		return nil
+	}
+
+	r.record(RightsizingResourceMemory, r.memoryMB, newMemoryMB, reason)
+	r.memoryMB = newMemoryMB
+	rightsizingMemory.Set(float64(uint64(newMemoryMB) << utils.MibToBytesShift))
+
+	return nil
+}
+
+func (r *rightsizer) record(resource string, from, to uint32, reason string) {
+	direction := "up"
+	if to < from {
+		direction = "down"
+	}
+
+	rightsizingDecisions.WithLabelValues(resource, direction).Inc()
+
+	decision := RightsizingDecision{
+		Timestamp: time.Now(),
+		Resource:  resource,
+		Reason:    reason,
+		From:      from,
+		To:        to,
+	}
+
+	r.logger().WithFields(logrus.Fields{
+		"resource": resource,
+		"from":     from,
+		"to":       to,
+		"reason":   reason,
+	}).Info("sandbox rightsized")
+
+	select {
+	case r.events <- decision:
+	default:
+		r.logger().WithField("resource", resource).Warn("rightsizing event dropped")
+	}
+}
+
+// parseGuestUsage extracts the guest CPU and memory usage from the metrics
+// returned by the agent.
+func parseGuestUsage(metrics string) (guestUsage, error) {
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(strings.NewReader(metrics))
+	if err != nil {
+		return guestUsage{}, err
+	}
+
+	var usage guestUsage
+
+	cpuTime, ok := families["kata_guest_cpu_time"]
+	if !ok {
+		return guestUsage{}, fmt.Errorf("no guest CPU time in agent metrics")
+	}
+
+	for _, m := range cpuTime.GetMetric() {
+		if metricLabel(m, "cpu") != "total" {
+			continue
+		}
+
+		value := metricValue(m)
+
+		switch metricLabel(m, "item") {
+		case "idle", "iowait":
+			usage.cpuTotalMs += value
+		case "user", "nice", "system", "irq", "softirq", "steal":
+			usage.cpuTotalMs += value
+			usage.cpuBusyMs += value
+		}
+	}
+
+	memInfo, ok := families["kata_guest_meminfo"]
+	if !ok {
+		return guestUsage{}, fmt.Errorf("no guest memory information in agent metrics")
+	}
+
+	for _, m := range memInfo.GetMetric() {
+		// the agent reports memory sizes in bytes
+		value := uint32(uint64(metricValue(m)) >> utils.MibToBytesShift)
+
+		switch metricLabel(m, "item") {
+		case "mem_total":
+			usage.memTotalMB = value
+		case "mem_available":
+			usage.memAvailableMB = value
+		}
+	}
+
+	return usage, nil
+}
+
+func metricLabel(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue()
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue()
+	case m.GetUntyped() != nil:
+		return m.GetUntyped().GetValue()
+	}
+	return 0
+}
+
+// resetRightsizing is called by updateResources once the sandbox has been
+// resized to fit its containers. The controller resizes the sandbox between
+// its default size and the pod limits on top of it, or the hypervisor
+// maximum for sandboxes without limits.
+func (s *Sandbox) resetRightsizing(workloadVCPUs float32, workloadMemoryMB uint32, vcpus, memoryMB uint32) {
+	if s.rightsizer == nil {
+		return
+	}
+
+	hconfig := s.hypervisor.HypervisorConfig()
+
+	bounds := rightsizingBounds{
+		minVCPUs:    hconfig.NumVCPUs(),
+		maxVCPUs:    hconfig.DefaultMaxVCPUs,
+		minMemoryMB: hconfig.MemorySize,
+		maxMemoryMB: uint32(hconfig.DefaultMaxMemorySize),
+	}
+
+	if workloadVCPUs > 0 {
+		bounds.maxVCPUs = min(bounds.maxVCPUs, RoundUpNumVCPUs(hconfig.NumVCPUsF+workloadVCPUs))
+	}
+
+	if workloadMemoryMB > 0 {
+		bounds.maxMemoryMB = min(bounds.maxMemoryMB, hconfig.MemorySize+workloadMemoryMB)
+	}
+
+	// Memory hot unplug is not supported, so without virtio-mem or a
+	// balloon the sandbox memory only grows.
+	caps := s.hypervisor.Capabilities(s.ctx)
+
+	s.rightsizer.reset(bounds, vcpus, memoryMB, caps.IsMemoryShrinkSupported())
+}
+
+// RightsizingEvents returns the resizes performed by the rightsizing
+// controller, or nil if the controller is disabled.
+func (s *Sandbox) RightsizingEvents() <-chan RightsizingDecision {
+	if s.rightsizer == nil {
+		return nil
+	}
+
+	return s.rightsizer.events
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAgentMetrics(busyMs, idleMs float64, memTotalMB, memAvailableMB uint64) string {
+	return fmt.Sprintf(`# HELP kata_guest_cpu_time Guest CPU statistics.
+# TYPE kata_guest_cpu_time gauge
+kata_guest_cpu_time{cpu="0",item="user"} 1000
+kata_guest_cpu_time{cpu="total",item="user"} %f
+kata_guest_cpu_time{cpu="total",item="system"} 0
+kata_guest_cpu_time{cpu="total",item="idle"} %f
+kata_guest_cpu_time{cpu="total",item="guest"} 500
+# HELP kata_guest_meminfo Statistics about memory usage in the system.
+# TYPE kata_guest_meminfo gauge
+kata_guest_meminfo{item="mem_total"} %d
+kata_guest_meminfo{item="mem_free"} 0
+kata_guest_meminfo{item="mem_available"} %d
+`, busyMs, idleMs, memTotalMB<<20, memAvailableMB<<20)
+}
+
+func newTestRightsizer() *rightsizer {
+	s := &Sandbox{
+		id:         "rightsizing",
+		ctx:        context.Background(),
+		hypervisor: &mockHypervisor{config: HypervisorConfig{MemorySize: 2048}},
+		agent:      &mockAgent{},
+		config:     &SandboxConfig{},
+	}
+
+	r := newRightsizer(s, RightsizingConfig{
+		Enabled:         true,
+		HighWatermark:   80,
+		LowWatermark:    30,
+		StableIntervals: 3,
+	})
+
+	s.rightsizer = r
+	r.reset(rightsizingBounds{
+		minVCPUs:    1,
+		maxVCPUs:    8,
+		minMemoryMB: 512,
+		maxMemoryMB: 4096,
+	}, 4, 2048, true)
+
+	return r
+}
+
+func TestParseGuestUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	usage, err := parseGuestUsage(testAgentMetrics(300, 700, 2048, 1536))
+	assert.NoError(err)
+	assert.Equal(300.0, usage.cpuBusyMs)
+	assert.Equal(1000.0, usage.cpuTotalMs)
+	assert.Equal(uint32(2048), usage.memTotalMB)
+	assert.Equal(uint32(1536), usage.memAvailableMB)
+
+	_, err = parseGuestUsage(`kata_guest_load{item="load1"} 1`)
+	assert.Error(err)
+
+	_, err = parseGuestUsage(`kata_guest_cpu_time{cpu="total",item="user"} 1`)
+	assert.Error(err)
+
+	_, err = parseGuestUsage("not metrics {")
+	assert.Error(err)
+}
+
+func TestRightsizerDecideVCPUs(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newTestRightsizer()
+
+	sample := func(busy, total float64) *guestUsage {
+		return &guestUsage{cpuBusyMs: busy, cpuTotalMs: total}
+	}
+
+	// 90% of 4 vCPUs busy: grow so that the usage is back between the
+	// watermarks.
+	vcpus, reason := r.decideVCPUs(sample(0, 0), sample(900, 1000))
+	assert.Equal(uint32(7), vcpus)
+	assert.NotEmpty(reason)
+
+	// never above the pod limit
+	vcpus, _ = r.decideVCPUs(sample(0, 0), sample(1000, 1000))
+	assert.Equal(uint32(8), vcpus)
+
+	// between the watermarks
+	vcpus, reason = r.decideVCPUs(sample(0, 0), sample(500, 1000))
+	assert.Equal(uint32(4), vcpus)
+	assert.Empty(reason)
+
+	// shrink only after StableIntervals samples below the low watermark
+	for i := 0; i < 2; i++ {
+		vcpus, _ = r.decideVCPUs(sample(0, 0), sample(100, 1000))
+		assert.Equal(uint32(4), vcpus)
+	}
+
+	// a sample between the watermarks restarts the hysteresis
+	r.decideVCPUs(sample(0, 0), sample(500, 1000))
+	for i := 0; i < 2; i++ {
+		vcpus, _ = r.decideVCPUs(sample(0, 0), sample(100, 1000))
+		assert.Equal(uint32(4), vcpus)
+	}
+
+	vcpus, reason = r.decideVCPUs(sample(0, 0), sample(100, 1000))
+	assert.Equal(uint32(1), vcpus)
+	assert.NotEmpty(reason)
+
+	// no CPU time elapsed
+	vcpus, _ = r.decideVCPUs(sample(100, 1000), sample(100, 1000))
+	assert.Equal(uint32(4), vcpus)
+}
+
+func TestRightsizerDecideMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newTestRightsizer()
+	r.s.state.GuestMemoryBlockSizeMB = 128
+
+	// 1843MB of 2048MB used
+	memoryMB, reason := r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 205})
+	assert.Equal(uint32(3456), memoryMB)
+	assert.NotEmpty(reason)
+	assert.Zero(memoryMB % 128)
+
+	// never above the pod limit
+	r.bounds.maxMemoryMB = 3072
+	memoryMB, _ = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 0})
+	assert.Equal(uint32(3072), memoryMB)
+
+	// 256MB used
+	for i := 0; i < 2; i++ {
+		memoryMB, _ = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 1792})
+		assert.Equal(uint32(2048), memoryMB)
+	}
+	memoryMB, reason = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 1792})
+	assert.Equal(uint32(512), memoryMB)
+	assert.NotEmpty(reason)
+
+	// The balloon inflated to shrink the guest to 1024MB is accounted as
+	// used by the guest: only 256MB are actually in use.
+	r.memoryMB = 1024
+	for i := 0; i < 3; i++ {
+		memoryMB, _ = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 768})
+	}
+	assert.Equal(uint32(512), memoryMB)
+
+	// Without virtio-mem or a balloon the guest memory only grows.
+	r.shrinkMemory = false
+	r.memoryMB = 2048
+	for i := 0; i < 6; i++ {
+		memoryMB, reason = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 1792})
+		assert.Equal(uint32(2048), memoryMB)
+		assert.Empty(reason)
+	}
+
+	memoryMB, _ = r.decideMemory(&guestUsage{memTotalMB: 2048, memAvailableMB: 0})
+	assert.Equal(uint32(3072), memoryMB)
+}
+
+func TestRightsizerResize(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newTestRightsizer()
+	ctx := context.Background()
+
+	assert.NoError(r.resizeMemory(ctx, 1024, "test"))
+	assert.Equal(uint32(1024), r.memoryMB)
+
+	select {
+	case d := <-r.s.RightsizingEvents():
+		assert.Equal(RightsizingResourceMemory, d.Resource)
+		assert.Equal(uint32(2048), d.From)
+		assert.Equal(uint32(1024), d.To)
+		assert.Equal("test", d.Reason)
+	default:
+		t.Fatal("no rightsizing event")
+	}
+
+	// Events are dropped rather than blocking the controller.
+	for i := 0; i < rightsizingEventsBufferSize+1; i++ {
+		r.record(RightsizingResourceCPU, 1, 2, "test")
+	}
+	assert.Len(r.events, rightsizingEventsBufferSize)
+}
+
+func TestSandboxResetRightsizing(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		hypervisor: &mockHypervisor{config: HypervisorConfig{
+			NumVCPUsF:            1,
+			DefaultMaxVCPUs:      16,
+			MemorySize:           256,
+			DefaultMaxMemorySize: 8192,
+		}},
+	}
+
+	// disabled
+	s.resetRightsizing(2, 1024, 3, 1280)
+	assert.Nil(s.RightsizingEvents())
+
+	s.rightsizer = newRightsizer(s, RightsizingConfig{Enabled: true})
+	assert.Equal(DefaultRightsizingInterval, s.rightsizer.config.Interval)
+
+	s.resetRightsizing(2, 1024, 3, 1280)
+	assert.Equal(rightsizingBounds{minVCPUs: 1, maxVCPUs: 3, minMemoryMB: 256, maxMemoryMB: 1280}, s.rightsizer.bounds)
+	assert.Equal(uint32(3), s.rightsizer.vcpus)
+	assert.Equal(uint32(1280), s.rightsizer.memoryMB)
+	assert.False(s.rightsizer.shrinkMemory)
+
+	// The bounds come from the pod limits, not from the current size: a
+	// sandbox smaller than its limits can grow up to them.
+	s.resetRightsizing(2, 1024, 2, 768)
+	assert.Equal(rightsizingBounds{minVCPUs: 1, maxVCPUs: 3, minMemoryMB: 256, maxMemoryMB: 1280}, s.rightsizer.bounds)
+	assert.Equal(uint32(2), s.rightsizer.vcpus)
+	assert.Equal(uint32(768), s.rightsizer.memoryMB)
+
+	// pod limits above the hypervisor maximum
+	s.resetRightsizing(32, 16384, 16, 8192)
+	assert.Equal(rightsizingBounds{minVCPUs: 1, maxVCPUs: 16, minMemoryMB: 256, maxMemoryMB: 8192}, s.rightsizer.bounds)
+
+	// pods without limits can grow up to the hypervisor maximum
+	s.resetRightsizing(0, 0, 1, 256)
+	assert.Equal(rightsizingBounds{minVCPUs: 1, maxVCPUs: 16, minMemoryMB: 256, maxMemoryMB: 8192}, s.rightsizer.bounds)
+}
@@ -7,6 +7,7 @@ package virtcontainers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -15,11 +16,13 @@ import (
 	"sync"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/drivers"
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/manager"
 	ktu "github.com/kata-containers/kata-containers/src/runtime/pkg/katatestutils"
+	resCtrl "github.com/kata-containers/kata-containers/src/runtime/pkg/resourcecontrol"
 	exp "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/experimental"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/fs"
 
@@ -1199,6 +1202,69 @@ func TestAttachBlockDevice(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+type mockDiskResizer struct {
+	mockHypervisor
+	drive *config.BlockDrive
+	size  uint64
+}
+
+func (m *mockDiskResizer) resizeDisk(ctx context.Context, drive *config.BlockDrive, size uint64) error {
+	m.drive = drive
+	m.size = size
+	return nil
+}
+
+func TestSandboxResizeGuestVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	volumePath := t.TempDir()
+	dm := manager.NewDeviceManager(config.VirtioBlock, false, "", 0, nil)
+	device, err := dm.NewDevice(config.DeviceInfo{
+		HostPath:      "/dev/hda",
+		ContainerPath: "/dev/hda",
+		DevType:       "b",
+	})
+	assert.NoError(err)
+	drive := &config.BlockDrive{File: "/dev/hda", Index: 1}
+	device.(*drivers.BlockDevice).BlockDrive = drive
+
+	hypervisor := &mockDiskResizer{}
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         testSandboxID,
+		agent:      &mockAgent{},
+		hypervisor: hypervisor,
+		devManager: dm,
+		config:     &SandboxConfig{},
+		containers: map[string]*Container{
+			"100": {
+				mounts: []Mount{
+					{
+						Source:           volumePath,
+						GuestDeviceMount: "/run/kata-containers/sandbox/storage/volume",
+						BlockDeviceID:    device.DeviceID(),
+					},
+				},
+			},
+		},
+	}
+
+	err = sandbox.ResizeGuestVolume(context.Background(), "/no/such/volume", 1<<30)
+	assert.Error(err)
+	assert.Nil(hypervisor.drive)
+
+	err = sandbox.ResizeGuestVolume(context.Background(), volumePath, 1<<30)
+	assert.NoError(err)
+	assert.Equal(drive, hypervisor.drive)
+	assert.Equal(uint64(1<<30), hypervisor.size)
+
+	// Hypervisors unable to resize disks only resize the guest filesystem
+	sandbox.hypervisor = &mockHypervisor{}
+	err = sandbox.ResizeGuestVolume(context.Background(), volumePath, 2<<30)
+	assert.NoError(err)
+	assert.Equal(uint64(1<<30), hypervisor.size)
+}
+
 func TestPreAddDevice(t *testing.T) {
 	hypervisor := &mockHypervisor{}
 
@@ -1291,6 +1357,217 @@ func TestSandboxStopStopped(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestSandboxMigrateVMUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{},
+		config: &SandboxConfig{
+			HypervisorType: MockHypervisor,
+		},
+	}
+
+	assert.Error(s.MigrateVM(context.Background()))
+	assert.Error(s.SnapshotVM(context.Background(), "snapshot"))
+	assert.Error(s.RestoreVM(context.Background(), "snapshot"))
+
+	_, err := s.DumpGuest(context.Background())
+	assert.Error(err)
+}
+
+type mockVMMigrator struct {
+	mockHypervisor
+	snapshotDir string
+	snapshot    func()
+}
+
+func (m *mockVMMigrator) migrateVM(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockVMMigrator) snapshotVM(ctx context.Context, dir string) error {
+	if m.snapshot != nil {
+		m.snapshot()
+	}
+	m.snapshotDir = dir
+	return nil
+}
+
+func (m *mockVMMigrator) restoreVMSnapshot(ctx context.Context, dir string) error {
+	return nil
+}
+
+type mockReconnectAgent struct {
+	mockAgent
+	disconnected bool
+	checkErr     error
+}
+
+func (m *mockReconnectAgent) disconnect(ctx context.Context) error {
+	m.disconnected = true
+	return nil
+}
+
+func (m *mockReconnectAgent) check(ctx context.Context) error {
+	return m.checkErr
+}
+
+func TestSandboxSnapshotVMDir(t *testing.T) {
+	assert := assert.New(t)
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	assert.NoError(os.Symlink(outside, filepath.Join(root, "link")))
+
+	hypervisor := &mockVMMigrator{}
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: hypervisor,
+		config: &SandboxConfig{
+			HypervisorType: MockHypervisor,
+		},
+	}
+
+	// Snapshots require a snapshot path
+	assert.Error(s.SnapshotVM(context.Background(), "snapshot"))
+
+	s.config.HypervisorConfig.VMSnapshotPath = root
+	assert.NoError(s.SnapshotVM(context.Background(), "snapshot"))
+	assert.Equal(filepath.Join(root, "snapshot"), hypervisor.snapshotDir)
+
+	// Absolute directories are taken relative to the snapshot path too
+	assert.NoError(s.SnapshotVM(context.Background(), "/snapshot/1"))
+	assert.Equal(filepath.Join(root, "snapshot", "1"), hypervisor.snapshotDir)
+
+	for _, dir := range []string{"", ".", "..", "../snapshot", "snapshot/../..", "link", "link/snapshot"} {
+		hypervisor.snapshotDir = ""
+		assert.Error(s.SnapshotVM(context.Background(), dir), dir)
+		assert.Empty(hypervisor.snapshotDir, dir)
+	}
+}
+
+func TestSandboxReconnectAgent(t *testing.T) {
+	assert := assert.New(t)
+
+	agent := &mockReconnectAgent{}
+	s := &Sandbox{
+		id:         testSandboxID,
+		agent:      agent,
+		hypervisor: &mockVMMigrator{},
+		config: &SandboxConfig{
+			HypervisorType: MockHypervisor,
+		},
+	}
+
+	assert.NoError(s.reconnectAgent(context.Background()))
+	assert.True(agent.disconnected)
+
+	// The VM was moved, but its agent cannot be reached anymore
+	agent.disconnected = false
+	agent.checkErr = fmt.Errorf("agent unreachable")
+	assert.Error(s.MigrateVM(context.Background()))
+	assert.True(agent.disconnected)
+}
+
+func TestSandboxSnapshotVMSuspendsLoops(t *testing.T) {
+	assert := assert.New(t)
+
+	hypervisor := &mockVMMigrator{}
+	s := &Sandbox{
+		id:         testSandboxID,
+		agent:      &mockReconnectAgent{},
+		hypervisor: hypervisor,
+		config: &SandboxConfig{
+			HypervisorType: MockHypervisor,
+		},
+	}
+	s.config.HypervisorConfig.VMSnapshotPath = t.TempDir()
+	s.rightsizer = newRightsizer(s, RightsizingConfig{Enabled: true, Interval: time.Hour})
+	s.timeSyncer = newTimeSyncer(s, GuestTimeSyncConfig{Enabled: true, Interval: time.Hour})
+	s.rightsizer.start()
+	s.timeSyncer.start()
+	defer s.rightsizer.stop()
+	defer s.timeSyncer.stop()
+
+	// No resize or time sync runs while the VM is paused
+	hypervisor.snapshot = func() {
+		assert.False(s.resizeLock.TryLock())
+		assert.Nil(s.rightsizer.stopCh)
+		assert.Nil(s.timeSyncer.stopCh)
+	}
+	assert.NoError(s.SnapshotVM(context.Background(), "snapshot"))
+	assert.NotEmpty(hypervisor.snapshotDir)
+
+	assert.True(s.resizeLock.TryLock())
+	s.resizeLock.Unlock()
+	assert.NotNil(s.rightsizer.stopCh)
+	assert.NotNil(s.timeSyncer.stopCh)
+}
+
+type mockMetricsHypervisor struct {
+	mockHypervisor
+}
+
+func (m *mockMetricsHypervisor) updateHypervisorMetrics(ctx context.Context) error {
+	return errors.New("no metrics")
+}
+
+func TestSandboxUpdateRuntimeMetrics(t *testing.T) {
+	// The metrics the hypervisor fails to collect don't prevent updating
+	// the others
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockMetricsHypervisor{mockHypervisor{mockPid: os.Getpid()}},
+		config:     &SandboxConfig{},
+	}
+	assert.NoError(t, s.UpdateRuntimeMetrics())
+}
+
+func TestSandboxGuestMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		id: testSandboxID,
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				SandboxName:      "pod",
+				SandboxNamespace: "default",
+				Initdata:         "version = \"0.1.0\"",
+				InitdataDigest:   []byte{0xde, 0xad},
+				MmdsMetadata:     DefaultGuestMetadataFields,
+			},
+			AgentConfig: KataAgentConfig{
+				Policy: "package agent_policy",
+			},
+		},
+	}
+
+	// The policy and the initdata are not published by default
+	metadata := s.guestMetadata()
+	assert.Equal(map[string]interface{}{
+		guestMetadataKey: map[string]interface{}{
+			"sandbox_id":        testSandboxID,
+			"sandbox_name":      "pod",
+			"sandbox_namespace": "default",
+			"initdata_digest":   "dead",
+		},
+	}, metadata)
+
+	s.config.HypervisorConfig.MmdsMetadata = []string{GuestMetadataPolicy, GuestMetadataInitdata}
+	metadata = s.guestMetadata()
+	assert.Equal(map[string]interface{}{
+		guestMetadataKey: map[string]interface{}{
+			"policy":   "package agent_policy",
+			"initdata": "version = \"0.1.0\"",
+		},
+	}, metadata)
+
+	s.config.HypervisorConfig.MmdsMetadata = nil
+	metadata = s.guestMetadata()
+	assert.Empty(metadata[guestMetadataKey])
+}
+
 func checkDirNotExist(path string) error {
 	if _, err := os.Stat(path); os.IsExist(err) {
 		return fmt.Errorf("%s is still exists", path)
@@ -1705,3 +1982,138 @@ func TestCheckVCPUsPinningNUMABadHostCPUs(t *testing.T) {
 	assert.Error(err)
 	assert.Contains(err.Error(), "failed to parse HostCPUs")
 }
+
+// fakeResourceController records the processes and threads added to it.
+type fakeResourceController struct {
+	stats     interface{}
+	processes []int
+	threads   []int
+}
+
+func (c *fakeResourceController) Type() resCtrl.ResourceControllerType { return resCtrl.LinuxCgroupsV2 }
+func (c *fakeResourceController) ID() string                           { return "/fake" }
+func (c *fakeResourceController) Parent() string                       { return "/" }
+func (c *fakeResourceController) Delete() error                        { return nil }
+func (c *fakeResourceController) Stat() (interface{}, error)           { return c.stats, nil }
+func (c *fakeResourceController) Update(*specs.LinuxResources) error   { return nil }
+func (c *fakeResourceController) MoveTo(string) error                  { return nil }
+func (c *fakeResourceController) AddDevice(string) error               { return nil }
+func (c *fakeResourceController) RemoveDevice(string) error            { return nil }
+func (c *fakeResourceController) UpdateCpuSet(string, string) error    { return nil }
+
+func (c *fakeResourceController) AddProcess(pid int, subsystems ...string) error {
+	c.processes = append(c.processes, pid)
+	return nil
+}
+
+func (c *fakeResourceController) AddThread(tid int, subsystems ...string) error {
+	c.threads = append(c.threads, tid)
+	return nil
+}
+
+func TestSandboxOverheadResources(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{config: &SandboxConfig{}}
+	assert.Equal(&specs.LinuxResources{}, s.overheadResources())
+
+	s.config.SandboxResources.OverheadCPUs = 0.5
+	s.config.SandboxResources.OverheadMemMB = 160
+	resources := s.overheadResources()
+	assert.Equal(uint64(100000), *resources.CPU.Period)
+	assert.Equal(int64(50000), *resources.CPU.Quota)
+	assert.Equal(int64(160<<20), *resources.Memory.Limit)
+}
+
+func TestSandboxStatsOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	sandboxStats := &resCtrl.CgroupV2Stats{}
+	sandboxStats.CPU.UsageUsec = 3000000
+	sandboxStats.Memory.Current = 1 << 30
+	sandboxStats.Memory.Events.OOMKill = 1
+
+	overheadStats := &resCtrl.CgroupV2Stats{}
+	overheadStats.CPU.UsageUsec = 500000
+	overheadStats.Memory.Current = 64 << 20
+
+	s := &Sandbox{
+		config:            &SandboxConfig{SandboxCgroupOnly: true},
+		hypervisor:        &mockHypervisor{},
+		sandboxController: &fakeResourceController{stats: sandboxStats},
+	}
+
+	stats, err := s.Stats(context.Background())
+	assert.NoError(err)
+	assert.Nil(stats.Overhead)
+	assert.Equal(SandboxResourceUsage{CPUTime: 3e9, Memory: 1 << 30}, stats.Workload)
+	assert.Equal(uint64(1), stats.MemoryEvents.OOMKill)
+
+	// The overhead sub-controller usage is part of the sandbox controller one
+	s.overheadController = &fakeResourceController{stats: overheadStats}
+	stats, err = s.Stats(context.Background())
+	assert.NoError(err)
+	assert.Equal(&SandboxResourceUsage{CPUTime: 5e8, Memory: 64 << 20}, stats.Overhead)
+	assert.Equal(SandboxResourceUsage{CPUTime: 25e8, Memory: 960 << 20}, stats.Workload)
+
+	// The overhead controller is a sibling of the sandbox one
+	s.config.SandboxCgroupOnly = false
+	stats, err = s.Stats(context.Background())
+	assert.NoError(err)
+	assert.Equal(SandboxResourceUsage{CPUTime: 3e9, Memory: 1 << 30}, stats.Workload)
+
+	s.overheadController = &fakeResourceController{}
+	_, err = s.Stats(context.Background())
+	assert.Error(err)
+}
+
+func TestConstrainHypervisorOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	sandboxController := &fakeResourceController{}
+	overheadController := &fakeResourceController{}
+	s := &Sandbox{
+		config:             &SandboxConfig{SandboxCgroupOnly: true},
+		hypervisor:         &mockHypervisor{},
+		sandboxController:  sandboxController,
+		overheadController: overheadController,
+	}
+
+	// The vCPU threads are constrained in the sandbox controller, the
+	// runtime already lives in the overhead one
+	assert.NoError(s.constrainHypervisor(context.Background()))
+	assert.Equal([]int{os.Getpid()}, sandboxController.threads)
+	assert.Empty(overheadController.processes)
+}
+
+func TestRunInSandboxController(t *testing.T) {
+	assert := assert.New(t)
+
+	sandboxController := &fakeResourceController{}
+	overheadController := &fakeResourceController{}
+	s := &Sandbox{
+		config:             &SandboxConfig{SandboxCgroupOnly: true},
+		sandboxController:  sandboxController,
+		overheadController: overheadController,
+	}
+
+	// The VMM is started with the runtime in the sandbox controller, which
+	// then moves back to the overhead one, even when the start fails
+	startErr := errors.New("start failed")
+	err := s.runInSandboxController(func() error {
+		assert.Equal([]int{os.Getpid()}, sandboxController.processes)
+		assert.Empty(overheadController.processes)
+		return startErr
+	})
+	assert.Equal(startErr, err)
+	assert.Equal([]int{os.Getpid()}, overheadController.processes)
+
+	// Without sandbox_cgroup_only the runtime stays in the overhead
+	// controller
+	sandboxController.processes = nil
+	overheadController.processes = nil
+	s.config.SandboxCgroupOnly = false
+	assert.NoError(s.runInSandboxController(func() error { return nil }))
+	assert.Empty(sandboxController.processes)
+	assert.Empty(overheadController.processes)
+}
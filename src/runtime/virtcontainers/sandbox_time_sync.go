@@ -0,0 +1,250 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+)
+
+// Default values of the guest time synchronization settings.
+const (
+	DefaultGuestTimeSyncInterval    = 60 * time.Second
+	DefaultGuestClockDriftThreshold = 100 * time.Millisecond
+)
+
+// Reasons the guest clock is resynchronized for.
+const (
+	guestTimeResyncDrift  = "drift"
+	guestTimeResyncResume = "resume"
+)
+
+// guestClockMetric is the agent metric holding the guest wall clock time.
+const guestClockMetric = "kata_guest_clock_realtime_seconds"
+
+var (
+	guestClockDrift = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespaceKatashim,
+		Name:      "guest_clock_drift_seconds",
+		Help:      "Offset of the guest wall clock from the host one, measured by the guest time synchronization.",
+	})
+
+	guestTimeResyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespaceKatashim,
+		Name:      "guest_time_resyncs_total",
+		Help:      "Guest clock resynchronizations to the host time.",
+	},
+		[]string{"reason"},
+	)
+
+	guestTimeSyncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespaceKatashim,
+		Name:      "guest_time_sync_errors_total",
+		Help:      "Guest time synchronization iterations which failed.",
+	})
+)
+
+// GuestTimeSyncConfig configures the periodic synchronization of the guest
+// clock with the host one.
+type GuestTimeSyncConfig struct {
+	// Interval between two measures of the guest clock drift.
+	Interval time.Duration
+
+	// The guest clock is set to the host time when it drifted by more
+	// than DriftThreshold.
+	DriftThreshold time.Duration
+
+	Enabled bool
+}
+
+// timeSyncer measures the drift of the guest clock, from the time reported
+// by the agent metrics, and sets the guest clock to the host time when it
+// drifted too much or when the VM was resumed.
+type timeSyncer struct {
+	s      *Sandbox
+	config GuestTimeSyncConfig
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	// serializes the measures and the resynchronizations
+	lock sync.Mutex
+}
+
+func newTimeSyncer(s *Sandbox, config GuestTimeSyncConfig) *timeSyncer {
+	if config.Interval == 0 {
+		config.Interval = DefaultGuestTimeSyncInterval
+	}
+
+	if config.DriftThreshold == 0 {
+		config.DriftThreshold = DefaultGuestClockDriftThreshold
+	}
+
+	return &timeSyncer{
+		s:      s,
+		config: config,
+	}
+}
+
+func (t *timeSyncer) logger() *logrus.Entry {
+	return t.s.Logger().WithField("subsystem", "time-sync")
+}
+
+func (t *timeSyncer) start() {
+	if t.stopCh != nil {
+		return
+	}
+
+	t.stopCh = make(chan struct{})
+	t.wg.Add(1)
+
+	go func() {
+		defer t.wg.Done()
+
+		ticker := time.NewTicker(t.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.stopCh:
+				return
+			case <-ticker.C:
+				if err := t.step(t.s.ctx); err != nil {
+					guestTimeSyncErrors.Inc()
+					t.logger().WithError(err).Warn("failed to synchronize guest time")
+				}
+			}
+		}
+	}()
+
+	t.logger().WithFields(logrus.Fields{
+		"interval":        t.config.Interval,
+		"drift-threshold": t.config.DriftThreshold,
+	}).Info("guest time synchronization started")
+}
+
+func (t *timeSyncer) stop() {
+	if t.stopCh == nil {
+		return
+	}
+
+	close(t.stopCh)
+	t.wg.Wait()
+	t.stopCh = nil
+}
+
+// step measures the drift of the guest clock and resynchronizes it if the
+// drift exceeds the threshold.
+func (t *timeSyncer) step(ctx context.Context) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	drift, uncertainty, err := t.measure(ctx)
+	if err != nil {
+		return err
+	}
+
+	guestClockDrift.Set(drift.Seconds())
+
+	// Only resynchronize when the guest clock drifted for sure, the
+	// guest time was sampled at some point of the round trip.
+	if drift.Abs()-uncertainty <= t.config.DriftThreshold {
+		return nil
+	}
+
+	t.logger().WithFields(logrus.Fields{
+		"drift":       drift,
+		"uncertainty": uncertainty,
+	}).Info("guest clock drifted")
+
+	return t.resyncLocked(ctx, guestTimeResyncDrift)
+}
+
+// measure returns the offset of the guest clock from the host one, along
+// with the uncertainty of the measure, which is half of the round trip of
+// the agent request.
+func (t *timeSyncer) measure(ctx context.Context) (time.Duration, time.Duration, error) {
+	sent := time.Now()
+	metrics, err := t.s.GetAgentMetrics(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	received := time.Now()
+
+	guest, err := parseGuestClock(metrics)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	uncertainty := received.Sub(sent) / 2
+	host := sent.Add(uncertainty)
+
+	return guest.Sub(host), uncertainty, nil
+}
+
+// resync sets the guest clock to the host time.
+func (t *timeSyncer) resync(ctx context.Context, reason string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.resyncLocked(ctx, reason)
+}
+
+func (t *timeSyncer) resyncLocked(ctx context.Context, reason string) error {
+	if err := t.s.agent.setGuestDateTime(ctx, time.Now()); err != nil {
+		return err
+	}
+
+	guestTimeResyncs.WithLabelValues(reason).Inc()
+	t.logger().WithField("reason", reason).Info("guest clock resynchronized")
+
+	return nil
+}
+
+// vmResumed resynchronizes the guest clock once the sandbox VM ran again
+// after having been paused, which leaves the guest clock behind.
+func (s *Sandbox) vmResumed(ctx context.Context) {
+	if s.timeSyncer == nil {
+		return
+	}
+
+	if err := s.timeSyncer.resync(ctx, guestTimeResyncResume); err != nil {
+		guestTimeSyncErrors.Inc()
+		s.timeSyncer.logger().WithError(err).Warn("failed to resynchronize guest time after resume")
+	}
+}
+
+// parseGuestClock extracts the guest wall clock time from the metrics
+// returned by the agent.
+func parseGuestClock(metrics string) (time.Time, error) {
+	var parser expfmt.TextParser
+
+	families, err := parser.TextToMetricFamilies(strings.NewReader(metrics))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	clock, ok := families[guestClockMetric]
+	if !ok || len(clock.GetMetric()) == 0 || clock.GetMetric()[0].GetGauge() == nil {
+		return time.Time{}, fmt.Errorf("no guest clock in agent metrics")
+	}
+
+	value := clock.GetMetric()[0].GetGauge().GetValue()
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return time.Time{}, fmt.Errorf("invalid guest clock %v", value)
+	}
+
+	sec, frac := math.Modf(value)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/agent/protocols/grpc"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func timeSyncMetric(t *testing.T, metric prometheus.Metric) float64 {
+	var m dto.Metric
+	assert.NoError(t, metric.Write(&m))
+	return metricValue(&m)
+}
+
+// timeSyncAgent reports a guest clock offset from the host one by offset,
+// and records the times the guest clock is set to.
+type timeSyncAgent struct {
+	mockAgent
+
+	offset  time.Duration
+	err     error
+	setTime []time.Time
+}
+
+func (a *timeSyncAgent) getAgentMetrics(ctx context.Context, req *grpc.GetMetricsRequest) (*grpc.Metrics, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	return &grpc.Metrics{Metrics: testGuestClockMetrics(time.Now().Add(a.offset))}, nil
+}
+
+func (a *timeSyncAgent) setGuestDateTime(ctx context.Context, tv time.Time) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	a.setTime = append(a.setTime, tv)
+	a.offset = 0
+	return nil
+}
+
+func testGuestClockMetrics(now time.Time) string {
+	return fmt.Sprintf(`# HELP kata_guest_clock_realtime_seconds Guest wall clock time.
+# TYPE kata_guest_clock_realtime_seconds gauge
+kata_guest_clock_realtime_seconds %f
+`, float64(now.UnixNano())/float64(time.Second))
+}
+
+func newTestTimeSyncer(agent *timeSyncAgent) *timeSyncer {
+	s := &Sandbox{
+		id:     "time-sync",
+		ctx:    context.Background(),
+		agent:  agent,
+		config: &SandboxConfig{},
+	}
+
+	s.timeSyncer = newTimeSyncer(s, GuestTimeSyncConfig{
+		Enabled:        true,
+		DriftThreshold: time.Second,
+	})
+
+	return s.timeSyncer
+}
+
+func TestParseGuestClock(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1760000000, 250000000)
+	clock, err := parseGuestClock(testGuestClockMetrics(now))
+	assert.NoError(err)
+	assert.WithinDuration(now, clock, time.Microsecond)
+
+	_, err = parseGuestClock(`kata_guest_load{item="load1"} 1`)
+	assert.Error(err)
+
+	_, err = parseGuestClock(`kata_guest_clock_realtime_seconds 0`)
+	assert.Error(err)
+
+	_, err = parseGuestClock("not metrics {")
+	assert.Error(err)
+}
+
+func TestNewTimeSyncer(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newTimeSyncer(&Sandbox{}, GuestTimeSyncConfig{Enabled: true})
+	assert.Equal(DefaultGuestTimeSyncInterval, ts.config.Interval)
+	assert.Equal(DefaultGuestClockDriftThreshold, ts.config.DriftThreshold)
+}
+
+func TestTimeSyncerStep(t *testing.T) {
+	assert := assert.New(t)
+
+	agent := &timeSyncAgent{}
+	ts := newTestTimeSyncer(agent)
+	ctx := context.Background()
+
+	// Within the threshold
+	agent.offset = 200 * time.Millisecond
+	assert.NoError(ts.step(ctx))
+	assert.Empty(agent.setTime)
+	assert.InDelta(0.2, timeSyncMetric(t, guestClockDrift), 0.1)
+
+	// Guest clock behind
+	resyncs := timeSyncMetric(t, guestTimeResyncs.WithLabelValues(guestTimeResyncDrift))
+	agent.offset = -time.Minute
+	assert.NoError(ts.step(ctx))
+	assert.Len(agent.setTime, 1)
+	assert.InDelta(-60, timeSyncMetric(t, guestClockDrift), 0.1)
+	assert.Equal(resyncs+1, timeSyncMetric(t, guestTimeResyncs.WithLabelValues(guestTimeResyncDrift)))
+
+	// Guest clock ahead
+	agent.offset = 5 * time.Second
+	assert.NoError(ts.step(ctx))
+	assert.Len(agent.setTime, 2)
+
+	agent.err = errors.New("agent unavailable")
+	assert.Error(ts.step(ctx))
+}
+
+func TestSandboxVMResumed(t *testing.T) {
+	assert := assert.New(t)
+
+	// Without time synchronization
+	s := &Sandbox{}
+	s.vmResumed(context.Background())
+
+	agent := &timeSyncAgent{}
+	ts := newTestTimeSyncer(agent)
+
+	resyncs := timeSyncMetric(t, guestTimeResyncs.WithLabelValues(guestTimeResyncResume))
+	ts.s.vmResumed(context.Background())
+	assert.Len(agent.setTime, 1)
+	assert.Equal(resyncs+1, timeSyncMetric(t, guestTimeResyncs.WithLabelValues(guestTimeResyncResume)))
+
+	// Failures are not reported to the caller
+	errs := timeSyncMetric(t, guestTimeSyncErrors)
+	agent.err = errors.New("agent unavailable")
+	ts.s.vmResumed(context.Background())
+	assert.Equal(errs+1, timeSyncMetric(t, guestTimeSyncErrors))
+}
+
+func TestTimeSyncerStartStop(t *testing.T) {
+	agent := &timeSyncAgent{}
+	ts := newTestTimeSyncer(agent)
+	ts.config.Interval = time.Millisecond
+
+	ts.start()
+	// Started once
+	ts.start()
+	time.Sleep(10 * time.Millisecond)
+	ts.stop()
+	ts.stop()
+
+	assert.Nil(t, ts.stopCh)
+}
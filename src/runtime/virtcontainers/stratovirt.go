@@ -10,12 +10,14 @@ package virtcontainers
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -52,8 +54,13 @@ const (
 	virtiofsSocket                                = "virtiofs_kata.socket"
 	nydusdSock                                    = "nydusd_kata.socket"
 	maxMmioBlkCount                               = 4
+	maxMmioNetCount                               = 2
 	machineTypeMicrovm                            = "microvm"
+	pcieRootBus                                   = "pcie.0"
+	firstPCISlot                                  = 1
+	defaultStratoVirtRootPorts                    = 8
 	mmioBus                          VirtioDriver = "mmio"
+	pciBus                           VirtioDriver = "pci"
 )
 
 var defaultKernelParames = []Param{
@@ -72,21 +79,45 @@ var defaultMicroVMParames = []Param{
 var (
 	blkDriver = map[VirtioDriver]string{
 		mmioBus: "virtio-blk-device",
+		pciBus:  "virtio-blk-pci",
 	}
 	netDriver = map[VirtioDriver]string{
 		mmioBus: "virtio-net-device",
+		pciBus:  "virtio-net-pci",
 	}
 	virtiofsDriver = map[VirtioDriver]string{
 		mmioBus: "vhost-user-fs-device",
+		pciBus:  "vhost-user-fs-pci",
 	}
 	vsockDriver = map[VirtioDriver]string{
 		mmioBus: "vhost-vsock-device",
+		pciBus:  "vhost-vsock-pci",
 	}
 	rngDriver = map[VirtioDriver]string{
 		mmioBus: "virtio-rng-device",
+		pciBus:  "virtio-rng-pci",
 	}
 	consoleDriver = map[VirtioDriver]string{
 		mmioBus: "virtio-serial-device",
+		pciBus:  "virtio-serial-pci",
+	}
+	balloonDriver = map[VirtioDriver]string{
+		mmioBus: "virtio-balloon-device",
+		pciBus:  "virtio-balloon-pci",
+	}
+
+	// Drivers of the devices hotplugged through QMP
+	blkHotplugDriver = map[VirtioDriver]string{
+		mmioBus: "virtio-blk-mmio",
+		pciBus:  "virtio-blk-pci",
+	}
+	netHotplugDriver = map[VirtioDriver]string{
+		mmioBus: "virtio-net-mmio",
+		pciBus:  "virtio-net-pci",
+	}
+	// vCPUs can only be hotplugged to the standard x86_64 machine
+	cpuHotplugDriver = map[string]string{
+		"amd64": "generic-x86-cpu",
 	}
 )
 
@@ -117,6 +148,7 @@ func (b blkDevice) getParams(config *StratovirtConfig) []string {
 
 	devParams = append(devParams, Param{"drive", b.id})
 	devParams = append(devParams, Param{"id", b.deviceID})
+	devParams = append(devParams, config.busParams(b.driver)...)
 
 	params = append(params, "-drive", strings.Join(SerializeParams(driveParams, "="), ","))
 	params = append(params, "-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(devParams, "="), ",")))
@@ -158,6 +190,7 @@ func (n netDevice) getParams(config *StratovirtConfig) []string {
 	if n.mac != "" {
 		devParams = append(devParams, Param{"mac", n.mac})
 	}
+	devParams = append(devParams, config.busParams(n.driver)...)
 
 	params = append(params, "-netdev", fmt.Sprintf("%s,%s", n.devType, strings.Join(SerializeParams(netdevParams, "="), ",")))
 	params = append(params, "-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(devParams, "="), ",")))
@@ -185,6 +218,7 @@ func (v virtioFs) getParams(config *StratovirtConfig) []string {
 	fsParams = append(fsParams, Param{"chardev", v.charDev})
 	fsParams = append(fsParams, Param{"tag", v.tag})
 	fsParams = append(fsParams, Param{"id", v.deviceID})
+	fsParams = append(fsParams, config.busParams(v.driver)...)
 
 	params = append(params, "-chardev", fmt.Sprintf("%s,%s,server,nowait", v.backend, strings.Join(SerializeParams(charParams, "="), ",")))
 	params = append(params, "-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(fsParams, "="), ",")))
@@ -210,6 +244,7 @@ func (v vhostVsock) getParams(config *StratovirtConfig) []string {
 		FDs := config.appendFDs([]*os.File{v.VHostFD})
 		devParams = append(devParams, Param{"vhostfd", fmt.Sprintf("%d", FDs[0])})
 	}
+	devParams = append(devParams, config.busParams(v.driver)...)
 
 	params = append(params, "-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(devParams, "="), ",")))
 	return params
@@ -234,6 +269,7 @@ func (r rngDevice) getParams(config *StratovirtConfig) []string {
 
 	devParams = append(devParams, Param{"rng", r.rng})
 	devParams = append(devParams, Param{"id", r.deviceID})
+	devParams = append(devParams, config.busParams(r.driver)...)
 
 	params = append(params, "-object", fmt.Sprintf("rng-random,%s", strings.Join(SerializeParams(objParams, "="), ",")))
 	params = append(params, "-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(devParams, "="), ",")))
@@ -260,6 +296,7 @@ func (c consoleDevice) getParams(config *StratovirtConfig) []string {
 	if c.id != "" {
 		devParams = append(devParams, Param{"id", c.id})
 	}
+	devParams = append(devParams, config.busParams(c.driver)...)
 
 	conParams = append(conParams, Param{"chardev", c.charDev})
 	conParams = append(conParams, Param{"id", c.deviceID})
@@ -272,6 +309,40 @@ func (c consoleDevice) getParams(config *StratovirtConfig) []string {
 	return params
 }
 
+type balloonDevice struct {
+	driver            VirtioDriver
+	id                string
+	deflateOnOOM      bool
+	freePageReporting bool
+}
+
+func (b balloonDevice) getParams(config *StratovirtConfig) []string {
+	var devParams []Param
+
+	driver := balloonDriver[b.driver]
+	devParams = append(devParams, Param{"id", b.id})
+	devParams = append(devParams, Param{"deflate-on-oom", strconv.FormatBool(b.deflateOnOOM)})
+	devParams = append(devParams, Param{"free-page-reporting", strconv.FormatBool(b.freePageReporting)})
+	devParams = append(devParams, config.busParams(b.driver)...)
+
+	return []string{"-device", fmt.Sprintf("%s,%s", driver, strings.Join(SerializeParams(devParams, "="), ","))}
+}
+
+type rootPort struct {
+	id   string
+	port int
+}
+
+func (r rootPort) getParams(config *StratovirtConfig) []string {
+	var devParams []Param
+
+	devParams = append(devParams, Param{"id", r.id})
+	devParams = append(devParams, Param{"port", fmt.Sprintf("0x%x", r.port)})
+	devParams = append(devParams, config.busParams(pciBus)...)
+
+	return []string{"-device", fmt.Sprintf("pcie-root-port,%s", strings.Join(SerializeParams(devParams, "="), ","))}
+}
+
 // StratovirtConfig keeps the custom settings and parameters to start virtual machine.
 type StratovirtConfig struct {
 	name                   string
@@ -289,6 +360,7 @@ type StratovirtConfig struct {
 	consolePath            string
 	fsSockPath             string
 	fds                    []*os.File
+	pciSlots               int
 }
 
 func (config *StratovirtConfig) appendFDs(fds []*os.File) []int {
@@ -313,11 +385,35 @@ func (config *StratovirtConfig) appendFDs(fds []*os.File) []int {
 	return fdInts
 }
 
+// busParams returns the parameters placing a device on the bus of driver. PCI
+// devices are given the next free slot of the PCIe root bus, in the order the
+// devices are added to the command line.
+func (config *StratovirtConfig) busParams(driver VirtioDriver) []Param {
+	if driver != pciBus {
+		return nil
+	}
+
+	slot := firstPCISlot + config.pciSlots
+	config.pciSlots++
+
+	return []Param{
+		{"bus", pcieRootBus},
+		{"addr", fmt.Sprintf("0x%x", slot)},
+	}
+}
+
 // State keeps StratoVirt device and pids state.
 type State struct {
 	mmioBlkSlots [maxMmioBlkCount]bool
-	pid          int
-	virtiofsPid  int
+	// mmioNetSlots holds the ID of the network device in each slot
+	mmioNetSlots [maxMmioNetCount]string
+	// rootPorts holds the ID of the device plugged in each PCIe root port
+	rootPorts       []string
+	hotpluggedVCPUs []hv.CPUDevice
+	pid             int
+	virtiofsPid     int
+	// balloonMemory is the guest memory in MiB held back by the balloon
+	balloonMemory uint32
 }
 
 type stratovirt struct {
@@ -331,6 +427,7 @@ type stratovirt struct {
 	state          State
 	stopped        atomic.Bool
 	virtiofsDaemon VirtiofsDaemon
+	events         hypervisorEventStream
 }
 
 func (s *stratovirt) getKernelParams(machineType string, initrdPath string) (string, error) {
@@ -366,6 +463,12 @@ func (s *stratovirt) getKernelParams(machineType string, initrdPath string) (str
 			{"8250.nr_uarts", "0"},
 			{"agent.log_vport", fmt.Sprintf("%d", vSockLogsPort)},
 		}...)
+
+		// Capture the quiet output of the kernel to the sandbox
+		// console log.
+		if s.config.ConsoleLogEnabled() {
+			kernelParams = append(kernelParams, Param{"console", "hvc0"})
+		}
 	}
 
 	kernelParams = append(s.config.KernelParams, kernelParams...)
@@ -447,10 +550,18 @@ func (s *stratovirt) qmpSetup() error {
 func (s *stratovirt) loopQMPEvent(event chan govmmQemu.QMPEvent) {
 	for e := range event {
 		s.Logger().WithField("event", e).Debug("got QMP event")
+		if hypervisorEvent, ok := qmpHypervisorEvent(e); ok {
+			s.events.publish(s.Logger(), hypervisorEvent)
+		}
 	}
 	s.Logger().Infof("QMP event channel closed")
 }
 
+// HypervisorEvents returns the lifecycle events of the VM reported by QMP.
+func (s *stratovirt) HypervisorEvents() <-chan HypervisorEvent {
+	return s.events.channel()
+}
+
 func (s *stratovirt) qmpShutdown() {
 	s.qmpMonitorCh.Lock()
 	defer s.qmpMonitorCh.Unlock()
@@ -465,25 +576,78 @@ func (s *stratovirt) qmpShutdown() {
 	}
 }
 
+// busDriver returns the bus the virtio devices are attached to, MMIO for the
+// microvm machine type, which is the default, and PCI for the standard ones.
+func (s *stratovirt) busDriver() VirtioDriver {
+	switch s.svConfig.machineType {
+	case "", machineTypeMicrovm:
+		return mmioBus
+	}
+
+	return pciBus
+}
+
 func (s *stratovirt) createDevices() []VirtioDev {
 	var devices []VirtioDev
 	ctx := s.ctx
 
+	// Set PCIe root ports first, so that they take the first slots of the
+	// root bus, devices are hotplugged to them.
+	if s.busDriver() == pciBus {
+		devices = s.appendRootPorts(ctx, devices)
+	}
+
 	// Set random device.
 	devices = s.appendRng(ctx, devices)
 
-	// Set serial console device for Debug.
-	if s.config.Debug {
+	// Set serial console device for Debug, or for the sandbox console log.
+	if s.config.GuestConsoleEnabled() {
 		devices = s.appendConsole(ctx, devices)
 	}
 
 	if s.svConfig.initrdPath == "" {
 		devices = s.appendBlock(ctx, devices)
-		if s.svConfig.machineType == machineTypeMicrovm {
+		if s.busDriver() == mmioBus {
 			s.state.mmioBlkSlots[0] = true
 		}
 	}
 
+	if s.balloonEnabled() {
+		devices = s.appendBalloon(ctx, devices)
+	}
+
+	return devices
+}
+
+func (s *stratovirt) appendRootPorts(ctx context.Context, devices []VirtioDev) []VirtioDev {
+	num := s.config.PCIeRootPort
+	if num == 0 {
+		num = defaultStratoVirtRootPorts
+	}
+
+	s.state.rootPorts = make([]string, num)
+	for i := range s.state.rootPorts {
+		devices = append(devices, rootPort{
+			id:   rootPortID(i),
+			port: i + 1,
+		})
+	}
+
+	return devices
+}
+
+func (s *stratovirt) appendBalloon(ctx context.Context, devices []VirtioDev) []VirtioDev {
+	devices = append(devices, balloonDevice{
+		id:                "balloon0",
+		deflateOnOOM:      true,
+		freePageReporting: s.config.ReclaimGuestFreedMemory,
+		driver:            s.busDriver(),
+	})
+
+	// The balloon is inflated to hold back the memory above the default
+	// memory once the VM is started.
+	s.state.balloonMemory = s.bootMemoryMB() - s.config.MemorySize
+
 	return devices
 }
 
@@ -492,7 +656,7 @@ func (s *stratovirt) appendBlock(ctx context.Context, devices []VirtioDev) []Vir
 		id:       "rootfs",
 		filePath: s.svConfig.rootfsPath,
 		deviceID: "virtio-blk0",
-		driver:   mmioBus,
+		driver:   s.busDriver(),
 	})
 
 	return devices
@@ -504,7 +668,7 @@ func (s *stratovirt) appendRng(ctx context.Context, devices []VirtioDev) []Virti
 		fileName: s.config.EntropySource,
 		rng:      "objrng0",
 		deviceID: "virtio-rng0",
-		driver:   mmioBus,
+		driver:   s.busDriver(),
 	})
 
 	return devices
@@ -518,7 +682,7 @@ func (s *stratovirt) appendConsole(ctx context.Context, devices []VirtioDev) []V
 		devType:  "virtconsole",
 		charDev:  "charconsole0",
 		deviceID: "virtio-console0",
-		driver:   mmioBus,
+		driver:   s.busDriver(),
 	})
 
 	return devices
@@ -529,7 +693,7 @@ func (s *stratovirt) appendVhostVsock(ctx context.Context, devices []VirtioDev,
 		id:      "vsock-id",
 		guestID: fmt.Sprintf("%d", vsock.ContextID),
 		VHostFD: vsock.VhostFd,
-		driver:  mmioBus,
+		driver:  s.busDriver(),
 	})
 
 	return devices
@@ -546,7 +710,7 @@ func (s *stratovirt) appendNetwork(ctx context.Context, devices []VirtioDev, end
 		deviceID: name,
 		FDs:      endpoint.NetworkPair().VMFds,
 		mac:      endpoint.HardwareAddr(),
-		driver:   mmioBus,
+		driver:   s.busDriver(),
 	})
 
 	return devices
@@ -565,7 +729,7 @@ func (s *stratovirt) appendVirtioFs(ctx context.Context, devices []VirtioDev, vo
 		charDev:  name,
 		tag:      volume.MountTag,
 		deviceID: "virtio-fs0",
-		driver:   mmioBus,
+		driver:   s.busDriver(),
 	})
 
 	return devices
@@ -618,7 +782,7 @@ func (s *stratovirt) setVMConfig(id string, hypervisorConfig *HypervisorConfig)
 		machineType:            machineType,
 		vmPath:                 vmPath,
 		smp:                    s.config.NumVCPUs(),
-		memory:                 uint64(s.config.MemorySize),
+		memory:                 uint64(s.bootMemoryMB()),
 		kernelPath:             kernelPath,
 		kernelAdditionalParams: kernelParams,
 		rootfsPath:             imagePath,
@@ -766,7 +930,7 @@ func (s *stratovirt) waitVM(ctx context.Context, timeout int) error {
 func (s *stratovirt) createParams(params *[]string) {
 	*params = append(*params, "-name", s.svConfig.name)
 	*params = append(*params, "-uuid", s.svConfig.uuid)
-	*params = append(*params, "-smp", strconv.Itoa(int(s.svConfig.smp)))
+	*params = append(*params, "-smp", s.smpParam())
 	*params = append(*params, "-m", strconv.Itoa(int(s.svConfig.memory)))
 	*params = append(*params, "-kernel", s.svConfig.kernelPath)
 	*params = append(*params, "-append", s.svConfig.kernelAdditionalParams)
@@ -787,6 +951,27 @@ func (s *stratovirt) createParams(params *[]string) {
 	for _, d := range s.svConfig.devices {
 		*params = append(*params, d.getParams(&s.svConfig)...)
 	}
+
+	if s.config.BootFromTemplate {
+		*params = append(*params, "-incoming", fmt.Sprintf("file:%s", s.templatePath()))
+	}
+}
+
+// smpParam returns the vCPUs of the VM, and the maximum vCPUs when vCPUs can
+// be hotplugged.
+func (s *stratovirt) smpParam() string {
+	if s.cpuHotplugSupported() && s.config.DefaultMaxVCPUs > s.svConfig.smp {
+		return fmt.Sprintf("cpus=%d,maxcpus=%d", s.svConfig.smp, s.config.DefaultMaxVCPUs)
+	}
+
+	return strconv.Itoa(int(s.svConfig.smp))
+}
+
+// templatePath returns the directory of the VM template. A StratoVirt snapshot
+// is a directory holding the guest memory and the device state files, which
+// the template factory keeps side by side.
+func (s *stratovirt) templatePath() string {
+	return filepath.Dir(s.config.DevicesStatePath)
 }
 
 // cleanupVM will remove generated files and directories related with VM.
@@ -870,22 +1055,76 @@ func (s *stratovirt) delDevSlot(Name string) error {
 	return nil
 }
 
-func (s *stratovirt) hotplugBlk(ctx context.Context, drive *config.BlockDrive, op Operation) error {
-	err := s.qmpSetup()
-	if err != nil {
-		return err
-	}
+// rootPortID returns the ID of the PCIe root port i.
+func rootPortID(i int) string {
+	return fmt.Sprintf("%s%d", config.PCIeRootPortPrefix, i)
+}
 
-	driver := "virtio-blk-mmio"
+// getRootPort reserves a free PCIe root port for the device devID. It returns
+// the ID of the root port and the PCI path of the device in the guest.
+func (s *stratovirt) getRootPort(devID string) (string, types.PciPath, error) {
+	for i, id := range s.state.rootPorts {
+		if id != "" {
+			continue
+		}
 
-	defer func() {
+		// Root ports take the first slots of the root bus, the
+		// device is the only one behind its root port.
+		portSlot, err := types.PciSlotFromInt(firstPCISlot + i)
 		if err != nil {
-			s.qmpMonitorCh.qmp.ExecuteBlockdevDel(s.qmpMonitorCh.ctx, drive.ID)
-			if errDel := s.delDevSlot(drive.VirtPath); errDel != nil {
-				s.Logger().WithError(errDel).Warn("Failed to delete device slot.")
-			}
+			return "", types.PciPath{}, err
 		}
-	}()
+		devSlot, err := types.PciSlotFromInt(0)
+		if err != nil {
+			return "", types.PciPath{}, err
+		}
+		pciPath, err := types.PciPathFromSlots(portSlot, devSlot)
+		if err != nil {
+			return "", types.PciPath{}, err
+		}
+
+		s.state.rootPorts[i] = devID
+		return rootPortID(i), pciPath, nil
+	}
+
+	return "", types.PciPath{}, fmt.Errorf("no free PCIe root port to hotplug %q, %d ports in use", devID, len(s.state.rootPorts))
+}
+
+// getNetSlot reserves a free MMIO network device slot for the device devID.
+func (s *stratovirt) getNetSlot(devID string) (int, error) {
+	for i, id := range s.state.mmioNetSlots {
+		if id == "" {
+			s.state.mmioNetSlots[i] = devID
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no free mmio slot to hotplug %q, %d slots in use", devID, maxMmioNetCount)
+}
+
+// putHotplugSlot releases the MMIO network device slot or the PCIe root port
+// the device devID was hotplugged to.
+func (s *stratovirt) putHotplugSlot(devID string) {
+	for i, id := range s.state.mmioNetSlots {
+		if id == devID {
+			s.state.mmioNetSlots[i] = ""
+		}
+	}
+
+	for i, id := range s.state.rootPorts {
+		if id == devID {
+			s.state.rootPorts[i] = ""
+		}
+	}
+}
+
+func (s *stratovirt) hotplugBlk(ctx context.Context, drive *config.BlockDrive, op Operation) (err error) {
+	if err = s.qmpSetup(); err != nil {
+		return err
+	}
+
+	bus := s.busDriver()
+	driver := blkHotplugDriver[bus]
 
 	switch op {
 	case AddDevice:
@@ -895,31 +1134,315 @@ func (s *stratovirt) hotplugBlk(ctx context.Context, drive *config.BlockDrive, o
 			ReadOnly: drive.ReadOnly,
 			AIO:      govmmQemu.BlockDeviceAIO("native"),
 		}
-		if err := s.qmpMonitorCh.qmp.ExecuteBlockdevAdd(s.qmpMonitorCh.ctx, &sblkDevice); err != nil {
+		if err = s.qmpMonitorCh.qmp.ExecuteBlockdevAdd(s.qmpMonitorCh.ctx, &sblkDevice); err != nil {
 			return err
 		}
 
-		slot, err := s.getDevSlot(drive.VirtPath)
-		if err != nil {
-			return err
+		defer func() {
+			if err != nil {
+				s.qmpMonitorCh.qmp.ExecuteBlockdevDel(s.qmpMonitorCh.ctx, drive.ID)
+			}
+		}()
+
+		var devAddr, devBus string
+		if bus == pciBus {
+			if devBus, drive.PCIPath, err = s.getRootPort(drive.ID); err != nil {
+				return err
+			}
+			devAddr = "0x0"
+		} else {
+			var slot int
+			if slot, err = s.getDevSlot(drive.VirtPath); err != nil {
+				return err
+			}
+			devAddr = fmt.Sprintf("%d", slot)
 		}
 
-		devAddr := fmt.Sprintf("%d", slot)
-		if err := s.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(s.qmpMonitorCh.ctx, drive.ID, drive.ID, driver, devAddr, "", "", 0, false, false, "", s.config.BlockDeviceLogicalSectorSize, s.config.BlockDevicePhysicalSectorSize); err != nil {
+		defer func() {
+			if err != nil {
+				s.releaseBlkSlot(drive)
+			}
+		}()
+
+		if err = s.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(s.qmpMonitorCh.ctx, drive.ID, drive.ID, driver, devAddr, devBus, "", 0, false, false, "", s.config.BlockDeviceLogicalSectorSize, s.config.BlockDevicePhysicalSectorSize); err != nil {
 			return err
 		}
 	case RemoveDevice:
-		if errDel := s.delDevSlot(drive.VirtPath); errDel != nil {
-			s.Logger().WithError(errDel).Warn("Failed to delete device slot.")
+		s.releaseBlkSlot(drive)
+		if err = s.qmpMonitorCh.qmp.ExecuteDeviceDel(s.qmpMonitorCh.ctx, drive.ID); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("operation is not supported %d", op)
+	}
+
+	return nil
+}
+
+func (s *stratovirt) releaseBlkSlot(drive *config.BlockDrive) {
+	if s.busDriver() == pciBus {
+		s.putHotplugSlot(drive.ID)
+		return
+	}
+
+	if errDel := s.delDevSlot(drive.VirtPath); errDel != nil {
+		s.Logger().WithError(errDel).Warn("Failed to delete device slot.")
+	}
+}
+
+func (s *stratovirt) hotplugNet(ctx context.Context, endpoint Endpoint, op Operation) (err error) {
+	if err = s.qmpSetup(); err != nil {
+		return err
+	}
+
+	var tap TapInterface
+	switch endpoint.Type() {
+	case VethEndpointType, IPVlanEndpointType, MacvlanEndpointType, TuntapEndpointType:
+		tap = endpoint.NetworkPair().TapInterface
+	case TapEndpointType:
+		drive := endpoint.(*TapEndpoint)
+		tap = drive.TapInterface
+	default:
+		return fmt.Errorf("this endpoint is not supported")
+	}
+
+	devID := "virtio-" + tap.ID
+	if op == RemoveDevice {
+		if err = s.qmpMonitorCh.qmp.ExecuteDeviceDel(s.qmpMonitorCh.ctx, devID); err != nil {
+			return err
 		}
-		if err := s.qmpMonitorCh.qmp.ExecuteDeviceDel(s.qmpMonitorCh.ctx, drive.ID); err != nil {
+		s.putHotplugSlot(devID)
+
+		return s.qmpMonitorCh.qmp.ExecuteNetdevDel(s.qmpMonitorCh.ctx, tap.Name)
+	}
+
+	var fdNames []string
+	for i, fd := range tap.VMFds {
+		fdName := fmt.Sprintf("fd%d", i)
+		if err = s.qmpMonitorCh.qmp.ExecuteGetFD(s.qmpMonitorCh.ctx, fdName, fd); err != nil {
 			return err
 		}
+		fdNames = append(fdNames, fdName)
+	}
+
+	if err = s.qmpMonitorCh.qmp.ExecuteNetdevAddByFds(s.qmpMonitorCh.ctx, "tap", tap.Name, fdNames, nil); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			s.putHotplugSlot(devID)
+			s.qmpMonitorCh.qmp.ExecuteNetdevDel(s.qmpMonitorCh.ctx, tap.Name)
+		}
+	}()
+
+	bus := s.busDriver()
+	driver := netHotplugDriver[bus]
+	if bus == pciBus {
+		var portID string
+		var pciPath types.PciPath
+		if portID, pciPath, err = s.getRootPort(devID); err != nil {
+			return err
+		}
+		endpoint.SetPciPath(pciPath)
+
+		return s.qmpMonitorCh.qmp.ExecuteNetDeviceAdd(s.qmpMonitorCh.ctx, tap.Name, devID, driver, endpoint.HardwareAddr(), "0x0", portID)
+	}
+
+	var slot int
+	if slot, err = s.getNetSlot(devID); err != nil {
+		return err
+	}
+
+	return s.qmpMonitorCh.qmp.ExecuteNetDeviceAdd(s.qmpMonitorCh.ctx, tap.Name, devID, driver, endpoint.HardwareAddr(), fmt.Sprintf("%d", slot), "")
+}
+
+func (s *stratovirt) hotplugVFIO(ctx context.Context, device *config.VFIODev, op Operation) (err error) {
+	if s.busDriver() != pciBus {
+		return fmt.Errorf("VFIO hotplug is not supported by the %s machine type", s.svConfig.machineType)
+	}
+
+	if err = s.qmpSetup(); err != nil {
+		return err
+	}
+
+	if op == RemoveDevice {
+		s.Logger().WithField("dev-id", device.ID).Info("Start hot-unplug VFIO device")
+
+		if err = s.qmpMonitorCh.qmp.ExecuteDeviceDel(s.qmpMonitorCh.ctx, device.ID); err != nil {
+			return err
+		}
+		s.putHotplugSlot(device.ID)
+
+		return nil
+	}
+
+	s.Logger().WithFields(logrus.Fields{
+		"dev-id": device.ID,
+		"bdf":    device.BDF,
+	}).Info("Start hot-plug VFIO device")
+
+	portID, pciPath, err := s.getRootPort(device.ID)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			s.putHotplugSlot(device.ID)
+		}
+	}()
 
+	switch device.Type {
+	case config.VFIOPCIDeviceNormalType:
+		err = s.qmpMonitorCh.qmp.ExecutePCIVFIODeviceAdd(s.qmpMonitorCh.ctx, device.ID, device.BDF, "0x0", portID, "")
+	case config.VFIOPCIDeviceMediatedType:
+		err = s.qmpMonitorCh.qmp.ExecutePCIVFIOMediatedDeviceAdd(s.qmpMonitorCh.ctx, device.ID, device.SysfsDev, "0x0", portID, "")
 	default:
-		return fmt.Errorf("operation is not supported %d", op)
+		err = fmt.Errorf("VFIO device type %v is not supported by StratoVirt", device.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	device.GuestPciPath = pciPath
+
+	return nil
+}
+
+// cpuHotplugSupported returns whether vCPUs can be hotplugged to the VM.
+func (s *stratovirt) cpuHotplugSupported() bool {
+	_, ok := cpuHotplugDriver[runtime.GOARCH]
+	return ok && s.busDriver() == pciBus
+}
+
+func (s *stratovirt) hotplugCPUs(vcpus uint32, op Operation) (uint32, error) {
+	if vcpus == 0 {
+		s.Logger().Warnf("cannot hotplug 0 vCPUs")
+		return 0, nil
+	}
+
+	if !s.cpuHotplugSupported() {
+		return 0, fmt.Errorf("vCPU hotplug is not supported by the %s machine type on %s", s.svConfig.machineType, runtime.GOARCH)
+	}
+
+	if err := s.qmpSetup(); err != nil {
+		return 0, err
+	}
+
+	if op == AddDevice {
+		return s.hotplugAddCPUs(vcpus)
+	}
+
+	return s.hotplugRemoveCPUs(vcpus)
+}
+
+// try to hot add an amount of vCPUs, returns the number of vCPUs added
+func (s *stratovirt) hotplugAddCPUs(amount uint32) (uint32, error) {
+	currentVCPUs := s.svConfig.smp + uint32(len(s.state.hotpluggedVCPUs))
+
+	// Don't fail if the number of max vCPUs is exceeded, log a warning and hot add the vCPUs needed
+	// to reach out max vCPUs
+	if currentVCPUs+amount > s.config.DefaultMaxVCPUs {
+		s.Logger().Warnf("Cannot hotplug %d CPUs, currently this SB has %d CPUs and the maximum amount of CPUs is %d",
+			amount, currentVCPUs, s.config.DefaultMaxVCPUs)
+		amount = s.config.DefaultMaxVCPUs - currentVCPUs
+	}
+
+	if amount == 0 {
+		// Don't fail if no more vCPUs can be added, since cgroups still can be updated
+		s.Logger().Warnf("maximum number of vCPUs '%d' has been reached", s.config.DefaultMaxVCPUs)
+		return 0, nil
+	}
+
+	driver := cpuHotplugDriver[runtime.GOARCH]
+	for i := uint32(0); i < amount; i++ {
+		// StratoVirt identifies vCPUs by index, the hotplugged
+		// vCPUs follow the ones the VM was booted with.
+		cpuID := int(currentVCPUs + i)
+		devID := fmt.Sprintf("cpu-%d", cpuID)
+		if err := s.qmpMonitorCh.qmp.ExecuteGenericCPUDeviceAdd(s.qmpMonitorCh.ctx, driver, devID, cpuID); err != nil {
+			return i, fmt.Errorf("failed to hot add vCPUs: only %d vCPUs of %d were added: %v", i, amount, err)
+		}
+
+		s.state.hotpluggedVCPUs = append(s.state.hotpluggedVCPUs, hv.CPUDevice{ID: devID})
+	}
+
+	return amount, nil
+}
+
+// try to  hot remove an amount of vCPUs, returns the number of vCPUs removed
+func (s *stratovirt) hotplugRemoveCPUs(amount uint32) (uint32, error) {
+	hotpluggedVCPUs := uint32(len(s.state.hotpluggedVCPUs))
+
+	// we can only remove hotplugged vCPUs
+	if amount > hotpluggedVCPUs {
+		return 0, fmt.Errorf("Unable to remove %d CPUs, currently there are only %d hotplugged CPUs", amount, hotpluggedVCPUs)
+	}
+
+	for i := uint32(0); i < amount; i++ {
+		// get the last vCPUs and try to remove it
+		cpu := s.state.hotpluggedVCPUs[len(s.state.hotpluggedVCPUs)-1]
+		if err := s.qmpMonitorCh.qmp.ExecuteDeviceDel(s.qmpMonitorCh.ctx, cpu.ID); err != nil {
+			return i, fmt.Errorf("failed to hotunplug CPUs, only %d CPUs were hotunplugged: %v", i, err)
+		}
+
+		// remove from the list the vCPU hotunplugged
+		s.state.hotpluggedVCPUs = s.state.hotpluggedVCPUs[:len(s.state.hotpluggedVCPUs)-1]
+	}
+
+	return amount, nil
+}
+
+// hotplugMemory gives memDev more memory to the guest by deflating the
+// balloon, StratoVirt cannot hotplug memory devices.
+func (s *stratovirt) hotplugMemory(ctx context.Context, memDev *MemoryDevice) (int, error) {
+	if !s.config.EnableMemoryBalloon {
+		return 0, errors.New("memory hotplug requires the StratoVirt memory balloon to be enabled")
+	}
+
+	if memDev.SizeMB < 0 {
+		return 0, fmt.Errorf("cannot hotplug negative size (%d) memory", memDev.SizeMB)
+	}
+
+	currentMemory := s.GetTotalMemoryMB(ctx)
+	newMemory, _, err := s.ResizeMemory(ctx, currentMemory+uint32(memDev.SizeMB), 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newMemory - currentMemory), nil
+}
+
+// balloonEnabled returns whether the VM has a balloon device, to resize the
+// guest memory or to reclaim the memory freed by the guest.
+func (s *stratovirt) balloonEnabled() bool {
+	return s.config.EnableMemoryBalloon || s.config.ReclaimGuestFreedMemory
+}
+
+// bootMemoryMB returns the memory the VM is booted with. StratoVirt cannot
+// hotplug memory, with the memory balloon the VM is over-provisioned instead.
+func (s *stratovirt) bootMemoryMB() uint32 {
+	if s.config.EnableMemoryBalloon && s.config.DefaultMaxMemorySize > uint64(s.config.MemorySize) {
+		return uint32(s.config.DefaultMaxMemorySize)
 	}
 
+	return s.config.MemorySize
+}
+
+// resizeBalloon sets the memory available to the guest to targetMB by
+// inflating or deflating the balloon.
+func (s *stratovirt) resizeBalloon(targetMB uint32) error {
+	if err := s.qmpSetup(); err != nil {
+		return err
+	}
+
+	if err := s.qmpMonitorCh.qmp.ExecuteBalloon(s.qmpMonitorCh.ctx, uint64(targetMB)<<utils.MibToBytesShift); err != nil {
+		s.Logger().WithError(err).WithField("target-mib", targetMB).Error("Failed to resize balloon")
+		return err
+	}
+	s.state.balloonMemory = s.bootMemoryMB() - targetMB
+
 	return nil
 }
 
@@ -1055,7 +1578,15 @@ func (s *stratovirt) StartVM(ctx context.Context, timeout int) error {
 		return err
 	}
 
-	return nil
+	// VMs booted from a template restore the balloon of the template.
+	if s.bootMemoryMB() > s.config.MemorySize && !s.config.BootFromTemplate {
+		if err = s.resizeBalloon(s.config.MemorySize); err != nil {
+			return err
+		}
+	}
+
+	// Keep a QMP connection open to observe the lifecycle events of the VM.
+	return s.qmpSetup()
 }
 
 func (s *stratovirt) StopVM(ctx context.Context, waitOnly bool) (err error) {
@@ -1107,16 +1638,77 @@ func (s *stratovirt) StopVM(ctx context.Context, waitOnly bool) (err error) {
 	return nil
 }
 
+func (s *stratovirt) togglePauseSandbox(ctx context.Context, pause bool) error {
+	span, _ := katatrace.Trace(ctx, s.Logger(), "togglePauseSandbox", stratovirtTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	if err := s.qmpSetup(); err != nil {
+		return err
+	}
+
+	if pause {
+		return s.qmpMonitorCh.qmp.ExecuteStop(s.qmpMonitorCh.ctx)
+	}
+	return s.qmpMonitorCh.qmp.ExecuteCont(s.qmpMonitorCh.ctx)
+}
+
 func (s *stratovirt) PauseVM(ctx context.Context) error {
-	return nil
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "PauseVM", stratovirtTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	return s.togglePauseSandbox(ctx, true)
 }
 
+// SaveVM snapshots the paused VM to the template directory, VMs booted from
+// the template are restored from this snapshot.
 func (s *stratovirt) SaveVM() error {
+	s.Logger().Info("Save sandbox")
+
+	if err := s.qmpSetup(); err != nil {
+		return err
+	}
+
+	err := s.qmpMonitorCh.qmp.ExecSetMigrateArguments(s.qmpMonitorCh.ctx, fmt.Sprintf("file:%s", s.templatePath()))
+	if err != nil {
+		s.Logger().WithError(err).Error("snapshot migration")
+		return err
+	}
+
+	return s.waitMigration()
+}
+
+func (s *stratovirt) waitMigration() error {
+	t := time.NewTimer(qmpMigrationWaitTimeout)
+	defer t.Stop()
+	for {
+		status, err := s.qmpMonitorCh.qmp.ExecuteQueryMigration(s.qmpMonitorCh.ctx)
+		if err != nil {
+			s.Logger().WithError(err).Error("failed to query migration status")
+			return err
+		}
+		if status.Status == "completed" {
+			break
+		}
+
+		select {
+		case <-t.C:
+			s.Logger().WithField("migration-status", status).Error("timeout waiting for StratoVirt migration")
+			return fmt.Errorf("timed out after %d seconds waiting for StratoVirt migration", qmpMigrationWaitTimeout)
+		default:
+			// migration in progress
+			s.Logger().WithField("migration-status", status).Debug("migration in progress")
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
 	return nil
 }
 
 func (s *stratovirt) ResumeVM(ctx context.Context) error {
-	return nil
+	span, ctx := katatrace.Trace(ctx, s.Logger(), "ResumeVM", stratovirtTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	return s.togglePauseSandbox(ctx, false)
 }
 
 func (s *stratovirt) AddDevice(ctx context.Context, devInfo interface{}, devType DeviceType) error {
@@ -1132,6 +1724,13 @@ func (s *stratovirt) AddDevice(ctx context.Context, devInfo interface{}, devType
 	case Endpoint:
 		s.fds = append(s.fds, v.NetworkPair().VMFds...)
 		s.svConfig.devices = s.appendNetwork(ctx, s.svConfig.devices, v)
+		// Network devices of the microvm machine type take the
+		// slots used to hotplug network devices.
+		if s.busDriver() == mmioBus {
+			if _, err := s.getNetSlot(v.Name()); err != nil {
+				s.Logger().WithError(err).Warn("Could not reserve a network device slot")
+			}
+		}
 	case config.BlockDrive:
 		s.svConfig.devices = s.appendBlock(ctx, s.svConfig.devices)
 	case types.Volume:
@@ -1150,6 +1749,14 @@ func (s *stratovirt) HotplugAddDevice(ctx context.Context, devInfo interface{},
 	switch devType {
 	case BlockDev:
 		return nil, s.hotplugBlk(ctx, devInfo.(*config.BlockDrive), AddDevice)
+	case NetDev:
+		return nil, s.hotplugNet(ctx, devInfo.(Endpoint), AddDevice)
+	case VfioDev:
+		return nil, s.hotplugVFIO(ctx, devInfo.(*config.VFIODev), AddDevice)
+	case CpuDev:
+		return s.hotplugCPUs(devInfo.(uint32), AddDevice)
+	case MemoryDev:
+		return s.hotplugMemory(ctx, devInfo.(*MemoryDevice))
 	default:
 		return nil, fmt.Errorf("Hotplug add device: unsupported device type '%v'", devType)
 	}
@@ -1162,17 +1769,89 @@ func (s *stratovirt) HotplugRemoveDevice(ctx context.Context, devInfo interface{
 	switch devType {
 	case BlockDev:
 		return nil, s.hotplugBlk(ctx, devInfo.(*config.BlockDrive), RemoveDevice)
+	case NetDev:
+		return nil, s.hotplugNet(ctx, devInfo.(Endpoint), RemoveDevice)
+	case VfioDev:
+		return nil, s.hotplugVFIO(ctx, devInfo.(*config.VFIODev), RemoveDevice)
+	case CpuDev:
+		return s.hotplugCPUs(devInfo.(uint32), RemoveDevice)
 	default:
 		return nil, fmt.Errorf("Hotplug remove device: unsupported device type '%v'", devType)
 	}
 }
 
+// ResizeMemory inflates or deflates the memory balloon so that the guest gets
+// reqMemMB. The guest memory stays between its default size and the memory
+// the VM was booted with.
 func (s *stratovirt) ResizeMemory(ctx context.Context, reqMemMB uint32, memoryBlockSizeMB uint32, probe bool) (uint32, MemoryDevice, error) {
-	return 0, MemoryDevice{}, nil
+	span, _ := katatrace.Trace(ctx, s.Logger(), "ResizeMemory", stratovirtTracingTags, map[string]string{"sandbox_id": s.id})
+	defer span.End()
+
+	if !s.config.EnableMemoryBalloon {
+		return s.GetTotalMemoryMB(ctx), MemoryDevice{}, nil
+	}
+
+	bootMemory := s.bootMemoryMB()
+	if reqMemMB > bootMemory {
+		s.Logger().Warnf("Requested %dMB of memory, the VM is limited to %dMB", reqMemMB, bootMemory)
+		reqMemMB = bootMemory
+	}
+
+	if reqMemMB < s.config.MemorySize {
+		reqMemMB = s.config.MemorySize
+	}
+
+	if bootMemory-reqMemMB == s.state.balloonMemory {
+		return reqMemMB, MemoryDevice{}, nil
+	}
+
+	if err := s.resizeBalloon(reqMemMB); err != nil {
+		return s.GetTotalMemoryMB(ctx), MemoryDevice{}, err
+	}
+
+	return reqMemMB, MemoryDevice{}, nil
 }
 
 func (s *stratovirt) ResizeVCPUs(ctx context.Context, reqVCPUs uint32) (currentVCPUs uint32, newVCPUs uint32, err error) {
-	return 0, 0, nil
+	currentVCPUs = s.svConfig.smp + uint32(len(s.state.hotpluggedVCPUs))
+	newVCPUs = currentVCPUs
+
+	if !s.cpuHotplugSupported() {
+		// Don't fail, the vCPUs are still constrained by cgroups.
+		if currentVCPUs != reqVCPUs {
+			s.Logger().Warnf("Cannot resize the VM to %d vCPUs, vCPU hotplug is not supported by the %s machine type", reqVCPUs, s.svConfig.machineType)
+		}
+		return currentVCPUs, newVCPUs, nil
+	}
+
+	switch {
+	case currentVCPUs < reqVCPUs:
+		//hotplug
+		addCPUs := reqVCPUs - currentVCPUs
+		data, err := s.HotplugAddDevice(ctx, addCPUs, CpuDev)
+		if err != nil {
+			return currentVCPUs, newVCPUs, err
+		}
+		vCPUsAdded, ok := data.(uint32)
+		if !ok {
+			return currentVCPUs, newVCPUs, fmt.Errorf("Could not get the vCPUs added, got %+v", data)
+		}
+		newVCPUs += vCPUsAdded
+	case currentVCPUs > reqVCPUs:
+		//hotunplug
+		removeCPUs := currentVCPUs - reqVCPUs
+		data, err := s.HotplugRemoveDevice(ctx, removeCPUs, CpuDev)
+		if err != nil {
+			return currentVCPUs, newVCPUs, err
+		}
+		vCPUsRemoved, ok := data.(uint32)
+		if !ok {
+			return currentVCPUs, newVCPUs, fmt.Errorf("Could not get the vCPUs removed, got %+v", data)
+		}
+		newVCPUs -= vCPUsRemoved
+	}
+
+	return currentVCPUs, newVCPUs, nil
 }
 
 func (s *stratovirt) GetVMConsole(ctx context.Context, id string) (string, string, error) {
@@ -1199,9 +1878,13 @@ func (s *stratovirt) Capabilities(ctx context.Context) types.Capabilities {
 	defer span.End()
 	var caps types.Capabilities
 	caps.SetBlockDeviceHotplugSupport()
+	caps.SetNetworkDeviceHotplugSupported()
 	if s.config.SharedFS != config.NoSharedFS {
 		caps.SetFsSharingSupport()
 	}
+	if s.balloonEnabled() {
+		caps.SetMemoryShrinkSupport()
+	}
 
 	return caps
 }
@@ -1211,7 +1894,7 @@ func (s *stratovirt) HypervisorConfig() HypervisorConfig {
 }
 
 func (s *stratovirt) GetTotalMemoryMB(ctx context.Context) uint32 {
-	return s.config.MemorySize
+	return s.bootMemoryMB() - s.state.balloonMemory
 }
 
 func (s *stratovirt) GetThreadIDs(ctx context.Context) (VcpuThreadIDs, error) {
@@ -1269,12 +1952,78 @@ func (s *stratovirt) GetVirtioFsPid() *int {
 	return &s.state.virtiofsPid
 }
 
+// stratovirtGrpc is what a runtime needs to take over a StratoVirt VM
+// created by the VM cache server.
+type stratovirtGrpc struct {
+	ID             string
+	QmpChannelpath string
+	VMPath         string
+	MachineType    string
+	SMP            uint32
+	Pid            int
+	VirtiofsPid    int
+	MmioBlkSlots   [maxMmioBlkCount]bool
+	MmioNetSlots   [maxMmioNetCount]string
+	RootPorts      []string
+	BalloonMemory  uint32
+}
+
 func (s *stratovirt) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
-	return errors.New("StratoVirt is not supported by VM cache")
+	var sp stratovirtGrpc
+	if err := json.Unmarshal(j, &sp); err != nil {
+		return err
+	}
+
+	s.ctx = ctx
+	s.id = sp.ID
+	if err := s.setConfig(hypervisorConfig); err != nil {
+		return err
+	}
+
+	s.qmpMonitorCh = qmpChannel{
+		ctx:  ctx,
+		path: sp.QmpChannelpath,
+	}
+	s.svConfig = StratovirtConfig{
+		machineType: sp.MachineType,
+		vmPath:      sp.VMPath,
+		smp:         sp.SMP,
+		memory:      uint64(s.bootMemoryMB()),
+	}
+	s.state = State{
+		mmioBlkSlots:  sp.MmioBlkSlots,
+		mmioNetSlots:  sp.MmioNetSlots,
+		rootPorts:     sp.RootPorts,
+		pid:           sp.Pid,
+		virtiofsPid:   sp.VirtiofsPid,
+		balloonMemory: sp.BalloonMemory,
+	}
+
+	var err error
+	s.virtiofsDaemon, err = s.createVirtiofsDaemon(hypervisorConfig.SharedPath)
+
+	return err
 }
 
 func (s *stratovirt) toGrpc(ctx context.Context) ([]byte, error) {
-	return nil, errors.New("StratoVirt is not supported by VM cache")
+	s.qmpShutdown()
+
+	s.Cleanup(ctx)
+	sp := stratovirtGrpc{
+		ID:             s.id,
+		QmpChannelpath: s.qmpMonitorCh.path,
+		VMPath:         s.svConfig.vmPath,
+		MachineType:    s.svConfig.machineType,
+		SMP:            s.svConfig.smp,
+		Pid:            s.state.pid,
+		VirtiofsPid:    s.state.virtiofsPid,
+		MmioBlkSlots:   s.state.mmioBlkSlots,
+		MmioNetSlots:   s.state.mmioNetSlots,
+		RootPorts:      s.state.rootPorts,
+		BalloonMemory:  s.state.balloonMemory,
+	}
+
+	return json.Marshal(&sp)
 }
 
 func (s *stratovirt) Check() error {
@@ -1293,6 +2042,8 @@ func (s *stratovirt) Save() (hs hv.HypervisorState) {
 	pids := s.GetPids()
 	hs.Pid = pids[0]
 	hs.VirtiofsDaemonPid = s.state.virtiofsPid
+	hs.HotpluggedVCPUs = s.state.hotpluggedVCPUs
+	hs.BalloonMemory = int(s.state.balloonMemory)
 	hs.Type = string(StratovirtHypervisor)
 	return
 }
@@ -1300,6 +2051,8 @@ func (s *stratovirt) Save() (hs hv.HypervisorState) {
 func (s *stratovirt) Load(hs hv.HypervisorState) {
 	s.state.pid = hs.Pid
 	s.state.virtiofsPid = hs.VirtiofsDaemonPid
+	s.state.hotpluggedVCPUs = hs.HotpluggedVCPUs
+	s.state.balloonMemory = uint32(hs.BalloonMemory)
 }
 
 func (s *stratovirt) GenerateSocket(id string) (interface{}, error) {
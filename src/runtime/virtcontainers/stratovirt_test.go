@@ -15,6 +15,7 @@ import (
 	"testing"
 
 	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	hv "github.com/kata-containers/kata-containers/src/runtime/pkg/hypervisors"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist"
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/pkg/errors"
@@ -342,6 +343,8 @@ func TestStratovirtCapabilities(t *testing.T) {
 	var ctx context.Context
 	c := sv.Capabilities(ctx)
 	assert.True(c.IsFsSharingSupported())
+	assert.True(c.IsBlockDeviceHotplugSupported())
+	assert.True(c.IsNetworkDeviceHotplugSupported())
 
 	sConfig.SharedFS = config.NoSharedFS
 
@@ -350,6 +353,15 @@ func TestStratovirtCapabilities(t *testing.T) {
 
 	c = sv.Capabilities(ctx)
 	assert.False(c.IsFsSharingSupported())
+	assert.False(c.IsMemoryShrinkSupported())
+
+	sConfig.EnableMemoryBalloon = true
+
+	err = sv.setConfig(&sConfig)
+	assert.NoError(err)
+
+	c = sv.Capabilities(ctx)
+	assert.True(c.IsMemoryShrinkSupported())
 }
 
 func TestStratovirtSetConfig(t *testing.T) {
@@ -430,3 +442,260 @@ func TestStratovirtBinPath(t *testing.T) {
 		assert.Equal(path, defaultStratoVirt)
 	}
 }
+
+func TestStratovirtBusParams(t *testing.T) {
+	assert := assert.New(t)
+
+	svConfig := StratovirtConfig{}
+
+	assert.Nil(svConfig.busParams(mmioBus))
+	assert.Equal(0, svConfig.pciSlots)
+
+	assert.Equal([]Param{{"bus", pcieRootBus}, {"addr", "0x1"}}, svConfig.busParams(pciBus))
+	assert.Equal([]Param{{"bus", pcieRootBus}, {"addr", "0x2"}}, svConfig.busParams(pciBus))
+	assert.Equal(2, svConfig.pciSlots)
+}
+
+func TestStratovirtCreateParamsRootPorts(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		ctx: context.Background(),
+		config: HypervisorConfig{
+			PCIeRootPort: 2,
+		},
+		svConfig: StratovirtConfig{
+			machineType: "q35",
+			initrdPath:  testStratovirtInitrdPath,
+		},
+	}
+	sv.svConfig.devices = sv.createDevices()
+	assert.Equal([]string{"", ""}, sv.state.rootPorts)
+
+	var params []string
+	sv.createParams(&params)
+
+	assert.Contains(params, "pcie-root-port,id=rp0,port=0x1,bus=pcie.0,addr=0x1")
+	assert.Contains(params, "pcie-root-port,id=rp1,port=0x2,bus=pcie.0,addr=0x2")
+	assert.Contains(params, "virtio-rng-pci,rng=objrng0,id=virtio-rng0,bus=pcie.0,addr=0x3")
+	assert.NotContains(params, "-incoming")
+}
+
+func TestStratovirtRootPorts(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		state: State{
+			rootPorts: make([]string, 2),
+		},
+	}
+
+	port, pciPath, err := sv.getRootPort("dev0")
+	assert.NoError(err)
+	assert.Equal("rp0", port)
+	assert.Equal("01/00", pciPath.String())
+
+	port, pciPath, err = sv.getRootPort("dev1")
+	assert.NoError(err)
+	assert.Equal("rp1", port)
+	assert.Equal("02/00", pciPath.String())
+
+	_, _, err = sv.getRootPort("dev2")
+	assert.Error(err)
+
+	sv.putHotplugSlot("dev0")
+	port, pciPath, err = sv.getRootPort("dev2")
+	assert.NoError(err)
+	assert.Equal("rp0", port)
+	assert.Equal("01/00", pciPath.String())
+	assert.Equal([]string{"dev2", "dev1"}, sv.state.rootPorts)
+}
+
+func TestStratovirtNetSlots(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{}
+
+	for i := 0; i < maxMmioNetCount; i++ {
+		slot, err := sv.getNetSlot(fmt.Sprintf("net%d", i))
+		assert.NoError(err)
+		assert.Equal(i, slot)
+	}
+
+	_, err := sv.getNetSlot("net-extra")
+	assert.Error(err)
+
+	sv.putHotplugSlot("net0")
+	slot, err := sv.getNetSlot("net-extra")
+	assert.NoError(err)
+	assert.Equal(0, slot)
+}
+
+func TestStratovirtSmpParam(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		config: HypervisorConfig{
+			DefaultMaxVCPUs: 4,
+		},
+		svConfig: StratovirtConfig{
+			machineType: machineTypeMicrovm,
+			smp:         1,
+		},
+	}
+	assert.Equal("1", sv.smpParam())
+
+	sv.svConfig.machineType = "q35"
+	if sv.cpuHotplugSupported() {
+		assert.Equal("cpus=1,maxcpus=4", sv.smpParam())
+	} else {
+		assert.Equal("1", sv.smpParam())
+	}
+}
+
+func TestStratovirtResizeVCPUsUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		ctx: context.Background(),
+		svConfig: StratovirtConfig{
+			machineType: machineTypeMicrovm,
+			smp:         2,
+		},
+	}
+
+	current, updated, err := sv.ResizeVCPUs(sv.ctx, 4)
+	assert.NoError(err)
+	assert.Equal(uint32(2), current)
+	assert.Equal(uint32(2), updated)
+}
+
+func TestStratovirtMemoryBalloon(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		ctx: context.Background(),
+		config: HypervisorConfig{
+			MemorySize:           1024,
+			DefaultMaxMemorySize: 4096,
+		},
+	}
+
+	// Without the balloon the VM is not over-provisioned.
+	assert.Equal(uint32(1024), sv.bootMemoryMB())
+	assert.Equal(uint32(1024), sv.GetTotalMemoryMB(sv.ctx))
+
+	mem, _, err := sv.ResizeMemory(sv.ctx, 2048, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(1024), mem)
+
+	sv.config.EnableMemoryBalloon = true
+	assert.Equal(uint32(4096), sv.bootMemoryMB())
+
+	sv.appendBalloon(sv.ctx, nil)
+	assert.Equal(uint32(3072), sv.state.balloonMemory)
+	assert.Equal(uint32(1024), sv.GetTotalMemoryMB(sv.ctx))
+
+	// Requests below the default memory are clamped to it, the balloon
+	// is left untouched.
+	mem, _, err = sv.ResizeMemory(sv.ctx, 512, 128, false)
+	assert.NoError(err)
+	assert.Equal(uint32(1024), mem)
+}
+
+func TestStratovirtTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		config: HypervisorConfig{
+			BootFromTemplate: true,
+			MemoryPath:       "/run/vc/vm/template/memory",
+			DevicesStatePath: "/run/vc/vm/template/state",
+		},
+		svConfig: StratovirtConfig{
+			machineType: machineTypeMicrovm,
+			smp:         1,
+		},
+	}
+	assert.Equal("/run/vc/vm/template", sv.templatePath())
+
+	var params []string
+	sv.createParams(&params)
+	assert.Equal([]string{"-incoming", "file:/run/vc/vm/template"}, params[len(params)-2:])
+}
+
+func TestStratovirtHotplugVFIOMicrovm(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		ctx: context.Background(),
+		svConfig: StratovirtConfig{
+			machineType: machineTypeMicrovm,
+		},
+	}
+
+	_, err := sv.HotplugAddDevice(sv.ctx, &config.VFIODev{ID: "vfio0"}, VfioDev)
+	assert.Error(err)
+}
+
+func TestStratovirtSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	sv := &stratovirt{
+		state: State{
+			pid:             1234,
+			virtiofsPid:     5678,
+			balloonMemory:   512,
+			hotpluggedVCPUs: []hv.CPUDevice{{ID: "cpu-1"}},
+		},
+	}
+
+	hs := sv.Save()
+	assert.Equal(string(StratovirtHypervisor), hs.Type)
+
+	loaded := &stratovirt{}
+	loaded.Load(hs)
+	assert.Equal(sv.state, loaded.state)
+}
+
+func TestStratovirtGrpc(t *testing.T) {
+	assert := assert.New(t)
+
+	sConfig, err := newStratovirtConfig()
+	assert.NoError(err)
+	sConfig.VMStorePath = t.TempDir()
+	sConfig.RunStorePath = t.TempDir()
+
+	sv := &stratovirt{
+		ctx:    context.Background(),
+		id:     "testSandbox",
+		config: sConfig,
+		qmpMonitorCh: qmpChannel{
+			path: "/run/vc/vm/testSandbox/qmp.socket",
+		},
+		svConfig: StratovirtConfig{
+			machineType: machineTypeMicrovm,
+			vmPath:      "/run/vc/vm/testSandbox",
+			smp:         1,
+		},
+		state: State{
+			mmioBlkSlots:  [maxMmioBlkCount]bool{true},
+			mmioNetSlots:  [maxMmioNetCount]string{"virtio-tap0"},
+			pid:           1234,
+			balloonMemory: 256,
+		},
+	}
+
+	j, err := sv.toGrpc(sv.ctx)
+	assert.NoError(err)
+
+	sv2 := &stratovirt{}
+	err = sv2.fromGrpc(context.Background(), &sConfig, j)
+	assert.NoError(err)
+
+	assert.Equal(sv.id, sv2.id)
+	assert.Equal(sv.qmpMonitorCh.path, sv2.qmpMonitorCh.path)
+	assert.Equal(sv.svConfig.machineType, sv2.svConfig.machineType)
+	assert.Equal(sv.svConfig.smp, sv2.svConfig.smp)
+	assert.Equal(sv.state, sv2.state)
+}
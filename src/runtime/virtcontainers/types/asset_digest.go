@@ -0,0 +1,96 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Supported asset digest algorithms.
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// ParseAssetDigest validates a digest of the form "<algorithm>:<hex>" and
+// returns its algorithm and normalised (lower case) value.
+func ParseAssetDigest(digest string) (algorithm string, value string, err error) {
+	algorithm, value, found := strings.Cut(digest, ":")
+	if !found {
+		return "", "", fmt.Errorf("invalid asset digest %q: expected <algorithm>:<hex>", digest)
+	}
+
+	var size int
+	switch algorithm {
+	case SHA256:
+		size = sha256.Size
+	case SHA512:
+		size = sha512.Size
+	default:
+		return "", "", fmt.Errorf("invalid asset digest %q: unsupported algorithm %q", digest, algorithm)
+	}
+
+	value = strings.ToLower(value)
+
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != size {
+		return "", "", fmt.Errorf("invalid asset digest %q: expected %d hex encoded bytes", digest, size)
+	}
+
+	return algorithm, value, nil
+}
+
+func newAssetHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported asset digest algorithm %q", algorithm)
+}
+
+// ComputeFileDigest returns the digest of the file at path in the form
+// "<algorithm>:<hex>". The file is streamed so that large guest images do
+// not need to be read into memory.
+func ComputeFileDigest(path, algorithm string) (string, error) {
+	h, err := newAssetHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ComputeDigest returns the digest of data in the form "<algorithm>:<hex>".
+func ComputeDigest(data []byte, algorithm string) (string, error) {
+	h, err := newAssetHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	h.Write(data)
+
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
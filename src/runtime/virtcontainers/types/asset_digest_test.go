@@ -0,0 +1,75 @@
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAssetDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	sha256Value := strings.Repeat("ab", 32)
+
+	algorithm, value, err := ParseAssetDigest(SHA512 + ":" + strings.ToUpper(assetContentHash))
+	assert.NoError(err)
+	assert.Equal(SHA512, algorithm)
+	assert.Equal(assetContentHash, value)
+
+	algorithm, value, err = ParseAssetDigest(SHA256 + ":" + sha256Value)
+	assert.NoError(err)
+	assert.Equal(SHA256, algorithm)
+	assert.Equal(sha256Value, value)
+
+	for _, digest := range []string{
+		"",
+		assetContentHash,
+		"md5:" + sha256Value,
+		SHA256 + ":" + assetContentHash,
+		SHA512 + ":" + sha256Value,
+		SHA256 + ":" + strings.Repeat("zz", 32),
+	} {
+		_, _, err := ParseAssetDigest(digest)
+		assert.Error(err, "digest %q", digest)
+	}
+}
+
+func TestComputeFileDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "asset")
+	assert.NoError(os.WriteFile(path, assetContent, 0600))
+
+	digest, err := ComputeFileDigest(path, SHA512)
+	assert.NoError(err)
+	assert.Equal(SHA512+":"+assetContentHash, digest)
+
+	digest, err = ComputeFileDigest(path, SHA256)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(digest, SHA256+":"))
+
+	_, err = ComputeFileDigest(path, "shafoo")
+	assert.Error(err)
+
+	_, err = ComputeFileDigest(path+"-missing", SHA512)
+	assert.Error(err)
+}
+
+func TestComputeDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	digest, err := ComputeDigest(assetContent, SHA512)
+	assert.NoError(err)
+	assert.Equal(SHA512+":"+assetContentHash, digest)
+
+	_, err = ComputeDigest(assetContent, "shafoo")
+	assert.Error(err)
+}
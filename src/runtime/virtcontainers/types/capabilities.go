@@ -11,6 +11,7 @@ const (
 	multiQueueSupport
 	fsSharingSupported
 	networkDeviceHotplugSupport
+	memoryShrinkSupport
 )
 
 // Capabilities describe a virtcontainers hypervisor capabilities
@@ -68,3 +69,14 @@ func (caps *Capabilities) IsNetworkDeviceHotplugSupported() bool {
 func (caps *Capabilities) SetNetworkDeviceHotplugSupported() {
 	caps.flags |= networkDeviceHotplugSupport
 }
+
+// IsMemoryShrinkSupported tells if an hypervisor can return guest memory
+// to the host.
+func (caps *Capabilities) IsMemoryShrinkSupported() bool {
+	return caps.flags&memoryShrinkSupport != 0
+}
+
+// SetMemoryShrinkSupport sets the guest memory shrinking capability to true.
+func (caps *Capabilities) SetMemoryShrinkSupport() {
+	caps.flags |= memoryShrinkSupport
+}
@@ -43,3 +43,11 @@ func TestMultiQueueCapability(t *testing.T) {
 	caps.SetMultiQueueSupport()
 	assert.True(caps.IsMultiQueueSupported())
 }
+
+func TestMemoryShrinkCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsMemoryShrinkSupported())
+	caps.SetMemoryShrinkSupport()
+	assert.True(t, caps.IsMemoryShrinkSupported())
+}
@@ -22,6 +22,7 @@ type DanDeviceType string
 
 const (
 	VfioDanDeviceType DanDeviceType = "vfio"
+	VdpaDanDeviceType DanDeviceType = "vhost-vdpa"
 )
 
 type Device struct {
@@ -23,4 +23,8 @@ type FcConfig struct {
 	Drives []*models.Drive `json:"drives,omitempty"`
 
 	NetworkInterfaces []*models.NetworkInterface `json:"network-interfaces,omitempty"`
+
+	Balloon *models.Balloon `json:"balloon,omitempty"`
+
+	MmdsConfig *models.MmdsConfig `json:"mmds-config,omitempty"`
 }
@@ -826,3 +826,52 @@ func DistributeVCPUsProportionally(numaNodes []types.GuestNUMANode, totalVCPUs u
 
 	return vcpusPerNode, nil
 }
+
+// DistributeMemoryProportionally distributes memMB across NUMA nodes
+// proportionally to the vCPUs of each node, as returned by
+// DistributeVCPUsProportionally, so that memory follows the vCPUs pinned
+// to each host NUMA node. The memory of each node is a multiple of alignMB,
+// each node gets at least alignMB and the remainder goes to the last node.
+func DistributeMemoryProportionally(vcpusPerNode []uint32, memMB uint64, alignMB uint64) ([]uint64, error) {
+	numNodes := len(vcpusPerNode)
+	if numNodes == 0 {
+		return nil, fmt.Errorf("no NUMA nodes")
+	}
+	if alignMB == 0 {
+		alignMB = 1
+	}
+
+	var totalVCPUs uint64
+	for _, n := range vcpusPerNode {
+		totalVCPUs += uint64(n)
+	}
+	if totalVCPUs == 0 {
+		return nil, fmt.Errorf("total vCPU count is 0")
+	}
+
+	memPerNode := make([]uint64, numNodes)
+	var memAssigned uint64
+	for i, n := range vcpusPerNode {
+		raw := memMB * uint64(n) / totalVCPUs
+		memPerNode[i] = (raw / alignMB) * alignMB
+		if memPerNode[i] == 0 {
+			memPerNode[i] = alignMB
+		}
+		memAssigned += memPerNode[i]
+	}
+
+	// Give the remainder to the last node (must also be aligned).
+	if memAssigned < memMB {
+		remainder := memMB - memAssigned
+		if remainder%alignMB != 0 {
+			return nil, fmt.Errorf("%d MiB of memory cannot be evenly distributed across %d NUMA nodes with %d MiB alignment",
+				memMB, numNodes, alignMB)
+		}
+		memPerNode[numNodes-1] += remainder
+	} else if memAssigned > memMB {
+		return nil, fmt.Errorf("%d MiB of memory cannot be evenly distributed across %d NUMA nodes with %d MiB alignment",
+			memMB, numNodes, alignMB)
+	}
+
+	return memPerNode, nil
+}
@@ -12,6 +12,7 @@ import (
 	"io"
 	"math/big"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,6 +26,10 @@ import (
 	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/pkg/cpuset"
 )
 
+// sysNodePath is the sysfs directory describing the host NUMA topology, it is
+// a variable so that the tests can use a fake topology.
+var sysNodePath = "/sys/devices/system/node"
+
 var nodeMemTotalRegexp = regexp.MustCompile(`Node\s+\d+\s+MemTotal:\s+(\d+)\s+kB`)
 
 var ioctlFunc = Ioctl
@@ -205,7 +210,7 @@ func waitForProcessCompletion(pid int, timeoutSecs uint, logger *logrus.Entry) b
 }
 
 func getHostNUMANodes() ([]int, error) {
-	data, err := os.ReadFile("/sys/devices/system/node/online")
+	data, err := os.ReadFile(filepath.Join(sysNodePath, "online"))
 	if err != nil {
 		return nil, err
 	}
@@ -217,7 +222,7 @@ func getHostNUMANodes() ([]int, error) {
 }
 
 func getHostNUMANodeCPUs(nodeId int) (string, error) {
-	fileName := fmt.Sprintf("/sys/devices/system/node/node%v/cpulist", nodeId)
+	fileName := filepath.Join(sysNodePath, fmt.Sprintf("node%d", nodeId), "cpulist")
 	data, err := os.ReadFile(fileName)
 	if err != nil {
 		return "", err
@@ -228,7 +233,7 @@ func getHostNUMANodeCPUs(nodeId int) (string, error) {
 // getHostNUMANodeMemoryMB returns the total memory in MiB for the given
 // host NUMA node, parsed from /sys/devices/system/node/nodeN/meminfo.
 func getHostNUMANodeMemoryMB(nodeId int) (uint64, error) {
-	fileName := fmt.Sprintf("/sys/devices/system/node/node%d/meminfo", nodeId)
+	fileName := filepath.Join(sysNodePath, fmt.Sprintf("node%d", nodeId), "meminfo")
 	data, err := os.ReadFile(fileName)
 	if err != nil {
 		return 0, err
@@ -255,7 +260,7 @@ func getHostNUMADistance(hostNodes string) string {
 	if len(ids) == 0 {
 		return ""
 	}
-	fileName := fmt.Sprintf("/sys/devices/system/node/node%d/distance", ids[0])
+	fileName := filepath.Join(sysNodePath, fmt.Sprintf("node%d", ids[0]), "distance")
 	data, err := os.ReadFile(fileName)
 	if err != nil {
 		return ""
@@ -8,13 +8,49 @@ package utils
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 )
 
+// fakeNUMANode describes a host NUMA node of a fake sysfs topology.
+type fakeNUMANode struct {
+	cpus     string
+	memMB    uint64
+	distance string
+}
+
+// setupFakeNUMATopology points the NUMA helpers to a fake sysfs topology made
+// of nodes, node i being the i-th node of the slice.
+func setupFakeNUMATopology(t *testing.T, nodes []fakeNUMANode) {
+	dir := t.TempDir()
+
+	online := "0"
+	if len(nodes) > 1 {
+		online = fmt.Sprintf("0-%d", len(nodes)-1)
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "online"), []byte(online+"\n"), 0644))
+
+	for i, n := range nodes {
+		nodeDir := filepath.Join(dir, fmt.Sprintf("node%d", i))
+		assert.NoError(t, os.MkdirAll(nodeDir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(nodeDir, "cpulist"), []byte(n.cpus+"\n"), 0644))
+		meminfo := fmt.Sprintf("Node %d MemTotal:       %d kB\nNode %d MemFree:        1024 kB\n", i, n.memMB*1024, i)
+		assert.NoError(t, os.WriteFile(filepath.Join(nodeDir, "meminfo"), []byte(meminfo), 0644))
+		assert.NoError(t, os.WriteFile(filepath.Join(nodeDir, "distance"), []byte(n.distance+"\n"), 0644))
+	}
+
+	orgSysNodePath := sysNodePath
+	sysNodePath = dir
+	t.Cleanup(func() { sysNodePath = orgSysNodePath })
+}
+
 func TestFindContextID(t *testing.T) {
 	assert := assert.New(t)
 
@@ -63,3 +99,44 @@ func TestGetDevicePathAndFsTypeOptionsSuccessful(t *testing.T) {
 	assert.Equal(fstype, fstypeOut)
 	assert.Equal(fsOptions, optsOut)
 }
+
+func TestGetGuestNUMANodesFakeTopology(t *testing.T) {
+	assert := assert.New(t)
+
+	setupFakeNUMATopology(t, []fakeNUMANode{
+		{cpus: "0-3", memMB: 4096, distance: "10 21"},
+		{cpus: "4-7", memMB: 2048, distance: "21 10"},
+	})
+
+	nodes, err := GetGuestNUMANodes(nil)
+	assert.NoError(err)
+	assert.Equal([]types.GuestNUMANode{
+		{HostNodes: "0", HostCPUs: "0-3"},
+		{HostNodes: "1", HostCPUs: "4-7"},
+	}, nodes)
+
+	nodes, err = GetGuestNUMANodes([]string{"0-1"})
+	assert.NoError(err)
+	assert.Equal([]types.GuestNUMANode{
+		{HostNodes: "0-1", HostCPUs: "0-3,4-7"},
+	}, nodes)
+
+	_, err = GetGuestNUMANodes([]string{"2"})
+	assert.Error(err)
+
+	capacities, err := GetHostNUMANodeCapacities([]int{0, 1})
+	assert.NoError(err)
+	assert.Equal([]HostNUMANodeCapacity{
+		{NodeID: 0, CPUs: 4, MemMB: 4096},
+		{NodeID: 1, CPUs: 4, MemMB: 2048},
+	}, capacities)
+
+	dists := GetHostNUMADistances([]types.GuestNUMANode{
+		{HostNodes: "0", HostCPUs: "0-3"},
+		{HostNodes: "1", HostCPUs: "4-7"},
+	})
+	assert.Equal([]NUMADistEntry{
+		{Src: 0, Dst: 1, Val: 21},
+		{Src: 1, Dst: 0, Val: 21},
+	}, dists)
+}
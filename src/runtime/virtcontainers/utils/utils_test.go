@@ -817,6 +817,30 @@ func TestDistributeVCPUsProportionallyTooFewVCPUs(t *testing.T) {
 	assert.Contains(err.Error(), "must be >= NUMA node count")
 }
 
+func TestDistributeMemoryProportionally(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, err := DistributeMemoryProportionally([]uint32{4, 4}, 4096, 1)
+	assert.NoError(err)
+	assert.Equal([]uint64{2048, 2048}, mem)
+
+	// The remainder goes to the last node
+	mem, err = DistributeMemoryProportionally([]uint32{6, 3, 1}, 1001, 1)
+	assert.NoError(err)
+	assert.Equal([]uint64{600, 300, 101}, mem)
+
+	mem, err = DistributeMemoryProportionally([]uint32{8, 2}, 2048, 128)
+	assert.NoError(err)
+	assert.Equal([]uint64{1536, 512}, mem)
+
+	_, err = DistributeMemoryProportionally([]uint32{1, 1}, 2049, 2)
+	assert.Error(err)
+	assert.Contains(err.Error(), "cannot be evenly distributed")
+
+	_, err = DistributeMemoryProportionally(nil, 2048, 1)
+	assert.Error(err)
+}
+
 func TestFilterNUMANodesByCPUSet(t *testing.T) {
 	assert := assert.New(t)
 
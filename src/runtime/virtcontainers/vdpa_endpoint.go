@@ -0,0 +1,285 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	persistapi "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/persist/api"
+	vcTypes "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/kata-containers/kata-containers/src/runtime/virtcontainers/utils"
+	"github.com/safchain/ethtool"
+)
+
+var vdpaTrace = getNetworkTrace(VdpaEndpointType)
+
+// VdpaEndpoint represents a vDPA network device given to the guest through
+// its vhost-vdpa character device. The guest network interface gets the MAC
+// address of the vDPA device, which must match HardAddr.
+type VdpaEndpoint struct {
+	// Path to the vhost-vdpa character device on the host system
+	VhostDevPath string
+	// MAC address of the interface
+	HardAddr           string
+	IfaceName          string
+	DevID              string
+	NumQueues          uint32
+	EndpointProperties NetworkInfo
+	EndpointType       EndpointType
+	PCIPath            vcTypes.PciPath
+	CCWDevice          *vcTypes.CcwDevice
+}
+
+// Properties returns the properties of the interface.
+func (endpoint *VdpaEndpoint) Properties() NetworkInfo {
+	return endpoint.EndpointProperties
+}
+
+// Name returns name of the interface.
+func (endpoint *VdpaEndpoint) Name() string {
+	return endpoint.IfaceName
+}
+
+// HardwareAddr returns the mac address of the vDPA network interface
+func (endpoint *VdpaEndpoint) HardwareAddr() string {
+	return endpoint.HardAddr
+}
+
+// Type indentifies the endpoint as a vDPA endpoint.
+func (endpoint *VdpaEndpoint) Type() EndpointType {
+	return endpoint.EndpointType
+}
+
+// SetProperties sets the properties of the endpoint.
+func (endpoint *VdpaEndpoint) SetProperties(properties NetworkInfo) {
+	endpoint.EndpointProperties = properties
+}
+
+// PciPath returns the PCI path of the endpoint.
+func (endpoint *VdpaEndpoint) PciPath() vcTypes.PciPath {
+	return endpoint.PCIPath
+}
+
+// SetPciPath sets the PCI path of the endpoint.
+func (endpoint *VdpaEndpoint) SetPciPath(pciPath vcTypes.PciPath) {
+	endpoint.PCIPath = pciPath
+}
+
+// CcwDevice returns the CCW device of the endpoint.
+func (endpoint *VdpaEndpoint) CcwDevice() *vcTypes.CcwDevice {
+	return endpoint.CCWDevice
+}
+
+// SetCcwDevice sets the CCW device of the endpoint.
+func (endpoint *VdpaEndpoint) SetCcwDevice(ccwDev vcTypes.CcwDevice) {
+	endpoint.CCWDevice = &ccwDev
+}
+
+// NetworkPair returns the network pair of the endpoint.
+func (endpoint *VdpaEndpoint) NetworkPair() *NetworkInterfacePair {
+	return nil
+}
+
+func (endpoint *VdpaEndpoint) deviceAttrs() (config.VhostVDPADeviceAttrs, error) {
+	if endpoint.DevID == "" {
+		// Generate a unique ID to be used for hypervisor commandline fields
+		randBytes, err := utils.GenerateRandomBytes(8)
+		if err != nil {
+			return config.VhostVDPADeviceAttrs{}, err
+		}
+		endpoint.DevID = hex.EncodeToString(randBytes)
+	}
+
+	return config.VhostVDPADeviceAttrs{
+		DevID:        endpoint.DevID,
+		VhostDevPath: endpoint.VhostDevPath,
+		MacAddress:   endpoint.HardAddr,
+		VirtioID:     config.VirtioNetID,
+		NumQueues:    endpoint.NumQueues,
+	}, nil
+}
+
+// Attach for vDPA endpoint adds the vhost-vdpa device to the hypervisor
+// configuration.
+func (endpoint *VdpaEndpoint) Attach(ctx context.Context, s *Sandbox) error {
+	span, ctx := vdpaTrace(ctx, "Attach", endpoint)
+	defer span.End()
+
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
+	}
+
+	return s.hypervisor.AddDevice(ctx, d, VhostVDPADev)
+}
+
+// Detach for vDPA endpoint, the vDPA device is left to the host as is.
+func (endpoint *VdpaEndpoint) Detach(ctx context.Context, netNsCreated bool, netNsPath string) error {
+	return nil
+}
+
+// HotAttach for vDPA endpoint hotplugs the vhost-vdpa device into the guest.
+func (endpoint *VdpaEndpoint) HotAttach(ctx context.Context, s *Sandbox) error {
+	span, ctx := vdpaTrace(ctx, "HotAttach", endpoint)
+	defer span.End()
+
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
+	}
+
+	if s.sandboxController != nil {
+		if err := s.sandboxController.AddDevice(endpoint.VhostDevPath); err != nil {
+			networkLogger().WithError(err).WithField("device", endpoint.VhostDevPath).
+				Warnf("Could not add device to the %s controller", s.sandboxController)
+		}
+	}
+
+	if _, err := s.hypervisor.HotplugAddDevice(ctx, &d, VhostVDPADev); err != nil {
+		networkLogger().WithError(err).Error("Error attach vdpa ep")
+		return err
+	}
+
+	endpoint.PCIPath = d.PCIPath
+
+	return nil
+}
+
+// HotDetach for vDPA endpoint hot unplugs the vhost-vdpa device from the guest.
+func (endpoint *VdpaEndpoint) HotDetach(ctx context.Context, s *Sandbox, netNsCreated bool, netNsPath string) error {
+	span, ctx := vdpaTrace(ctx, "HotDetach", endpoint)
+	defer span.End()
+
+	d, err := endpoint.deviceAttrs()
+	if err != nil {
+		return err
+	}
+	d.PCIPath = endpoint.PCIPath
+
+	if _, err := s.hypervisor.HotplugRemoveDevice(ctx, &d, VhostVDPADev); err != nil {
+		networkLogger().WithError(err).Error("Error detach vdpa ep")
+		return err
+	}
+
+	if s.sandboxController != nil {
+		if err := s.sandboxController.RemoveDevice(endpoint.VhostDevPath); err != nil {
+			networkLogger().WithError(err).WithField("device", endpoint.VhostDevPath).
+				Warnf("Could not remove device from the %s controller", s.sandboxController)
+		}
+	}
+
+	return nil
+}
+
+// createVdpaEndpoint creates a vDPA endpoint for the vhost-vdpa character
+// device at vhostDevPath, which must be a vDPA network device.
+func createVdpaEndpoint(ifaceName, hardAddr, vhostDevPath string) (*VdpaEndpoint, error) {
+	virtioID, numQueues, err := config.GetVhostVDPADeviceInfoFunc(vhostDevPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vhost-vdpa device %s: %v", vhostDevPath, err)
+	}
+
+	if virtioID != config.VirtioNetID {
+		return nil, fmt.Errorf("vhost-vdpa device %s is not a network device (virtio device ID %d)", vhostDevPath, virtioID)
+	}
+
+	return &VdpaEndpoint{
+		VhostDevPath: vhostDevPath,
+		HardAddr:     hardAddr,
+		IfaceName:    ifaceName,
+		NumQueues:    numQueues,
+		EndpointType: VdpaEndpointType,
+	}, nil
+}
+
+// findVdpaIfaceDevice checks if the PCI function behind a network interface
+// has a vDPA device bound to the vhost-vdpa driver, and if it does it returns
+// the path to its vhost-vdpa character device.
+// We use ethtool here to not rely on device sysfs inside the network namespace.
+func findVdpaIfaceDevice(ifaceName string) (string, error) {
+	ethHandle, err := ethtool.NewEthtool()
+	if err != nil {
+		return "", err
+	}
+	defer ethHandle.Close()
+
+	bdf, err := ethHandle.BusInfo(ifaceName)
+	if err != nil {
+		return "", nil
+	}
+
+	return vdpaDevicePath(bdf)
+}
+
+// vdpaDevicePath returns the vhost-vdpa character device of the vDPA device
+// created on top of the PCI function bdf, either directly or through an
+// auxiliary device like mlx5_core.sf.
+func vdpaDevicePath(bdf string) (string, error) {
+	for _, pattern := range []string{
+		filepath.Join(sysPCIDevicesPath, bdf, "vdpa*", "vhost-vdpa-*"),
+		filepath.Join(sysPCIDevicesPath, bdf, "*", "vdpa*", "vhost-vdpa-*"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", err
+		}
+
+		if len(matches) > 0 {
+			return filepath.Join("/dev", filepath.Base(matches[0])), nil
+		}
+	}
+
+	return "", nil
+}
+
+func (endpoint *VdpaEndpoint) save() persistapi.NetworkEndpoint {
+	return persistapi.NetworkEndpoint{
+		Type: string(endpoint.Type()),
+		Vdpa: &persistapi.VdpaEndpoint{
+			IfaceName:    endpoint.IfaceName,
+			HardAddr:     endpoint.HardAddr,
+			VhostDevPath: endpoint.VhostDevPath,
+			DevID:        endpoint.DevID,
+			PCIPath:      endpoint.PCIPath,
+		},
+	}
+}
+
+func (endpoint *VdpaEndpoint) load(s persistapi.NetworkEndpoint) {
+	endpoint.EndpointType = VdpaEndpointType
+
+	if s.Vdpa != nil {
+		endpoint.IfaceName = s.Vdpa.IfaceName
+		endpoint.HardAddr = s.Vdpa.HardAddr
+		endpoint.VhostDevPath = s.Vdpa.VhostDevPath
+		endpoint.DevID = s.Vdpa.DevID
+		endpoint.PCIPath = s.Vdpa.PCIPath
+	}
+}
+
+// unsupported
+func (endpoint *VdpaEndpoint) GetRxRateLimiter() bool {
+	return false
+}
+
+func (endpoint *VdpaEndpoint) SetRxRateLimiter() error {
+	return fmt.Errorf("rx rate limiter is unsupported for vdpa endpoint")
+}
+
+// unsupported
+func (endpoint *VdpaEndpoint) GetTxRateLimiter() bool {
+	return false
+}
+
+func (endpoint *VdpaEndpoint) SetTxRateLimiter() error {
+	return fmt.Errorf("tx rate limiter is unsupported for vdpa endpoint")
+}
@@ -0,0 +1,159 @@
+//go:build linux
+
+// Copyright (c) 2026 Kata Contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/kata-containers/src/runtime/pkg/device/config"
+	vcTypes "github.com/kata-containers/kata-containers/src/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockVdpaHypervisor struct {
+	mockHypervisor
+	added   []config.VhostVDPADeviceAttrs
+	removed []config.VhostVDPADeviceAttrs
+}
+
+func (m *mockVdpaHypervisor) AddDevice(ctx context.Context, devInfo interface{}, devType DeviceType) error {
+	m.added = append(m.added, devInfo.(config.VhostVDPADeviceAttrs))
+	return nil
+}
+
+func (m *mockVdpaHypervisor) HotplugAddDevice(ctx context.Context, devInfo interface{}, devType DeviceType) (interface{}, error) {
+	d := devInfo.(*config.VhostVDPADeviceAttrs)
+	d.PCIPath, _ = vcTypes.PciPathFromString("02/03")
+	m.added = append(m.added, *d)
+	return nil, nil
+}
+
+func (m *mockVdpaHypervisor) HotplugRemoveDevice(ctx context.Context, devInfo interface{}, devType DeviceType) (interface{}, error) {
+	m.removed = append(m.removed, *devInfo.(*config.VhostVDPADeviceAttrs))
+	return nil, nil
+}
+
+func TestCreateVdpaEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	savedFunc := config.GetVhostVDPADeviceInfoFunc
+	defer func() {
+		config.GetVhostVDPADeviceInfoFunc = savedFunc
+	}()
+
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return config.VirtioNetID, 3, nil
+	}
+	ep, err := createVdpaEndpoint("eth0", "02:00:ca:fe:00:04", "/dev/vhost-vdpa-0")
+	assert.NoError(err)
+	assert.Equal(&VdpaEndpoint{
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		HardAddr:     "02:00:ca:fe:00:04",
+		IfaceName:    "eth0",
+		NumQueues:    3,
+		EndpointType: VdpaEndpointType,
+	}, ep)
+
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return config.VirtioBlockID, 1, nil
+	}
+	_, err = createVdpaEndpoint("eth0", "02:00:ca:fe:00:04", "/dev/vhost-vdpa-0")
+	assert.Error(err)
+
+	config.GetVhostVDPADeviceInfoFunc = func(path string) (uint32, uint32, error) {
+		return 0, 0, os.ErrNotExist
+	}
+	_, err = createVdpaEndpoint("eth0", "02:00:ca:fe:00:04", "/dev/vhost-vdpa-0")
+	assert.Error(err)
+}
+
+func TestVdpaEndpointAttach(t *testing.T) {
+	assert := assert.New(t)
+	h := &mockVdpaHypervisor{}
+	s := &Sandbox{
+		hypervisor: h,
+	}
+
+	ep := &VdpaEndpoint{
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		HardAddr:     "02:00:ca:fe:00:04",
+		NumQueues:    2,
+		EndpointType: VdpaEndpointType,
+	}
+
+	assert.NoError(ep.Attach(context.Background(), s))
+	assert.NotEmpty(ep.DevID)
+	assert.Equal([]config.VhostVDPADeviceAttrs{{
+		DevID:        ep.DevID,
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		MacAddress:   "02:00:ca:fe:00:04",
+		VirtioID:     config.VirtioNetID,
+		NumQueues:    2,
+	}}, h.added)
+	assert.NoError(ep.Detach(context.Background(), true, ""))
+}
+
+func TestVdpaEndpointHotAttachDetach(t *testing.T) {
+	assert := assert.New(t)
+	h := &mockVdpaHypervisor{}
+	s := &Sandbox{
+		hypervisor: h,
+	}
+
+	ep := &VdpaEndpoint{
+		VhostDevPath: "/dev/vhost-vdpa-0",
+		HardAddr:     "02:00:ca:fe:00:04",
+		EndpointType: VdpaEndpointType,
+	}
+
+	assert.NoError(ep.HotAttach(context.Background(), s))
+	assert.Equal("02/03", ep.PciPath().String())
+	assert.Len(h.added, 1)
+
+	// The device is unplugged after a restart of the runtime
+	loaded := &VdpaEndpoint{}
+	loaded.load(ep.save())
+	assert.Equal(ep.DevID, loaded.DevID)
+
+	assert.NoError(loaded.HotDetach(context.Background(), s, true, ""))
+	assert.Len(h.removed, 1)
+	assert.Equal(ep.DevID, h.removed[0].DevID)
+	assert.Equal("/dev/vhost-vdpa-0", h.removed[0].VhostDevPath)
+	assert.Equal("02/03", h.removed[0].PCIPath.String())
+}
+
+func TestVdpaDevicePath(t *testing.T) {
+	assert := assert.New(t)
+
+	savedPath := sysPCIDevicesPath
+	defer func() {
+		sysPCIDevicesPath = savedPath
+	}()
+	sysPCIDevicesPath = t.TempDir()
+
+	// No vDPA device
+	assert.NoError(os.MkdirAll(filepath.Join(sysPCIDevicesPath, "0000:01:00.0", "net", "eth0"), DirMode))
+	path, err := vdpaDevicePath("0000:01:00.0")
+	assert.NoError(err)
+	assert.Empty(path)
+
+	// vDPA device on the PCI function
+	assert.NoError(os.MkdirAll(filepath.Join(sysPCIDevicesPath, "0000:01:00.1", "vdpa0", "vhost-vdpa-0"), DirMode))
+	path, err = vdpaDevicePath("0000:01:00.1")
+	assert.NoError(err)
+	assert.Equal("/dev/vhost-vdpa-0", path)
+
+	// vDPA device on an auxiliary device of the PCI function
+	assert.NoError(os.MkdirAll(filepath.Join(sysPCIDevicesPath, "0000:01:00.2", "mlx5_core.sf.2", "vdpa1", "vhost-vdpa-1"), DirMode))
+	path, err = vdpaDevicePath("0000:01:00.2")
+	assert.NoError(err)
+	assert.Equal("/dev/vhost-vdpa-1", path)
+}
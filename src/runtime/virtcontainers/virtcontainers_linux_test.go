@@ -6,10 +6,19 @@
 package virtcontainers
 
 import (
+	"errors"
 	"os"
+	"os/exec"
+	"strings"
 	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
+// userNSTestEnv is set when the test binary runs in a user namespace.
+const userNSTestEnv = "KATA_VC_TEST_USERNS"
+
 // cleanUp Removes any stale sandbox/container state that can affect
 // the next test to run.
 func cleanUp() {
@@ -19,3 +28,46 @@ func cleanUp() {
 
 	setup()
 }
+
+// runInUserNS runs the current test again as the root user of a new user
+// namespace, so that the rootless paths are tested without faking the
+// rootless detection. It returns true when called from that namespace, in
+// which case the caller runs the test body. When the host user is root,
+// a range of ids is mapped so that the VMM can run as another user.
+func runInUserNS(t *testing.T, cloneflags uintptr) bool {
+	if os.Getenv(userNSTestEnv) != "" {
+		return true
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.count=1")
+	cmd.Env = append(os.Environ(), userNSTestEnv+"=1", "XDG_RUNTIME_DIR="+t.TempDir())
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | cloneflags,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+	}
+	if os.Getuid() == 0 {
+		cmd.SysProcAttr.UidMappings[0].Size = 65536
+		cmd.SysProcAttr.GidMappings[0].Size = 65536
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = true
+	}
+
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Skipf("user namespaces are not available: %v", err)
+	}
+	assert.NoError(t, err, string(out))
+	return false
+}
+
+// canSetGroups states whether the user namespace allows setgroups(2),
+// which is needed to start a process as another user.
+func canSetGroups() bool {
+	data, err := os.ReadFile("/proc/self/setgroups")
+	return err == nil && strings.TrimSpace(string(data)) == "allow"
+}